@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/hex"
 	"flag"
 	"fmt"
@@ -18,6 +19,7 @@ var flags = flag.NewFlagSet("", flag.ExitOnError)
 var verbose = flags.Bool("v", false, "verbose output")
 
 func main() {
+	ctx := context.Background()
 	log.SetFormatter(&formatters.CliFormatter{})
 
 	flags.Usage = func() {
@@ -65,7 +67,7 @@ func main() {
 
 	wallet := &uphold.Wallet{Info: info, PrivKey: privateKey, PubKey: publicKey}
 
-	err = wallet.Register(name)
+	err = wallet.Register(ctx, name)
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/brave-intl/bat-go/datastore/grantserver"
+)
+
+var (
+	topic = flag.String("topic", "", "kafka topic of the events to replay")
+	from  = flag.String("from", "", "replay events created at or after this time, RFC3339")
+	to    = flag.String("to", "", "replay events created before this time, RFC3339, defaults to now")
+)
+
+func main() {
+	log.SetFlags(0)
+
+	flag.Usage = func() {
+		log.Printf("A helper for republishing a time range of events from the outbox.\n\n")
+		log.Printf("Usage:\n\n")
+		log.Printf("        %s -topic TOPIC -from TIME [-to TIME]\n\n", os.Args[0])
+		log.Printf("  Events are queued again in the outbox of the database at DATABASE_URL and are\n")
+		log.Printf("  published by the relay of the running server, consumers deduplicate them by id.\n")
+		log.Printf("  Published events are only retained for 30 days.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if len(*topic) == 0 || len(*from) == 0 {
+		log.Printf("ERROR: Must pass the topic and start of the time range to replay\n\n")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	start, err := time.Parse(time.RFC3339, *from)
+	if err != nil {
+		log.Fatalln("ERROR: Start of the time range must be RFC3339:", err)
+	}
+	end := time.Now()
+	if len(*to) > 0 {
+		end, err = time.Parse(time.RFC3339, *to)
+		if err != nil {
+			log.Fatalln("ERROR: End of the time range must be RFC3339:", err)
+		}
+	}
+	if !start.Before(end) {
+		log.Fatalln("ERROR: Start of the time range must be before the end")
+	}
+
+	pg, err := grantserver.NewPostgres("", false)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	replayed, err := pg.ReplayOutboxEvents(context.Background(), *topic, start, end)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	log.Printf("queued %d events of %s created between %s and %s for publication\n",
+		replayed, *topic, start.Format(time.RFC3339), end.Format(time.RFC3339))
+}
@@ -6,6 +6,8 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/asaskevich/govalidator"
@@ -17,6 +19,7 @@ import (
 	"github.com/brave-intl/bat-go/utils/clients/reputation"
 	"github.com/brave-intl/bat-go/utils/handlers"
 	srv "github.com/brave-intl/bat-go/utils/service"
+	"github.com/brave-intl/bat-go/webhook"
 	"github.com/getsentry/sentry-go"
 	"github.com/go-chi/chi"
 	chiware "github.com/go-chi/chi/middleware"
@@ -25,6 +28,9 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// shutdownTimeout is how long in flight requests and jobs are given to finish on shutdown
+const shutdownTimeout = 30 * time.Second
+
 var (
 	commit    string
 	version   string
@@ -137,6 +143,12 @@ func setupRouter(ctx context.Context, logger *zerolog.Logger) (context.Context,
 	r.Mount("/v1/grants", controllers.GrantsRouter(grantService))
 	r.Mount("/v1/promotions", promotion.Router(promotionService))
 	r.Mount("/v1/suggestions", promotion.SuggestionsRouter(promotionService))
+	r.Mount("/v1/admin/promotions", promotion.AdminRouter(promotionService))
+	r.Mount("/v1/admin/jobs", promotion.JobsAdminRouter(promotionService))
+	r.Mount("/v1/admin/hot-wallets", promotion.HotWalletAdminRouter(promotionService))
+
+	// services notified of transaction status changes by the wallet provider
+	transactionUpdaters := []webhook.TransactionUpdater{promotionService}
 
 	if os.Getenv("FEATURE_ORDERS") != "" {
 		paymentPG, err := payment.NewPostgres("", true, "payment_db")
@@ -157,6 +169,28 @@ func setupRouter(ctx context.Context, logger *zerolog.Logger) (context.Context,
 
 		r.Mount("/v1/orders", payment.Router(paymentService))
 		r.Mount("/v1/votes", payment.VoteRouter(paymentService))
+		r.Mount("/v1/admin/orders", payment.OrdersAdminRouter(paymentService))
+		r.Mount("/v1/admin/merchants", payment.MerchantsAdminRouter(paymentService))
+		r.Mount("/v1/merchants", payment.MerchantRouter(paymentService))
+
+		transactionUpdaters = append(transactionUpdaters, paymentService)
+	}
+
+	if os.Getenv("WEBHOOK_PUBLIC_KEYS") != "" {
+		webhookPG, err := webhook.NewPostgres("", true, "webhook_db")
+		if err != nil {
+			sentry.CaptureException(err)
+			sentry.Flush(time.Second * 2)
+			log.Panic().Err(err).Msg("Must be able to init postgres connection to start")
+		}
+		webhookService, err := webhook.InitService(webhookPG, transactionUpdaters...)
+		if err != nil {
+			sentry.CaptureException(err)
+			sentry.Flush(time.Second * 2)
+			log.Panic().Err(err).Msg("Webhook service initialization failed")
+		}
+
+		r.Mount("/v1/webhooks", webhook.Router(webhookService))
 	}
 	r.Get("/metrics", middleware.Metrics())
 
@@ -180,18 +214,6 @@ func setupRouter(ctx context.Context, logger *zerolog.Logger) (context.Context,
 	return ctx, r, promotionService, jobs
 }
 
-func jobWorker(ctx context.Context, job func(context.Context) (bool, error), duration time.Duration) {
-	for {
-		_, err := job(ctx)
-		if err != nil {
-			sentry.CaptureException(err)
-			sentry.Flush(time.Second * 2)
-		}
-		// regardless if attempted or not, wait for the duration until retrying
-		<-time.After(duration)
-	}
-}
-
 func main() {
 	serverCtx, logger := setupLogger(context.Background())
 	// setup sentry
@@ -213,19 +235,40 @@ func main() {
 	serverCtx, cancel := context.WithCancel(serverCtx)
 	defer cancel()
 
-	for _, job := range jobs {
-		// iterate over jobs
-		for i := 0; i < job.Workers; i++ {
-			// spin up a job worker for each worker
-			go jobWorker(serverCtx, job.Func, job.Cadence)
-		}
+	runner := srv.NewRunner()
+	runner.Register(jobs...)
+	if err := runner.Listen(os.Getenv("DATABASE_URL")); err != nil {
+		// jobs still run on their cadence without notifications
+		sentry.CaptureException(err)
+		logger.Error().Err(err).Msg("unable to listen for job notifications")
 	}
+	runner.Start(serverCtx)
 
-	srv := http.Server{Addr: ":3333", Handler: chi.ServerBaseContext(serverCtx, r)}
-	err := srv.ListenAndServe()
-	if err != nil {
+	server := http.Server{Addr: ":3333", Handler: chi.ServerBaseContext(serverCtx, r)}
+	go func() {
+		err := server.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			sentry.CaptureException(err)
+			sentry.Flush(time.Second * 2)
+			logger.Panic().Err(err).Msg("HTTP server start failed!")
+		}
+	}()
+
+	// drain in flight requests and jobs before exiting
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	<-stop
+	logger.Info().Msg("shutting down")
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer shutdownCancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error().Err(err).Msg("HTTP server shutdown failed")
+	}
+	if err := runner.Shutdown(shutdownCtx); err != nil {
 		sentry.CaptureException(err)
-		sentry.Flush(time.Second * 2)
-		logger.Panic().Err(err).Msg("HTTP server start failed!")
+		logger.Error().Err(err).Msg("jobs did not finish before the shutdown deadline")
 	}
+	sentry.Flush(time.Second * 2)
 }
@@ -304,7 +304,7 @@ func generateWallet(t *testing.T) *uphold.Wallet {
 	}
 	info.PublicKey = hex.EncodeToString(publicKey)
 	newWallet := &uphold.Wallet{Info: info, PrivKey: privateKey, PubKey: publicKey}
-	err = newWallet.Register("bat-go test card")
+	err = newWallet.Register(context.Background(), "bat-go test card")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -619,7 +619,7 @@ func TestDrain(t *testing.T) {
 		t.Fatal("Expected zero active grants")
 	}
 
-	_, err = userWallet.Transfer(altcurrency.BAT, expectedBAT, uphold.SettlementDestination)
+	_, err = userWallet.Transfer(context.Background(), altcurrency.BAT, expectedBAT, uphold.SettlementDestination)
 	if err != nil {
 		t.Log(err)
 	}
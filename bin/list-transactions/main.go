@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/csv"
 	"flag"
 	"fmt"
@@ -27,6 +28,7 @@ var walletProvider = flag.String("provider", "uphold", "provider for the source
 var signed = flag.Bool("signed", false, "signed value depending on transaction direction")
 
 func main() {
+	ctx := context.Background()
 	log.SetFormatter(&formatters.CliFormatter{})
 
 	flag.Usage = func() {
@@ -67,7 +69,7 @@ func main() {
 		log.Fatalln(err)
 	}
 
-	txns, err := w.ListTransactions(*limit, startDate)
+	txns, err := w.ListTransactions(ctx, *limit, startDate)
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -22,6 +23,7 @@ var (
 )
 
 func main() {
+	ctx := context.Background()
 	log.SetFormatter(&formatters.CliFormatter{})
 
 	flag.Usage = func() {
@@ -85,7 +87,7 @@ func main() {
 	for i := 0; i < len(settlementState.Transactions); i++ {
 		settlementTransaction := &settlementState.Transactions[i]
 
-		err = settlement.SubmitPreparedTransaction(settlementWallet, settlementTransaction)
+		err = settlement.SubmitPreparedTransaction(ctx, settlementWallet, settlementTransaction)
 		if err != nil {
 			log.Fatalln(err)
 		}
@@ -106,7 +108,7 @@ func main() {
 			log.Fatalln(err)
 		}
 
-		err = settlement.ConfirmPreparedTransaction(settlementWallet, settlementTransaction)
+		err = settlement.ConfirmPreparedTransaction(ctx, settlementWallet, settlementTransaction)
 		if err != nil {
 			log.Fatalln(err)
 		}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/brave-intl/bat-go/payment"
+	"github.com/brave-intl/bat-go/utils/clients"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	serverURL   = flag.String("url", "http://localhost:3333", "base url of the grant server")
+	merchantID  = flag.String("merchant", "", "id of the merchant whose catalog is managed")
+	sku         = flag.String("sku", "", "code of the SKU to create")
+	location    = flag.String("location", "", "location the SKU token is issued for, defaults to the first allowed for the merchant")
+	price       = flag.String("price", "", "price of the SKU to create")
+	currency    = flag.String("currency", "BAT", "currency of the SKU to create")
+	description = flag.String("description", "", "description of the SKU to create")
+	expiresAt   = flag.String("expires-at", "", "time after which orders are no longer created for the SKU [RFC 3339]")
+	inactive    = flag.Bool("inactive", false, "include revoked SKUs when listing")
+)
+
+func main() {
+	log.SetFlags(0)
+
+	flag.Usage = func() {
+		log.Printf("A helper for managing the SKU catalog of a merchant and minting SKU tokens.\n\n")
+		log.Printf("Usage:\n\n")
+		log.Printf("        %s -merchant MERCHANT_ID -sku SKU -price PRICE create\n", os.Args[0])
+		log.Printf("        %s -merchant MERCHANT_ID list\n", os.Args[0])
+		log.Printf("        %s -merchant MERCHANT_ID revoke SKU_ID\n\n", os.Args[0])
+		log.Printf("  The admin bearer token is read from the environment, TOKEN.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(*merchantID) == 0 || len(args) == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	client, err := clients.New(*serverURL, os.Getenv("TOKEN"), "sku-catalog")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	ctx := context.Background()
+	path := fmt.Sprintf("/v1/admin/merchants/%s/skus", *merchantID)
+
+	switch args[0] {
+	case "create":
+		if len(*sku) == 0 || len(*price) == 0 {
+			log.Printf("ERROR: -sku and -price are required to create a SKU\n\n")
+			flag.Usage()
+			os.Exit(1)
+		}
+		req := payment.CreateSKURequest{
+			SKU:         *sku,
+			Location:    *location,
+			Currency:    *currency,
+			Description: *description,
+		}
+		req.Price, err = decimal.NewFromString(*price)
+		if err != nil {
+			log.Fatalln("ERROR: price must be a decimal")
+		}
+		if len(*expiresAt) > 0 {
+			expiry, err := time.Parse(time.RFC3339, *expiresAt)
+			if err != nil {
+				log.Fatalln("ERROR: expires-at must be an RFC 3339 datetime")
+			}
+			req.ExpiresAt = &expiry
+		}
+
+		var created payment.SKU
+		do(ctx, client, http.MethodPost, path, "", req, &created)
+		printJSON(created)
+	case "list":
+		query := ""
+		if *inactive {
+			query = "inactive=true"
+		}
+		var skus []payment.SKU
+		do(ctx, client, http.MethodGet, path, query, nil, &skus)
+		printJSON(skus)
+	case "revoke":
+		if len(args) != 2 {
+			flag.Usage()
+			os.Exit(1)
+		}
+		do(ctx, client, http.MethodDelete, path+"/"+args[1], "", nil, nil)
+		log.Printf("revoked %s\n", args[1])
+	default:
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// do the request against the grant server, exiting if it fails
+func do(ctx context.Context, client *clients.SimpleHTTPClient, method string, path string, query string, body interface{}, v interface{}) {
+	req, err := client.NewRequest(ctx, method, path, body)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	req.URL.RawQuery = query
+	_, err = client.Do(ctx, req, v)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func printJSON(v interface{}) {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println(string(out))
+}
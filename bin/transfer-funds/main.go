@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"flag"
 	"os"
 
@@ -28,6 +29,7 @@ var verbose = flag.Bool("v", false, "verbose output")
 var walletProvider = flag.String("provider", "uphold", "provider for the source wallet")
 
 func main() {
+	ctx := context.Background()
 	log.SetFormatter(&formatters.CliFormatter{})
 
 	flag.Usage = func() {
@@ -76,7 +78,7 @@ func main() {
 	var balance *wallet.Balance
 
 	if walletc == altc {
-		balance, err = w.GetBalance(true)
+		balance, err = w.GetBalance(ctx, true)
 		if err != nil {
 			log.Fatalln(err)
 		}
@@ -122,7 +124,7 @@ func main() {
 			log.Fatalln(err)
 		}
 		for {
-			submitInfo, err := w.SubmitTransaction(signedTx, *oneshot)
+			submitInfo, err := w.SubmitTransaction(ctx, signedTx, *oneshot)
 			if err != nil {
 				log.Fatalln(err)
 			}
@@ -144,12 +146,12 @@ func main() {
 				log.Fatalln("Exiting...")
 			}
 
-			_, err = w.ConfirmTransaction(submitInfo.ID)
+			_, err = w.ConfirmTransaction(ctx, submitInfo.ID)
 			if err != nil {
 				log.Printf("error confirming: %s\n", err)
 			}
 
-			upholdInfo, err := w.GetTransaction(submitInfo.ID)
+			upholdInfo, err := w.GetTransaction(ctx, submitInfo.ID)
 			if err != nil {
 				log.Fatalln(err)
 			}
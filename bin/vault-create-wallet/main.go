@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"flag"
@@ -29,6 +30,7 @@ type State struct {
 }
 
 func main() {
+	ctx := context.Background()
 	log.SetFormatter(&formatters.CliFormatter{})
 
 	flags.Usage = func() {
@@ -131,7 +133,7 @@ func main() {
 		}
 		wallet := uphold.Wallet{Info: state.WalletInfo, PrivKey: ed25519.PrivateKey{}, PubKey: publicKey}
 
-		err = wallet.SubmitRegistration(state.Registration)
+		err = wallet.SubmitRegistration(ctx, state.Registration)
 		if err != nil {
 			log.Fatalln(err)
 		}
@@ -140,7 +142,7 @@ func main() {
 		fmt.Printf("Uphold card ID %s\n", wallet.Info.ProviderID)
 		state.WalletInfo.ProviderID = wallet.Info.ProviderID
 
-		depositAddr, err := wallet.CreateCardAddress("ethereum")
+		depositAddr, err := wallet.CreateCardAddress(ctx, "ethereum")
 		if err != nil {
 			log.Fatalln(err)
 		}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
@@ -24,6 +25,7 @@ var value = flag.String("value", "", "amount to transfer [float or all]")
 var verbose = flag.Bool("v", false, "verbose output")
 
 func main() {
+	ctx := context.Background()
 	log.SetFormatter(&formatters.CliFormatter{})
 
 	flag.Usage = func() {
@@ -95,7 +97,7 @@ func main() {
 	var balance *wallet.Balance
 
 	if walletc == altc {
-		balance, err = w.GetBalance(true)
+		balance, err = w.GetBalance(ctx, true)
 		if err != nil {
 			log.Fatalln(err)
 		}
@@ -119,7 +121,7 @@ func main() {
 		log.Fatalln(err)
 	}
 	for {
-		submitInfo, err := w.SubmitTransaction(signedTx, *oneshot)
+		submitInfo, err := w.SubmitTransaction(ctx, signedTx, *oneshot)
 		if err != nil {
 			log.Fatalln(err)
 		}
@@ -141,12 +143,12 @@ func main() {
 			log.Fatalln("Exiting...")
 		}
 
-		_, err = w.ConfirmTransaction(submitInfo.ID)
+		_, err = w.ConfirmTransaction(ctx, submitInfo.ID)
 		if err != nil {
 			log.Printf("error confirming: %s\n", err)
 		}
 
-		upholdInfo, err := w.GetTransaction(submitInfo.ID)
+		upholdInfo, err := w.GetTransaction(ctx, submitInfo.ID)
 		if err != nil {
 			log.Fatalln(err)
 		}
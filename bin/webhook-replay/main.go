@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/brave-intl/bat-go/webhook"
+	"golang.org/x/crypto/ed25519"
+)
+
+var (
+	inputFile = flag.String("in", "./callbacks.json", "input file path of newline delimited recorded callbacks")
+	baseURL   = flag.String("url", "http://localhost:3333/v1/webhooks", "base url the callbacks are replayed against")
+	keyID     = flag.String("key-id", "", "keyID to re-sign callbacks with, recorded signatures are sent as is if empty")
+)
+
+var privateKeyHex = os.Getenv("ED25519_PRIVATE_KEY")
+
+func main() {
+	log.SetFlags(0)
+
+	flag.Usage = func() {
+		log.Printf("A helper for replaying recorded webhook callbacks against a local server.\n\n")
+		log.Printf("Usage:\n\n")
+		log.Printf("        %s\n\n", os.Args[0])
+		log.Printf("  When re-signing, hex key material is read from the environment, ED25519_PRIVATE_KEY.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var signer ed25519.PrivateKey
+	if len(*keyID) > 0 {
+		if len(privateKeyHex) == 0 {
+			log.Printf("ERROR: Environment variable ED25519_PRIVATE_KEY must be passed to re-sign callbacks\n\n")
+			flag.Usage()
+			os.Exit(1)
+		}
+		privKey, err := hex.DecodeString(privateKeyHex)
+		if err != nil {
+			log.Fatalln("ERROR: Key material must be passed as hex")
+		}
+		signer = ed25519.PrivateKey(privKey)
+	}
+
+	f, err := os.Open(*inputFile)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	callbacks, err := webhook.ReadCallbacks(f)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	var statuses []int
+	if signer != nil {
+		statuses, err = webhook.Replay(context.Background(), client, *baseURL, callbacks, *keyID, signer)
+	} else {
+		statuses, err = webhook.Replay(context.Background(), client, *baseURL, callbacks, "", nil)
+	}
+	for i, status := range statuses {
+		log.Printf("%s %s: %d\n", callbacks[i].Method, callbacks[i].Path, status)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
@@ -0,0 +1,154 @@
+package grantserver
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	srv "github.com/brave-intl/bat-go/utils/service"
+	"github.com/brave-intl/bat-go/wallet"
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// ClaimCredsQueue holds claim credentials awaiting signing
+	ClaimCredsQueue = "claim_creds"
+	// SuggestionDrainQueue holds suggestions awaiting redemption
+	SuggestionDrainQueue = "suggestion_drain"
+	// ClaimDrainQueue holds ads grants awaiting transfer to a verified wallet
+	ClaimDrainQueue = "claim_drain"
+	// VoteDrainQueue holds votes awaiting redemption
+	VoteDrainQueue = "vote_drain"
+	// DrainBatchQueue holds aggregated ads grants awaiting transfer to a payout address
+	DrainBatchQueue = "drain_batch"
+)
+
+// ErrUnknownQueue is returned when a job queue does not exist
+var ErrUnknownQueue = errors.New("unknown job queue")
+
+// jobQueue describes how jobs are keyed and requeued in a table
+type jobQueue struct {
+	key string
+	// requeue resets additional state of a dead-lettered job so it is retried from the start
+	requeue string
+	// requeueable restricts which dead-lettered jobs may be requeued
+	requeueable string
+}
+
+// requeueTransfer resets a transfer which failed, it will never complete and must be resubmitted with a new
+// idempotency key
+const requeueTransfer = `,
+	idempotency_key = case when transaction_status in ('` + wallet.TransactionFailed + `', '` + wallet.TransactionCancelled + `')
+		then uuid_generate_v4() else idempotency_key end,
+	transaction_id = case when transaction_status in ('` + wallet.TransactionFailed + `', '` + wallet.TransactionCancelled + `')
+		then null else transaction_id end,
+	transaction_status = case when transaction_status in ('` + wallet.TransactionFailed + `', '` + wallet.TransactionCancelled + `')
+		then null else transaction_status end`
+
+// requeueableDrainBatch only allows batches which still hold drains to be requeued, the drains of a batch whose
+// transfer failed are released to be paid out in another batch
+const requeueableDrainBatch = ` and exists (select 1 from claim_drain where claim_drain.batch_id = drain_batch.id)`
+
+var (
+	jobQueues = map[string]jobQueue{
+		ClaimCredsQueue:      {key: "claim_id"},
+		SuggestionDrainQueue: {key: "id"},
+		ClaimDrainQueue:      {key: "id", requeue: requeueTransfer},
+		VoteDrainQueue:       {key: "id"},
+		DrainBatchQueue:      {key: "id", requeue: requeueTransfer, requeueable: requeueableDrainBatch},
+	}
+
+	countJobFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "job_failures_total",
+		Help: "Count of failed job attempts, broken down by queue and whether the job will be retried or was dead-lettered.",
+	}, []string{"queue", "outcome"})
+)
+
+func init() {
+	prometheus.MustRegister(countJobFailures)
+}
+
+// DeadLetter is a job which failed terminally or exhausted its attempts
+type DeadLetter struct {
+	ID            uuid.UUID `json:"id" db:"id"`
+	Attempts      int       `json:"attempts" db:"attempts"`
+	LastError     *string   `json:"lastError" db:"last_error"`
+	NextAttemptAt time.Time `json:"nextAttemptAt" db:"next_attempt_at"`
+}
+
+// RecordJobFailure of an attempt of a job within the transaction which claimed it. The next attempt is delayed
+// with exponential backoff, a job is dead-lettered once the failure is terminal or its attempts are exhausted.
+// Returns true if the job was dead-lettered.
+func RecordJobFailure(tx *sqlx.Tx, queue string, id uuid.UUID, attempts int, cause error) (bool, error) {
+	q, ok := jobQueues[queue]
+	if !ok {
+		return false, ErrUnknownQueue
+	}
+
+	attempts++
+	deadLettered := srv.IsTerminal(cause) || attempts >= srv.MaxJobAttempts
+
+	statement := `
+	update ` + queue + `
+	set attempts = $2, next_attempt_at = $3, last_error = $4, erred = $5
+	where ` + q.key + ` = $1`
+	_, err := tx.Exec(statement, id, attempts, time.Now().Add(srv.Backoff(attempts)), cause.Error(), deadLettered)
+	if err != nil {
+		return false, err
+	}
+
+	outcome := "retry"
+	if deadLettered {
+		outcome = "dead_letter"
+	}
+	countJobFailures.With(prometheus.Labels{"queue": queue, "outcome": outcome}).Inc()
+
+	return deadLettered, nil
+}
+
+// GetDeadLetters returns the dead-lettered jobs of a queue, most recently failed first
+func (pg *Postgres) GetDeadLetters(ctx context.Context, queue string) ([]DeadLetter, error) {
+	q, ok := jobQueues[queue]
+	if !ok {
+		return nil, ErrUnknownQueue
+	}
+
+	statement := `
+	select ` + q.key + ` as id, attempts, last_error, next_attempt_at
+	from ` + queue + `
+	where erred
+	order by next_attempt_at desc`
+	deadLetters := []DeadLetter{}
+	err := pg.DB.SelectContext(ctx, &deadLetters, statement)
+	if err != nil {
+		return nil, err
+	}
+
+	return deadLetters, nil
+}
+
+// RequeueDeadLetter resets the attempts of a dead-lettered job so it is run again, returning false if the
+// job was not dead-lettered or may not be requeued
+func (pg *Postgres) RequeueDeadLetter(ctx context.Context, queue string, id uuid.UUID) (bool, error) {
+	q, ok := jobQueues[queue]
+	if !ok {
+		return false, ErrUnknownQueue
+	}
+
+	statement := `
+	update ` + queue + `
+	set erred = false, attempts = 0, next_attempt_at = current_timestamp, last_error = null` + q.requeue + `
+	where ` + q.key + ` = $1 and erred` + q.requeueable
+	result, err := pg.DB.ExecContext(ctx, statement, id)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
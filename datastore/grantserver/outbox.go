@@ -0,0 +1,194 @@
+package grantserver
+
+import (
+	"context"
+	"time"
+
+	kafkautils "github.com/brave-intl/bat-go/utils/kafka"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// OutboxChannel is notified when events are queued in the outbox
+	OutboxChannel = "event_outbox"
+	// OutboxRetention is how long published events are kept, and so can be replayed, before they are deleted
+	OutboxRetention = 30 * 24 * time.Hour
+	// outboxBatchSize is the maximum number of events published at once
+	outboxBatchSize = 100
+	// outboxRetentionBatchSize is the maximum number of published events deleted at once
+	outboxRetentionBatchSize = 1000
+)
+
+var (
+	countOutboxEventsPublished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "outbox_events_published_total",
+		Help: "Count of events published from the outbox, broken down by topic.",
+	}, []string{"topic"})
+	countOutboxPublishFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "outbox_publish_failures_total",
+		Help: "Count of failed attempts to publish a batch of events from the outbox, broken down by topic.",
+	}, []string{"topic"})
+)
+
+func init() {
+	prometheus.MustRegister(countOutboxEventsPublished, countOutboxPublishFailures)
+}
+
+// OutboxEvent is an event awaiting publication. It is written in the same transaction as the
+// change it describes and relayed in order of insertion, events with the same key go to the same partition.
+type OutboxEvent struct {
+	ID          uuid.UUID  `db:"id"`
+	Seq         int64      `db:"seq"`
+	CreatedAt   time.Time  `db:"created_at"`
+	Topic       string     `db:"topic"`
+	Key         string     `db:"key"`
+	Payload     []byte     `db:"payload"`
+	PublishedAt *time.Time `db:"published_at"`
+	Attempts    int        `db:"attempts"`
+	LastError   *string    `db:"last_error"`
+}
+
+// InsertOutboxEvent queues an event for publication within the transaction of the change it describes.
+// Events are deduplicated by id, inserting an event which is already queued has no effect.
+func InsertOutboxEvent(tx *sqlx.Tx, event OutboxEvent) error {
+	statement := `
+	insert into event_outbox (id, topic, key, payload)
+	values ($1, $2, $3, $4)
+	on conflict (id) do nothing`
+	_, err := tx.Exec(statement, event.ID, event.Topic, event.Key, event.Payload)
+	return err
+}
+
+// RunNextOutboxJob publishes the next batch of unpublished events of the topic, returning true if a batch
+// was attempted. Only one relay publishes a topic at a time so that events are written in order, delivery
+// is at least once as a batch which partially failed is published again in full.
+func (pg *Postgres) RunNextOutboxJob(ctx context.Context, topic string, publisher kafkautils.EventPublisher) (bool, error) {
+	tx, err := pg.DB.Beginx()
+	attempted := false
+	if err != nil {
+		return attempted, err
+	}
+	defer pg.RollbackTx(tx)
+
+	var locked bool
+	err = tx.Get(&locked, `select pg_try_advisory_xact_lock(hashtext('event_outbox'), hashtext($1))`, topic)
+	if err != nil {
+		return attempted, err
+	}
+	if !locked {
+		// another relay is publishing this topic
+		return attempted, nil
+	}
+
+	statement := `
+	select *
+	from event_outbox
+	where topic = $1 and published_at is null
+	order by seq
+	limit $2`
+	events := []OutboxEvent{}
+	err = tx.Select(&events, statement, topic, outboxBatchSize)
+	if err != nil {
+		return attempted, err
+	}
+	if len(events) == 0 {
+		return attempted, nil
+	}
+	attempted = true
+
+	ids := make([]string, len(events))
+	messages := make([]kafkautils.Event, len(events))
+	for i, event := range events {
+		ids[i] = event.ID.String()
+		messages[i] = kafkautils.Event{
+			ID:    ids[i],
+			Key:   []byte(event.Key),
+			Value: event.Payload,
+		}
+	}
+
+	if err := publisher.Publish(ctx, messages...); err != nil {
+		countOutboxPublishFailures.With(prometheus.Labels{"topic": topic}).Inc()
+		_, updateErr := tx.Exec(`
+		update event_outbox
+		set attempts = attempts + 1, last_error = $2
+		where id = any($1::uuid[])`, pq.Array(ids), err.Error())
+		if updateErr != nil {
+			return attempted, updateErr
+		}
+		if commitErr := tx.Commit(); commitErr != nil {
+			return attempted, commitErr
+		}
+		return attempted, err
+	}
+
+	_, err = tx.Exec(`
+	update event_outbox
+	set published_at = current_timestamp, last_error = null
+	where id = any($1::uuid[])`, pq.Array(ids))
+	if err != nil {
+		return attempted, err
+	}
+	if err := tx.Commit(); err != nil {
+		return attempted, err
+	}
+
+	countOutboxEventsPublished.With(prometheus.Labels{"topic": topic}).Add(float64(len(events)))
+	return attempted, nil
+}
+
+// ReplayOutboxEvents queues the events of the topic created within [from, to) for publication again,
+// returning the number of events queued. Consumers deduplicate the replayed events by their id.
+func (pg *Postgres) ReplayOutboxEvents(ctx context.Context, topic string, from, to time.Time) (int64, error) {
+	tx, err := pg.DB.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer pg.RollbackTx(tx)
+
+	statement := `
+	update event_outbox
+	set published_at = null, attempts = 0, last_error = null
+	where topic = $1 and created_at >= $2 and created_at < $3 and published_at is not null`
+	result, err := tx.ExecContext(ctx, statement, topic, from, to)
+	if err != nil {
+		return 0, err
+	}
+	replayed, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	// wake the relay
+	_, err = tx.ExecContext(ctx, `select pg_notify($1, '')`, OutboxChannel)
+	if err != nil {
+		return 0, err
+	}
+
+	return replayed, tx.Commit()
+}
+
+// DeletePublishedOutboxEvents deletes the next batch of events of the topic published before the passed time,
+// returning true if any were deleted. Events which have not been published are never deleted.
+func (pg *Postgres) DeletePublishedOutboxEvents(ctx context.Context, topic string, before time.Time) (bool, error) {
+	statement := `
+	delete from event_outbox
+	where id in (
+		select id
+		from event_outbox
+		where topic = $1 and published_at < $2
+		limit $3
+	)`
+	result, err := pg.DB.ExecContext(ctx, statement, topic, before, outboxRetentionBatchSize)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return deleted > 0, nil
+}
@@ -18,7 +18,7 @@ import (
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 )
 
-const currentMigrationVersion = 12
+const currentMigrationVersion = 35
 
 var (
 	// dbInstanceClassToMaxConn -  https://docs.aws.amazon.com/AmazonRDS/latest/AuroraUserGuide/AuroraPostgreSQL.Managing.html
@@ -132,6 +132,11 @@ func NewPostgres(databaseURL string, performMigration bool, dbStatsPrefix ...str
 		if err != nil {
 			return nil, err
 		}
+
+		err = pg.SyncWalletProviders()
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return pg, nil
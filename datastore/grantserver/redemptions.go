@@ -0,0 +1,74 @@
+package grantserver
+
+import (
+	"context"
+	"errors"
+
+	"github.com/brave-intl/bat-go/utils/clients/cbr"
+	uuid "github.com/satori/go.uuid"
+)
+
+// ErrCredentialsClaimed is returned when credentials are already being redeemed for another drain
+var ErrCredentialsClaimed = errors.New("credentials already claimed by another redemption")
+
+// ClaimCredentialRedemptions records that owner is redeeming the credentials. The claim is committed before
+// the credentials are redeemed with the challenge bypass server so it survives a failure to complete the drain.
+// It returns true if owner had already claimed the credentials, in which case a previous attempt may have
+// redeemed them, and ErrCredentialsClaimed if another owner claimed any of them.
+func (pg *Postgres) ClaimCredentialRedemptions(ctx context.Context, owner uuid.UUID, credentials []cbr.CredentialRedemption) (bool, error) {
+	tx, err := pg.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer pg.RollbackTx(tx)
+
+	claimed := false
+	inserted := map[cbr.CredentialRedemption]bool{}
+	for _, credential := range credentials {
+		key := cbr.CredentialRedemption{Issuer: credential.Issuer, TokenPreimage: credential.TokenPreimage}
+		if inserted[key] {
+			continue
+		}
+
+		result, err := tx.Exec(`
+		insert into credential_redemptions (issuer, token_preimage, owner_id)
+		values ($1, $2, $3)
+		on conflict (issuer, token_preimage) do nothing`, credential.Issuer, credential.TokenPreimage, owner)
+		if err != nil {
+			return false, err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return false, err
+		}
+		if rows == 1 {
+			inserted[key] = true
+			continue
+		}
+
+		var existing uuid.UUID
+		err = tx.Get(&existing, `
+		select owner_id from credential_redemptions
+		where issuer = $1 and token_preimage = $2`, credential.Issuer, credential.TokenPreimage)
+		if err != nil {
+			return false, err
+		}
+		if !uuid.Equal(existing, owner) {
+			return false, ErrCredentialsClaimed
+		}
+		claimed = true
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+	return claimed, nil
+}
+
+// ReleaseCredentialRedemptions removes the claims of owner, used when its credentials were found to have been
+// redeemed elsewhere so a requeued drain does not mistake that redemption for its own
+func (pg *Postgres) ReleaseCredentialRedemptions(ctx context.Context, owner uuid.UUID) error {
+	_, err := pg.DB.ExecContext(ctx, `delete from credential_redemptions where owner_id = $1`, owner)
+	return err
+}
@@ -3,6 +3,7 @@ package grantserver
 import (
 	"github.com/brave-intl/bat-go/utils/altcurrency"
 	"github.com/brave-intl/bat-go/wallet"
+	"github.com/brave-intl/bat-go/wallet/provider"
 	uuid "github.com/satori/go.uuid"
 )
 
@@ -41,3 +42,18 @@ func (pg *Postgres) GetWallet(ID uuid.UUID) (*wallet.Info, error) {
 
 	return nil, nil
 }
+
+// SyncWalletProviders ensures every registered wallet provider is allowed by the wallets table
+func (pg *Postgres) SyncWalletProviders() error {
+	statement := `
+	insert into wallet_providers (name)
+	values ($1)
+	on conflict (name) do nothing`
+	for _, name := range provider.Names() {
+		_, err := pg.DB.Exec(statement, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
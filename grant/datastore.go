@@ -135,6 +135,11 @@ func (pg *Postgres) ClaimPromotionForWallet(promo *promotion.Promotion, wallet *
 		return nil, fmt.Errorf("Incorrect number of claims updated / inserted: %d", len(claims))
 	}
 
+	err = promotion.ChargePromotionBudget(tx, promo.ID, claims[0].ApproximateValue)
+	if err != nil {
+		return nil, err
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		return nil, err
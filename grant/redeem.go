@@ -10,7 +10,6 @@ import (
 	errorutils "github.com/brave-intl/bat-go/utils/errors"
 	"github.com/brave-intl/bat-go/wallet"
 	"github.com/brave-intl/bat-go/wallet/provider"
-	"github.com/brave-intl/bat-go/wallet/provider/uphold"
 	"github.com/getsentry/sentry-go"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
@@ -71,16 +70,16 @@ func (service *Service) Consume(ctx context.Context, walletInfo wallet.Info, tra
 	}
 
 	// 2. Enforce transaction checks and verify transaction signature
+	if !provider.Supports(walletInfo.Provider, provider.AnonCardVerify) {
+		return nil, fmt.Errorf("wallet provider %s does not support anonymous card transactions", walletInfo.Provider)
+	}
 	providerWallet, err := provider.GetWallet(walletInfo)
 	if err != nil {
 		return nil, err
 	}
-	userWallet, ok := providerWallet.(*uphold.Wallet)
-	if !ok {
-		return nil, errors.New("only uphold wallets are supported")
-	}
+	userWallet := providerWallet.(wallet.AnonCardVerifier)
 	// this ensures we have a valid wallet if refreshBalance == true
-	balance, err := userWallet.GetBalance(refreshBalance)
+	balance, err := userWallet.GetBalance(ctx, refreshBalance)
 	if err != nil {
 		return nil, err
 	}
@@ -123,7 +122,7 @@ func (service *Service) Consume(ctx context.Context, walletInfo wallet.Info, tra
 	}
 
 	// should be reasonable since we limit the redeem endpoint to a maximum of 1 simultaneous in-flight request
-	ugpBalance, err := grantWallet.GetBalance(refreshBalance)
+	ugpBalance, err := grantWallet.GetBalance(ctx, refreshBalance)
 	if err != nil {
 		return nil, err
 	}
@@ -141,7 +140,7 @@ func (service *Service) Consume(ctx context.Context, walletInfo wallet.Info, tra
 		var submitInfo *wallet.TransactionInfo
 		// TODO remove this once we can retrieve publicKey info from uphold
 		// NOTE We check the signature on the included transaction by submitting it but not confirming it
-		submitInfo, err = userWallet.SubmitTransaction(transaction, false)
+		submitInfo, err = userWallet.SubmitTransaction(ctx, transaction, false)
 		if err != nil {
 			if wallet.IsInvalidSignature(err) {
 				return nil, errors.New("the included transaction was signed with the wrong publicKey")
@@ -209,7 +208,7 @@ func (service *Service) Redeem(ctx context.Context, req *RedeemGrantsRequest) (*
 	}
 
 	// fund user wallet with probi from grants
-	_, err = grantWallet.Transfer(*grantFulfillmentInfo.AltCurrency, grantFulfillmentInfo.Probi, grantFulfillmentInfo.Destination)
+	_, err = grantWallet.Transfer(ctx, *grantFulfillmentInfo.AltCurrency, grantFulfillmentInfo.Probi, grantFulfillmentInfo.Destination)
 	if err != nil {
 		log.Ctx(ctx).
 			Error().
@@ -228,7 +227,7 @@ func (service *Service) Redeem(ctx context.Context, req *RedeemGrantsRequest) (*
 		// NOTE Consume (by way of VerifyTransaction) guards against transactions that seek to exploit parser differences
 		// such as including additional fields that are not understood by this wallet provider implementation but may
 		// be understood by the upstream wallet provider.
-		settlementInfo, err = userWallet.ConfirmTransaction(submitID)
+		settlementInfo, err = userWallet.ConfirmTransaction(ctx, submitID)
 		if err == nil {
 			break
 		}
@@ -260,7 +259,7 @@ func (service *Service) Drain(ctx context.Context, req *DrainGrantsRequest) (*Dr
 	}
 
 	// drain probi from grants into user wallet
-	_, err = grantWallet.Transfer(*grantFulfillmentInfo.AltCurrency, grantFulfillmentInfo.Probi, req.AnonymousAddress.String())
+	_, err = grantWallet.Transfer(ctx, *grantFulfillmentInfo.AltCurrency, grantFulfillmentInfo.Probi, req.AnonymousAddress.String())
 	if err != nil {
 		log.Ctx(ctx).
 			Error().
@@ -1,16 +1,18 @@
 package grant
 
 import (
+	"context"
 	"encoding/hex"
 	"errors"
 	"os"
+	"time"
 
 	"github.com/brave-intl/bat-go/utils/altcurrency"
 	errorutils "github.com/brave-intl/bat-go/utils/errors"
 	"github.com/brave-intl/bat-go/utils/httpsignature"
 	srv "github.com/brave-intl/bat-go/utils/service"
 	"github.com/brave-intl/bat-go/wallet"
-	"github.com/brave-intl/bat-go/wallet/provider/uphold"
+	"github.com/brave-intl/bat-go/wallet/provider"
 	"github.com/getsentry/sentry-go"
 	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/crypto/ed25519"
@@ -28,7 +30,7 @@ var (
 	grantWalletPublicKeyHex      = os.Getenv("GRANT_WALLET_PUBLIC_KEY")
 	grantWalletPrivateKeyHex     = os.Getenv("GRANT_WALLET_PRIVATE_KEY")
 	grantWalletCardID            = os.Getenv("GRANT_WALLET_CARD_ID")
-	grantWallet                  *uphold.Wallet
+	grantWallet                  wallet.Wallet
 	refreshBalance               = true  // for testing we can disable balance refresh
 	testSubmit                   = true  // for testing we can disable testing tx submit
 	registerGrantInstrumentation = true  // for testing we can disable grant claim / redeem instrumentation registration
@@ -107,7 +109,7 @@ func InitService(datastore Datastore, roDatastore ReadOnlyDatastore) (*Service,
 			return nil, errorutils.Wrap(err, "grantWalletPrivateKeyHex is invalid")
 		}
 
-		grantWallet, err = uphold.New(info, privKey, pubKey)
+		grantWallet, err = provider.NewWallet(info, privKey, pubKey)
 		if err != nil {
 			return nil, err
 		}
@@ -144,7 +146,10 @@ func (s *Service) Describe(ch chan<- *prometheus.Desc) {
 // Collect returns the current state of all metrics of the collector.
 // We implement this and the Describe function to fulfill the prometheus.Collector interface
 func (s *Service) Collect(ch chan<- prometheus.Metric) {
-	balance, err := grantWallet.GetBalance(true)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	balance, err := grantWallet.GetBalance(ctx, true)
 	if err != nil {
 		sentry.CaptureException(err)
 		return
@@ -11,6 +11,17 @@ var (
 	latencyBuckets = []float64{.25, .5, 1, 2.5, 5, 10}
 )
 
+// registerOrExisting registers the collector, returning the existing collector if an equivalent one was already registered
+func registerOrExisting(c prometheus.Collector) prometheus.Collector {
+	if err := prometheus.Register(c); err != nil {
+		if aerr, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return aerr.ExistingCollector
+		}
+		panic(err)
+	}
+	return c
+}
+
 // InstrumentRoundTripper instruments an http.RoundTripper to capture metrics like the number
 // of active requests, the total number of requests made and latency information
 func InstrumentRoundTripper(roundTripper http.RoundTripper, service string) http.RoundTripper {
@@ -68,8 +79,12 @@ func InstrumentRoundTripper(roundTripper http.RoundTripper, service string) http
 		[]string{},
 	)
 
-	// Register all of the metrics in the standard registry.
-	prometheus.MustRegister(counter, tlsLatencyVec, dnsLatencyVec, histVec, inFlightGauge)
+	// Register all of the metrics in the standard registry, reusing those of an earlier client for the same service.
+	counter = registerOrExisting(counter).(*prometheus.CounterVec)
+	tlsLatencyVec = registerOrExisting(tlsLatencyVec).(*prometheus.HistogramVec)
+	dnsLatencyVec = registerOrExisting(dnsLatencyVec).(*prometheus.HistogramVec)
+	histVec = registerOrExisting(histVec).(*prometheus.HistogramVec)
+	inFlightGauge = registerOrExisting(inFlightGauge).(prometheus.Gauge)
 
 	// Define functions for the available httptrace.ClientTrace hook
 	// functions that we want to instrument.
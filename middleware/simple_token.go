@@ -2,7 +2,9 @@ package middleware
 
 import (
 	"context"
+	"crypto/sha256"
 	"crypto/subtle"
+	"encoding/hex"
 	"net/http"
 	"os"
 	"strings"
@@ -62,3 +64,21 @@ func SimpleTokenAuthorizedOnly(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// SimpleTokenIdentity returns a non secret identifier for the bearer token via context, suitable for audit records
+// NOTE the token is populated via BearerToken
+func SimpleTokenIdentity(ctx context.Context) string {
+	token, ok := ctx.Value(bearerTokenKey{}).(string)
+	if !ok || token == "" {
+		return "anonymous"
+	}
+	sum := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(sum[:4])
+}
+
+// BearerTokenFromContext returns the bearer token of the request via context, empty if there was none
+// NOTE the token is populated via BearerToken
+func BearerTokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(bearerTokenKey{}).(string)
+	return token
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"strings"
 	"testing"
 )
@@ -39,3 +40,18 @@ func Test_isSimpleTokenValid(t *testing.T) {
 		t.Error("Expected wrong tokens to be invalid")
 	}
 }
+
+func TestSimpleTokenIdentity(t *testing.T) {
+	if SimpleTokenIdentity(context.Background()) != "anonymous" {
+		t.Error("Expected requests without a token to be anonymous")
+	}
+
+	foo := SimpleTokenIdentity(context.WithValue(context.Background(), bearerTokenKey{}, "FOO"))
+	bar := SimpleTokenIdentity(context.WithValue(context.Background(), bearerTokenKey{}, "BAR"))
+	if foo == bar {
+		t.Error("Expected different tokens to have different identities")
+	}
+	if strings.Contains(foo, "FOO") {
+		t.Error("Expected identity not to contain the token")
+	}
+}
@@ -0,0 +1,4 @@
+alter table wallets drop constraint fk_wallet_provider;
+alter table wallets add constraint check_provider check (provider in ('uphold'));
+
+drop table wallet_providers;
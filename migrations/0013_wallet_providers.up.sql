@@ -0,0 +1,9 @@
+create table wallet_providers (
+  name text primary key not null,
+  created_at timestamp with time zone not null default current_timestamp
+);
+
+insert into wallet_providers (name) values ('uphold');
+
+alter table wallets drop constraint check_provider;
+alter table wallets add constraint fk_wallet_provider foreign key (provider) references wallet_providers(name);
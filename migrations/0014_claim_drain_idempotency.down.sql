@@ -0,0 +1,4 @@
+alter table claim_drain drop column completed;
+alter table claim_drain drop column redeemed;
+alter table claim_drain drop constraint claim_drain_idempotency_key_unique;
+alter table claim_drain drop column idempotency_key;
@@ -0,0 +1,6 @@
+alter table claim_drain add column idempotency_key uuid not null default uuid_generate_v4();
+alter table claim_drain add constraint claim_drain_idempotency_key_unique unique (idempotency_key);
+alter table claim_drain add column redeemed boolean not null default false;
+alter table claim_drain add column completed boolean not null default false;
+
+update claim_drain set redeemed = true, completed = true where transaction_id is not null;
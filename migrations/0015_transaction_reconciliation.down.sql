@@ -0,0 +1,5 @@
+alter table transactions drop column status_checked_at;
+
+alter table claim_drain drop column status_checked_at;
+alter table claim_drain drop column transaction_status;
+alter table claim_drain drop column created_at;
@@ -0,0 +1,7 @@
+alter table claim_drain add column created_at timestamp with time zone not null default current_timestamp;
+alter table claim_drain add column transaction_status text default null;
+alter table claim_drain add column status_checked_at timestamp with time zone default null;
+
+update claim_drain set transaction_status = 'completed' where completed;
+
+alter table transactions add column status_checked_at timestamp with time zone default null;
@@ -0,0 +1,9 @@
+create table webhook_events (
+  id text primary key not null,
+  type text not null,
+  transaction_id text default null,
+  payload json not null,
+  matched boolean not null default false,
+  created_at timestamp with time zone not null default current_timestamp,
+  processed_at timestamp with time zone default null
+);
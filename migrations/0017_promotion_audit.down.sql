@@ -0,0 +1 @@
+drop table promotion_audit;
@@ -0,0 +1,11 @@
+create table promotion_audit (
+  id uuid primary key not null default uuid_generate_v4(),
+  promotion_id uuid not null references promotions(id),
+  created_at timestamp with time zone not null default current_timestamp,
+  actor text not null,
+  action text not null,
+  previous json not null,
+  updated json not null
+);
+
+create index on promotion_audit(promotion_id);
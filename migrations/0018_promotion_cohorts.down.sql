@@ -0,0 +1,4 @@
+drop table cohort_redemptions;
+alter table suggestion_drain drop column funding;
+alter table claims drop column cohort;
+drop table promotion_cohorts;
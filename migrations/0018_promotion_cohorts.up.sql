@@ -0,0 +1,23 @@
+create table promotion_cohorts (
+  promotion_id uuid not null references promotions(id),
+  name text not null,
+  weight integer not null check (weight > 0),
+  value numeric(28, 18) check (value > 0.0),
+  primary key (promotion_id, name)
+);
+
+alter table claims add column cohort text not null default 'control';
+
+alter table suggestion_drain add column funding json default null;
+
+create table cohort_redemptions (
+  id uuid primary key not null default uuid_generate_v4(),
+  created_at timestamp with time zone not null default current_timestamp,
+  promotion_id uuid not null references promotions(id),
+  cohort text not null,
+  kind text not null,
+  credentials integer not null check (credentials > 0),
+  amount numeric(28, 18) not null
+);
+
+create index on cohort_redemptions(promotion_id);
@@ -0,0 +1,13 @@
+alter table order_cred_issuers drop constraint merchant_version_uniq;
+alter table order_cred_issuers drop column tokens_signed;
+alter table order_cred_issuers drop column max_tokens;
+alter table order_cred_issuers drop column expires_at;
+alter table order_cred_issuers drop column version;
+
+alter table issuers drop constraint promo_cohort_version_uniq;
+alter table issuers add constraint promo_cohort_uniq unique (promotion_id, cohort);
+alter table issuers drop column tokens_signed;
+alter table issuers drop column max_tokens;
+alter table issuers drop column expires_at;
+alter table issuers drop column created_at;
+alter table issuers drop column version;
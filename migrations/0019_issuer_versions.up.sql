@@ -0,0 +1,25 @@
+alter table issuers add column version integer not null default 1;
+alter table issuers add column created_at timestamp with time zone not null default current_timestamp;
+alter table issuers add column expires_at timestamp with time zone not null default current_timestamp + interval '1 year';
+alter table issuers add column max_tokens integer not null default 4000000;
+alter table issuers add column tokens_signed integer not null default 0;
+alter table issuers drop constraint promo_cohort_uniq;
+alter table issuers add constraint promo_cohort_version_uniq unique (promotion_id, cohort, version);
+
+update issuers set tokens_signed = coalesce((
+  select sum(json_array_length(signed_creds))
+  from claim_creds
+  where claim_creds.issuer_id = issuers.id and signed_creds is not null
+), 0);
+
+alter table order_cred_issuers add column version integer not null default 1;
+alter table order_cred_issuers add column expires_at timestamp with time zone not null default current_timestamp + interval '1 year';
+alter table order_cred_issuers add column max_tokens integer not null default 4000000;
+alter table order_cred_issuers add column tokens_signed integer not null default 0;
+alter table order_cred_issuers add constraint merchant_version_uniq unique (merchant_id, version);
+
+update order_cred_issuers set tokens_signed = coalesce((
+  select sum(json_array_length(signed_creds))
+  from order_creds
+  where order_creds.issuer_id = order_cred_issuers.id and signed_creds is not null
+), 0);
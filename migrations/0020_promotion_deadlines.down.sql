@@ -0,0 +1,5 @@
+drop table if exists promotion_expiry_reports;
+
+alter table promotions drop constraint redeemable_after_claimable;
+alter table promotions drop column redeemable_until;
+alter table promotions drop column claimable_until;
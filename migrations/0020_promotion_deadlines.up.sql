@@ -0,0 +1,19 @@
+alter table promotions add column claimable_until timestamp with time zone;
+alter table promotions add column redeemable_until timestamp with time zone;
+
+update promotions set claimable_until = expires_at, redeemable_until = expires_at + interval '3 months';
+
+alter table promotions alter column claimable_until set default current_timestamp + interval '4 months';
+alter table promotions alter column claimable_until set not null;
+alter table promotions alter column redeemable_until set default current_timestamp + interval '7 months';
+alter table promotions alter column redeemable_until set not null;
+alter table promotions add constraint redeemable_after_claimable check (redeemable_until >= claimable_until);
+
+create table promotion_expiry_reports (
+  promotion_id uuid primary key not null references promotions(id),
+  created_at timestamp with time zone not null default current_timestamp,
+  claims integer not null,
+  issued_value numeric(28, 18) not null,
+  redeemed_value numeric(28, 18) not null,
+  unredeemed_value numeric(28, 18) not null
+);
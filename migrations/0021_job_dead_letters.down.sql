@@ -0,0 +1,16 @@
+alter table vote_drain drop column last_error;
+alter table vote_drain drop column next_attempt_at;
+alter table vote_drain drop column attempts;
+
+alter table claim_drain drop column last_error;
+alter table claim_drain drop column next_attempt_at;
+alter table claim_drain drop column attempts;
+
+alter table suggestion_drain drop column last_error;
+alter table suggestion_drain drop column next_attempt_at;
+alter table suggestion_drain drop column attempts;
+
+alter table claim_creds drop column last_error;
+alter table claim_creds drop column next_attempt_at;
+alter table claim_creds drop column attempts;
+alter table claim_creds drop column erred;
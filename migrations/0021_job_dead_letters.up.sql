@@ -0,0 +1,22 @@
+alter table claim_creds add column erred boolean not null default false;
+
+alter table claim_creds add column attempts integer not null default 0;
+alter table claim_creds add column next_attempt_at timestamp with time zone not null default current_timestamp;
+alter table claim_creds add column last_error text default null;
+
+alter table suggestion_drain add column attempts integer not null default 0;
+alter table suggestion_drain add column next_attempt_at timestamp with time zone not null default current_timestamp;
+alter table suggestion_drain add column last_error text default null;
+
+alter table claim_drain add column attempts integer not null default 0;
+alter table claim_drain add column next_attempt_at timestamp with time zone not null default current_timestamp;
+alter table claim_drain add column last_error text default null;
+
+alter table vote_drain add column attempts integer not null default 0;
+alter table vote_drain add column next_attempt_at timestamp with time zone not null default current_timestamp;
+alter table vote_drain add column last_error text default null;
+
+create index on claim_creds(erred) where erred;
+create index on suggestion_drain(erred) where erred;
+create index on claim_drain(erred) where erred;
+create index on vote_drain(erred) where erred;
@@ -0,0 +1,12 @@
+drop trigger if exists vote_drain_requeue_notify on vote_drain;
+drop trigger if exists claim_drain_requeue_notify on claim_drain;
+drop trigger if exists suggestion_drain_requeue_notify on suggestion_drain;
+drop trigger if exists claim_creds_requeue_notify on claim_creds;
+
+drop trigger if exists order_creds_notify on order_creds;
+drop trigger if exists vote_drain_notify on vote_drain;
+drop trigger if exists claim_drain_notify on claim_drain;
+drop trigger if exists suggestion_drain_notify on suggestion_drain;
+drop trigger if exists claim_creds_notify on claim_creds;
+
+drop function if exists notify_job_queue();
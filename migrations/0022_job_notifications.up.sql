@@ -0,0 +1,26 @@
+create or replace function notify_job_queue() returns trigger as $$
+begin
+  perform pg_notify(TG_TABLE_NAME, '');
+  return null;
+end;
+$$ language plpgsql;
+
+create trigger claim_creds_notify after insert on claim_creds
+  for each statement execute procedure notify_job_queue();
+create trigger suggestion_drain_notify after insert on suggestion_drain
+  for each statement execute procedure notify_job_queue();
+create trigger claim_drain_notify after insert on claim_drain
+  for each statement execute procedure notify_job_queue();
+create trigger vote_drain_notify after insert on vote_drain
+  for each statement execute procedure notify_job_queue();
+create trigger order_creds_notify after insert on order_creds
+  for each statement execute procedure notify_job_queue();
+
+create trigger claim_creds_requeue_notify after update of erred on claim_creds
+  for each row when (old.erred and not new.erred) execute procedure notify_job_queue();
+create trigger suggestion_drain_requeue_notify after update of erred on suggestion_drain
+  for each row when (old.erred and not new.erred) execute procedure notify_job_queue();
+create trigger claim_drain_requeue_notify after update of erred on claim_drain
+  for each row when (old.erred and not new.erred) execute procedure notify_job_queue();
+create trigger vote_drain_requeue_notify after update of erred on vote_drain
+  for each row when (old.erred and not new.erred) execute procedure notify_job_queue();
@@ -0,0 +1,2 @@
+drop trigger if exists event_outbox_notify on event_outbox;
+drop table if exists event_outbox;
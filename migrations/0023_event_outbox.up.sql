@@ -0,0 +1,17 @@
+create table event_outbox (
+  id uuid primary key not null,
+  seq bigserial not null unique,
+  created_at timestamp with time zone not null default current_timestamp,
+  topic text not null,
+  key text not null,
+  payload bytea not null,
+  published_at timestamp with time zone default null,
+  attempts integer not null default 0,
+  last_error text default null
+);
+
+create index on event_outbox(topic, seq) where published_at is null;
+create index on event_outbox(topic, created_at);
+
+create trigger event_outbox_notify after insert on event_outbox
+  for each statement execute procedure notify_job_queue();
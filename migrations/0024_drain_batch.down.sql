@@ -0,0 +1,7 @@
+drop trigger if exists drain_batch_requeue_notify on drain_batch;
+drop trigger if exists drain_batch_notify on drain_batch;
+
+alter table claim_drain drop column if exists batch_id;
+alter table claim_drain drop column if exists payout_address;
+
+drop table if exists drain_batch;
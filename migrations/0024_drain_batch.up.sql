@@ -0,0 +1,35 @@
+create table drain_batch (
+  id uuid primary key default uuid_generate_v4(),
+  payout_address text not null,
+  total numeric(28, 18) not null check (total > 0.0),
+  idempotency_key uuid not null unique default uuid_generate_v4(),
+  transaction_id text default null,
+  transaction_status text default null,
+  status_checked_at timestamp with time zone default null,
+  completed boolean not null default false,
+  erred boolean not null default false,
+  attempts integer not null default 0,
+  next_attempt_at timestamp with time zone not null default current_timestamp,
+  last_error text default null,
+  created_at timestamp with time zone not null default current_timestamp
+);
+
+create index on drain_batch(erred) where erred;
+create index on drain_batch(transaction_id);
+
+alter table claim_drain add column payout_address text default null;
+alter table claim_drain add column batch_id uuid default null references drain_batch(id);
+
+update claim_drain
+set payout_address = wallets.payout_address
+from wallets
+where claim_drain.wallet_id = wallets.id and not claim_drain.completed;
+
+create index on claim_drain(payout_address, created_at)
+  where redeemed and batch_id is null and transaction_id is null and not erred and not completed;
+create index on claim_drain(batch_id);
+
+create trigger drain_batch_notify after insert on drain_batch
+  for each statement execute procedure notify_job_queue();
+create trigger drain_batch_requeue_notify after update of erred on drain_batch
+  for each row when (old.erred and not new.erred) execute procedure notify_job_queue();
@@ -0,0 +1,2 @@
+alter table promotions drop column if exists claimed_value;
+alter table promotions drop column if exists budget;
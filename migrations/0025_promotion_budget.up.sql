@@ -0,0 +1,9 @@
+alter table promotions add column budget numeric(28, 18) default null check (budget >= 0.0);
+alter table promotions add column claimed_value numeric(28, 18) not null default 0.0;
+
+update promotions
+set claimed_value = coalesce((
+  select sum(claims.approximate_value)
+  from claims
+  where claims.promotion_id = promotions.id and (claims.redeemed or claims.legacy_claimed)
+), 0.0);
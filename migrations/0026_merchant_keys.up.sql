@@ -0,0 +1,11 @@
+create table merchant_keys (
+  id uuid primary key default uuid_generate_v4(),
+  merchant_id text not null,
+  name text not null,
+  encrypted_secret_key text not null,
+  nonce text not null,
+  created_at timestamp with time zone not null default current_timestamp,
+  expiry timestamp with time zone
+);
+
+create index merchant_keys_merchant_id_idx on merchant_keys(merchant_id);
@@ -0,0 +1,17 @@
+create table skus (
+  id uuid primary key default uuid_generate_v4(),
+  merchant_id text not null,
+  sku text not null,
+  location text not null,
+  price numeric(28, 18) not null check (price >= 0.0),
+  currency text not null,
+  description text,
+  expires_at timestamp with time zone,
+  key_id uuid not null references merchant_keys(id),
+  token text not null,
+  active boolean not null default true,
+  created_at timestamp with time zone not null default current_timestamp,
+  updated_at timestamp with time zone not null default current_timestamp
+);
+
+create unique index skus_merchant_id_sku_idx on skus(merchant_id, sku);
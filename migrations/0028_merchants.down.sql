@@ -0,0 +1,9 @@
+drop index if exists orders_merchant_id_idx;
+
+alter table order_cred_issuers drop constraint if exists order_cred_issuers_merchant_id_fkey;
+alter table orders drop constraint if exists orders_merchant_id_fkey;
+alter table skus drop constraint if exists skus_merchant_id_fkey;
+alter table merchant_keys drop constraint if exists merchant_keys_merchant_id_fkey;
+
+drop table if exists merchant_locations;
+drop table if exists merchants;
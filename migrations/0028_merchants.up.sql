@@ -0,0 +1,40 @@
+create table merchants (
+  id text primary key,
+  name text not null,
+  api_key_hash text unique,
+  payout_provider text,
+  payout_address text,
+  created_at timestamp with time zone not null default current_timestamp,
+  updated_at timestamp with time zone not null default current_timestamp
+);
+
+create table merchant_locations (
+  location text primary key,
+  merchant_id text not null references merchants(id) on delete cascade,
+  created_at timestamp with time zone not null default current_timestamp
+);
+
+create index merchant_locations_merchant_id_idx on merchant_locations(merchant_id);
+
+-- orders were attributed to brave.com before merchants were registered
+insert into merchants (id, name) values ('brave.com', 'Brave');
+
+insert into merchants (id, name)
+select merchant_id, merchant_id from (
+  select merchant_id from merchant_keys
+  union select merchant_id from skus
+  union select merchant_id from orders
+  union select merchant_id from order_cred_issuers
+) as existing
+on conflict do nothing;
+
+-- the location of a SKU token was the id of its merchant before locations were registered
+insert into merchant_locations (location, merchant_id)
+select distinct merchant_id, merchant_id from merchant_keys;
+
+alter table merchant_keys add constraint merchant_keys_merchant_id_fkey foreign key (merchant_id) references merchants(id);
+alter table skus add constraint skus_merchant_id_fkey foreign key (merchant_id) references merchants(id);
+alter table orders add constraint orders_merchant_id_fkey foreign key (merchant_id) references merchants(id);
+alter table order_cred_issuers add constraint order_cred_issuers_merchant_id_fkey foreign key (merchant_id) references merchants(id);
+
+create index orders_merchant_id_idx on orders(merchant_id, created_at);
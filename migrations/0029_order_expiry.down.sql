@@ -0,0 +1,3 @@
+drop index if exists orders_pending_expires_at_idx;
+
+alter table orders drop column if exists expires_at;
@@ -0,0 +1,6 @@
+alter table orders add column expires_at timestamp with time zone;
+
+-- give orders which are already pending a day to be paid before they are canceled
+update orders set expires_at = current_timestamp + interval '1 day' where status = 'pending';
+
+create index orders_pending_expires_at_idx on orders(expires_at) where status = 'pending';
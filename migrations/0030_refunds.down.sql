@@ -0,0 +1,9 @@
+alter table order_creds drop column if exists revoked_at;
+
+alter table order_items drop constraint if exists order_items_refunded_quantity_check;
+alter table order_items drop column if exists refunded_quantity;
+
+alter table orders drop constraint status_check;
+alter table orders add constraint status_check check (
+  status in ('pending', 'paid', 'fulfilled', 'canceled')
+);
@@ -0,0 +1,11 @@
+alter table orders drop constraint status_check;
+alter table orders add constraint status_check check (
+  status in ('pending', 'paid', 'fulfilled', 'canceled', 'refunded')
+);
+
+alter table order_items add column refunded_quantity integer not null default 0;
+alter table order_items add constraint order_items_refunded_quantity_check check (
+  refunded_quantity >= 0 and refunded_quantity <= quantity
+);
+
+alter table order_creds add column revoked_at timestamp with time zone;
@@ -0,0 +1,3 @@
+delete from promotion_expiry_reports where backfilled;
+
+alter table promotion_expiry_reports drop column if exists backfilled;
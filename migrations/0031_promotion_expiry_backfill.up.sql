@@ -0,0 +1,38 @@
+alter table promotion_expiry_reports add column backfilled boolean not null default false;
+
+-- redemptions were only recorded per promotion from 0018_promotion_cohorts onwards, so the unredeemed value of
+-- promotions created before the first recorded redemption cannot be known. sweep those which have already expired
+-- here, reporting them as backfilled rather than fully unredeemed. every other promotion is left to the sweeper.
+create temporary table backfilled_promotions as
+select promotions.*
+from promotions
+where promotions.redeemable_until < current_timestamp
+  and promotions.created_at < (select coalesce(min(created_at), current_timestamp) from cohort_redemptions)
+  and not exists (select 1 from promotion_expiry_reports where promotion_expiry_reports.promotion_id = promotions.id);
+
+insert into promotion_expiry_reports (promotion_id, claims, issued_value, redeemed_value, unredeemed_value, backfilled)
+select
+  backfilled_promotions.id,
+  (select count(*) from claims where claims.promotion_id = backfilled_promotions.id and claims.redeemed),
+  (select coalesce(sum(approximate_value), 0.0) from claims where claims.promotion_id = backfilled_promotions.id and claims.redeemed),
+  (select coalesce(sum(amount), 0.0) from cohort_redemptions where cohort_redemptions.promotion_id = backfilled_promotions.id),
+  0.0,
+  true
+from backfilled_promotions;
+
+update promotions set active = false
+from backfilled_promotions
+where promotions.id = backfilled_promotions.id;
+
+insert into promotion_audit (promotion_id, actor, action, previous, updated)
+select
+  id,
+  'sweeper',
+  'sweep',
+  json_build_object('active', active, 'expiresAt', expires_at, 'claimableUntil', claimable_until,
+    'redeemableUntil', redeemable_until, 'remainingGrants', remaining_grants, 'budget', budget),
+  json_build_object('active', false, 'expiresAt', expires_at, 'claimableUntil', claimable_until,
+    'redeemableUntil', redeemable_until, 'remainingGrants', remaining_grants, 'budget', budget)
+from backfilled_promotions;
+
+drop table backfilled_promotions;
@@ -0,0 +1 @@
+alter table suggestion_drain drop column if exists redeem_attempted_at;
@@ -0,0 +1 @@
+alter table suggestion_drain add column redeem_attempted_at timestamp with time zone default null;
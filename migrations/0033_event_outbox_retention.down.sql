@@ -0,0 +1 @@
+drop index if exists event_outbox_topic_published_at_idx;
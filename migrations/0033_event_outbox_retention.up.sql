@@ -0,0 +1 @@
+create index on event_outbox(topic, published_at) where published_at is not null;
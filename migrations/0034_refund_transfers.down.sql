@@ -0,0 +1,3 @@
+drop index if exists transactions_status_checked_at_idx;
+
+drop table if exists order_item_refunds;
@@ -0,0 +1,8 @@
+create table order_item_refunds (
+  transaction_id uuid not null references transactions(id),
+  item_id uuid not null references order_items(id),
+  quantity integer not null check (quantity > 0),
+  primary key (transaction_id, item_id)
+);
+
+create index on transactions(status_checked_at) where kind = 'refund' and status not in ('completed', 'failed', 'cancelled');
@@ -0,0 +1,3 @@
+alter table suggestion_drain add column redeem_attempted_at timestamp with time zone default null;
+
+drop table if exists credential_redemptions;
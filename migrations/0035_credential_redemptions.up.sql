@@ -0,0 +1,11 @@
+create table credential_redemptions (
+  issuer text not null,
+  token_preimage text not null,
+  owner_id uuid not null,
+  created_at timestamp with time zone not null default current_timestamp,
+  primary key (issuer, token_preimage)
+);
+
+create index on credential_redemptions(owner_id);
+
+alter table suggestion_drain drop column if exists redeem_attempted_at;
@@ -15,3 +15,7 @@ const voteSchema = `{
     { "name": "fundingSource", "type": "string", "default": "uphold" }
   ]
 }`
+
+// voteSchemas are the versions of the vote event schema, oldest first. Add a version rather than changing an
+// existing one, new versions must be able to read events written with the previous.
+var voteSchemas = []string{voteSchema}
@@ -3,8 +3,12 @@ package payment
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/brave-intl/bat-go/middleware"
@@ -13,6 +17,7 @@ import (
 	"github.com/brave-intl/bat-go/utils/requestutils"
 	"github.com/go-chi/chi"
 	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
 )
 
 // Router for order endpoints
@@ -33,6 +38,52 @@ func Router(service *Service) chi.Router {
 	return r
 }
 
+// OrdersAdminRouter for refunding orders
+func OrdersAdminRouter(service *Service) chi.Router {
+	r := chi.NewRouter()
+	if os.Getenv("ENV") != "local" {
+		r.Use(middleware.SimpleTokenAuthorizedOnly)
+	}
+
+	r.Method("POST", "/{orderID}/refunds", middleware.InstrumentHandler("RefundOrder", RefundOrder(service)))
+
+	return r
+}
+
+// MerchantsAdminRouter for managing merchants along with their root keys and SKU catalog
+func MerchantsAdminRouter(service *Service) chi.Router {
+	r := chi.NewRouter()
+	if os.Getenv("ENV") != "local" {
+		r.Use(middleware.SimpleTokenAuthorizedOnly)
+	}
+
+	r.Method("POST", "/", middleware.InstrumentHandler("CreateMerchant", CreateMerchant(service)))
+	r.Method("GET", "/", middleware.InstrumentHandler("GetMerchants", GetMerchants(service)))
+	r.Method("GET", "/{merchantID}", middleware.InstrumentHandler("GetMerchant", GetMerchant(service)))
+	r.Method("PUT", "/{merchantID}", middleware.InstrumentHandler("UpdateMerchant", UpdateMerchant(service)))
+	r.Method("POST", "/{merchantID}/api-key", middleware.InstrumentHandler("RotateMerchantAPIKey", RotateMerchantAPIKey(service)))
+
+	r.Method("POST", "/{merchantID}/keys", middleware.InstrumentHandler("CreateKey", CreateKey(service)))
+	r.Method("GET", "/{merchantID}/keys", middleware.InstrumentHandler("GetKeys", GetKeys(service)))
+	r.Method("DELETE", "/{merchantID}/keys/{keyID}", middleware.InstrumentHandler("ExpireKey", ExpireKey(service)))
+
+	r.Method("POST", "/{merchantID}/skus", middleware.InstrumentHandler("CreateSKU", CreateSKU(service)))
+	r.Method("GET", "/{merchantID}/skus", middleware.InstrumentHandler("GetSKUs", GetSKUs(service)))
+	r.Method("DELETE", "/{merchantID}/skus/{skuID}", middleware.InstrumentHandler("RevokeSKU", RevokeSKU(service)))
+	return r
+}
+
+// MerchantRouter for the endpoints merchants access with their API key
+func MerchantRouter(service *Service) chi.Router {
+	r := chi.NewRouter()
+	r.Use(service.MerchantAuthorizedOnly)
+
+	r.Method("GET", "/{merchantID}/orders", middleware.InstrumentHandler("GetMerchantOrders", GetMerchantOrders(service)))
+	r.Method("POST", "/{merchantID}/orders/{orderID}/cancel", middleware.InstrumentHandler("CancelOrder", CancelOrder(service)))
+	r.Method("GET", "/{merchantID}/transactions", middleware.InstrumentHandler("GetMerchantTransactions", GetMerchantTransactions(service)))
+	return r
+}
+
 // VoteRouter for voting endpoint
 func VoteRouter(service *Service) chi.Router {
 	r := chi.NewRouter()
@@ -76,6 +127,9 @@ func CreateOrder(service *Service) handlers.AppHandler {
 		order, err := service.CreateOrderFromRequest(req)
 
 		if err != nil {
+			if errors.Is(err, ErrInvalidSKU) {
+				return handlers.WrapError(err, "Error validating the order items", http.StatusBadRequest)
+			}
 			return handlers.WrapError(err, "Error creating the order in the database", http.StatusInternalServerError)
 		}
 
@@ -121,6 +175,108 @@ func GetOrder(service *Service) handlers.AppHandler {
 	})
 }
 
+// wrapOrderStatusError maps errors from orders in the wrong status to a conflict, falling back to the passed code
+func wrapOrderStatusError(err error, msg string, code int) *handlers.AppError {
+	if errors.Is(err, ErrOrderNotFound) {
+		return handlers.WrapError(err, msg, http.StatusNotFound)
+	}
+	if errors.Is(err, ErrInvalidOrderTransition) {
+		return handlers.WrapError(err, msg, http.StatusConflict)
+	}
+	return handlers.WrapError(err, msg, code)
+}
+
+// CancelOrder is the handler for the authorized merchant canceling one of its orders which has not yet been paid.
+// Orders which are abandoned by the payer are canceled once they expire.
+func CancelOrder(service *Service) handlers.AppHandler {
+	return handlers.AppHandler(func(w http.ResponseWriter, r *http.Request) *handlers.AppError {
+		merchant, appErr := authorizedMerchant(r)
+		if appErr != nil {
+			return appErr
+		}
+
+		orderID := chi.URLParam(r, "orderID")
+		if orderID == "" || !govalidator.IsUUIDv4(orderID) {
+			return handlers.ValidationError(
+				"Error validating request url parameter",
+				map[string]interface{}{
+					"orderID": "orderID must be a uuidv4",
+				},
+			)
+		}
+
+		id := uuid.Must(uuid.FromString(orderID))
+
+		order, err := service.CancelOrder(merchant.ID, id)
+		if err != nil {
+			return wrapOrderStatusError(err, "Error canceling the order", http.StatusInternalServerError)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(order); err != nil {
+			return handlers.WrapError(err, "Error encoding the order JSON", http.StatusInternalServerError)
+		}
+		return nil
+	})
+}
+
+// RefundOrderRequest includes the quantities of the order items to refund
+type RefundOrderRequest struct {
+	Items []OrderItemRefund `json:"items" valid:"-"`
+}
+
+// RefundOrder is the handler for refunding some or all of the items of a paid order, or of a canceled order
+// which was paid after it was canceled. The refund transaction is returned once reserved and the transfer to the
+// payer is made in the background
+func RefundOrder(service *Service) handlers.AppHandler {
+	return handlers.AppHandler(func(w http.ResponseWriter, r *http.Request) *handlers.AppError {
+		var req RefundOrderRequest
+		err := requestutils.ReadJSON(r.Body, &req)
+		if err != nil {
+			return handlers.WrapError(err, "Error in request body", http.StatusBadRequest)
+		}
+
+		orderID := chi.URLParam(r, "orderID")
+		if orderID == "" || !govalidator.IsUUIDv4(orderID) {
+			return handlers.ValidationError(
+				"Error validating request url parameter",
+				map[string]interface{}{
+					"orderID": "orderID must be a uuidv4",
+				},
+			)
+		}
+		if len(req.Items) == 0 {
+			return handlers.ValidationError(
+				"Error validating request body",
+				map[string]interface{}{
+					"items": "array must contain at least one item",
+				},
+			)
+		}
+
+		id := uuid.Must(uuid.FromString(orderID))
+
+		transaction, err := service.RefundOrder(r.Context(), id, req.Items)
+		if err != nil {
+			if errors.Is(err, ErrInvalidRefund) {
+				return handlers.WrapError(err, "Error validating the refund", http.StatusBadRequest)
+			}
+			if errors.Is(err, ErrNoRefundWallet) {
+				return handlers.WrapError(err, "Error refunding the order", http.StatusServiceUnavailable)
+			}
+			return wrapOrderStatusError(err, "Error refunding the order", http.StatusInternalServerError)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		if err := json.NewEncoder(w).Encode(transaction); err != nil {
+			return handlers.WrapError(err, "Error encoding the transaction JSON", http.StatusInternalServerError)
+		}
+		return nil
+	})
+}
+
 // GetTransactions is the handler for listing the transactions for an order
 func GetTransactions(service *Service) handlers.AppHandler {
 	return handlers.AppHandler(func(w http.ResponseWriter, r *http.Request) *handlers.AppError {
@@ -191,9 +347,9 @@ func CreateUpholdTransaction(service *Service) handlers.AppHandler {
 			return handlers.WrapError(err, "Error creating the transaction", http.StatusBadRequest)
 		}
 
-		transaction, err = service.CreateTransactionFromRequest(req, validOrderID)
+		transaction, err = service.CreateTransactionFromRequest(r.Context(), req, validOrderID)
 		if err != nil {
-			return handlers.WrapError(err, "Error creating the transaction", http.StatusBadRequest)
+			return wrapOrderStatusError(err, "Error creating the transaction", http.StatusBadRequest)
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -234,7 +390,7 @@ func CreateAnonCardTransaction(service *Service) handlers.AppHandler {
 
 		transaction, err := service.CreateAnonCardTransaction(r.Context(), req.WalletID, req.Transaction, validOrderID)
 		if err != nil {
-			return handlers.WrapError(err, "Error creating anon card transaction", http.StatusInternalServerError)
+			return wrapOrderStatusError(err, "Error creating anon card transaction", http.StatusInternalServerError)
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -289,7 +445,7 @@ func CreateOrderCreds(service *Service) handlers.AppHandler {
 
 		err = service.CreateOrderCreds(r.Context(), validOrderID, req.ItemID, req.BlindedCreds)
 		if err != nil {
-			return handlers.WrapError(err, "Error creating order creds", http.StatusBadRequest)
+			return wrapOrderStatusError(err, "Error creating order creds", http.StatusBadRequest)
 		}
 
 		return nil
@@ -324,7 +480,8 @@ func GetOrderCreds(service *Service) handlers.AppHandler {
 
 		status := http.StatusOK
 		for i := 0; i < len(*creds); i++ {
-			if (*creds)[i].SignedCreds == nil {
+			// revoked credentials are never signed
+			if (*creds)[i].SignedCreds == nil && (*creds)[i].RevokedAt == nil {
 				status = http.StatusAccepted
 				break
 			}
@@ -378,6 +535,14 @@ func GetOrderCredsByID(service *Service) handlers.AppHandler {
 			}
 		}
 
+		if creds.RevokedAt != nil {
+			return &handlers.AppError{
+				Message: "Credentials have been revoked",
+				Code:    http.StatusGone,
+				Data:    map[string]interface{}{},
+			}
+		}
+
 		status := http.StatusOK
 		if creds.SignedCreds == nil {
 			status = http.StatusAccepted
@@ -424,3 +589,472 @@ func MakeVote(service *Service) handlers.AppHandler {
 		return nil
 	})
 }
+
+// CreateKeyRequest includes the name of the root key to create
+type CreateKeyRequest struct {
+	Name string `json:"name" valid:"required"`
+}
+
+// CreateKey is the handler for creating a new root key of a merchant
+func CreateKey(service *Service) handlers.AppHandler {
+	return handlers.AppHandler(func(w http.ResponseWriter, r *http.Request) *handlers.AppError {
+		merchantID := chi.URLParam(r, "merchantID")
+
+		var req CreateKeyRequest
+		err := requestutils.ReadJSON(r.Body, &req)
+		if err != nil {
+			return handlers.WrapError(err, "Error in request body", http.StatusBadRequest)
+		}
+
+		_, err = govalidator.ValidateStruct(req)
+		if err != nil {
+			return handlers.WrapValidationError(err)
+		}
+
+		key, err := service.CreateKey(merchantID, req.Name)
+		if err != nil {
+			if errors.Is(err, ErrMerchantNotFound) {
+				return handlers.WrapError(err, "Error creating the key", http.StatusNotFound)
+			}
+			return handlers.WrapError(err, "Error creating the key", http.StatusInternalServerError)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(key); err != nil {
+			return handlers.WrapError(err, "Error encoding the key JSON", http.StatusInternalServerError)
+		}
+		return nil
+	})
+}
+
+// GetKeys is the handler for listing the root keys of a merchant
+func GetKeys(service *Service) handlers.AppHandler {
+	return handlers.AppHandler(func(w http.ResponseWriter, r *http.Request) *handlers.AppError {
+		merchantID := chi.URLParam(r, "merchantID")
+		showExpired := r.URL.Query().Get("expired") == "true"
+
+		keys, err := service.datastore.GetKeys(merchantID, showExpired)
+		if err != nil {
+			return handlers.WrapError(err, "Error retrieving the keys", http.StatusInternalServerError)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(keys); err != nil {
+			return handlers.WrapError(err, "Error encoding the keys JSON", http.StatusInternalServerError)
+		}
+		return nil
+	})
+}
+
+// ExpireKey is the handler for expiring a root key of a merchant, SKU tokens signed with it are rejected
+func ExpireKey(service *Service) handlers.AppHandler {
+	return handlers.AppHandler(func(w http.ResponseWriter, r *http.Request) *handlers.AppError {
+		merchantID := chi.URLParam(r, "merchantID")
+		keyID := chi.URLParam(r, "keyID")
+		if keyID == "" || !govalidator.IsUUIDv4(keyID) {
+			return handlers.ValidationError(
+				"Error validating request url parameter",
+				map[string]interface{}{
+					"keyID": "keyID must be a uuidv4",
+				},
+			)
+		}
+
+		expired, err := service.datastore.ExpireKey(merchantID, uuid.Must(uuid.FromString(keyID)))
+		if err != nil {
+			return handlers.WrapError(err, "Error expiring the key", http.StatusInternalServerError)
+		}
+		if !expired {
+			return handlers.WrapError(errors.New("no such key"), "Error expiring the key", http.StatusNotFound)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		return nil
+	})
+}
+
+// CreateSKURequest includes the caveats of the SKU token to mint
+type CreateSKURequest struct {
+	SKU         string          `json:"sku" valid:"required"`
+	Location    string          `json:"location" valid:"-"`
+	Price       decimal.Decimal `json:"price" valid:"-"`
+	Currency    string          `json:"currency" valid:"required"`
+	Description string          `json:"description" valid:"-"`
+	ExpiresAt   *time.Time      `json:"expiresAt" valid:"-"`
+}
+
+// CreateSKU is the handler for adding a SKU to the catalog of a merchant, returning it with its token
+func CreateSKU(service *Service) handlers.AppHandler {
+	return handlers.AppHandler(func(w http.ResponseWriter, r *http.Request) *handlers.AppError {
+		merchantID := chi.URLParam(r, "merchantID")
+
+		var req CreateSKURequest
+		err := requestutils.ReadJSON(r.Body, &req)
+		if err != nil {
+			return handlers.WrapError(err, "Error in request body", http.StatusBadRequest)
+		}
+
+		_, err = govalidator.ValidateStruct(req)
+		if err != nil {
+			return handlers.WrapValidationError(err)
+		}
+
+		sku := SKU{
+			SKU:       req.SKU,
+			Location:  req.Location,
+			Price:     req.Price,
+			Currency:  req.Currency,
+			ExpiresAt: req.ExpiresAt,
+		}
+		if len(req.Description) > 0 {
+			sku.Description.String = req.Description
+			sku.Description.Valid = true
+		}
+
+		created, err := service.CreateSKU(merchantID, sku)
+		if err != nil {
+			switch {
+			case errors.Is(err, errInvalidSKUFields), errors.Is(err, ErrNoMerchantKey), errors.Is(err, ErrLocationNotAllowed):
+				return handlers.WrapError(err, "Error creating the SKU", http.StatusBadRequest)
+			case errors.Is(err, ErrMerchantNotFound):
+				return handlers.WrapError(err, "Error creating the SKU", http.StatusNotFound)
+			case errors.Is(err, ErrSKUExists):
+				return handlers.WrapError(err, "Error creating the SKU", http.StatusConflict)
+			default:
+				return handlers.WrapError(err, "Error creating the SKU", http.StatusInternalServerError)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(created); err != nil {
+			return handlers.WrapError(err, "Error encoding the SKU JSON", http.StatusInternalServerError)
+		}
+		return nil
+	})
+}
+
+// GetSKUs is the handler for listing the SKU catalog of a merchant
+func GetSKUs(service *Service) handlers.AppHandler {
+	return handlers.AppHandler(func(w http.ResponseWriter, r *http.Request) *handlers.AppError {
+		merchantID := chi.URLParam(r, "merchantID")
+		showInactive := r.URL.Query().Get("inactive") == "true"
+
+		skus, err := service.datastore.GetSKUs(merchantID, showInactive)
+		if err != nil {
+			return handlers.WrapError(err, "Error retrieving the SKUs", http.StatusInternalServerError)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(skus); err != nil {
+			return handlers.WrapError(err, "Error encoding the SKUs JSON", http.StatusInternalServerError)
+		}
+		return nil
+	})
+}
+
+// RevokeSKU is the handler for marking a SKU of a merchant inactive, orders are no longer created for it
+func RevokeSKU(service *Service) handlers.AppHandler {
+	return handlers.AppHandler(func(w http.ResponseWriter, r *http.Request) *handlers.AppError {
+		merchantID := chi.URLParam(r, "merchantID")
+		skuID := chi.URLParam(r, "skuID")
+		if skuID == "" || !govalidator.IsUUIDv4(skuID) {
+			return handlers.ValidationError(
+				"Error validating request url parameter",
+				map[string]interface{}{
+					"skuID": "skuID must be a uuidv4",
+				},
+			)
+		}
+
+		revoked, err := service.datastore.RevokeSKU(merchantID, uuid.Must(uuid.FromString(skuID)))
+		if err != nil {
+			return handlers.WrapError(err, "Error revoking the SKU", http.StatusInternalServerError)
+		}
+		if !revoked {
+			return handlers.WrapError(errors.New("no such active SKU"), "Error revoking the SKU", http.StatusNotFound)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		return nil
+	})
+}
+
+// MerchantRequest includes the settings of a merchant
+type MerchantRequest struct {
+	Name           string   `json:"name" valid:"required"`
+	PayoutProvider string   `json:"payoutProvider" valid:"in(uphold)"`
+	PayoutAddress  string   `json:"payoutAddress" valid:"-"`
+	Locations      []string `json:"locations" valid:"-"`
+}
+
+// CreateMerchantRequest includes the id and settings of a new merchant
+type CreateMerchantRequest struct {
+	ID string `json:"id" valid:"required"`
+	MerchantRequest
+}
+
+// CreateMerchantResponse includes the API key of the new merchant, which is only returned once
+type CreateMerchantResponse struct {
+	*Merchant
+	APIKey string `json:"apiKey"`
+}
+
+// APIKeyResponse includes a new API key of a merchant, which is only returned once
+type APIKeyResponse struct {
+	APIKey string `json:"apiKey"`
+}
+
+// applyMerchantRequest validates the settings and applies them to the merchant
+func applyMerchantRequest(merchant *Merchant, req MerchantRequest) *handlers.AppError {
+	_, err := govalidator.ValidateStruct(req)
+	if err != nil {
+		return handlers.WrapValidationError(err)
+	}
+	if len(req.PayoutAddress) > 0 && len(req.PayoutProvider) == 0 {
+		return handlers.ValidationError(
+			"Error validating request body",
+			map[string]interface{}{
+				"payoutProvider": "payoutProvider is required with a payoutAddress",
+			},
+		)
+	}
+
+	locations := []string{}
+	seen := map[string]bool{}
+	for _, location := range req.Locations {
+		if len(location) == 0 {
+			return handlers.ValidationError(
+				"Error validating request body",
+				map[string]interface{}{
+					"locations": "locations must not be empty",
+				},
+			)
+		}
+		if !seen[location] {
+			seen[location] = true
+			locations = append(locations, location)
+		}
+	}
+
+	merchant.Name = req.Name
+	merchant.PayoutProvider.String = req.PayoutProvider
+	merchant.PayoutProvider.Valid = len(req.PayoutProvider) > 0
+	merchant.PayoutAddress.String = req.PayoutAddress
+	merchant.PayoutAddress.Valid = len(req.PayoutAddress) > 0
+	merchant.Locations = locations
+	return nil
+}
+
+// CreateMerchant is the handler for registering a new merchant, returning its API key
+func CreateMerchant(service *Service) handlers.AppHandler {
+	return handlers.AppHandler(func(w http.ResponseWriter, r *http.Request) *handlers.AppError {
+		var req CreateMerchantRequest
+		err := requestutils.ReadJSON(r.Body, &req)
+		if err != nil {
+			return handlers.WrapError(err, "Error in request body", http.StatusBadRequest)
+		}
+
+		_, err = govalidator.ValidateStruct(req)
+		if err != nil {
+			return handlers.WrapValidationError(err)
+		}
+
+		merchant := Merchant{ID: req.ID}
+		if appErr := applyMerchantRequest(&merchant, req.MerchantRequest); appErr != nil {
+			return appErr
+		}
+
+		created, apiKey, err := service.CreateMerchant(merchant)
+		if err != nil {
+			if errors.Is(err, ErrMerchantExists) || errors.Is(err, ErrLocationTaken) {
+				return handlers.WrapError(err, "Error creating the merchant", http.StatusConflict)
+			}
+			return handlers.WrapError(err, "Error creating the merchant", http.StatusInternalServerError)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(CreateMerchantResponse{Merchant: created, APIKey: apiKey}); err != nil {
+			return handlers.WrapError(err, "Error encoding the merchant JSON", http.StatusInternalServerError)
+		}
+		return nil
+	})
+}
+
+// GetMerchants is the handler for listing all merchants
+func GetMerchants(service *Service) handlers.AppHandler {
+	return handlers.AppHandler(func(w http.ResponseWriter, r *http.Request) *handlers.AppError {
+		merchants, err := service.datastore.GetMerchants()
+		if err != nil {
+			return handlers.WrapError(err, "Error retrieving the merchants", http.StatusInternalServerError)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(merchants); err != nil {
+			return handlers.WrapError(err, "Error encoding the merchants JSON", http.StatusInternalServerError)
+		}
+		return nil
+	})
+}
+
+// GetMerchant is the handler for getting a merchant
+func GetMerchant(service *Service) handlers.AppHandler {
+	return handlers.AppHandler(func(w http.ResponseWriter, r *http.Request) *handlers.AppError {
+		merchant, err := service.getMerchant(chi.URLParam(r, "merchantID"))
+		if err != nil {
+			if errors.Is(err, ErrMerchantNotFound) {
+				return handlers.WrapError(err, "Error retrieving the merchant", http.StatusNotFound)
+			}
+			return handlers.WrapError(err, "Error retrieving the merchant", http.StatusInternalServerError)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(merchant); err != nil {
+			return handlers.WrapError(err, "Error encoding the merchant JSON", http.StatusInternalServerError)
+		}
+		return nil
+	})
+}
+
+// UpdateMerchant is the handler for changing the name, payout settings and allowed locations of a merchant
+func UpdateMerchant(service *Service) handlers.AppHandler {
+	return handlers.AppHandler(func(w http.ResponseWriter, r *http.Request) *handlers.AppError {
+		var req MerchantRequest
+		err := requestutils.ReadJSON(r.Body, &req)
+		if err != nil {
+			return handlers.WrapError(err, "Error in request body", http.StatusBadRequest)
+		}
+
+		merchant := Merchant{ID: chi.URLParam(r, "merchantID")}
+		if appErr := applyMerchantRequest(&merchant, req); appErr != nil {
+			return appErr
+		}
+
+		updated, err := service.datastore.UpdateMerchant(&merchant)
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrMerchantNotFound):
+				return handlers.WrapError(err, "Error updating the merchant", http.StatusNotFound)
+			case errors.Is(err, ErrLocationTaken):
+				return handlers.WrapError(err, "Error updating the merchant", http.StatusConflict)
+			default:
+				return handlers.WrapError(err, "Error updating the merchant", http.StatusInternalServerError)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(updated); err != nil {
+			return handlers.WrapError(err, "Error encoding the merchant JSON", http.StatusInternalServerError)
+		}
+		return nil
+	})
+}
+
+// RotateMerchantAPIKey is the handler for replacing the API key of a merchant
+func RotateMerchantAPIKey(service *Service) handlers.AppHandler {
+	return handlers.AppHandler(func(w http.ResponseWriter, r *http.Request) *handlers.AppError {
+		apiKey, err := service.RotateMerchantAPIKey(chi.URLParam(r, "merchantID"))
+		if err != nil {
+			if errors.Is(err, ErrMerchantNotFound) {
+				return handlers.WrapError(err, "Error rotating the API key", http.StatusNotFound)
+			}
+			return handlers.WrapError(err, "Error rotating the API key", http.StatusInternalServerError)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(APIKeyResponse{APIKey: apiKey}); err != nil {
+			return handlers.WrapError(err, "Error encoding the API key JSON", http.StatusInternalServerError)
+		}
+		return nil
+	})
+}
+
+// pageFromRequest returns the limit and offset of the page requested with the query parameters
+func pageFromRequest(r *http.Request) (int, int, *handlers.AppError) {
+	limit := defaultMerchantPageSize
+	offset := 0
+	var err error
+	if l := r.URL.Query().Get("limit"); len(l) > 0 {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > maxMerchantPageSize {
+			return 0, 0, handlers.ValidationError(
+				"Error validating request query",
+				map[string]interface{}{
+					"limit": fmt.Sprintf("limit must be between 1 and %d", maxMerchantPageSize),
+				},
+			)
+		}
+	}
+	if o := r.URL.Query().Get("offset"); len(o) > 0 {
+		offset, err = strconv.Atoi(o)
+		if err != nil || offset < 0 {
+			return 0, 0, handlers.ValidationError(
+				"Error validating request query",
+				map[string]interface{}{
+					"offset": "offset must be a non-negative integer",
+				},
+			)
+		}
+	}
+	return limit, offset, nil
+}
+
+// GetMerchantOrders is the handler for listing the orders of the authorized merchant, newest first
+func GetMerchantOrders(service *Service) handlers.AppHandler {
+	return handlers.AppHandler(func(w http.ResponseWriter, r *http.Request) *handlers.AppError {
+		merchant, appErr := authorizedMerchant(r)
+		if appErr != nil {
+			return appErr
+		}
+		limit, offset, appErr := pageFromRequest(r)
+		if appErr != nil {
+			return appErr
+		}
+
+		orders, err := service.datastore.GetMerchantOrders(merchant.ID, limit, offset)
+		if err != nil {
+			return handlers.WrapError(err, "Error retrieving the orders", http.StatusInternalServerError)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(orders); err != nil {
+			return handlers.WrapError(err, "Error encoding the orders JSON", http.StatusInternalServerError)
+		}
+		return nil
+	})
+}
+
+// GetMerchantTransactions is the handler for listing the transactions for orders of the authorized merchant, newest first
+func GetMerchantTransactions(service *Service) handlers.AppHandler {
+	return handlers.AppHandler(func(w http.ResponseWriter, r *http.Request) *handlers.AppError {
+		merchant, appErr := authorizedMerchant(r)
+		if appErr != nil {
+			return appErr
+		}
+		limit, offset, appErr := pageFromRequest(r)
+		if appErr != nil {
+			return appErr
+		}
+
+		transactions, err := service.datastore.GetMerchantTransactions(merchant.ID, limit, offset)
+		if err != nil {
+			return handlers.WrapError(err, "Error retrieving the transactions", http.StatusInternalServerError)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(transactions); err != nil {
+			return handlers.WrapError(err, "Error encoding the transactions JSON", http.StatusInternalServerError)
+		}
+		return nil
+	})
+}
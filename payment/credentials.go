@@ -4,19 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/brave-intl/bat-go/utils/clients/cbr"
 	appctx "github.com/brave-intl/bat-go/utils/context"
 	errorutils "github.com/brave-intl/bat-go/utils/errors"
+	"github.com/brave-intl/bat-go/utils/issuerpolicy"
 	"github.com/brave-intl/bat-go/utils/jsonutils"
 	uuid "github.com/satori/go.uuid"
 )
 
-const (
-	defaultMaxTokensPerIssuer = 4000000 // ~1M BAT
-)
-
 // CredentialBinding includes info needed to redeem a single credential
 type CredentialBinding struct {
 	PublicKey     string `json:"publicKey" valid:"base64"`
@@ -26,17 +24,31 @@ type CredentialBinding struct {
 
 // Issuer includes information about a particular credential issuer
 type Issuer struct {
-	ID         uuid.UUID `json:"id" db:"id"`
-	CreatedAt  time.Time `json:"createdAt" db:"created_at"`
-	MerchantID string    `json:"merchantId" db:"merchant_id"`
-	PublicKey  string    `json:"publicKey" db:"public_key"`
+	ID           uuid.UUID `json:"id" db:"id"`
+	CreatedAt    time.Time `json:"createdAt" db:"created_at"`
+	MerchantID   string    `json:"merchantId" db:"merchant_id"`
+	PublicKey    string    `json:"publicKey" db:"public_key"`
+	Version      int       `json:"version" db:"version"`
+	ExpiresAt    time.Time `json:"expiresAt" db:"expires_at"`
+	MaxTokens    int       `json:"maxTokens" db:"max_tokens"`
+	TokensSigned int       `json:"tokensSigned" db:"tokens_signed"`
 }
 
 // CreateIssuer creates a new challenge bypass credential issuer, saving it's information into the datastore
 func (service *Service) CreateIssuer(ctx context.Context, merchantID string) (*Issuer, error) {
-	issuer := &Issuer{MerchantID: merchantID}
+	return service.createIssuerVersion(ctx, merchantID, 1)
+}
+
+// createIssuerVersion creates a particular version of the issuer for a merchant
+func (service *Service) createIssuerVersion(ctx context.Context, merchantID string, version int) (*Issuer, error) {
+	issuer := &Issuer{
+		MerchantID: merchantID,
+		Version:    version,
+		ExpiresAt:  time.Now().Add(issuerpolicy.Validity),
+		MaxTokens:  issuerpolicy.DefaultMaxTokens,
+	}
 
-	err := service.cbClient.CreateIssuer(ctx, issuer.Name(), defaultMaxTokensPerIssuer)
+	err := service.cbClient.CreateIssuer(ctx, issuer.Name(), issuer.MaxTokens)
 	if err != nil {
 		return nil, err
 	}
@@ -53,17 +65,56 @@ func (service *Service) CreateIssuer(ctx context.Context, merchantID string) (*I
 
 // Name returns the name of the issuer as known by the challenge bypass server
 func (issuer *Issuer) Name() string {
+	if issuer.Version > 1 {
+		return issuer.MerchantID + ":v" + strconv.Itoa(issuer.Version)
+	}
 	return issuer.MerchantID
 }
 
-// GetOrCreateIssuer gets a matching issuer if one exists and otherwise creates one
+// VersionNumber of the issuer
+func (issuer *Issuer) VersionNumber() int {
+	return issuer.Version
+}
+
+// Usage of the issuer version which the rotation policy is applied to
+func (issuer *Issuer) Usage() issuerpolicy.Usage {
+	return issuerpolicy.Usage{ExpiresAt: issuer.ExpiresAt, MaxTokens: issuer.MaxTokens, TokensSigned: issuer.TokensSigned}
+}
+
+// merchantIssuers are the versions of the issuer of a merchant
+type merchantIssuers struct {
+	ctx        context.Context
+	service    *Service
+	merchantID string
+}
+
+// Latest version of the issuer of the merchant
+func (m *merchantIssuers) Latest() (issuerpolicy.Version, error) {
+	issuer, err := m.service.datastore.GetIssuer(m.merchantID)
+	if err != nil || issuer == nil {
+		return nil, err
+	}
+	return issuer, nil
+}
+
+// Create a version of the issuer of the merchant
+func (m *merchantIssuers) Create(number int) (issuerpolicy.Version, error) {
+	return m.service.createIssuerVersion(m.ctx, m.merchantID, number)
+}
+
+// GetOrCreateIssuer gets the latest matching issuer if one exists and otherwise creates one,
+// a new version of the issuer is created when the latest is near capacity or expiry
 func (service *Service) GetOrCreateIssuer(ctx context.Context, merchantID string) (*Issuer, error) {
-	issuer, err := service.datastore.GetIssuer(merchantID)
-	if issuer == nil {
-		issuer, err = service.CreateIssuer(ctx, merchantID)
+	issuer, err := issuerpolicy.GetOrCreate(&merchantIssuers{ctx: ctx, service: service, merchantID: merchantID}, issuerMetrics)
+	if err != nil {
+		return nil, err
 	}
+	return issuer.(*Issuer), nil
+}
 
-	return issuer, err
+// observeTokensSigned updates the issuer metrics after count credentials were signed
+func observeTokensSigned(issuer Issuer, count int) {
+	issuerMetrics.ObserveTokensSigned(issuer.Name(), issuer.Usage(), count)
 }
 
 // OrderCreds encapsulates the credentials to be signed in response to a completed order
@@ -75,6 +126,8 @@ type OrderCreds struct {
 	SignedCreds  *jsonutils.JSONStringArray `json:"signedCreds" db:"signed_creds"`
 	BatchProof   *string                    `json:"batchProof" db:"batch_proof"`
 	PublicKey    *string                    `json:"publicKey" db:"public_key"`
+	// RevokedAt is set once the item the credentials were issued for has been refunded
+	RevokedAt *time.Time `json:"revokedAt" db:"revoked_at"`
 }
 
 // CreateOrderCreds if the order is complete
@@ -84,8 +137,13 @@ func (service *Service) CreateOrderCreds(ctx context.Context, orderID uuid.UUID,
 		return errorutils.Wrap(err, "error finding order")
 	}
 
-	if !order.IsPaid() {
-		return errors.New("order has not yet been paid")
+	if order == nil {
+		return ErrOrderNotFound
+	}
+
+	err = checkOrderCreds(*order, itemID, len(blindedCreds))
+	if err != nil {
+		return err
 	}
 
 	issuer, err := service.GetOrCreateIssuer(ctx, order.MerchantID)
@@ -108,6 +166,27 @@ func (service *Service) CreateOrderCreds(ctx context.Context, orderID uuid.UUID,
 	return nil
 }
 
+// checkOrderCreds returns why credentials cannot be requested for the item of the order, if they cannot
+func checkOrderCreds(order Order, itemID uuid.UUID, count int) error {
+	if !order.IsPaid() {
+		return ErrOrderNotPaid
+	}
+
+	for _, item := range order.Items {
+		if !uuid.Equal(item.ID, itemID) {
+			continue
+		}
+		if item.IsRefunded() {
+			return ErrItemRefunded
+		}
+		// partially refunded items only receive credentials for the quantity which remains
+		if item.RefundedQuantity > 0 && count > item.Quantity-item.RefundedQuantity {
+			return ErrTooManyCredentials
+		}
+	}
+	return nil
+}
+
 // OrderWorker attempts to work on an order job by signing the blinded credentials of the client
 type OrderWorker interface {
 	SignOrderCreds(ctx context.Context, orderID uuid.UUID, issuer Issuer, blindedCreds []string) (*OrderCreds, error)
@@ -121,6 +200,7 @@ func (service *Service) SignOrderCreds(ctx context.Context, orderID uuid.UUID, i
 	}
 
 	signedTokens := jsonutils.JSONStringArray(resp.SignedTokens)
+	observeTokensSigned(issuer, len(signedTokens))
 
 	creds := &OrderCreds{
 		ID:           orderID,
@@ -159,6 +239,10 @@ func generateCredentialRedemptions(ctx context.Context, cb []CredentialBinding)
 			if err != nil {
 				return nil, fmt.Errorf("error finding issuer: %w", err)
 			}
+			if issuer == nil {
+				return nil, fmt.Errorf("issuer not found or expired for public key %s", publicKey)
+			}
+			issuers[publicKey] = issuer
 		}
 
 		requestCredentials[i].Issuer = issuer.Name()
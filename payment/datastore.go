@@ -6,13 +6,21 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
 	uuid "github.com/satori/go.uuid"
 	"github.com/shopspring/decimal"
 
 	"github.com/brave-intl/bat-go/datastore/grantserver"
+	"github.com/brave-intl/bat-go/utils/clients/cbr"
+	"github.com/brave-intl/bat-go/utils/issuerpolicy"
 	"github.com/brave-intl/bat-go/utils/jsonutils"
+	kafkautils "github.com/brave-intl/bat-go/utils/kafka"
+	srv "github.com/brave-intl/bat-go/utils/service"
+	w "github.com/brave-intl/bat-go/wallet"
 	walletservice "github.com/brave-intl/bat-go/wallet/service"
 
 	// needed for magic migration
@@ -23,15 +31,29 @@ import (
 type Datastore interface {
 	walletservice.Datastore
 	// CreateOrder is used to create an order for payments
-	CreateOrder(totalPrice decimal.Decimal, merchantID string, status string, currency string, location string, orderItems []OrderItem) (*Order, error)
+	CreateOrder(totalPrice decimal.Decimal, merchantID string, status string, currency string, location string, expiresAt *time.Time, orderItems []OrderItem) (*Order, error)
 	// GetOrder by ID
 	GetOrder(orderID uuid.UUID) (*Order, error)
-	// UpdateOrder updates an order when it has been paid
+	// UpdateOrder moves an order to the status, returning ErrInvalidOrderTransition if it is not allowed from its current one
 	UpdateOrder(orderID uuid.UUID, status string) error
+	// CancelOrder cancels a pending order of the merchant which has no unfinished transactions
+	CancelOrder(merchantID string, orderID uuid.UUID) error
+	// CancelExpiredOrders cancels pending orders past their expiry which have no unfinished transactions
+	CancelExpiredOrders() (int64, error)
+	// ReserveRefund of the order items, recording it as a refund transaction which is transferred by the refund job
+	ReserveRefund(ctx context.Context, orderID uuid.UUID, refunds []OrderItemRefund) (*Transaction, error)
+	// RunNextRefundJob to transfer a reserved refund or check the status of an unfinished refund transfer
+	RunNextRefundJob(ctx context.Context, worker RefundWorker) (bool, error)
 	// CreateTransaction creates a transaction
 	CreateTransaction(orderID uuid.UUID, externalTransactionID string, status string, currency string, kind string, amount decimal.Decimal) (*Transaction, error)
+	// ReservePayment records a placeholder payment for a pending order before it is submitted
+	ReservePayment(orderID uuid.UUID, kind string) (*Transaction, error)
+	// RecordPayment replaces the placeholder of a reserved payment with the result of submitting it
+	RecordPayment(id uuid.UUID, externalTransactionID string, status string, currency string, amount decimal.Decimal) (*Transaction, error)
 	// GetTransaction returns a transaction given an external transaction id
 	GetTransaction(externalTransactionID string) (*Transaction, error)
+	// UpdateTransactionStatus of the transaction with the passed external id unless it is terminal, returning it if the status changed
+	UpdateTransactionStatus(externalTransactionID string, status string) (*Transaction, error)
 	// GetTransactions returns all the transactions for a specific order
 	GetTransactions(orderID uuid.UUID) (*[]Transaction, error)
 	// GetSumForTransactions gets a decimal sum of for transactions for an order
@@ -42,7 +64,39 @@ type Datastore interface {
 	GetIssuer(merchantID string) (*Issuer, error)
 	// GetIssuerByPublicKey
 	GetIssuerByPublicKey(publicKey string) (*Issuer, error)
-	// InsertOrderCreds
+	// CreateKey stores an encrypted root key of the merchant
+	CreateKey(merchantID string, name string, encryptedSecretKey string, nonce string) (*Key, error)
+	// GetKeys returns the root keys of the merchant, only those which have not expired unless showExpired is set
+	GetKeys(merchantID string, showExpired bool) ([]Key, error)
+	// ExpireKey of the merchant so SKU tokens signed with it are rejected, returning false if there is no such key
+	ExpireKey(merchantID string, keyID uuid.UUID) (bool, error)
+	// CreateSKU adds a SKU to the catalog of its merchant
+	CreateSKU(sku *SKU) (*SKU, error)
+	// GetSKU by the merchant and SKU code, nil if the merchant has no such SKU
+	GetSKU(merchantID string, sku string) (*SKU, error)
+	// GetSKUs returns the catalog of the merchant, only active SKUs unless showInactive is set
+	GetSKUs(merchantID string, showInactive bool) ([]SKU, error)
+	// RevokeSKU of the merchant so orders are no longer created for it, returning false if there is no such SKU
+	RevokeSKU(merchantID string, skuID uuid.UUID) (bool, error)
+	// CreateMerchant along with its allowed locations
+	CreateMerchant(merchant *Merchant) (*Merchant, error)
+	// GetMerchant by id, nil if there is no such merchant
+	GetMerchant(merchantID string) (*Merchant, error)
+	// GetMerchants returns all merchants
+	GetMerchants() ([]Merchant, error)
+	// GetMerchantByLocation returns the merchant SKU tokens of the location are issued for, nil if there is none
+	GetMerchantByLocation(location string) (*Merchant, error)
+	// GetMerchantByAPIKeyHash returns the merchant with the API key, nil if there is none
+	GetMerchantByAPIKeyHash(apiKeyHash string) (*Merchant, error)
+	// UpdateMerchant name, payout settings and allowed locations
+	UpdateMerchant(merchant *Merchant) (*Merchant, error)
+	// SetMerchantAPIKeyHash replaces the API key of the merchant, returning false if there is no such merchant
+	SetMerchantAPIKeyHash(merchantID string, apiKeyHash string) (bool, error)
+	// GetMerchantOrders returns a page of the orders of the merchant, newest first
+	GetMerchantOrders(merchantID string, limit int, offset int) ([]Order, error)
+	// GetMerchantTransactions returns a page of the transactions for orders of the merchant, newest first
+	GetMerchantTransactions(merchantID string, limit int, offset int) ([]Transaction, error)
+	// InsertOrderCreds of an item if they may still be requested, locking the order against a concurrent refund
 	InsertOrderCreds(creds *OrderCreds) error
 	// GetOrderCreds
 	GetOrderCreds(orderID uuid.UUID, isSigned bool) (*[]OrderCreds, error)
@@ -50,11 +104,23 @@ type Datastore interface {
 	GetOrderCredsByItemID(orderID uuid.UUID, itemID uuid.UUID) (*OrderCreds, error)
 	// RunNextOrderJob
 	RunNextOrderJob(ctx context.Context, worker OrderWorker) (bool, error)
+	// RunNextTransactionReconcileJob to check the status of a transaction which has not yet completed
+	RunNextTransactionReconcileJob(ctx context.Context, worker TransactionReconciler) (bool, error)
+	// CountStuckTransactions returns the number of transactions which have not completed after the passed age
+	CountStuckTransactions(age time.Duration) (int, error)
 
 	// Votes
 	GetUncommittedVotesForUpdate(ctx context.Context) (*sqlx.Tx, []*VoteRecord, error)
-	CommitVote(ctx context.Context, vr VoteRecord, tx *sqlx.Tx) error
-	MarkVoteErrored(ctx context.Context, vr VoteRecord, tx *sqlx.Tx) error
+	CommitVote(ctx context.Context, vr VoteRecord, tx *sqlx.Tx, event grantserver.OutboxEvent) error
+	MarkVoteErrored(ctx context.Context, vr VoteRecord, tx *sqlx.Tx, cause error) error
+	// ClaimCredentialRedemptions records that the vote is redeeming the credentials, returning true if it already had
+	ClaimCredentialRedemptions(ctx context.Context, owner uuid.UUID, credentials []cbr.CredentialRedemption) (bool, error)
+	// ReleaseCredentialRedemptions removes the claims of the vote on credentials which were redeemed elsewhere
+	ReleaseCredentialRedemptions(ctx context.Context, owner uuid.UUID) error
+	// RunNextOutboxJob publishes the next batch of events of the topic queued in the outbox
+	RunNextOutboxJob(ctx context.Context, topic string, publisher kafkautils.EventPublisher) (bool, error)
+	// DeletePublishedOutboxEvents deletes the next batch of events of the topic published before the passed time
+	DeletePublishedOutboxEvents(ctx context.Context, topic string, before time.Time) (bool, error)
 	InsertVote(ctx context.Context, vr VoteRecord) error
 }
 
@@ -66,6 +132,7 @@ type VoteRecord struct {
 	VoteEventBinary    []byte
 	Erred              bool
 	Processed          bool
+	Attempts           int
 }
 
 // Postgres is a Datastore wrapper around a postgres database
@@ -83,16 +150,16 @@ func NewPostgres(databaseURL string, performMigration bool, dbStatsPrefix ...str
 }
 
 // CreateOrder creates orders given the total price, merchant ID, status and items of the order
-func (pg *Postgres) CreateOrder(totalPrice decimal.Decimal, merchantID string, status string, currency string, location string, orderItems []OrderItem) (*Order, error) {
+func (pg *Postgres) CreateOrder(totalPrice decimal.Decimal, merchantID string, status string, currency string, location string, expiresAt *time.Time, orderItems []OrderItem) (*Order, error) {
 	tx := pg.DB.MustBegin()
 
 	var order Order
 	err := tx.Get(&order, `
-			INSERT INTO orders (total_price, merchant_id, status, currency, location)
-			VALUES ($1, $2, $3, $4, $5)
+			INSERT INTO orders (total_price, merchant_id, status, currency, location, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
 			RETURNING *
 		`,
-		totalPrice, merchantID, status, currency, location)
+		totalPrice, merchantID, status, currency, location, expiresAt)
 
 	if err != nil {
 		return nil, err
@@ -173,20 +240,417 @@ func (pg *Postgres) GetTransaction(externalTransactionID string) (*Transaction,
 	return &transaction, nil
 }
 
-// UpdateOrder updates the orders status.
-// 	Status should either be one of pending, paid, fulfilled, or canceled.
+// UpdateTransactionStatus of the transaction with the passed external id, returning it if the status changed
+// Transactions which have reached a terminal status are never changed, so late or replayed notifications are ignored.
+// Refunds are left to the refund job, which settles the order items along with the status.
+func (pg *Postgres) UpdateTransactionStatus(externalTransactionID string, status string) (*Transaction, error) {
+	statement := `
+update transactions
+set status = $2, status_checked_at = current_timestamp, updated_at = current_timestamp
+where external_transaction_id = $1 and status <> $2 and status not in ('completed', 'failed', 'cancelled')
+	and kind <> 'refund'
+returning *`
+	transaction := Transaction{}
+	err := pg.DB.Get(&transaction, statement, externalTransactionID, status)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &transaction, nil
+}
+
+// UpdateOrder moves the order to the status if the transition is allowed from its current status
 func (pg *Postgres) UpdateOrder(orderID uuid.UUID, status string) error {
-	result, err := pg.DB.Exec(`UPDATE orders set status = $1, updated_at = CURRENT_TIMESTAMP where id = $2`, status, orderID)
+	return transitionOrder(pg.DB, orderID, status)
+}
+
+// transitionOrder moves the order to the status only if it currently has one the status may be reached from
+func transitionOrder(db sqlx.Queryer, orderID uuid.UUID, status string) error {
+	statement := `
+update orders
+set status = $1, updated_at = current_timestamp
+where id = $2 and status = any($3)
+returning status`
+
+	var updated string
+	err := sqlx.Get(db, &updated, statement, status, orderID, pq.StringArray(orderStatusesBefore(status)))
+	if err == nil {
+		return nil
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+
+	var current string
+	err = sqlx.Get(db, &current, `select status from orders where id = $1`, orderID)
+	if err == sql.ErrNoRows {
+		return ErrOrderNotFound
+	} else if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: from %s to %s", ErrInvalidOrderTransition, current, status)
+}
+
+// CancelOrder cancels a pending order of the merchant. The order is locked so that no transaction can be recorded
+// for it meanwhile, orders with transactions which have not reached a terminal status cannot be canceled.
+func (pg *Postgres) CancelOrder(merchantID string, orderID uuid.UUID) error {
+	tx, err := pg.DB.Beginx()
+	if err != nil {
+		return err
+	}
+	defer pg.RollbackTx(tx)
+
+	var order Order
+	err = tx.Get(&order, `select * from orders where id = $1 and merchant_id = $2 for update`, orderID, merchantID)
+	if err == sql.ErrNoRows {
+		return ErrOrderNotFound
+	} else if err != nil {
+		return err
+	}
+
+	var pending bool
+	err = tx.Get(&pending, `
+select exists (
+	select 1 from transactions
+	where order_id = $1 and status not in ('completed', 'failed', 'cancelled')
+)`, orderID)
+	if err != nil {
+		return err
+	}
+	if pending {
+		return ErrOrderHasPendingTransactions
+	}
 
+	err = transitionOrder(tx, orderID, OrderStatusCanceled)
 	if err != nil {
 		return err
 	}
+	return tx.Commit()
+}
 
-	rowsAffected, err := result.RowsAffected()
-	if rowsAffected == 0 || err != nil {
-		return errors.New("No rows updated")
+// CancelExpiredOrders cancels pending orders past their expiry, orders with transactions which have not reached
+// a terminal status are left for reconciliation to settle
+func (pg *Postgres) CancelExpiredOrders() (int64, error) {
+	statement := `
+update orders
+set status = 'canceled', updated_at = current_timestamp
+where status = 'pending'
+	and expires_at < current_timestamp
+	and not exists (
+		select 1 from transactions
+		where transactions.order_id = orders.id and transactions.status not in ('completed', 'failed', 'cancelled')
+	)`
+
+	result, err := pg.DB.Exec(statement)
+	if err != nil {
+		return 0, err
 	}
+	return result.RowsAffected()
+}
 
+// ReserveRefund locks the order so items cannot be refunded twice, then records the refund transaction with the
+// refunded quantities reserved and revokes the credentials of items which are now fully refunded. Nothing is
+// transferred until the reservation is committed, the refund job then transfers it using its id as the
+// idempotency key.
+func (pg *Postgres) ReserveRefund(ctx context.Context, orderID uuid.UUID, refunds []OrderItemRefund) (*Transaction, error) {
+	tx, err := pg.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer pg.RollbackTx(tx)
+
+	var order Order
+	err = tx.Get(&order, `select * from orders where id = $1 for update`, orderID)
+	if err == sql.ErrNoRows {
+		return nil, ErrOrderNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if !order.CanTransitionTo(OrderStatusRefunded) {
+		return nil, ErrOrderNotRefundable
+	}
+
+	// refunds never exceed the completed payments, which for canceled orders are those settled after they were canceled
+	var payments struct {
+		Paid     decimal.Decimal `db:"paid"`
+		Refunded decimal.Decimal `db:"refunded"`
+	}
+	err = tx.Get(&payments, `
+		select
+			coalesce(sum(amount) filter (where kind <> $2 and status = 'completed'), 0) as paid,
+			coalesce(sum(amount) filter (where kind = $2 and status not in ('failed', 'cancelled')), 0) as refunded
+		from transactions
+		where order_id = $1`, orderID, TransactionKindRefund)
+	if err != nil {
+		return nil, err
+	}
+	if !payments.Paid.IsPositive() {
+		return nil, ErrOrderNotRefundable
+	}
+
+	err = tx.Select(&order.Items, `select * from order_items where order_id = $1`, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	var credentialedItems []uuid.UUID
+	err = tx.Select(&credentialedItems, `select item_id from order_creds where order_id = $1`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	credentialed := map[string]bool{}
+	for _, itemID := range credentialedItems {
+		credentialed[itemID.String()] = true
+	}
+
+	amount, err := refundAmount(order.Items, refunds, credentialed)
+	if err != nil {
+		return nil, err
+	}
+	if payments.Refunded.Add(amount).GreaterThan(payments.Paid) {
+		return nil, fmt.Errorf("%w: only %s of the %s paid remains to be refunded", ErrRefundExceedsPayments,
+			payments.Paid.Sub(payments.Refunded), payments.Paid)
+	}
+
+	// the external id is a placeholder until the transfer is submitted
+	id := uuid.NewV4()
+	var transaction Transaction
+	err = tx.Get(&transaction, `
+		insert into transactions (id, order_id, external_transaction_id, status, currency, kind, amount)
+		values ($1, $2, $3, $4, $5, $6, $7)
+		returning *`,
+		id, orderID, "refund:"+id.String(), TransactionStatusReserved, order.Currency, TransactionKindRefund, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, refund := range refunds {
+		_, err = tx.Exec(`
+			insert into order_item_refunds (transaction_id, item_id, quantity)
+			values ($1, $2, $3)`, transaction.ID, refund.ItemID, refund.Quantity)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Exec(`
+			update order_items
+			set refunded_quantity = refunded_quantity + $1, updated_at = current_timestamp
+			where id = $2`, refund.Quantity, refund.ItemID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = revokeRefundedCreds(tx, transaction.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// revokeRefundedCreds revokes the credentials of the items of the refund which are fully refunded
+func revokeRefundedCreds(tx *sqlx.Tx, refundID uuid.UUID) error {
+	_, err := tx.Exec(`
+		update order_creds
+		set revoked_at = current_timestamp
+		from order_item_refunds, order_items
+		where order_item_refunds.transaction_id = $1 and order_items.id = order_item_refunds.item_id
+			and order_creds.item_id = order_items.id and order_creds.order_id = order_items.order_id
+			and order_items.refunded_quantity >= order_items.quantity and order_creds.revoked_at is null`, refundID)
+	return err
+}
+
+// RunNextRefundJob to transfer a reserved refund or check the status of an unfinished refund transfer. Like the
+// drain job the transfer is submitted and its transaction id persisted before it is confirmed, a retried refund
+// resumes the existing transfer instead of creating a new one.
+func (pg *Postgres) RunNextRefundJob(ctx context.Context, worker RefundWorker) (bool, error) {
+	tx, err := pg.DB.Beginx()
+	attempted := false
+	if err != nil {
+		return attempted, err
+	}
+	defer pg.RollbackTx(tx)
+
+	statement := `
+select *
+from transactions
+where kind = $1 and status not in ('completed', 'failed', 'cancelled')
+	and (status_checked_at is null or status_checked_at < current_timestamp - $2 * interval '1 second')
+order by status_checked_at asc nulls first
+for update skip locked
+limit 1`
+
+	refunds := []Transaction{}
+	err = tx.Select(&refunds, statement, TransactionKindRefund, reconcileInterval.Seconds())
+	if err != nil {
+		return attempted, err
+	}
+
+	if len(refunds) != 1 {
+		return attempted, nil
+	}
+
+	refund := refunds[0]
+	attempted = true
+
+	if refund.Status == TransactionStatusReserved {
+		txInfo, err := worker.SubmitRefund(ctx, &refund)
+		if err == nil && txInfo == nil {
+			err = errors.New("refund transfer was not submitted")
+		}
+		if err != nil {
+			return attempted, pg.deferRefund(tx, refund, err)
+		}
+
+		_, err = tx.Exec(`
+			update transactions
+			set external_transaction_id = $2, status = $3, updated_at = current_timestamp
+			where id = $1`, refund.ID, txInfo.ID, TransactionStatusSubmitted)
+		if err != nil {
+			return attempted, err
+		}
+
+		// persist the submitted transaction before moving any funds
+		err = tx.Commit()
+		if err != nil {
+			return attempted, err
+		}
+
+		tx, err = pg.DB.Beginx()
+		if err != nil {
+			return attempted, err
+		}
+		defer pg.RollbackTx(tx)
+
+		refunds = []Transaction{}
+		err = tx.Select(&refunds, `select * from transactions where id = $1 and status = $2 for update skip locked`,
+			refund.ID, TransactionStatusSubmitted)
+		if err != nil {
+			return attempted, err
+		}
+		if len(refunds) != 1 {
+			// another worker has picked up the refund in the meantime
+			return attempted, nil
+		}
+		refund = refunds[0]
+	}
+
+	txInfo, err := worker.ConfirmRefund(ctx, refund.ExternalTransactionID)
+	if errors.Is(err, errRefundTransferExpired) {
+		// funds were never moved, return to the reservation so the next attempt resubmits
+		_, err = tx.Exec(`
+			update transactions
+			set external_transaction_id = $2, status = $3, updated_at = current_timestamp
+			where id = $1`, refund.ID, "refund:"+refund.ID.String(), TransactionStatusReserved)
+		if err != nil {
+			return attempted, err
+		}
+		return attempted, tx.Commit()
+	} else if err != nil {
+		return attempted, pg.deferRefund(tx, refund, err)
+	}
+
+	err = settleRefund(tx, refund, txInfo.Status)
+	if err != nil {
+		return attempted, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return attempted, err
+	}
+
+	if txInfo.Status != refund.Status {
+		countTransactionsReconciled.With(prometheus.Labels{"status": txInfo.Status}).Inc()
+	}
+	return attempted, nil
+}
+
+// deferRefund until the next interval after a failed attempt, failing it if retrying cannot succeed
+func (pg *Postgres) deferRefund(tx *sqlx.Tx, refund Transaction, cause error) error {
+	if srv.IsTerminal(cause) {
+		err := settleRefund(tx, refund, w.TransactionFailed)
+		if err != nil {
+			return err
+		}
+	} else {
+		_, err := tx.Exec(`update transactions set status_checked_at = current_timestamp where id = $1`, refund.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := tx.Commit()
+	if err != nil {
+		return err
+	}
+	return cause
+}
+
+// settleRefund records the latest status of the refund transfer. A completed refund marks the order refunded once
+// every item is, a failed refund releases its reserved quantities and restores the credentials it revoked.
+func settleRefund(tx *sqlx.Tx, refund Transaction, status string) error {
+	_, err := tx.Exec(`
+		update transactions
+		set status = $2, status_checked_at = current_timestamp, updated_at = current_timestamp
+		where id = $1`, refund.ID, status)
+	if err != nil {
+		return err
+	}
+
+	switch status {
+	case w.TransactionCompleted:
+		var order Order
+		err = tx.Get(&order, `select * from orders where id = $1 for update`, refund.OrderID)
+		if err != nil {
+			return err
+		}
+		err = tx.Select(&order.Items, `select * from order_items where order_id = $1`, refund.OrderID)
+		if err != nil {
+			return err
+		}
+
+		refunded := order.CanTransitionTo(OrderStatusRefunded)
+		for _, item := range order.Items {
+			refunded = refunded && item.IsRefunded()
+		}
+		// refunds reserved for the remaining items may still fail
+		var pending bool
+		err = tx.Get(&pending, `
+			select exists (
+				select 1 from transactions
+				where order_id = $1 and kind = $2 and id <> $3 and status not in ('completed', 'failed', 'cancelled')
+			)`, refund.OrderID, TransactionKindRefund, refund.ID)
+		if err != nil {
+			return err
+		}
+		if refunded && !pending {
+			return transitionOrder(tx, refund.OrderID, OrderStatusRefunded)
+		}
+	case w.TransactionFailed, w.TransactionCancelled:
+		_, err = tx.Exec(`
+			update order_creds
+			set revoked_at = null
+			from order_item_refunds
+			where order_item_refunds.transaction_id = $1 and order_creds.item_id = order_item_refunds.item_id
+				and order_creds.order_id = $2`, refund.ID, refund.OrderID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			update order_items
+			set refunded_quantity = order_items.refunded_quantity - order_item_refunds.quantity,
+				updated_at = current_timestamp
+			from order_item_refunds
+			where order_item_refunds.transaction_id = $1 and order_items.id = order_item_refunds.item_id`, refund.ID)
+		return err
+	}
 	return nil
 }
 
@@ -216,6 +680,64 @@ func (pg *Postgres) CreateTransaction(orderID uuid.UUID, externalTransactionID s
 	return &transaction, nil
 }
 
+// ReservePayment locks the pending order and records a placeholder payment of the kind for it, so the order is not
+// canceled while the payment is submitted. The external id is a placeholder until RecordPayment is called with the
+// result of the submission, reconciliation fails placeholders which are never recorded.
+func (pg *Postgres) ReservePayment(orderID uuid.UUID, kind string) (*Transaction, error) {
+	tx, err := pg.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer pg.RollbackTx(tx)
+
+	var order Order
+	err = tx.Get(&order, `select * from orders where id = $1 for update`, orderID)
+	if err == sql.ErrNoRows {
+		return nil, ErrOrderNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if order.Status != OrderStatusPending {
+		return nil, ErrOrderNotPending
+	}
+	if order.IsExpired() {
+		return nil, ErrOrderExpired
+	}
+
+	id := uuid.NewV4()
+	var transaction Transaction
+	err = tx.Get(&transaction, `
+		insert into transactions (id, order_id, external_transaction_id, status, currency, kind, amount)
+		values ($1, $2, $3, $4, $5, $6, $7)
+		returning *`,
+		id, orderID, kind+":"+id.String(), TransactionStatusReserved, order.Currency, kind, decimal.Zero)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// RecordPayment of a reserved payment once it has been submitted. The result replaces the placeholder even if
+// reconciliation has already failed it, a payment which settled must be recorded so it can be refunded.
+func (pg *Postgres) RecordPayment(id uuid.UUID, externalTransactionID string, status string, currency string, amount decimal.Decimal) (*Transaction, error) {
+	statement := `
+update transactions
+set external_transaction_id = $2, status = $3, currency = $4, amount = $5, updated_at = current_timestamp
+where id = $1
+returning *`
+	var transaction Transaction
+	err := pg.DB.Get(&transaction, statement, id, externalTransactionID, status, currency, amount)
+	if err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
 // GetSumForTransactions returns the calculated sum
 func (pg *Postgres) GetSumForTransactions(orderID uuid.UUID) (decimal.Decimal, error) {
 	var sum decimal.Decimal
@@ -223,7 +745,7 @@ func (pg *Postgres) GetSumForTransactions(orderID uuid.UUID) (decimal.Decimal, e
 	err := pg.DB.Get(&sum, `
 		SELECT SUM(amount) as sum
 		FROM transactions
-		WHERE order_id = $1 AND status = 'completed'
+		WHERE order_id = $1 AND status = 'completed' AND kind <> 'refund'
 	`, orderID)
 
 	return sum, err
@@ -231,12 +753,25 @@ func (pg *Postgres) GetSumForTransactions(orderID uuid.UUID) (decimal.Decimal, e
 
 // InsertIssuer inserts the given issuer
 func (pg *Postgres) InsertIssuer(issuer *Issuer) (*Issuer, error) {
+	version := issuer.Version
+	if version == 0 {
+		version = 1
+	}
+	expiresAt := issuer.ExpiresAt
+	if expiresAt.IsZero() {
+		expiresAt = time.Now().Add(issuerpolicy.Validity)
+	}
+	maxTokens := issuer.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = issuerpolicy.DefaultMaxTokens
+	}
+
 	statement := `
-	insert into order_cred_issuers (merchant_id, public_key)
-	values ($1, $2)
+	insert into order_cred_issuers (merchant_id, public_key, version, expires_at, max_tokens)
+	values ($1, $2, $3, $4, $5)
 	returning *`
 	var issuers []Issuer
-	err := pg.DB.Select(&issuers, statement, issuer.MerchantID, issuer.PublicKey)
+	err := pg.DB.Select(&issuers, statement, issuer.MerchantID, issuer.PublicKey, version, expiresAt, maxTokens)
 	if err != nil {
 		return nil, err
 	}
@@ -248,23 +783,25 @@ func (pg *Postgres) InsertIssuer(issuer *Issuer) (*Issuer, error) {
 	return &issuers[0], nil
 }
 
-// GetIssuer retrieves the given issuer
+// GetIssuer retrieves the latest version of the given issuer
 func (pg *Postgres) GetIssuer(merchantID string) (*Issuer, error) {
-	statement := "select * from order_cred_issuers where merchant_id = $1"
+	statement := "select * from order_cred_issuers where merchant_id = $1 order by version desc limit 1"
 	var issuer Issuer
 	err := pg.DB.Get(&issuer, statement, merchantID)
-	if err != nil {
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
 		return nil, err
 	}
 
 	return &issuer, nil
 }
 
-// GetIssuerByPublicKey or return an error
+// GetIssuerByPublicKey or return an error, issuers which expired before the redemption grace period are not returned
 func (pg *Postgres) GetIssuerByPublicKey(publicKey string) (*Issuer, error) {
-	statement := "select * from order_cred_issuers where public_key = $1"
+	statement := "select * from order_cred_issuers where public_key = $1 and expires_at > $2"
 	var issuer Issuer
-	err := pg.DB.Get(&issuer, statement, publicKey)
+	err := pg.DB.Get(&issuer, statement, publicKey, time.Now().Add(-issuerpolicy.RedemptionGracePeriod))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	} else if err != nil {
@@ -274,18 +811,385 @@ func (pg *Postgres) GetIssuerByPublicKey(publicKey string) (*Issuer, error) {
 	return &issuer, nil
 }
 
-// InsertOrderCreds inserts the given order creds
+// CreateKey stores an encrypted root key of the merchant
+func (pg *Postgres) CreateKey(merchantID string, name string, encryptedSecretKey string, nonce string) (*Key, error) {
+	statement := `
+	insert into merchant_keys (merchant_id, name, encrypted_secret_key, nonce)
+	values ($1, $2, $3, $4)
+	returning *`
+	var keys []Key
+	err := pg.DB.Select(&keys, statement, merchantID, name, encryptedSecretKey, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keys) != 1 {
+		return nil, errors.New("Unexpected number of keys returned")
+	}
+
+	return &keys[0], nil
+}
+
+// GetKeys returns the root keys of the merchant, only those which have not expired unless showExpired is set
+func (pg *Postgres) GetKeys(merchantID string, showExpired bool) ([]Key, error) {
+	statement := `
+	select * from merchant_keys
+	where merchant_id = $1 and ($2 or expiry is null or expiry > now())
+	order by created_at`
+	keys := []Key{}
+	err := pg.DB.Select(&keys, statement, merchantID, showExpired)
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// ExpireKey of the merchant so SKU tokens signed with it are rejected, returning false if there is no such key
+func (pg *Postgres) ExpireKey(merchantID string, keyID uuid.UUID) (bool, error) {
+	statement := `
+	update merchant_keys
+	set expiry = now()
+	where id = $1 and merchant_id = $2 and (expiry is null or expiry > now())`
+	result, err := pg.DB.Exec(statement, keyID, merchantID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// CreateSKU adds a SKU to the catalog of its merchant
+func (pg *Postgres) CreateSKU(sku *SKU) (*SKU, error) {
+	statement := `
+	insert into skus (merchant_id, sku, location, price, currency, description, expires_at, key_id, token)
+	values ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	returning *`
+	var skus []SKU
+	err := pg.DB.Select(&skus, statement, sku.MerchantID, sku.SKU, sku.Location, sku.Price, sku.Currency,
+		sku.Description, sku.ExpiresAt, sku.KeyID, sku.Token)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			return nil, ErrSKUExists
+		}
+		return nil, err
+	}
+
+	if len(skus) != 1 {
+		return nil, errors.New("Unexpected number of skus returned")
+	}
+
+	return &skus[0], nil
+}
+
+// GetSKU by the merchant and SKU code, nil if the merchant has no such SKU
+func (pg *Postgres) GetSKU(merchantID string, sku string) (*SKU, error) {
+	statement := "select * from skus where merchant_id = $1 and sku = $2"
+	var result SKU
+	err := pg.DB.Get(&result, statement, merchantID, sku)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetSKUs returns the catalog of the merchant, only active SKUs unless showInactive is set
+func (pg *Postgres) GetSKUs(merchantID string, showInactive bool) ([]SKU, error) {
+	statement := `
+	select * from skus
+	where merchant_id = $1 and ($2 or active)
+	order by created_at`
+	skus := []SKU{}
+	err := pg.DB.Select(&skus, statement, merchantID, showInactive)
+	if err != nil {
+		return nil, err
+	}
+
+	return skus, nil
+}
+
+// RevokeSKU of the merchant so orders are no longer created for it, returning false if there is no such SKU
+func (pg *Postgres) RevokeSKU(merchantID string, skuID uuid.UUID) (bool, error) {
+	statement := `
+	update skus
+	set active = false, updated_at = current_timestamp
+	where id = $1 and merchant_id = $2 and active`
+	result, err := pg.DB.Exec(statement, skuID, merchantID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// merchantStatement selects merchants along with their allowed locations
+const merchantStatement = `
+	select merchants.*,
+		array(
+			select location from merchant_locations
+			where merchant_locations.merchant_id = merchants.id
+			order by location
+		) as locations
+	from merchants`
+
+// insertMerchantLocations replaces the allowed locations of the merchant within the transaction
+func insertMerchantLocations(tx *sqlx.Tx, merchantID string, locations []string) error {
+	_, err := tx.Exec(`delete from merchant_locations where merchant_id = $1`, merchantID)
+	if err != nil {
+		return err
+	}
+	for _, location := range locations {
+		_, err = tx.Exec(`insert into merchant_locations (location, merchant_id) values ($1, $2)`, location, merchantID)
+		if err != nil {
+			if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+				return ErrLocationTaken
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateMerchant along with its allowed locations
+func (pg *Postgres) CreateMerchant(merchant *Merchant) (*Merchant, error) {
+	tx, err := pg.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer pg.RollbackTx(tx)
+
+	statement := `
+	insert into merchants (id, name, api_key_hash, payout_provider, payout_address)
+	values ($1, $2, $3, $4, $5)`
+	_, err = tx.Exec(statement, merchant.ID, merchant.Name, merchant.APIKeyHash, merchant.PayoutProvider, merchant.PayoutAddress)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			return nil, ErrMerchantExists
+		}
+		return nil, err
+	}
+
+	err = insertMerchantLocations(tx, merchant.ID, merchant.Locations)
+	if err != nil {
+		return nil, err
+	}
+
+	var created Merchant
+	err = tx.Get(&created, merchantStatement+" where id = $1", merchant.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// getMerchant by the filter, nil if there is no such merchant
+func (pg *Postgres) getMerchant(filter string, args ...interface{}) (*Merchant, error) {
+	var merchant Merchant
+	err := pg.DB.Get(&merchant, merchantStatement+" where "+filter, args...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &merchant, nil
+}
+
+// GetMerchant by id, nil if there is no such merchant
+func (pg *Postgres) GetMerchant(merchantID string) (*Merchant, error) {
+	return pg.getMerchant("id = $1", merchantID)
+}
+
+// GetMerchantByLocation returns the merchant SKU tokens of the location are issued for, nil if there is none
+func (pg *Postgres) GetMerchantByLocation(location string) (*Merchant, error) {
+	return pg.getMerchant("id = (select merchant_id from merchant_locations where location = $1)", location)
+}
+
+// GetMerchantByAPIKeyHash returns the merchant with the API key, nil if there is none
+func (pg *Postgres) GetMerchantByAPIKeyHash(apiKeyHash string) (*Merchant, error) {
+	return pg.getMerchant("api_key_hash = $1", apiKeyHash)
+}
+
+// GetMerchants returns all merchants
+func (pg *Postgres) GetMerchants() ([]Merchant, error) {
+	merchants := []Merchant{}
+	err := pg.DB.Select(&merchants, merchantStatement+" order by id")
+	if err != nil {
+		return nil, err
+	}
+
+	return merchants, nil
+}
+
+// UpdateMerchant name, payout settings and allowed locations
+func (pg *Postgres) UpdateMerchant(merchant *Merchant) (*Merchant, error) {
+	tx, err := pg.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer pg.RollbackTx(tx)
+
+	statement := `
+	update merchants
+	set name = $2, payout_provider = $3, payout_address = $4, updated_at = current_timestamp
+	where id = $1`
+	result, err := tx.Exec(statement, merchant.ID, merchant.Name, merchant.PayoutProvider, merchant.PayoutAddress)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rows != 1 {
+		return nil, ErrMerchantNotFound
+	}
+
+	err = insertMerchantLocations(tx, merchant.ID, merchant.Locations)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated Merchant
+	err = tx.Get(&updated, merchantStatement+" where id = $1", merchant.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// SetMerchantAPIKeyHash replaces the API key of the merchant, returning false if there is no such merchant
+func (pg *Postgres) SetMerchantAPIKeyHash(merchantID string, apiKeyHash string) (bool, error) {
+	statement := `
+	update merchants
+	set api_key_hash = $2, updated_at = current_timestamp
+	where id = $1`
+	result, err := pg.DB.Exec(statement, merchantID, apiKeyHash)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// GetMerchantOrders returns a page of the orders of the merchant, newest first
+func (pg *Postgres) GetMerchantOrders(merchantID string, limit int, offset int) ([]Order, error) {
+	statement := `
+	select * from orders
+	where merchant_id = $1
+	order by created_at desc, id
+	limit $2 offset $3`
+	orders := []Order{}
+	err := pg.DB.Select(&orders, statement, merchantID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return orders, nil
+	}
+
+	orderIDs := []string{}
+	for _, order := range orders {
+		orderIDs = append(orderIDs, order.ID.String())
+	}
+	orderItems := []OrderItem{}
+	err = pg.DB.Select(&orderItems, "select * from order_items where order_id = any($1::uuid[])", pq.Array(orderIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range orders {
+		orders[i].Items = []OrderItem{}
+		for _, orderItem := range orderItems {
+			if uuid.Equal(orderItem.OrderID, orders[i].ID) {
+				orders[i].Items = append(orders[i].Items, orderItem)
+			}
+		}
+	}
+	return orders, nil
+}
+
+// GetMerchantTransactions returns a page of the transactions for orders of the merchant, newest first
+func (pg *Postgres) GetMerchantTransactions(merchantID string, limit int, offset int) ([]Transaction, error) {
+	statement := `
+	select transactions.* from transactions
+	join orders on orders.id = transactions.order_id
+	where orders.merchant_id = $1
+	order by transactions.created_at desc, transactions.id
+	limit $2 offset $3`
+	transactions := []Transaction{}
+	err := pg.DB.Select(&transactions, statement, merchantID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return transactions, nil
+}
+
+// InsertOrderCreds inserts the given order creds. The order is locked and the item checked again as in ReserveRefund,
+// so a refund either sees the credentials and refunds the item in full or is reserved before they are requested.
 func (pg *Postgres) InsertOrderCreds(creds *OrderCreds) error {
 	blindedCredsJSON, err := json.Marshal(creds.BlindedCreds)
 	if err != nil {
 		return err
 	}
 
+	tx, err := pg.DB.Beginx()
+	if err != nil {
+		return err
+	}
+	defer pg.RollbackTx(tx)
+
+	var order Order
+	err = tx.Get(&order, `select * from orders where id = $1 for update`, creds.OrderID)
+	if err == sql.ErrNoRows {
+		return ErrOrderNotFound
+	} else if err != nil {
+		return err
+	}
+	err = tx.Select(&order.Items, `select * from order_items where order_id = $1`, creds.OrderID)
+	if err != nil {
+		return err
+	}
+
+	err = checkOrderCreds(order, creds.ID, len(creds.BlindedCreds))
+	if err != nil {
+		return err
+	}
+
 	statement := `
 	insert into order_creds (item_id, order_id, issuer_id, blinded_creds)
 	values ($1, $2, $3, $4)`
-	_, err = pg.DB.Exec(statement, creds.ID, creds.OrderID, creds.IssuerID, blindedCredsJSON)
-	return err
+	_, err = tx.Exec(statement, creds.ID, creds.OrderID, creds.IssuerID, blindedCredsJSON)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // GetOrderCreds returns the order credentials for a OrderID
@@ -326,7 +1230,7 @@ func (pg *Postgres) GetOrderCredsByItemID(orderID uuid.UUID, itemID uuid.UUID) (
 // returns a transaction to commit, the vote records, and an error
 func (pg *Postgres) GetUncommittedVotesForUpdate(ctx context.Context) (*sqlx.Tx, []*VoteRecord, error) {
 	var (
-		results = make([]*VoteRecord, 100)
+		results = make([]*VoteRecord, 0, 100)
 		tx, err = pg.DB.Beginx()
 	)
 
@@ -336,14 +1240,15 @@ func (pg *Postgres) GetUncommittedVotesForUpdate(ctx context.Context) (*sqlx.Tx,
 
 	statement := `
 select
-	id, credentials, vote_text, vote_event, erred, processed
+	id, credentials, vote_text, vote_event, erred, processed, attempts
 from
 	vote_drain
 where
 	processed = false AND
-	erred = false
+	erred = false AND
+	next_attempt_at <= current_timestamp
 limit 100
-FOR UPDATE
+FOR UPDATE SKIP LOCKED
 `
 	rows, err := tx.QueryContext(ctx, statement)
 	if err != nil {
@@ -353,7 +1258,7 @@ FOR UPDATE
 	for rows.Next() {
 		var vr = new(VoteRecord)
 		if err := rows.Scan(&vr.ID, &vr.RequestCredentials, &vr.VoteText,
-			&vr.VoteEventBinary, &vr.Erred, &vr.Processed); err != nil {
+			&vr.VoteEventBinary, &vr.Erred, &vr.Processed, &vr.Attempts); err != nil {
 			return tx, nil, fmt.Errorf("failed to scan vote drain record: %w", err)
 		}
 		// add to results
@@ -370,24 +1275,27 @@ FOR UPDATE
 	return tx, results, err
 }
 
-// MarkVoteErrored - Update a vote to show it has errored, designed to run on a transaction so
-// a batch number of votes can be processed.
-func (pg *Postgres) MarkVoteErrored(ctx context.Context, vr VoteRecord, tx *sqlx.Tx) error {
-	var (
-		statement = `update vote_drain set erred=true where id=$1`
-		_, err    = pg.DB.Exec(statement, vr.ID)
-	)
-	return fmt.Errorf("failed to commit vote from drain: %w", err)
+// MarkVoteErrored - Record the failed attempt of a vote, it is retried with backoff unless the failure is
+// terminal or the attempts are exhausted in which case it is dead-lettered. Designed to run on a transaction
+// so a batch number of votes can be processed.
+func (pg *Postgres) MarkVoteErrored(ctx context.Context, vr VoteRecord, tx *sqlx.Tx, cause error) error {
+	if _, err := grantserver.RecordJobFailure(tx, grantserver.VoteDrainQueue, vr.ID, vr.Attempts, cause); err != nil {
+		return fmt.Errorf("failed to mark vote from drain as errored: %w", err)
+	}
+	return nil
 }
 
-// CommitVote - Update a vote to show it has been processed, designed to run on a transaction so
-// a batch number of votes can be processed.
-func (pg *Postgres) CommitVote(ctx context.Context, vr VoteRecord, tx *sqlx.Tx) error {
-	var (
-		statement = `update vote_drain set processed=true where id=$1`
-		_, err    = pg.DB.Exec(statement, vr.ID)
-	)
-	return fmt.Errorf("failed to commit vote from drain: %w", err)
+// CommitVote - Update a vote to show it has been processed and queue its event for publication, designed to
+// run on a transaction so a batch number of votes can be processed.
+func (pg *Postgres) CommitVote(ctx context.Context, vr VoteRecord, tx *sqlx.Tx, event grantserver.OutboxEvent) error {
+	statement := `update vote_drain set processed=true where id=$1`
+	if _, err := tx.ExecContext(ctx, statement, vr.ID); err != nil {
+		return fmt.Errorf("failed to commit vote from drain: %w", err)
+	}
+	if err := grantserver.InsertOutboxEvent(tx, event); err != nil {
+		return fmt.Errorf("failed to queue vote event: %w", err)
+	}
+	return nil
 }
 
 // InsertVote - Add a vote to our "queue" to be processed
@@ -427,7 +1335,7 @@ select
 from
 	(select *
 	from order_creds
-	where batch_proof is null
+	where batch_proof is null and revoked_at is null
 	for update skip locked
 	limit 1
 ) order_cred
@@ -458,6 +1366,24 @@ on order_cred.issuer_id = order_cred_issuers.id`
 		return attempted, err
 	}
 
+	_, err = tx.Exec(`update order_cred_issuers set tokens_signed = tokens_signed + $1 where id = $2`, len(*creds.SignedCreds), job.Issuer.ID)
+	if err != nil {
+		return attempted, err
+	}
+
+	// the order is fulfilled once all of its credentials have been signed
+	var unsigned int
+	err = tx.Get(&unsigned, `select count(*) from order_creds where order_id = $1 and batch_proof is null and revoked_at is null`, job.OrderID)
+	if err != nil {
+		return attempted, err
+	}
+	if unsigned == 0 {
+		err = transitionOrder(tx, job.OrderID, OrderStatusFulfilled)
+		if err != nil && !errors.Is(err, ErrInvalidOrderTransition) {
+			return attempted, err
+		}
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		return attempted, err
@@ -465,3 +1391,98 @@ on order_cred.issuer_id = order_cred_issuers.id`
 
 	return attempted, nil
 }
+
+// RunNextTransactionReconcileJob to check the status of a payment which has not reached a terminal status,
+// updating the order status if it has changed. Reserved payments which were never submitted are failed, refunds
+// are reconciled by RunNextRefundJob.
+func (pg *Postgres) RunNextTransactionReconcileJob(ctx context.Context, worker TransactionReconciler) (bool, error) {
+	tx, err := pg.DB.Beginx()
+	attempted := false
+	if err != nil {
+		return attempted, err
+	}
+	defer pg.RollbackTx(tx)
+
+	statement := `
+select *
+from transactions
+where status not in ('completed', 'failed', 'cancelled') and kind <> 'refund'
+	and (status_checked_at is null or status_checked_at < current_timestamp - $1 * interval '1 second')
+order by status_checked_at asc nulls first
+for update skip locked
+limit 1`
+
+	transactions := []Transaction{}
+	err = tx.Select(&transactions, statement, reconcileInterval.Seconds())
+	if err != nil {
+		return attempted, err
+	}
+
+	if len(transactions) != 1 {
+		return attempted, nil
+	}
+
+	transaction := transactions[0]
+	attempted = true
+
+	var txn *w.TransactionInfo
+	if transaction.Status == TransactionStatusReserved {
+		// reserved payments are recorded as soon as their submission returns, so those still reserved after the
+		// interval were abandoned and no longer hold the order open
+		txn = &w.TransactionInfo{ID: transaction.ExternalTransactionID, Status: transaction.Status}
+		if time.Since(transaction.CreatedAt) > reconcileInterval {
+			txn.Status = w.TransactionFailed
+		}
+	} else {
+		txn, err = worker.LookupTransaction(ctx, transaction.ExternalTransactionID)
+		if err != nil {
+			// wait for the next interval before checking this transaction again
+			{
+				_, err := tx.Exec(`update transactions set status_checked_at = current_timestamp where id = $1`, transaction.ID)
+				if err != nil {
+					pg.RollbackTx(tx)
+				}
+				_ = tx.Commit()
+			}
+			return attempted, err
+		}
+	}
+
+	if txn.Status == transaction.Status {
+		_, err = tx.Exec(`update transactions set status_checked_at = current_timestamp where id = $1`, transaction.ID)
+		if err != nil {
+			return attempted, err
+		}
+		return attempted, tx.Commit()
+	}
+
+	statement = `
+update transactions
+set status = $2, status_checked_at = current_timestamp, updated_at = current_timestamp
+where id = $1`
+	_, err = tx.Exec(statement, transaction.ID, txn.Status)
+	if err != nil {
+		return attempted, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return attempted, err
+	}
+
+	countTransactionsReconciled.With(prometheus.Labels{"status": txn.Status}).Inc()
+
+	return attempted, worker.UpdateOrderStatus(transaction.OrderID)
+}
+
+// CountStuckTransactions returns the number of transactions which have not completed after the passed age
+func (pg *Postgres) CountStuckTransactions(age time.Duration) (int, error) {
+	statement := `
+select count(*)
+from transactions
+where status not in ('completed', 'failed', 'cancelled') and created_at < current_timestamp - $1 * interval '1 second'`
+
+	var count int
+	err := pg.DB.Get(&count, statement, age.Seconds())
+	return count, err
+}
@@ -0,0 +1,211 @@
+package payment
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brave-intl/bat-go/utils/altcurrency"
+	"github.com/brave-intl/bat-go/utils/cryptography"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	macaroon "gopkg.in/macaroon.v2"
+)
+
+const (
+	// rootKeySize is the size of the root keys SKU macaroons are signed with
+	rootKeySize = 32
+	// caveatSeparator separates the name and value of a SKU caveat
+	caveatSeparator = "="
+)
+
+var (
+	// ErrInvalidSKU is wrapped by every error returned when a SKU token is rejected
+	ErrInvalidSKU = errors.New("invalid SKU token")
+	// ErrUnknownSKUMerchant is returned when no merchant with keys is allowed the location of a SKU token
+	ErrUnknownSKUMerchant = fmt.Errorf("%w: no merchant keys for the location", ErrInvalidSKU)
+	// ErrSKUSignature is returned when a SKU token was not signed by any key of its merchant
+	ErrSKUSignature = fmt.Errorf("%w: signature does not match any key of the merchant", ErrInvalidSKU)
+	// ErrThirdPartyCaveat is returned when a SKU token has a third party caveat
+	ErrThirdPartyCaveat = fmt.Errorf("%w: third party caveats are not supported", ErrInvalidSKU)
+	// ErrMalformedCaveat is returned when a caveat is not of the form "name = value"
+	ErrMalformedCaveat = fmt.Errorf("%w: malformed caveat", ErrInvalidSKU)
+	// ErrUnknownCaveat is returned when a caveat is not one of the allowed SKU caveats
+	ErrUnknownCaveat = fmt.Errorf("%w: unknown caveat", ErrInvalidSKU)
+	// ErrDuplicateCaveat is returned when a caveat is present more than once
+	ErrDuplicateCaveat = fmt.Errorf("%w: duplicate caveat", ErrInvalidSKU)
+	// ErrMissingCaveat is returned when a required caveat is not present
+	ErrMissingCaveat = fmt.Errorf("%w: missing caveat", ErrInvalidSKU)
+	// ErrInvalidCaveat is returned when the value of a caveat is invalid
+	ErrInvalidCaveat = fmt.Errorf("%w: invalid caveat", ErrInvalidSKU)
+	// ErrSKUExpired is returned when the expiry caveat of a SKU token has passed
+	ErrSKUExpired = fmt.Errorf("%w: expired", ErrInvalidSKU)
+
+	// skuCaveats are the allowed caveats of a SKU token, mapped to whether they are required
+	skuCaveats = map[string]bool{
+		"sku":         true,
+		"price":       true,
+		"currency":    true,
+		"description": false,
+		"expiry":      false,
+	}
+)
+
+// Key is a root key of a merchant which SKU tokens are signed with, the secret is stored encrypted
+type Key struct {
+	ID                 uuid.UUID  `json:"id" db:"id"`
+	Name               string     `json:"name" db:"name"`
+	MerchantID         string     `json:"merchantId" db:"merchant_id"`
+	EncryptedSecretKey string     `json:"-" db:"encrypted_secret_key"`
+	Nonce              string     `json:"-" db:"nonce"`
+	CreatedAt          time.Time  `json:"createdAt" db:"created_at"`
+	Expiry             *time.Time `json:"expiry" db:"expiry"`
+}
+
+// GetSecretKey decrypts the root key with the encryption key it was stored with
+func (key *Key) GetSecretKey(encryptionKey [cryptography.KeySize]byte) ([]byte, error) {
+	ciphertext, err := hex.DecodeString(key.EncryptedSecretKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hex.DecodeString(key.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	return cryptography.DecryptMessage(encryptionKey, ciphertext, nonce)
+}
+
+// RootKeys returns the root keys which may have signed the SKU tokens of a location
+type RootKeys func(location string) ([][]byte, error)
+
+// verifySKU checks the signature of the SKU token against the root keys of its location,
+// returning the first party caveats it was signed with
+func verifySKU(mac *macaroon.Macaroon, rootKeys RootKeys) ([]string, error) {
+	for _, caveat := range mac.Caveats() {
+		if len(caveat.VerificationId) > 0 {
+			return nil, ErrThirdPartyCaveat
+		}
+	}
+
+	keys, err := rootKeys(mac.Location())
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrUnknownSKUMerchant
+	}
+	for _, key := range keys {
+		conditions, err := mac.VerifySignature(key, nil)
+		if err == nil {
+			return conditions, nil
+		}
+	}
+	return nil, ErrSKUSignature
+}
+
+// applySKUCaveats checks each caveat against the allowed SKU caveats and sets them on the order item
+func applySKUCaveats(orderItem *OrderItem, conditions []string) error {
+	seen := map[string]bool{}
+	for _, condition := range conditions {
+		values := strings.SplitN(condition, caveatSeparator, 2)
+		if len(values) != 2 {
+			return fmt.Errorf("%w: %q", ErrMalformedCaveat, condition)
+		}
+		key := strings.TrimSpace(values[0])
+		value := strings.TrimSpace(values[1])
+
+		if _, ok := skuCaveats[key]; !ok {
+			return fmt.Errorf("%w: %q", ErrUnknownCaveat, key)
+		}
+		if seen[key] {
+			return fmt.Errorf("%w: %q", ErrDuplicateCaveat, key)
+		}
+		seen[key] = true
+
+		switch key {
+		case "sku":
+			if len(value) == 0 {
+				return fmt.Errorf("%w: sku must not be empty", ErrInvalidCaveat)
+			}
+			orderItem.SKU = value
+		case "price":
+			price, err := decimal.NewFromString(value)
+			if err != nil || price.IsNegative() {
+				return fmt.Errorf("%w: price must be a non-negative decimal", ErrInvalidCaveat)
+			}
+			orderItem.Price = price
+		case "currency":
+			if _, err := altcurrency.FromString(value); err != nil {
+				return fmt.Errorf("%w: unsupported currency %q", ErrInvalidCaveat, value)
+			}
+			orderItem.Currency = value
+		case "description":
+			orderItem.Description.String = value
+			orderItem.Description.Valid = true
+		case "expiry":
+			expiry, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%w: expiry must be a unix timestamp", ErrInvalidCaveat)
+			}
+			if time.Now().After(time.Unix(expiry, 0)) {
+				return ErrSKUExpired
+			}
+		}
+	}
+
+	for key, required := range skuCaveats {
+		if required && !seen[key] {
+			return fmt.Errorf("%w: %q", ErrMissingCaveat, key)
+		}
+	}
+	return nil
+}
+
+// CreateKey generates a new root key for the merchant, storing it encrypted
+func (s *Service) CreateKey(merchantID string, name string) (*Key, error) {
+	if _, err := s.getMerchant(merchantID); err != nil {
+		return nil, err
+	}
+
+	secretKey := make([]byte, rootKeySize)
+	if _, err := io.ReadFull(rand.Reader, secretKey); err != nil {
+		return nil, err
+	}
+
+	encrypted, nonce, err := cryptography.EncryptMessage(s.encryptionKey, secretKey)
+	if err != nil {
+		return nil, err
+	}
+	return s.datastore.CreateKey(merchantID, name, hex.EncodeToString(encrypted), hex.EncodeToString(nonce[:]))
+}
+
+// skuRootKeys returns the decrypted root keys of the merchant the location of a SKU token is allowed for
+func (s *Service) skuRootKeys(location string) ([][]byte, error) {
+	merchant, err := s.datastore.GetMerchantByLocation(location)
+	if err != nil {
+		return nil, err
+	}
+	if merchant == nil {
+		return nil, nil
+	}
+
+	keys, err := s.datastore.GetKeys(merchant.ID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	rootKeys := [][]byte{}
+	for _, key := range keys {
+		secretKey, err := key.GetSecretKey(s.encryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt key %s: %w", key.ID, err)
+		}
+		rootKeys = append(rootKeys, secretKey)
+	}
+	return rootKeys, nil
+}
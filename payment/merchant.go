@@ -0,0 +1,156 @@
+package payment
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/brave-intl/bat-go/middleware"
+	"github.com/brave-intl/bat-go/utils/datastore"
+	"github.com/brave-intl/bat-go/utils/handlers"
+	"github.com/go-chi/chi"
+	"github.com/lib/pq"
+)
+
+const (
+	// apiKeySize is the size of the random API keys merchants authenticate with
+	apiKeySize = 32
+	// defaultMerchantPageSize is the number of orders or transactions listed for a merchant by default
+	defaultMerchantPageSize = 100
+	// maxMerchantPageSize is the maximum number of orders or transactions listed for a merchant at once
+	maxMerchantPageSize = 1000
+)
+
+var (
+	// ErrMerchantNotFound is returned when there is no merchant with the id
+	ErrMerchantNotFound = errors.New("no such merchant")
+	// ErrMerchantExists is returned when creating a merchant with the id of an existing one
+	ErrMerchantExists = errors.New("a merchant with this id already exists")
+	// ErrLocationTaken is returned when a location is already allowed for another merchant
+	ErrLocationTaken = errors.New("location is already allowed for another merchant")
+	// ErrLocationNotAllowed is returned when a SKU is created for a location which is not allowed for the merchant
+	ErrLocationNotAllowed = errors.New("location is not allowed for the merchant")
+)
+
+type merchantContextKey struct{}
+
+// Merchant sells SKUs, orders are attributed to the merchant whose location their SKU tokens were issued for
+type Merchant struct {
+	ID         string               `json:"id" db:"id"`
+	Name       string               `json:"name" db:"name"`
+	APIKeyHash datastore.NullString `json:"-" db:"api_key_hash"`
+	// PayoutProvider and PayoutAddress are where the proceeds of the orders of the merchant are paid out
+	PayoutProvider datastore.NullString `json:"payoutProvider" db:"payout_provider"`
+	PayoutAddress  datastore.NullString `json:"payoutAddress" db:"payout_address"`
+	// Locations the SKU tokens of the merchant may be issued for, each location belongs to one merchant
+	Locations pq.StringArray `json:"locations" db:"locations"`
+	CreatedAt time.Time      `json:"createdAt" db:"created_at"`
+	UpdatedAt time.Time      `json:"updatedAt" db:"updated_at"`
+}
+
+// AllowsLocation returns true if SKU tokens of the merchant may be issued for the location
+func (merchant *Merchant) AllowsLocation(location string) bool {
+	for _, allowed := range merchant.Locations {
+		if allowed == location {
+			return true
+		}
+	}
+	return false
+}
+
+// hashAPIKey returns the hash of the API key which is stored in place of the key
+func hashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// newAPIKey generates a random API key, returning it along with its hash
+func newAPIKey() (string, string, error) {
+	b := make([]byte, apiKeySize)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", "", err
+	}
+	apiKey := hex.EncodeToString(b)
+	return apiKey, hashAPIKey(apiKey), nil
+}
+
+// CreateMerchant registers the merchant, returning it along with its API key which is not stored
+func (s *Service) CreateMerchant(merchant Merchant) (*Merchant, string, error) {
+	apiKey, apiKeyHash, err := newAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+	merchant.APIKeyHash.String = apiKeyHash
+	merchant.APIKeyHash.Valid = true
+
+	created, err := s.datastore.CreateMerchant(&merchant)
+	if err != nil {
+		return nil, "", err
+	}
+	return created, apiKey, nil
+}
+
+// RotateMerchantAPIKey replaces the API key of the merchant, the previous key stops working immediately
+func (s *Service) RotateMerchantAPIKey(merchantID string) (string, error) {
+	apiKey, apiKeyHash, err := newAPIKey()
+	if err != nil {
+		return "", err
+	}
+	updated, err := s.datastore.SetMerchantAPIKeyHash(merchantID, apiKeyHash)
+	if err != nil {
+		return "", err
+	}
+	if !updated {
+		return "", ErrMerchantNotFound
+	}
+	return apiKey, nil
+}
+
+// getMerchant returns the merchant with the id, or ErrMerchantNotFound
+func (s *Service) getMerchant(merchantID string) (*Merchant, error) {
+	merchant, err := s.datastore.GetMerchant(merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if merchant == nil {
+		return nil, ErrMerchantNotFound
+	}
+	return merchant, nil
+}
+
+// MerchantAuthorizedOnly is a middleware that restricts access to requests with the API key of a merchant
+// NOTE the bearer token is populated via BearerToken
+func (s *Service) MerchantAuthorizedOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey := middleware.BearerTokenFromContext(r.Context())
+		if len(apiKey) == 0 {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		merchant, err := s.datastore.GetMerchantByAPIKeyHash(hashAPIKey(apiKey))
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if merchant == nil {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		ctx := context.WithValue(r.Context(), merchantContextKey{}, merchant)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authorizedMerchant returns the merchant the request was authorized for if it matches the merchant in the url
+func authorizedMerchant(r *http.Request) (*Merchant, *handlers.AppError) {
+	merchant, ok := r.Context().Value(merchantContextKey{}).(*Merchant)
+	if !ok || merchant.ID != chi.URLParam(r, "merchantID") {
+		return nil, handlers.WrapError(errors.New("not authorized for this merchant"), "Error authorizing the merchant", http.StatusForbidden)
+	}
+	return merchant, nil
+}
@@ -0,0 +1,57 @@
+package payment
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/brave-intl/bat-go/middleware"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerchantAuthorizedOnly(t *testing.T) {
+	apiKey, apiKeyHash, err := newAPIKey()
+	require.NoError(t, err)
+
+	merchant := Merchant{ID: "brave"}
+	merchant.APIKeyHash.String = apiKeyHash
+	merchant.APIKeyHash.Valid = true
+	service := &Service{datastore: &catalogDatastore{merchants: []Merchant{merchant}}}
+
+	router := chi.NewRouter()
+	router.Use(middleware.BearerToken)
+	router.Mount("/v1/merchants", MerchantRouter(service))
+	router.Method("GET", "/v1/merchants/{merchantID}/ping", service.MerchantAuthorizedOnly(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if _, appErr := authorizedMerchant(r); appErr != nil {
+				appErr.ServeHTTP(w, r)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		})))
+
+	cases := []struct {
+		path   string
+		apiKey string
+		status int
+	}{
+		{"/v1/merchants/brave/ping", "", http.StatusUnauthorized},
+		{"/v1/merchants/brave/ping", "not an api key", http.StatusUnauthorized},
+		{"/v1/merchants/other/ping", apiKey, http.StatusForbidden},
+		{"/v1/merchants/brave/ping", apiKey, http.StatusOK},
+		{"/v1/merchants/other/orders", apiKey, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		req, err := http.NewRequestWithContext(context.Background(), "GET", c.path, nil)
+		require.NoError(t, err)
+		if len(c.apiKey) > 0 {
+			req.Header.Set("Authorization", "Bearer "+c.apiKey)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, c.status, rr.Code, c.path)
+	}
+}
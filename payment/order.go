@@ -1,8 +1,9 @@
 package payment
 
 import (
+	"errors"
+	"fmt"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/brave-intl/bat-go/utils/datastore"
@@ -11,6 +12,59 @@ import (
 	macaroon "gopkg.in/macaroon.v2"
 )
 
+const (
+	// OrderStatusPending orders are awaiting payment and are canceled once they expire
+	OrderStatusPending = "pending"
+	// OrderStatusPaid orders have been paid in full and may request credentials
+	OrderStatusPaid = "paid"
+	// OrderStatusFulfilled orders have had their credentials signed
+	OrderStatusFulfilled = "fulfilled"
+	// OrderStatusCanceled orders were canceled or expired before being paid, payments which settle afterwards are refunded
+	OrderStatusCanceled = "canceled"
+	// OrderStatusRefunded orders have had every item refunded
+	OrderStatusRefunded = "refunded"
+
+	// defaultOrderTTL is how long a pending order waits for payment when ORDER_TTL is unset
+	defaultOrderTTL = 24 * time.Hour
+)
+
+var (
+	// ErrOrderNotFound is returned when there is no order with the id
+	ErrOrderNotFound = errors.New("no such order")
+	// ErrInvalidOrderTransition is returned when an order cannot move from its current status to the requested one
+	ErrInvalidOrderTransition = errors.New("invalid order status transition")
+	// ErrOrderNotPending is returned when a transaction is submitted for an order which is no longer awaiting payment
+	ErrOrderNotPending = fmt.Errorf("%w: order is not pending", ErrInvalidOrderTransition)
+	// ErrOrderExpired is returned when a transaction is submitted for a pending order past its expiry
+	ErrOrderExpired = fmt.Errorf("%w: order has expired", ErrInvalidOrderTransition)
+	// ErrOrderNotPaid is returned when credentials are requested for an order which is not paid
+	ErrOrderNotPaid = fmt.Errorf("%w: order has not yet been paid", ErrInvalidOrderTransition)
+	// ErrOrderHasPendingTransactions is returned when an order is canceled while a payment may still settle
+	ErrOrderHasPendingTransactions = fmt.Errorf("%w: order has transactions which have not yet settled", ErrInvalidOrderTransition)
+
+	// orderTransitions maps each order status to the statuses an order may move to from it
+	orderTransitions = map[string][]string{
+		OrderStatusPending:   {OrderStatusPaid, OrderStatusCanceled},
+		OrderStatusPaid:      {OrderStatusFulfilled, OrderStatusRefunded},
+		OrderStatusFulfilled: {OrderStatusRefunded},
+		OrderStatusCanceled:  {OrderStatusRefunded},
+		OrderStatusRefunded:  {},
+	}
+)
+
+// orderStatusesBefore returns the statuses an order may move to the passed status from
+func orderStatusesBefore(status string) []string {
+	from := []string{}
+	for before, after := range orderTransitions {
+		for _, s := range after {
+			if s == status {
+				from = append(from, before)
+			}
+		}
+	}
+	return from
+}
+
 // Order includes information about a particular order
 type Order struct {
 	ID         uuid.UUID            `json:"id" db:"id"`
@@ -21,7 +75,9 @@ type Order struct {
 	MerchantID string               `json:"-" db:"merchant_id"`
 	Location   datastore.NullString `json:"location" db:"location"`
 	Status     string               `json:"status" db:"status"`
-	Items      []OrderItem          `json:"items"`
+	// ExpiresAt is when the order is canceled if it is still pending
+	ExpiresAt *time.Time  `json:"expiresAt" db:"expires_at"`
+	Items     []OrderItem `json:"items"`
 }
 
 // OrderItem includes information about a particular order item
@@ -37,58 +93,43 @@ type OrderItem struct {
 	Subtotal    decimal.Decimal      `json:"subtotal"`
 	Location    datastore.NullString `json:"location" db:"location"`
 	Description datastore.NullString `json:"description" db:"description"`
+	// RefundedQuantity is how many of the quantity have been refunded
+	RefundedQuantity int `json:"refundedQuantity" db:"refunded_quantity"`
 }
 
-// CreateOrderItemFromMacaroon creates an order item from a macaroon
-func CreateOrderItemFromMacaroon(sku string, quantity int) (*OrderItem, error) {
+// IsRefunded returns true if the whole quantity of the item has been refunded
+func (item OrderItem) IsRefunded() bool {
+	return item.RefundedQuantity >= item.Quantity
+}
+
+// CreateOrderItemFromMacaroon creates an order item from a SKU macaroon, verifying it was signed by a root key
+// of the merchant at its location and that its caveats are all allowed and satisfied
+func CreateOrderItemFromMacaroon(sku string, quantity int, rootKeys RootKeys) (*OrderItem, error) {
 	macBytes, err := macaroon.Base64Decode([]byte(sku))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSKU, err)
 	}
 	mac := &macaroon.Macaroon{}
 	err = mac.UnmarshalBinary(macBytes)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSKU, err)
 	}
 
-	// TODO Figure out how to verify macaroon using library
-	// I think we have to call .Bind()
-	// https://github.com/go-macaroon/macaroon#func-macaroon-bind
-	// I think we simply want to verify the signature and not the caveats?
-	// SO maybe VerifySignature
-	// https://github.com/go-macaroon/macaroon#func-macaroon-verifysignature
+	conditions, err := verifySKU(mac, rootKeys)
+	if err != nil {
+		return nil, err
+	}
 
-	caveats := mac.Caveats()
 	orderItem := OrderItem{}
 	orderItem.Quantity = quantity
 	orderItem.Location.String = mac.Location()
 	orderItem.Location.Valid = true
 
-	for i := 0; i < len(caveats); i++ {
-		caveat := mac.Caveats()[i]
-		values := strings.Split(string(caveat.Id), "=")
-		key := strings.TrimSpace(values[0])
-		value := strings.TrimSpace(values[1])
-
-		switch key {
-		case "sku":
-			orderItem.SKU = value
-		case "price", "amount":
-			orderItem.Price, err = decimal.NewFromString(value)
-			if err != nil {
-				return nil, err
-			}
-		case "description":
-			orderItem.Description.String = value
-			orderItem.Description.Valid = true
-			if err != nil {
-				return nil, err
-			}
-		case "currency":
-			orderItem.Currency = value
-		}
-
+	err = applySKUCaveats(&orderItem, conditions)
+	if err != nil {
+		return nil, err
 	}
+
 	newQuantity, err := decimal.NewFromString(strconv.Itoa(orderItem.Quantity))
 	if err != nil {
 		return nil, err
@@ -101,5 +142,20 @@ func CreateOrderItemFromMacaroon(sku string, quantity int) (*OrderItem, error) {
 
 // IsPaid returns true if the order is paid
 func (order Order) IsPaid() bool {
-	return order.Status == "paid"
+	return order.Status == OrderStatusPaid
+}
+
+// IsExpired returns true if the order is pending past its expiry
+func (order Order) IsExpired() bool {
+	return order.Status == OrderStatusPending && order.ExpiresAt != nil && time.Now().After(*order.ExpiresAt)
+}
+
+// CanTransitionTo returns true if the order may move from its current status to the passed one
+func (order Order) CanTransitionTo(status string) bool {
+	for _, s := range orderTransitions[order.Status] {
+		if s == status {
+			return true
+		}
+	}
+	return false
 }
@@ -1,28 +1,174 @@
 package payment
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
+	uuid "github.com/satori/go.uuid"
 	"github.com/stretchr/testify/suite"
+	macaroon "gopkg.in/macaroon.v2"
 )
 
 type OrderTestSuite struct {
 	suite.Suite
+	rootKey []byte
 }
 
 func TestOrderTestSuite(t *testing.T) {
 	suite.Run(t, new(OrderTestSuite))
 }
 
+func (suite *OrderTestSuite) SetupTest() {
+	suite.rootKey = []byte("0123456789abcdef0123456789abcdef")
+}
+
+// rootKeys returns the keys of the merchant at localhost:8080, including the suite root key
+func (suite *OrderTestSuite) rootKeys(location string) ([][]byte, error) {
+	if location != "localhost:8080" {
+		return nil, nil
+	}
+	return [][]byte{[]byte("another key of the merchant"), suite.rootKey}, nil
+}
+
+func (suite *OrderTestSuite) mintSKU(rootKey []byte, caveats ...string) *macaroon.Macaroon {
+	mac, err := macaroon.New(rootKey, []byte("Brave SKU v1.0"), "localhost:8080", macaroon.LatestVersion)
+	suite.Require().NoError(err)
+	for _, caveat := range caveats {
+		suite.Require().NoError(mac.AddFirstPartyCaveat([]byte(caveat)))
+	}
+	return mac
+}
+
+func (suite *OrderTestSuite) encodeSKU(mac *macaroon.Macaroon) string {
+	macBytes, err := mac.MarshalBinary()
+	suite.Require().NoError(err)
+	return base64.URLEncoding.EncodeToString(macBytes)
+}
+
 func (suite *OrderTestSuite) TestCreateOrderItemFromMacaroon() {
-	sku := "MDAxY2xvY2F0aW9uIGxvY2FsaG9zdDo4MDgwCjAwMWVpZGVudGlmaWVyIEJyYXZlIFNLVSB2MS4wCjAwMWFjaWQgc2t1ID0gQlJBVkUtMTIzNDUKMDAxMmNpZCBwcmljZSA9IDgKMDAxN2NpZCBjdXJyZW5jeSA9IEJBVAowMDJhY2lkIGRlc2NyaXB0aW9uID0gMTIgb3VuY2VzIG9mIENvZmZlZQowMDFjY2lkIGV4cGlyeSA9IDE1ODU2MDczNTkKMDAyZnNpZ25hdHVyZSB60s2IxrUuE0SYqFM3mD2p85nogryrOkkaNUkrHgjEPQo"
+	expiry := fmt.Sprintf("expiry = %d", time.Now().Add(time.Hour).Unix())
+	sku := suite.encodeSKU(suite.mintSKU(suite.rootKey,
+		"sku = BRAVE-12345", "price = 8", "currency = BAT", "description = 12 ounces of Coffee", expiry))
 
-	orderItem, err := CreateOrderItemFromMacaroon(sku, 1)
+	orderItem, err := CreateOrderItemFromMacaroon(sku, 2, suite.rootKeys)
 	suite.Require().NoError(err)
 
 	suite.Assert().Equal("BAT", orderItem.Currency)
 	suite.Assert().Equal("BRAVE-12345", orderItem.SKU)
 	suite.Assert().Equal("8", orderItem.Price.String())
+	suite.Assert().Equal("16", orderItem.Subtotal.String())
 	suite.Assert().Equal("12 ounces of Coffee", orderItem.Description.String)
 	suite.Assert().Equal("localhost:8080", orderItem.Location.String)
 }
+
+func (suite *OrderTestSuite) TestCreateOrderItemFromMacaroonRejected() {
+	valid := []string{"sku = BRAVE-12345", "price = 8", "currency = BAT"}
+
+	cases := []struct {
+		name string
+		sku  func() string
+		err  error
+	}{
+		{"not a macaroon", func() string { return "not a macaroon" }, ErrInvalidSKU},
+		{"signed with another key", func() string {
+			return suite.encodeSKU(suite.mintSKU([]byte("fedcba9876543210fedcba9876543210"), valid...))
+		}, ErrSKUSignature},
+		{"merchant without keys", func() string {
+			mac, err := macaroon.New(suite.rootKey, []byte("Brave SKU v1.0"), "example.com", macaroon.LatestVersion)
+			suite.Require().NoError(err)
+			return suite.encodeSKU(mac)
+		}, ErrUnknownSKUMerchant},
+		{"unknown caveat", func() string {
+			return suite.encodeSKU(suite.mintSKU(suite.rootKey, append(valid, "discount = 100")...))
+		}, ErrUnknownCaveat},
+		{"malformed caveat", func() string {
+			return suite.encodeSKU(suite.mintSKU(suite.rootKey, append(valid, "expiry")...))
+		}, ErrMalformedCaveat},
+		{"missing caveat", func() string {
+			return suite.encodeSKU(suite.mintSKU(suite.rootKey, "sku = BRAVE-12345", "currency = BAT"))
+		}, ErrMissingCaveat},
+		{"invalid price", func() string {
+			return suite.encodeSKU(suite.mintSKU(suite.rootKey, "sku = BRAVE-12345", "price = -1", "currency = BAT"))
+		}, ErrInvalidCaveat},
+		{"expired", func() string {
+			return suite.encodeSKU(suite.mintSKU(suite.rootKey, append(valid, "expiry = 1585607359")...))
+		}, ErrSKUExpired},
+		{"price attenuated by the holder", func() string {
+			mac := suite.mintSKU(suite.rootKey, valid...)
+			suite.Require().NoError(mac.AddFirstPartyCaveat([]byte("price = 0")))
+			return suite.encodeSKU(mac)
+		}, ErrDuplicateCaveat},
+		{"third party caveat", func() string {
+			mac := suite.mintSKU(suite.rootKey, valid...)
+			suite.Require().NoError(mac.AddThirdPartyCaveat([]byte("third party key"), []byte("is-human"), "example.com"))
+			return suite.encodeSKU(mac)
+		}, ErrThirdPartyCaveat},
+	}
+
+	for _, c := range cases {
+		_, err := CreateOrderItemFromMacaroon(c.sku(), 1, suite.rootKeys)
+		suite.Assert().True(errors.Is(err, c.err), "%s: expected %v, got %v", c.name, c.err, err)
+		suite.Assert().True(errors.Is(err, ErrInvalidSKU), "%s: every rejection should be an invalid SKU", c.name)
+	}
+}
+
+func (suite *OrderTestSuite) TestOrderTransitions() {
+	cases := []struct {
+		from    string
+		to      string
+		allowed bool
+	}{
+		{OrderStatusPending, OrderStatusPaid, true},
+		{OrderStatusPending, OrderStatusCanceled, true},
+		{OrderStatusPending, OrderStatusFulfilled, false},
+		{OrderStatusPaid, OrderStatusFulfilled, true},
+		{OrderStatusPaid, OrderStatusCanceled, false},
+		{OrderStatusPaid, OrderStatusPending, false},
+		{OrderStatusFulfilled, OrderStatusPaid, false},
+		{OrderStatusCanceled, OrderStatusPaid, false},
+		{OrderStatusCanceled, OrderStatusRefunded, true},
+	}
+
+	for _, c := range cases {
+		order := Order{Status: c.from}
+		suite.Assert().Equal(c.allowed, order.CanTransitionTo(c.to), "%s to %s", c.from, c.to)
+	}
+
+	suite.Assert().ElementsMatch([]string{OrderStatusPending}, orderStatusesBefore(OrderStatusCanceled))
+	suite.Assert().Empty(orderStatusesBefore(OrderStatusPending), "orders should never move back to pending")
+}
+
+// fixedOrderDatastore returns the same order for every id
+type fixedOrderDatastore struct {
+	Datastore
+	order Order
+}
+
+func (d *fixedOrderDatastore) GetOrder(orderID uuid.UUID) (*Order, error) {
+	return &d.order, nil
+}
+
+func (suite *OrderTestSuite) TestOrderStatusRejected() {
+	expired := time.Now().Add(-time.Minute)
+	datastore := &fixedOrderDatastore{}
+	service := &Service{datastore: datastore}
+	ctx := context.Background()
+
+	datastore.order = Order{ID: uuid.NewV4(), Status: OrderStatusPending, ExpiresAt: &expired}
+	_, err := service.CreateAnonCardTransaction(ctx, uuid.NewV4(), "", datastore.order.ID)
+	suite.Assert().Equal(ErrOrderExpired, err, "transactions should not be submitted for expired orders")
+
+	err = service.CreateOrderCreds(ctx, datastore.order.ID, uuid.NewV4(), []string{})
+	suite.Assert().Equal(ErrOrderNotPaid, err)
+
+	for _, status := range []string{OrderStatusPaid, OrderStatusFulfilled, OrderStatusCanceled} {
+		datastore.order = Order{ID: uuid.NewV4(), Status: status}
+		_, err = service.CreateAnonCardTransaction(ctx, uuid.NewV4(), "", datastore.order.ID)
+		suite.Assert().Equal(ErrOrderNotPending, err, "transactions should not be submitted for %s orders", status)
+		suite.Assert().True(errors.Is(err, ErrInvalidOrderTransition))
+	}
+}
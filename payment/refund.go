@@ -0,0 +1,224 @@
+package payment
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/brave-intl/bat-go/utils/altcurrency"
+	"github.com/brave-intl/bat-go/utils/httpsignature"
+	srv "github.com/brave-intl/bat-go/utils/service"
+	w "github.com/brave-intl/bat-go/wallet"
+	"github.com/brave-intl/bat-go/wallet/provider"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"golang.org/x/crypto/ed25519"
+)
+
+var (
+	// ErrOrderNotRefundable is returned when a refund is requested for an order which has not been paid
+	ErrOrderNotRefundable = fmt.Errorf("%w: only orders with completed payments can be refunded", ErrInvalidOrderTransition)
+	// ErrInvalidRefund is returned when a refund names an item which is not in the order or more than its remaining quantity
+	ErrInvalidRefund = errors.New("invalid refund")
+	// ErrItemRefunded is returned when credentials are requested for an item which has been refunded
+	ErrItemRefunded = fmt.Errorf("%w: order item has been refunded", ErrInvalidOrderTransition)
+	// ErrNoRefundWallet is returned when a refund is requested but no refund wallet is configured
+	ErrNoRefundWallet = errors.New("refunds are not configured")
+	// ErrPayerNotFound is returned when the card which paid for an order cannot be found
+	ErrPayerNotFound = errors.New("could not find the card which paid for the order")
+	// ErrPartialRefund is returned when part of an item whose credentials have been requested is refunded
+	ErrPartialRefund = fmt.Errorf("%w: items whose credentials have been requested can only be refunded in full", ErrInvalidRefund)
+	// ErrRefundExceedsPayments is returned when a refund is for more than remains of the completed payments of the order
+	ErrRefundExceedsPayments = fmt.Errorf("%w: refund exceeds the amount paid", ErrInvalidRefund)
+	// ErrTooManyCredentials is returned when more credentials are requested than remain of a partially refunded item
+	ErrTooManyCredentials = fmt.Errorf("%w: more credentials requested than remain of the refunded item", ErrItemRefunded)
+
+	// errRefundTransferExpired is returned when a submitted refund transfer expired before it was confirmed
+	errRefundTransferExpired = errors.New("refund transfer expired before it was confirmed")
+)
+
+// OrderItemRefund is the quantity of an order item to refund
+type OrderItemRefund struct {
+	ItemID   uuid.UUID `json:"itemId" valid:"-"`
+	Quantity int       `json:"quantity" valid:"-"`
+}
+
+// refundAmount checks each refund is for an item of the order and within its remaining quantity,
+// returning the total amount to refund. Credentials cannot be partially revoked, so items whose
+// credentials have been requested can only be refunded in full, revoking their credentials.
+func refundAmount(items []OrderItem, refunds []OrderItemRefund, credentialed map[string]bool) (decimal.Decimal, error) {
+	amount := decimal.Zero
+	if len(refunds) == 0 {
+		return amount, fmt.Errorf("%w: at least one item must be refunded", ErrInvalidRefund)
+	}
+
+	seen := map[string]bool{}
+	for _, refund := range refunds {
+		if seen[refund.ItemID.String()] {
+			return amount, fmt.Errorf("%w: item %s is refunded more than once", ErrInvalidRefund, refund.ItemID)
+		}
+		seen[refund.ItemID.String()] = true
+
+		if refund.Quantity <= 0 {
+			return amount, fmt.Errorf("%w: quantity must be positive", ErrInvalidRefund)
+		}
+
+		var item *OrderItem
+		for i := range items {
+			if uuid.Equal(items[i].ID, refund.ItemID) {
+				item = &items[i]
+				break
+			}
+		}
+		if item == nil {
+			return amount, fmt.Errorf("%w: item %s is not in the order", ErrInvalidRefund, refund.ItemID)
+		}
+		if item.RefundedQuantity+refund.Quantity > item.Quantity {
+			return amount, fmt.Errorf("%w: only %d of item %s remain to be refunded", ErrInvalidRefund, item.Quantity-item.RefundedQuantity, item.ID)
+		}
+		if credentialed[item.ID.String()] && item.RefundedQuantity+refund.Quantity < item.Quantity {
+			return amount, fmt.Errorf("%w: item %s", ErrPartialRefund, item.ID)
+		}
+
+		amount = amount.Add(item.Price.Mul(decimal.New(int64(refund.Quantity), 0)))
+	}
+	return amount, nil
+}
+
+// RefundWorker transfers the amount of a reserved refund back to the card which paid for the order. The
+// transfer is submitted and its id recorded before it is confirmed, so a retried refund never pays out twice.
+type RefundWorker interface {
+	// SubmitRefund transfer to the payer without confirming it, the refund id is used as its idempotency key
+	SubmitRefund(ctx context.Context, refund *Transaction) (*w.TransactionInfo, error)
+	// ConfirmRefund transfer previously submitted with SubmitRefund, moving funds
+	ConfirmRefund(ctx context.Context, transactionID string) (*w.TransactionInfo, error)
+}
+
+// initRefundWallet by reading the keypair and card id from the environment, refunds are unavailable when unset
+func (s *Service) initRefundWallet() error {
+	refundWalletPublicKeyHex := os.Getenv("REFUND_WALLET_PUBLIC_KEY")
+	refundWalletPrivateKeyHex := os.Getenv("REFUND_WALLET_PRIVATE_KEY")
+	refundWalletCardID := os.Getenv("REFUND_WALLET_CARD_ID")
+
+	if len(refundWalletCardID) == 0 {
+		return nil
+	}
+
+	var info w.Info
+	info.Provider = "uphold"
+	info.ProviderID = refundWalletCardID
+	{
+		tmp := altcurrency.BAT
+		info.AltCurrency = &tmp
+	}
+
+	var pubKey httpsignature.Ed25519PubKey
+	var privKey ed25519.PrivateKey
+	var err error
+
+	pubKey, err = hex.DecodeString(refundWalletPublicKeyHex)
+	if err != nil {
+		return fmt.Errorf("REFUND_WALLET_PUBLIC_KEY is invalid: %w", err)
+	}
+	privKey, err = hex.DecodeString(refundWalletPrivateKeyHex)
+	if err != nil {
+		return fmt.Errorf("REFUND_WALLET_PRIVATE_KEY is invalid: %w", err)
+	}
+
+	if !provider.Supports(info.Provider, provider.PrepareSubmitConfirm) {
+		return fmt.Errorf("refund wallet provider %s cannot confirm transfers separately", info.Provider)
+	}
+	refundWallet, err := provider.NewWallet(info, privKey, pubKey)
+	if err != nil {
+		return err
+	}
+	s.refundWallet = refundWallet.(w.TransactionPreparer)
+	return nil
+}
+
+// RefundOrder reserves the refund of the passed quantities of order items, the transfer to the payer is made by
+// the refund job. Items whose credentials have been requested can only be refunded in full and their credentials
+// are revoked, which stops them being signed or fetched. Credentials which were already fetched cannot be
+// revoked at the challenge bypass server and remain redeemable.
+func (s *Service) RefundOrder(ctx context.Context, orderID uuid.UUID, refunds []OrderItemRefund) (*Transaction, error) {
+	if s.refundWallet == nil {
+		return nil, ErrNoRefundWallet
+	}
+	return s.datastore.ReserveRefund(ctx, orderID, refunds)
+}
+
+// RunNextRefundJob transfers the next reserved refund or checks the next unfinished refund transfer
+func (s *Service) RunNextRefundJob(ctx context.Context) (bool, error) {
+	if s.refundWallet == nil {
+		return false, nil
+	}
+	return s.datastore.RunNextRefundJob(ctx, s)
+}
+
+// SubmitRefund transfer from the refund wallet to the card which paid for the order, the refund id is
+// included as the transaction message so the transfer can be traced back to its refund
+func (s *Service) SubmitRefund(ctx context.Context, refund *Transaction) (*w.TransactionInfo, error) {
+	payer, err := s.orderPayer(ctx, refund.OrderID)
+	if errors.Is(err, ErrPayerNotFound) {
+		return nil, srv.Terminal(err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	currency, err := altcurrency.FromString(refund.Currency)
+	if err != nil {
+		return nil, srv.Terminal(err)
+	}
+
+	signedTx, err := s.refundWallet.PrepareTransaction(currency, currency.ToProbi(refund.Amount), payer, "refund:"+refund.ID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return s.refundWallet.SubmitTransaction(ctx, signedTx, false)
+}
+
+// ConfirmRefund transfer previously submitted, resuming if it has already been confirmed
+func (s *Service) ConfirmRefund(ctx context.Context, transactionID string) (*w.TransactionInfo, error) {
+	// unconfirmed transactions appear as "not found"
+	tx, err := s.refundWallet.GetTransaction(ctx, transactionID)
+	if err == nil {
+		return tx, nil
+	} else if !w.IsNotFound(err) {
+		return nil, err
+	}
+
+	tx, err = s.refundWallet.ConfirmTransaction(ctx, transactionID)
+	if w.AlreadyExists(err) {
+		// a previous attempt confirmed the transaction but we did not see the result
+		tx, err = s.refundWallet.GetTransaction(ctx, transactionID)
+	}
+	if w.IsNotFound(err) {
+		return nil, errRefundTransferExpired
+	}
+	return tx, err
+}
+
+// orderPayer returns the card the first completed payment of the order was made from
+func (s *Service) orderPayer(ctx context.Context, orderID uuid.UUID) (string, error) {
+	transactions, err := s.datastore.GetTransactions(orderID)
+	if err != nil {
+		return "", err
+	}
+
+	for _, transaction := range *transactions {
+		if transaction.Kind == TransactionKindRefund || transaction.Status != w.TransactionCompleted {
+			continue
+		}
+		txInfo, err := s.LookupTransaction(ctx, transaction.ExternalTransactionID)
+		if err != nil {
+			return "", err
+		}
+		if len(txInfo.Source) > 0 {
+			return txInfo.Source, nil
+		}
+	}
+	return "", fmt.Errorf("%w: order %s", ErrPayerNotFound, orderID)
+}
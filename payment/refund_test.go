@@ -0,0 +1,59 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefundAmount(t *testing.T) {
+	coffee := OrderItem{ID: uuid.NewV4(), Quantity: 4, Price: decimal.NewFromFloat(0.25)}
+	tea := OrderItem{ID: uuid.NewV4(), Quantity: 2, Price: decimal.NewFromFloat(1.5), RefundedQuantity: 1}
+	items := []OrderItem{coffee, tea}
+
+	amount, err := refundAmount(items, []OrderItemRefund{{ItemID: coffee.ID, Quantity: 3}, {ItemID: tea.ID, Quantity: 1}}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "2.25", amount.String(), "partial refunds should be priced per item")
+
+	cases := []struct {
+		name    string
+		refunds []OrderItemRefund
+	}{
+		{"no items", []OrderItemRefund{}},
+		{"unknown item", []OrderItemRefund{{ItemID: uuid.NewV4(), Quantity: 1}}},
+		{"zero quantity", []OrderItemRefund{{ItemID: coffee.ID, Quantity: 0}}},
+		{"more than the quantity", []OrderItemRefund{{ItemID: coffee.ID, Quantity: 5}}},
+		{"more than remains", []OrderItemRefund{{ItemID: tea.ID, Quantity: 2}}},
+		{"duplicate item", []OrderItemRefund{{ItemID: coffee.ID, Quantity: 1}, {ItemID: coffee.ID, Quantity: 1}}},
+	}
+	for _, c := range cases {
+		_, err := refundAmount(items, c.refunds, nil)
+		assert.True(t, errors.Is(err, ErrInvalidRefund), "%s: expected an invalid refund, got %v", c.name, err)
+	}
+
+	credentialed := map[string]bool{coffee.ID.String(): true, tea.ID.String(): true}
+	_, err = refundAmount(items, []OrderItemRefund{{ItemID: coffee.ID, Quantity: 3}}, credentialed)
+	assert.True(t, errors.Is(err, ErrPartialRefund), "items with credentials should only be refunded in full")
+
+	amount, err = refundAmount(items, []OrderItemRefund{{ItemID: coffee.ID, Quantity: 4}, {ItemID: tea.ID, Quantity: 1}}, credentialed)
+	require.NoError(t, err)
+	assert.Equal(t, "2.5", amount.String(), "the remainder of items with credentials should be refundable")
+}
+
+func TestRefundOrderTransitions(t *testing.T) {
+	// canceled orders are refundable by the payments which settled after they were canceled
+	for _, status := range []string{OrderStatusPaid, OrderStatusFulfilled, OrderStatusCanceled} {
+		assert.True(t, Order{Status: status}.CanTransitionTo(OrderStatusRefunded), "%s orders should be refundable", status)
+	}
+	for _, status := range []string{OrderStatusPending, OrderStatusRefunded} {
+		assert.False(t, Order{Status: status}.CanTransitionTo(OrderStatusRefunded), "%s orders should not be refundable", status)
+	}
+
+	_, err := (&Service{}).RefundOrder(context.Background(), uuid.NewV4(), nil)
+	assert.Equal(t, ErrNoRefundWallet, err)
+}
@@ -2,62 +2,91 @@ package payment
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
-	"encoding/json"
-	"encoding/pem"
-	"io/ioutil"
+	"fmt"
 	"log"
 	"os"
-	"strings"
 	"time"
 
 	"errors"
 
+	"github.com/brave-intl/bat-go/datastore/grantserver"
 	srv "github.com/brave-intl/bat-go/utils/service"
-	"github.com/brave-intl/bat-go/wallet/provider/uphold"
+	w "github.com/brave-intl/bat-go/wallet"
+	"github.com/brave-intl/bat-go/wallet/provider"
 	wallet "github.com/brave-intl/bat-go/wallet/service"
-	"github.com/linkedin/goavro"
 	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/brave-intl/bat-go/utils/altcurrency"
+	"github.com/brave-intl/bat-go/utils/avro"
 	"github.com/brave-intl/bat-go/utils/clients/cbr"
+	"github.com/brave-intl/bat-go/utils/cryptography"
 	errorutils "github.com/brave-intl/bat-go/utils/errors"
+	"github.com/brave-intl/bat-go/utils/issuerpolicy"
+	kafkautils "github.com/brave-intl/bat-go/utils/kafka"
 	uuid "github.com/satori/go.uuid"
-	kafka "github.com/segmentio/kafka-go"
 	"github.com/shopspring/decimal"
 )
 
 var (
-	voteTopic          = os.Getenv("ENV") + ".payment.vote"
-	kafkaCertNotBefore = prometheus.NewGauge(prometheus.GaugeOpts{
-		Name: "kafka_cert_not_before",
-		Help: "Date when the kafka certificate becomes valid.",
+	voteTopic         = os.Getenv("ENV") + ".payment.vote"
+	transactionsStuck = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "payment_transactions_stuck",
+		Help: "Number of order transactions which have not completed within the expected time.",
 	})
-	kafkaCertNotAfter = prometheus.NewGauge(prometheus.GaugeOpts{
-		Name: "kafka_cert_not_after",
-		Help: "Date when the kafka certificate expires.",
+	countTransactionsReconciled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "payment_transactions_reconciled_total",
+		Help: "Count of order transaction status changes found by reconciliation, broken down by status.",
+	}, []string{"status"})
+	issuerMetrics      = issuerpolicy.NewMetrics("payment")
+	countOrdersExpired = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "payment_orders_expired_total",
+		Help: "Count of pending orders canceled because they were not paid before their expiry.",
 	})
 )
 
+const (
+	// defaultTransactionProvider is the wallet provider order payments are made with
+	defaultTransactionProvider = "uphold"
+	// reconcileInterval is the minimum time between status checks of the same transaction
+	reconcileInterval = time.Minute
+	// stuckTransactionAge is the age after which an unfinished transaction is considered stuck
+	stuckTransactionAge = time.Hour
+)
+
 func init() {
 	// gracefully try to register collectors for prom, no need to panic
-	if err := prometheus.Register(kafkaCertNotBefore); err != nil {
-		log.Printf("already registered kafkaCertNotBefore collector: %s\n", err)
+	if err := prometheus.Register(transactionsStuck); err != nil {
+		log.Printf("already registered transactionsStuck collector: %s\n", err)
+	}
+	if err := prometheus.Register(countTransactionsReconciled); err != nil {
+		log.Printf("already registered countTransactionsReconciled collector: %s\n", err)
 	}
-	if err := prometheus.Register(kafkaCertNotAfter); err != nil {
-		log.Printf("already registered kafkaCertNotBefore collector: %s\n", err)
+	for _, collector := range issuerMetrics.Collectors() {
+		if err := prometheus.Register(collector); err != nil {
+			log.Printf("already registered issuer collector: %s\n", err)
+		}
+	}
+	if err := prometheus.Register(countOrdersExpired); err != nil {
+		log.Printf("already registered countOrdersExpired collector: %s\n", err)
 	}
 }
 
 // Service contains datastore
 type Service struct {
-	wallet      wallet.Service
-	cbClient    cbr.Client
-	datastore   Datastore
-	codecs      map[string]*goavro.Codec
-	kafkaWriter *kafka.Writer
-	kafkaDialer *kafka.Dialer
-	jobs        []srv.Job
+	wallet    wallet.Service
+	cbClient  cbr.Client
+	datastore Datastore
+	codecs    map[string]*avro.Codec
+	publisher kafkautils.EventPublisher
+	jobs      []srv.Job
+	// encryptionKey the root keys of merchants are stored encrypted with
+	encryptionKey [cryptography.KeySize]byte
+	// orderTTL is how long a pending order waits for payment before it is canceled
+	orderTTL time.Duration
+	// refundWallet refunds are transferred from, nil when refunds are not configured
+	refundWallet w.TransactionPreparer
+	// transactionProvider is the name of the wallet provider order payments are looked up with
+	transactionProvider string
 }
 
 // Jobs - Implement srv.JobService interface
@@ -65,116 +94,11 @@ func (s *Service) Jobs() []srv.Job {
 	return s.jobs
 }
 
-func readFileFromEnvLoc(env string, required bool) ([]byte, error) {
-	loc := os.Getenv(env)
-	if len(loc) == 0 {
-		if !required {
-			return []byte{}, nil
-		}
-		return []byte{}, errors.New(env + " must be passed")
-	}
-	buf, err := ioutil.ReadFile(loc)
-	if err != nil {
-		return []byte{}, err
-	}
-	return buf, nil
-}
-
-func tlsDialer() (*kafka.Dialer, error) {
-	keyPasswordEnv := "KAFKA_SSL_KEY_PASSWORD"
-	keyPassword := os.Getenv(keyPasswordEnv)
-
-	caPEM, err := readFileFromEnvLoc("KAFKA_SSL_CA_LOCATION", false)
-	if err != nil {
-		return nil, err
-	}
-
-	certEnv := "KAFKA_SSL_CERTIFICATE"
-	certPEM := []byte(os.Getenv(certEnv))
-	if len(certPEM) == 0 {
-		certPEM, err = readFileFromEnvLoc("KAFKA_SSL_CERTIFICATE_LOCATION", true)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	keyEnv := "KAFKA_SSL_KEY"
-	encryptedKeyPEM := []byte(os.Getenv(keyEnv))
-
-	// Check to see if KAFKA_SSL_CERTIFICATE includes both certificate and key
-	if certPEM[0] == '{' {
-		type Certificate struct {
-			Certificate string `json:"certificate"`
-			Key         string `json:"key"`
-		}
-		var cert Certificate
-		err := json.Unmarshal(certPEM, &cert)
-		if err != nil {
-			return nil, err
-		}
-		certPEM = []byte(cert.Certificate)
-		encryptedKeyPEM = []byte(cert.Key)
-	}
-
-	if len(encryptedKeyPEM) == 0 {
-		encryptedKeyPEM, err = readFileFromEnvLoc("KAFKA_SSL_KEY_LOCATION", true)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	block, rest := pem.Decode(encryptedKeyPEM)
-	if len(rest) > 0 {
-		return nil, errors.New("Extra data in KAFKA_SSL_KEY")
-	}
-
-	keyPEM := pem.EncodeToMemory(block)
-	if len(keyPassword) != 0 {
-		keyDER, err := x509.DecryptPEMBlock(block, []byte(keyPassword))
-		if err != nil {
-			return nil, errorutils.Wrap(err, "decrypt KAFKA_SSL_KEY failed")
-		}
-
-		keyPEM = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: keyDER})
-	}
-
-	certificate, err := tls.X509KeyPair([]byte(certPEM), keyPEM)
-	if err != nil {
-		return nil, errorutils.Wrap(err, "Could not parse x509 keypair")
-	}
-
-	// Define TLS configuration
-	config := &tls.Config{
-		Certificates: []tls.Certificate{certificate},
-	}
-
-	// Instrument kafka cert expiration information
-	x509Cert, err := x509.ParseCertificate(certificate.Certificate[0])
-	if err != nil {
-		return nil, errorutils.Wrap(err, "Could not parse certificate")
-	}
-	kafkaCertNotBefore.Set(float64(x509Cert.NotBefore.Unix()))
-	kafkaCertNotAfter.Set(float64(x509Cert.NotAfter.Unix()))
+// InitCodecs used for Avro encoding / decoding, registering the schema versions with the registry
+func (s *Service) InitCodecs(registry avro.Registry) error {
+	s.codecs = make(map[string]*avro.Codec)
 
-	if len(caPEM) > 0 {
-		caCertPool := x509.NewCertPool()
-		if ok := caCertPool.AppendCertsFromPEM([]byte(caPEM)); !ok {
-			return nil, errors.New("Could not add custom CA from KAFKA_SSL_CA_LOCATION")
-		}
-		config.RootCAs = caCertPool
-	}
-
-	return &kafka.Dialer{
-		Timeout:   10 * time.Second,
-		DualStack: true,
-		TLS:       config}, nil
-}
-
-// InitCodecs used for Avro encoding / decoding
-func (s *Service) InitCodecs() error {
-	s.codecs = make(map[string]*goavro.Codec)
-
-	voteEventCodec, err := goavro.NewCodec(string(voteSchema))
+	voteEventCodec, err := avro.NewCodec(context.Background(), registry, avro.Subject(voteTopic), voteSchemas...)
 	s.codecs["vote"] = voteEventCodec
 	if err != nil {
 		return err
@@ -182,26 +106,19 @@ func (s *Service) InitCodecs() error {
 	return nil
 }
 
-// InitKafka by creating a kafka writer and creating local copies of codecs
+// InitKafka by creating the vote event publisher and creating local copies of codecs
 func (s *Service) InitKafka() error {
-	dialer, err := tlsDialer()
+	publisher, err := kafkautils.NewPublisherFromEnv(voteTopic)
 	if err != nil {
 		return err
 	}
-	s.kafkaDialer = dialer
+	s.publisher = publisher
 
-	kafkaBrokers := os.Getenv("KAFKA_BROKERS")
-	kafkaWriter := kafka.NewWriter(kafka.WriterConfig{
-		// by default we are waitng for acks from all nodes
-		Brokers:  strings.Split(kafkaBrokers, ","),
-		Topic:    voteTopic,
-		Balancer: &kafka.LeastBytes{},
-		Dialer:   dialer,
-		Logger:   kafka.LoggerFunc(log.Printf), // FIXME
-	})
-
-	s.kafkaWriter = kafkaWriter
-	err = s.InitCodecs()
+	registry, err := avro.NewRegistryFromEnvironment()
+	if err != nil {
+		return err
+	}
+	err = s.InitCodecs(registry)
 	if err != nil {
 		return err
 	}
@@ -211,7 +128,7 @@ func (s *Service) InitKafka() error {
 
 // InitService creates a service using the passed datastore and clients configured from the environment
 func InitService(datastore Datastore) (*Service, error) {
-	cbClient, err := cbr.New()
+	cbClient, err := cbr.NewFromEnvironment()
 	if err != nil {
 		return nil, err
 	}
@@ -221,26 +138,84 @@ func InitService(datastore Datastore) (*Service, error) {
 		return nil, err
 	}
 
+	encryptionKey, err := cryptography.EncryptionKeyFromEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	orderTTL, err := orderTTLFromEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	transactionProvider, err := transactionProviderFromEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
 	service := &Service{
-		wallet:    *walletService,
-		cbClient:  cbClient,
-		datastore: datastore,
+		wallet:              *walletService,
+		cbClient:            cbClient,
+		datastore:           datastore,
+		encryptionKey:       encryptionKey,
+		orderTTL:            orderTTL,
+		transactionProvider: transactionProvider,
 	}
 
 	// setup runnable jobs
 	service.jobs = []srv.Job{
 		{
+			Name:    "vote_drain",
 			Func:    service.RunNextVoteDrainJob,
 			Cadence: 5 * time.Second,
 			Workers: 1,
+			Channel: "vote_drain",
 		},
 		{
+			Name:    "vote_outbox",
+			Func:    service.RunNextVoteOutboxJob,
+			Cadence: 5 * time.Second,
+			Workers: 1,
+			Channel: grantserver.OutboxChannel,
+		},
+		{
+			Name:    "vote_outbox_retention",
+			Func:    service.RunNextVoteOutboxRetentionJob,
+			Cadence: time.Hour,
+			Workers: 1,
+		},
+		{
+			Name:    "order_creds",
 			Func:    service.RunNextOrderJob,
 			Cadence: 5 * time.Second,
 			Workers: 1,
+			Channel: "order_creds",
+		},
+		{
+			Name:    "transaction_reconcile",
+			Func:    service.RunNextTransactionReconcileJob,
+			Cadence: 5 * time.Second,
+			Workers: 1,
+		},
+		{
+			Name:    "refund",
+			Func:    service.RunNextRefundJob,
+			Cadence: 5 * time.Second,
+			Workers: 1,
+		},
+		{
+			Name:    "order_expiry",
+			Func:    service.RunNextOrderExpiryJob,
+			Cadence: time.Minute,
+			Workers: 1,
 		},
 	}
 
+	err = service.initRefundWallet()
+	if err != nil {
+		return nil, err
+	}
+
 	err = service.InitKafka()
 	if err != nil {
 		return nil, err
@@ -249,6 +224,35 @@ func InitService(datastore Datastore) (*Service, error) {
 	return service, nil
 }
 
+// orderTTLFromEnvironment returns how long pending orders wait for payment, ORDER_TTL or a day when unset
+func orderTTLFromEnvironment() (time.Duration, error) {
+	ttl := os.Getenv("ORDER_TTL")
+	if len(ttl) == 0 {
+		return defaultOrderTTL, nil
+	}
+	orderTTL, err := time.ParseDuration(ttl)
+	if err != nil {
+		return 0, fmt.Errorf("ORDER_TTL is invalid: %w", err)
+	}
+	if orderTTL <= 0 {
+		return 0, errors.New("ORDER_TTL must be positive")
+	}
+	return orderTTL, nil
+}
+
+// transactionProviderFromEnvironment returns the wallet provider order payments are looked up with,
+// ORDER_TRANSACTION_PROVIDER or uphold when unset
+func transactionProviderFromEnvironment() (string, error) {
+	name := os.Getenv("ORDER_TRANSACTION_PROVIDER")
+	if len(name) == 0 {
+		return defaultTransactionProvider, nil
+	}
+	if !provider.Supports(name, provider.PrepareSubmitConfirm) {
+		return "", fmt.Errorf("ORDER_TRANSACTION_PROVIDER %s does not support transaction lookups", name)
+	}
+	return name, nil
+}
+
 // CreateOrderFromRequest creates an order from the request
 func (s *Service) CreateOrderFromRequest(req CreateOrderRequest) (*Order, error) {
 	totalPrice := decimal.New(0, 0)
@@ -257,7 +261,7 @@ func (s *Service) CreateOrderFromRequest(req CreateOrderRequest) (*Order, error)
 	var location string
 
 	for i := 0; i < len(req.Items); i++ {
-		orderItem, err := CreateOrderItemFromMacaroon(req.Items[i].SKU, req.Items[i].Quantity)
+		orderItem, err := CreateOrderItemFromMacaroon(req.Items[i].SKU, req.Items[i].Quantity, s.skuRootKeys)
 		if err != nil {
 			return nil, err
 		}
@@ -278,17 +282,82 @@ func (s *Service) CreateOrderFromRequest(req CreateOrderRequest) (*Order, error)
 		orderItems = append(orderItems, *orderItem)
 	}
 
-	order, err := s.datastore.CreateOrder(totalPrice, "brave.com", "pending", currency, location, orderItems)
+	// the order is attributed to the merchant the verified location of its SKU tokens is allowed for
+	merchant, err := s.datastore.GetMerchantByLocation(location)
+	if err != nil {
+		return nil, err
+	}
+	if merchant == nil {
+		return nil, ErrUnknownSKUMerchant
+	}
+	for i := range orderItems {
+		err = s.checkSKUActive(merchant.ID, &orderItems[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	orderTTL := s.orderTTL
+	if orderTTL == 0 {
+		orderTTL = defaultOrderTTL
+	}
+	expiresAt := time.Now().Add(orderTTL)
+
+	order, err := s.datastore.CreateOrder(totalPrice, merchant.ID, OrderStatusPending, currency, location, &expiresAt, orderItems)
 
 	return order, err
 }
 
-// UpdateOrderStatus checks to see if an order has been paid and updates it if so
+// getPendingOrder returns the order if it is still awaiting payment
+func (s *Service) getPendingOrder(orderID uuid.UUID) (*Order, error) {
+	order, err := s.datastore.GetOrder(orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, ErrOrderNotFound
+	}
+	if order.Status != OrderStatusPending {
+		return nil, ErrOrderNotPending
+	}
+	if order.IsExpired() {
+		return nil, ErrOrderExpired
+	}
+	return order, nil
+}
+
+// CancelOrder of the merchant which has not yet been paid
+func (s *Service) CancelOrder(merchantID string, orderID uuid.UUID) (*Order, error) {
+	err := s.datastore.CancelOrder(merchantID, orderID)
+	if err != nil {
+		return nil, err
+	}
+	return s.datastore.GetOrder(orderID)
+}
+
+// RunNextOrderExpiryJob cancels the pending orders which have passed their expiry
+func (s *Service) RunNextOrderExpiryJob(ctx context.Context) (bool, error) {
+	canceled, err := s.datastore.CancelExpiredOrders()
+	if err != nil {
+		return false, err
+	}
+	countOrdersExpired.Add(float64(canceled))
+	return canceled > 0, nil
+}
+
+// UpdateOrderStatus checks to see if a pending order has been paid and updates it if so
 func (s *Service) UpdateOrderStatus(orderID uuid.UUID) error {
 	order, err := s.datastore.GetOrder(orderID)
 	if err != nil {
 		return err
 	}
+	if order == nil {
+		return ErrOrderNotFound
+	}
+	if order.Status != OrderStatusPending {
+		// payments settling after the order moved on do not change it, those of canceled orders are refunded
+		return nil
+	}
 
 	sum, err := s.datastore.GetSumForTransactions(orderID)
 	if err != nil {
@@ -296,7 +365,7 @@ func (s *Service) UpdateOrderStatus(orderID uuid.UUID) error {
 	}
 
 	if sum.GreaterThanOrEqual(order.TotalPrice) {
-		err = s.datastore.UpdateOrder(orderID, "paid")
+		err = s.datastore.UpdateOrder(orderID, OrderStatusPaid)
 		if err != nil {
 			return err
 		}
@@ -305,11 +374,18 @@ func (s *Service) UpdateOrderStatus(orderID uuid.UUID) error {
 	return nil
 }
 
-// CreateTransactionFromRequest queries the endpoints and creates a transaciton
-func (s *Service) CreateTransactionFromRequest(req CreateTransactionRequest, orderID uuid.UUID) (*Transaction, error) {
-	var wallet uphold.Wallet
-	upholdTransaction, err := wallet.GetTransaction(req.ExternalTransactionID)
+// CreateTransactionFromRequest queries the endpoints and creates a transaciton. Payments are recorded even once the
+// order is no longer pending so they can be refunded, the order is then left unchanged and the reason returned.
+func (s *Service) CreateTransactionFromRequest(ctx context.Context, req CreateTransactionRequest, orderID uuid.UUID) (*Transaction, error) {
+	order, err := s.datastore.GetOrder(orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, ErrOrderNotFound
+	}
 
+	upholdTransaction, err := s.LookupTransaction(ctx, req.ExternalTransactionID)
 	if err != nil {
 		return nil, err
 	}
@@ -317,13 +393,18 @@ func (s *Service) CreateTransactionFromRequest(req CreateTransactionRequest, ord
 	amount := upholdTransaction.AltCurrency.FromProbi(upholdTransaction.Probi)
 	status := upholdTransaction.Status
 	currency := upholdTransaction.AltCurrency.String()
-	kind := "uphold"
+	kind := TransactionKindUphold
 
 	transaction, err := s.datastore.CreateTransaction(orderID, req.ExternalTransactionID, status, currency, kind, amount)
 	if err != nil {
 		return nil, errorutils.Wrap(err, "error recording transaction")
 	}
 
+	_, err = s.getPendingOrder(orderID)
+	if err != nil {
+		return nil, err
+	}
+
 	isPaid, err := s.IsOrderPaid(transaction.OrderID)
 	if err != nil {
 		return nil, errorutils.Wrap(err, "error submitting anon card transaction")
@@ -331,7 +412,7 @@ func (s *Service) CreateTransactionFromRequest(req CreateTransactionRequest, ord
 
 	// If the transaction that was satisifies the order then let's update the status
 	if isPaid {
-		err = s.datastore.UpdateOrder(transaction.OrderID, "paid")
+		err = s.datastore.UpdateOrder(transaction.OrderID, OrderStatusPaid)
 		if err != nil {
 			return nil, errorutils.Wrap(err, "error updating order status")
 		}
@@ -340,14 +421,30 @@ func (s *Service) CreateTransactionFromRequest(req CreateTransactionRequest, ord
 	return transaction, err
 }
 
-// CreateAnonCardTransaction takes a signed transaction and executes it on behalf of an anon card
+// CreateAnonCardTransaction takes a signed transaction and executes it on behalf of an anon card. The payment is
+// reserved before it is submitted so the order cannot be canceled until its result is recorded.
 func (s *Service) CreateAnonCardTransaction(ctx context.Context, walletID uuid.UUID, transaction string, orderID uuid.UUID) (*Transaction, error) {
+	_, err := s.getPendingOrder(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	reserved, err := s.datastore.ReservePayment(orderID, TransactionKindAnonymousCard)
+	if err != nil {
+		return nil, err
+	}
+
 	txInfo, err := s.wallet.SubmitAnonCardTransaction(ctx, walletID, transaction)
 	if err != nil {
+		_, recordErr := s.datastore.RecordPayment(reserved.ID, reserved.ExternalTransactionID, w.TransactionFailed, reserved.Currency, reserved.Amount)
+		if recordErr != nil {
+			// reconciliation fails the reservation instead
+			log.Printf("failed to record failed anon card transaction: %s", recordErr)
+		}
 		return nil, errorutils.Wrap(err, "error submitting anon card transaction")
 	}
 
-	txn, err := s.datastore.CreateTransaction(orderID, txInfo.ID, txInfo.Status, txInfo.DestCurrency, "anonymous-card", txInfo.DestAmount)
+	txn, err := s.datastore.RecordPayment(reserved.ID, txInfo.ID, txInfo.Status, txInfo.DestCurrency, txInfo.DestAmount)
 	if err != nil {
 		return nil, errorutils.Wrap(err, "error recording anon card transaction")
 	}
@@ -376,7 +473,77 @@ func (s *Service) IsOrderPaid(orderID uuid.UUID) (bool, error) {
 	return sum.GreaterThanOrEqual(order.TotalPrice), nil
 }
 
+// RunNextVoteOutboxJob publishes the next batch of vote events queued in the outbox
+func (s *Service) RunNextVoteOutboxJob(ctx context.Context) (bool, error) {
+	return s.datastore.RunNextOutboxJob(ctx, voteTopic, s.publisher)
+}
+
+// RunNextVoteOutboxRetentionJob deletes the next batch of vote events published longer ago than the retention
+func (s *Service) RunNextVoteOutboxRetentionJob(ctx context.Context) (bool, error) {
+	return s.datastore.DeletePublishedOutboxEvents(ctx, voteTopic, time.Now().Add(-grantserver.OutboxRetention))
+}
+
 // RunNextOrderJob takes the next order job and completes it
 func (s *Service) RunNextOrderJob(ctx context.Context) (bool, error) {
 	return s.datastore.RunNextOrderJob(ctx, s)
 }
+
+// TransactionReconciler looks up the current state of a transaction and updates the order it belongs to
+type TransactionReconciler interface {
+	LookupTransaction(ctx context.Context, transactionID string) (*w.TransactionInfo, error)
+	UpdateOrderStatus(orderID uuid.UUID) error
+}
+
+// LookupTransaction from the wallet provider
+func (s *Service) LookupTransaction(ctx context.Context, transactionID string) (*w.TransactionInfo, error) {
+	wallet, err := s.transactionWallet()
+	if err != nil {
+		return nil, err
+	}
+	return wallet.GetTransaction(ctx, transactionID)
+}
+
+// transactionWallet returns a wallet of the provider order payments are made with, used to look up transactions by id
+func (s *Service) transactionWallet() (w.TransactionPreparer, error) {
+	name := s.transactionProvider
+	if len(name) == 0 {
+		name = defaultTransactionProvider
+	}
+	if !provider.Supports(name, provider.PrepareSubmitConfirm) {
+		return nil, fmt.Errorf("wallet provider %s does not support transaction lookups", name)
+	}
+
+	bat := altcurrency.BAT
+	wallet, err := provider.GetWallet(w.Info{Provider: name, AltCurrency: &bat})
+	if err != nil {
+		return nil, err
+	}
+	return wallet.(w.TransactionPreparer), nil
+}
+
+// RunNextTransactionReconcileJob updates the stuck transaction gauge and checks the next unfinished transaction
+func (s *Service) RunNextTransactionReconcileJob(ctx context.Context) (bool, error) {
+	stuck, err := s.datastore.CountStuckTransactions(stuckTransactionAge)
+	if err != nil {
+		return false, err
+	}
+	transactionsStuck.Set(float64(stuck))
+
+	return s.datastore.RunNextTransactionReconcileJob(ctx, s)
+}
+
+// UpdateTransactionStatus of an order transaction as notified by the wallet provider, updating the order if needed
+func (s *Service) UpdateTransactionStatus(ctx context.Context, transactionID string, status string) (bool, error) {
+	transaction, err := s.datastore.UpdateTransactionStatus(transactionID, status)
+	if err != nil {
+		return false, err
+	}
+
+	if transaction == nil {
+		// either unknown or already up to date
+		existing, err := s.datastore.GetTransaction(transactionID)
+		return existing != nil, err
+	}
+
+	return true, s.UpdateOrderStatus(transaction.OrderID)
+}
@@ -0,0 +1,56 @@
+package payment
+
+import (
+	"context"
+	"crypto"
+	"os"
+	"testing"
+
+	"github.com/brave-intl/bat-go/utils/httpsignature"
+	"github.com/brave-intl/bat-go/wallet"
+	"github.com/brave-intl/bat-go/wallet/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lookupWallet returns a completed transaction for every id
+type lookupWallet struct {
+	wallet.TransactionPreparer
+}
+
+func (w *lookupWallet) GetTransaction(ctx context.Context, id string) (*wallet.TransactionInfo, error) {
+	return &wallet.TransactionInfo{ID: id, Status: wallet.TransactionCompleted, Source: "payer"}, nil
+}
+
+func TestLookupTransactionProvider(t *testing.T) {
+	defer provider.Unregister("payment-lookup")
+	provider.Register("payment-lookup", func(info wallet.Info, signer crypto.Signer, verifier httpsignature.Verifier) (wallet.Wallet, error) {
+		return &lookupWallet{}, nil
+	}, provider.PrepareSubmitConfirm)
+
+	service := &Service{transactionProvider: "payment-lookup"}
+	txInfo, err := service.LookupTransaction(context.Background(), "transaction")
+	require.NoError(t, err, "transactions should be looked up with the registered provider")
+	assert.Equal(t, "payer", txInfo.Source)
+
+	service.transactionProvider = "nonexistent"
+	_, err = service.LookupTransaction(context.Background(), "transaction")
+	assert.Error(t, err)
+}
+
+func TestTransactionProviderFromEnvironment(t *testing.T) {
+	defer os.Unsetenv("ORDER_TRANSACTION_PROVIDER")
+
+	name, err := transactionProviderFromEnvironment()
+	require.NoError(t, err)
+	assert.Equal(t, defaultTransactionProvider, name)
+
+	os.Setenv("ORDER_TRANSACTION_PROVIDER", "nonexistent")
+	_, err = transactionProviderFromEnvironment()
+	assert.Error(t, err, "providers which cannot look up transactions should be rejected")
+
+	os.Setenv("ORDER_TRANSACTION_PROVIDER", "uphold")
+	name, err = transactionProviderFromEnvironment()
+	require.NoError(t, err)
+	assert.Equal(t, "uphold", name)
+}
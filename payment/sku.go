@@ -0,0 +1,146 @@
+package payment
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/brave-intl/bat-go/utils/altcurrency"
+	"github.com/brave-intl/bat-go/utils/datastore"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	macaroon "gopkg.in/macaroon.v2"
+)
+
+// skuTokenIdentifier is the identifier of the SKU tokens minted by the catalog
+const skuTokenIdentifier = "Brave SKU v1.0"
+
+var (
+	// ErrSKUInactive is returned when an order includes a SKU which has been revoked
+	ErrSKUInactive = fmt.Errorf("%w: inactive", ErrInvalidSKU)
+	// ErrNoMerchantKey is returned when minting a SKU token for a merchant without an active key
+	ErrNoMerchantKey = errors.New("merchant has no active key to sign SKU tokens with")
+	// ErrSKUExists is returned when the merchant already has a SKU with the same code
+	ErrSKUExists = errors.New("merchant already has a SKU with this code")
+	// errInvalidSKUFields is returned when a SKU cannot be added to the catalog as requested
+	errInvalidSKUFields = errors.New("invalid SKU")
+)
+
+// SKU is an item in the catalog of a merchant, along with the signed token orders for it are created with
+type SKU struct {
+	ID          uuid.UUID            `json:"id" db:"id"`
+	MerchantID  string               `json:"merchantId" db:"merchant_id"`
+	SKU         string               `json:"sku" db:"sku"`
+	Location    string               `json:"location" db:"location"`
+	Price       decimal.Decimal      `json:"price" db:"price"`
+	Currency    string               `json:"currency" db:"currency"`
+	Description datastore.NullString `json:"description" db:"description"`
+	ExpiresAt   *time.Time           `json:"expiresAt" db:"expires_at"`
+	KeyID       uuid.UUID            `json:"keyId" db:"key_id"`
+	Token       string               `json:"token" db:"token"`
+	Active      bool                 `json:"active" db:"active"`
+	CreatedAt   time.Time            `json:"createdAt" db:"created_at"`
+	UpdatedAt   time.Time            `json:"updatedAt" db:"updated_at"`
+}
+
+// caveats of the SKU token, each of which is in the allowed SKU caveats
+func (sku *SKU) caveats() []string {
+	caveats := []string{
+		"sku = " + sku.SKU,
+		"price = " + sku.Price.String(),
+		"currency = " + sku.Currency,
+	}
+	if sku.Description.Valid {
+		caveats = append(caveats, "description = "+sku.Description.String)
+	}
+	if sku.ExpiresAt != nil {
+		caveats = append(caveats, fmt.Sprintf("expiry = %d", sku.ExpiresAt.Unix()))
+	}
+	return caveats
+}
+
+// mintSKUToken signs a token for the SKU with the root key
+func mintSKUToken(sku *SKU, rootKey []byte) (string, error) {
+	mac, err := macaroon.New(rootKey, []byte(skuTokenIdentifier), sku.Location, macaroon.LatestVersion)
+	if err != nil {
+		return "", err
+	}
+	for _, caveat := range sku.caveats() {
+		if err := mac.AddFirstPartyCaveat([]byte(caveat)); err != nil {
+			return "", err
+		}
+	}
+	macBytes, err := mac.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(macBytes), nil
+}
+
+// CreateSKU adds a SKU to the catalog of the merchant, minting its token with the newest key of the merchant.
+// The token is issued for the location of the SKU, which defaults to the first location allowed for the merchant.
+func (s *Service) CreateSKU(merchantID string, sku SKU) (*SKU, error) {
+	if _, err := altcurrency.FromString(sku.Currency); err != nil {
+		return nil, fmt.Errorf("%w: unsupported currency %q", errInvalidSKUFields, sku.Currency)
+	}
+	if sku.Price.IsNegative() {
+		return nil, fmt.Errorf("%w: price must not be negative", errInvalidSKUFields)
+	}
+	if sku.ExpiresAt != nil && sku.ExpiresAt.Before(time.Now()) {
+		return nil, fmt.Errorf("%w: expiry must be in the future", errInvalidSKUFields)
+	}
+
+	merchant, err := s.getMerchant(merchantID)
+	if err != nil {
+		return nil, err
+	}
+	if len(sku.Location) == 0 && len(merchant.Locations) > 0 {
+		sku.Location = merchant.Locations[0]
+	}
+	if !merchant.AllowsLocation(sku.Location) {
+		return nil, ErrLocationNotAllowed
+	}
+
+	existing, err := s.datastore.GetSKU(merchantID, sku.SKU)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrSKUExists
+	}
+
+	keys, err := s.datastore.GetKeys(merchantID, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoMerchantKey
+	}
+	key := keys[len(keys)-1]
+	rootKey, err := key.GetSecretKey(s.encryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt key %s: %w", key.ID, err)
+	}
+
+	sku.MerchantID = merchantID
+	sku.KeyID = key.ID
+	sku.Token, err = mintSKUToken(&sku, rootKey)
+	if err != nil {
+		return nil, err
+	}
+	return s.datastore.CreateSKU(&sku)
+}
+
+// checkSKUActive rejects order items for SKUs which have been revoked from the catalog of their merchant.
+// SKU tokens minted outside of the catalog are not tracked and remain valid until their key is expired.
+func (s *Service) checkSKUActive(merchantID string, orderItem *OrderItem) error {
+	sku, err := s.datastore.GetSKU(merchantID, orderItem.SKU)
+	if err != nil {
+		return err
+	}
+	if sku != nil && !sku.Active {
+		return fmt.Errorf("%w: %q", ErrSKUInactive, orderItem.SKU)
+	}
+	return nil
+}
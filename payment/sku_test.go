@@ -0,0 +1,142 @@
+package payment
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// catalogDatastore keeps merchants along with their keys and SKUs in memory
+type catalogDatastore struct {
+	Datastore
+	merchants []Merchant
+	keys      []Key
+	skus      []SKU
+}
+
+func (d *catalogDatastore) GetMerchant(merchantID string) (*Merchant, error) {
+	for _, merchant := range d.merchants {
+		if merchant.ID == merchantID {
+			return &merchant, nil
+		}
+	}
+	return nil, nil
+}
+
+func (d *catalogDatastore) GetMerchantByLocation(location string) (*Merchant, error) {
+	for _, merchant := range d.merchants {
+		if merchant.AllowsLocation(location) {
+			return &merchant, nil
+		}
+	}
+	return nil, nil
+}
+
+func (d *catalogDatastore) GetMerchantByAPIKeyHash(apiKeyHash string) (*Merchant, error) {
+	for _, merchant := range d.merchants {
+		if merchant.APIKeyHash.String == apiKeyHash {
+			return &merchant, nil
+		}
+	}
+	return nil, nil
+}
+
+func (d *catalogDatastore) CreateKey(merchantID string, name string, encryptedSecretKey string, nonce string) (*Key, error) {
+	key := Key{ID: uuid.NewV4(), MerchantID: merchantID, Name: name, EncryptedSecretKey: encryptedSecretKey, Nonce: nonce}
+	d.keys = append(d.keys, key)
+	return &key, nil
+}
+
+func (d *catalogDatastore) GetKeys(merchantID string, showExpired bool) ([]Key, error) {
+	keys := []Key{}
+	for _, key := range d.keys {
+		if key.MerchantID == merchantID {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (d *catalogDatastore) CreateSKU(sku *SKU) (*SKU, error) {
+	created := *sku
+	created.ID = uuid.NewV4()
+	created.Active = true
+	d.skus = append(d.skus, created)
+	return &created, nil
+}
+
+func (d *catalogDatastore) GetSKU(merchantID string, code string) (*SKU, error) {
+	for _, sku := range d.skus {
+		if sku.MerchantID == merchantID && sku.SKU == code {
+			return &sku, nil
+		}
+	}
+	return nil, nil
+}
+
+func (d *catalogDatastore) RevokeSKU(merchantID string, skuID uuid.UUID) (bool, error) {
+	for i := range d.skus {
+		if d.skus[i].MerchantID == merchantID && uuid.Equal(d.skus[i].ID, skuID) {
+			d.skus[i].Active = false
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (d *catalogDatastore) CreateOrder(totalPrice decimal.Decimal, merchantID string, status string, currency string, location string, expiresAt *time.Time, orderItems []OrderItem) (*Order, error) {
+	return &Order{ID: uuid.NewV4(), TotalPrice: totalPrice, MerchantID: merchantID, Status: status, Currency: currency, ExpiresAt: expiresAt, Items: orderItems}, nil
+}
+
+func TestSKUCatalog(t *testing.T) {
+	service := &Service{datastore: &catalogDatastore{
+		merchants: []Merchant{{ID: "brave", Locations: []string{"brave.com", "search.brave.com"}}},
+	}}
+	copy(service.encryptionKey[:], "0123456789abcdef0123456789abcdef")
+
+	_, err := service.CreateSKU("brave", SKU{SKU: "BRAVE-12345", Price: decimal.NewFromFloat(0.25), Currency: "BAT"})
+	assert.Equal(t, ErrNoMerchantKey, err, "SKUs cannot be created before the merchant has a key")
+
+	_, err = service.CreateKey("unknown", "catalog")
+	assert.Equal(t, ErrMerchantNotFound, err)
+	_, err = service.CreateKey("brave", "catalog")
+	require.NoError(t, err)
+
+	_, err = service.CreateSKU("brave", SKU{SKU: "BRAVE-12345", Location: "example.com", Price: decimal.NewFromFloat(0.25), Currency: "BAT"})
+	assert.Equal(t, ErrLocationNotAllowed, err)
+
+	expiresAt := time.Now().Add(time.Hour)
+	sku := SKU{SKU: "BRAVE-12345", Price: decimal.NewFromFloat(0.25), Currency: "BAT", ExpiresAt: &expiresAt}
+	sku.Description.String = "12 ounces of Coffee"
+	sku.Description.Valid = true
+	created, err := service.CreateSKU("brave", sku)
+	require.NoError(t, err)
+	assert.Equal(t, "brave.com", created.Location, "The token should be issued for the first location of the merchant")
+
+	_, err = service.CreateSKU("brave", sku)
+	assert.Equal(t, ErrSKUExists, err)
+
+	_, err = service.CreateSKU("brave", SKU{SKU: "BRAVE-FREE", Price: decimal.NewFromFloat(-1), Currency: "BAT"})
+	assert.True(t, errors.Is(err, errInvalidSKUFields))
+
+	req := CreateOrderRequest{Items: []OrderItemRequest{{SKU: created.Token, Quantity: 4}}}
+	order, err := service.CreateOrderFromRequest(req)
+	require.NoError(t, err, "The minted token should be verified with the key of the merchant")
+	assert.Equal(t, "1", order.TotalPrice.String())
+	assert.Equal(t, "brave", order.MerchantID, "The order should be attributed to the merchant of the location")
+	require.Len(t, order.Items, 1)
+	assert.Equal(t, "BRAVE-12345", order.Items[0].SKU)
+	assert.Equal(t, "12 ounces of Coffee", order.Items[0].Description.String)
+
+	revoked, err := service.datastore.RevokeSKU("brave", created.ID)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	_, err = service.CreateOrderFromRequest(req)
+	assert.True(t, errors.Is(err, ErrSKUInactive), "Orders should not be created for revoked SKUs")
+}
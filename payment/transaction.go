@@ -7,6 +7,21 @@ import (
 	"github.com/shopspring/decimal"
 )
 
+const (
+	// TransactionKindUphold transactions are payments made from an uphold card
+	TransactionKindUphold = "uphold"
+	// TransactionKindAnonymousCard transactions are payments made from an anonymous card on behalf of a wallet
+	TransactionKindAnonymousCard = "anonymous-card"
+	// TransactionKindRefund transactions return part or all of the payment of an order to the payer
+	TransactionKindRefund = "refund"
+
+	// TransactionStatusReserved refunds have reserved the refunded quantities but not yet submitted their transfer,
+	// reserved payments hold a pending order open while they are submitted
+	TransactionStatusReserved = "reserved"
+	// TransactionStatusSubmitted refunds have submitted their transfer, which moves funds once it is confirmed
+	TransactionStatusSubmitted = "submitted"
+)
+
 // Transaction includes information about a particular order. Status can be pending, failure, completed, or error.
 type Transaction struct {
 	ID                    uuid.UUID       `json:"id" db:"id"`
@@ -18,4 +33,5 @@ type Transaction struct {
 	Currency              string          `json:"currency" db:"currency"`
 	Kind                  string          `json:"kind" db:"kind"`
 	Amount                decimal.Decimal `json:"amount" db:"amount"`
+	StatusCheckedAt       *time.Time      `json:"-" db:"status_checked_at"`
 }
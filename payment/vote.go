@@ -4,19 +4,21 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
 	"github.com/asaskevich/govalidator"
+	"github.com/brave-intl/bat-go/datastore/grantserver"
+	"github.com/brave-intl/bat-go/utils/avro"
 	"github.com/brave-intl/bat-go/utils/clients/cbr"
 	appctx "github.com/brave-intl/bat-go/utils/context"
 	errorutils "github.com/brave-intl/bat-go/utils/errors"
 	"github.com/brave-intl/bat-go/utils/inputs"
+	srv "github.com/brave-intl/bat-go/utils/service"
 	"github.com/jmoiron/sqlx"
-	"github.com/linkedin/goavro"
 	uuid "github.com/satori/go.uuid"
-	kafka "github.com/segmentio/kafka-go"
 	"github.com/shopspring/decimal"
 )
 
@@ -99,8 +101,8 @@ func NewVoteEvent(v Vote) (*VoteEvent, error) {
 }
 
 // CodecEncode - encode using avro vote codec
-func (ve *VoteEvent) CodecEncode(codec *goavro.Codec) ([]byte, error) {
-	return codec.BinaryFromNative(nil, map[string]interface{}{
+func (ve *VoteEvent) CodecEncode(codec *avro.Codec) ([]byte, error) {
+	return codec.Encode(map[string]interface{}{
 		"type":          ve.Type,
 		"channel":       ve.Channel,
 		"id":            ve.ID.String(),
@@ -111,9 +113,9 @@ func (ve *VoteEvent) CodecEncode(codec *goavro.Codec) ([]byte, error) {
 	})
 }
 
-// CodecDecode - Decode using avro vote codec
-func (ve *VoteEvent) CodecDecode(codec *goavro.Codec, binary []byte) error {
-	native, _, err := codec.NativeFromBinary(binary)
+// CodecDecode - Decode using avro vote codec, resolving older schema versions to the latest
+func (ve *VoteEvent) CodecDecode(ctx context.Context, codec *avro.Codec, binary []byte) error {
+	native, err := codec.Decode(ctx, binary)
 	if err != nil {
 		return errorutils.Wrap(err, "error decoding vote")
 	}
@@ -163,29 +165,71 @@ func (service *Service) RunNextVoteDrainJob(ctx context.Context) (bool, error) {
 			err := json.Unmarshal([]byte(record.RequestCredentials), &requestCredentials)
 			if err != nil {
 				log.Printf("failed to decode credentials: %s", err)
-				if err := service.datastore.MarkVoteErrored(ctx, *record, tx); err != nil {
+				// the credentials will never decode, dead-letter the vote
+				if err := service.datastore.MarkVoteErrored(ctx, *record, tx, srv.Terminal(err)); err != nil {
 					return true, rollbackTx(service.datastore, tx, "failed to mark vote as errored for creds redemption", err)
 				}
-				// okay if it is errored, we will update the errored column
+				continue
+			}
+			var event VoteEvent
+			if err := event.CodecDecode(ctx, service.codecs["vote"], record.VoteEventBinary); err != nil {
+				log.Printf("failed to decode vote event: %s", err)
+				if err := service.datastore.MarkVoteErrored(ctx, *record, tx, srv.Terminal(err)); err != nil {
+					return true, rollbackTx(service.datastore, tx, "failed to mark vote as errored for event decoding", err)
+				}
+				continue
+			}
+			// the event is published with the latest schema version
+			payload, err := event.CodecEncode(service.codecs["vote"])
+			if err != nil {
+				log.Printf("failed to encode vote event: %s", err)
+				if err := service.datastore.MarkVoteErrored(ctx, *record, tx, srv.Terminal(err)); err != nil {
+					return true, rollbackTx(service.datastore, tx, "failed to mark vote as errored for event encoding", err)
+				}
+				continue
+			}
+			// claim the credentials before redeeming them so that a retry can tell its own redemption from a
+			// duplicate, a previous attempt may have redeemed the credentials and failed before the vote was committed
+			retry, err := service.datastore.ClaimCredentialRedemptions(ctx, record.ID, requestCredentials)
+			if errors.Is(err, grantserver.ErrCredentialsClaimed) {
+				log.Printf("credentials already claimed: %s", err)
+				if err := service.datastore.MarkVoteErrored(ctx, *record, tx, srv.Terminal(err)); err != nil {
+					return true, rollbackTx(service.datastore, tx, "failed to mark vote as errored for creds redemption", err)
+				}
+				continue
+			}
+			if err != nil {
+				return true, rollbackTx(service.datastore, tx, "failed to claim vote credentials", err)
 			}
 			// redeem the credentials
 			err = service.cbClient.RedeemCredentials(ctx, requestCredentials, record.VoteText)
+			if err != nil && retry && cbr.IsDuplicateRedemption(err) {
+				// the previous attempt redeemed the credentials, commit the vote now
+				log.Printf("vote credentials already redeemed, queueing event %s", event.ID)
+				err = nil
+			}
 			if err != nil {
-				if err := service.datastore.MarkVoteErrored(ctx, *record, tx); err != nil {
+				log.Printf("failed to redeem credentials: %s", err)
+				if cbr.IsDuplicateRedemption(err) {
+					// redeemed elsewhere, release the claim so a requeued vote does not take the redemption for its own
+					if err := service.datastore.ReleaseCredentialRedemptions(ctx, record.ID); err != nil {
+						return true, rollbackTx(service.datastore, tx, "failed to release vote credentials", err)
+					}
+				}
+				// retried with backoff unless the redemption was rejected
+				if err := service.datastore.MarkVoteErrored(ctx, *record, tx, err); err != nil {
 					return true, rollbackTx(service.datastore, tx, "failed to mark vote as errored for creds redemption", err)
 				}
-				// okay if errored, update errored column
-			}
-			// write the message to kafka if successful
-			if err = service.kafkaWriter.WriteMessages(ctx,
-				kafka.Message{
-					Value: record.VoteEventBinary,
-				},
-			); err != nil {
-				return true, rollbackTx(service.datastore, tx, "failed to write vote to kafka", err)
+				continue
 			}
-			// update the particular record to not be picked again
-			if err = service.datastore.CommitVote(ctx, *record, tx); err != nil {
+			// update the particular record to not be picked again and queue the event, votes
+			// for a channel are published in order
+			if err = service.datastore.CommitVote(ctx, *record, tx, grantserver.OutboxEvent{
+				ID:      event.ID,
+				Topic:   voteTopic,
+				Key:     event.Channel,
+				Payload: payload,
+			}); err != nil {
 				return true, rollbackTx(service.datastore, tx, "failed to commit vote to drain vote queue", err)
 			}
 		}
@@ -193,7 +237,7 @@ func (service *Service) RunNextVoteDrainJob(ctx context.Context) (bool, error) {
 		if err := tx.Commit(); err != nil {
 			return true, fmt.Errorf("failed to commit transaction in drain vote queue: %w", err)
 		}
-		return true, nil
+		return len(records) > 0, nil
 	}
 }
 
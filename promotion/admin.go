@@ -0,0 +1,96 @@
+package promotion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// PromotionActivated is the audit action recorded when a promotion is activated
+	PromotionActivated = "activate"
+	// PromotionDeactivated is the audit action recorded when a promotion is deactivated
+	PromotionDeactivated = "deactivate"
+	// PromotionExtended is the audit action recorded when the expiry of a promotion is changed
+	PromotionExtended = "extend"
+	// PromotionExpired is the audit action recorded when a promotion is expired immediately
+	PromotionExpired = "expire"
+	// PromotionToppedUp is the audit action recorded when the remaining grants of a promotion are adjusted
+	PromotionToppedUp = "top-up"
+	// PromotionBudgeted is the audit action recorded when the budget of a promotion is changed
+	PromotionBudgeted = "budget"
+)
+
+var (
+	errNegativeRemainingGrants = errors.New("remaining grants cannot be negative")
+	errNegativeBudget          = errors.New("budget cannot be negative")
+)
+
+// PromotionChanges to apply to a promotion, unset fields are left unchanged
+type PromotionChanges struct {
+	Active               *bool
+	ExpiresAt            *time.Time
+	ClaimableUntil       *time.Time
+	RedeemableUntil      *time.Time
+	RemainingGrantsDelta int
+	Budget               *decimal.Decimal
+}
+
+// PromotionState is the lifecycle state of a promotion recorded before and after each change
+type PromotionState struct {
+	Active          bool             `json:"active"`
+	ExpiresAt       time.Time        `json:"expiresAt"`
+	ClaimableUntil  time.Time        `json:"claimableUntil"`
+	RedeemableUntil time.Time        `json:"redeemableUntil"`
+	RemainingGrants int              `json:"remainingGrants"`
+	Budget          *decimal.Decimal `json:"budget,omitempty"`
+}
+
+// State of the promotion lifecycle
+func (promotion *Promotion) State() PromotionState {
+	return PromotionState{
+		Active:          promotion.Active,
+		ExpiresAt:       promotion.ExpiresAt,
+		ClaimableUntil:  promotion.ClaimableUntil,
+		RedeemableUntil: promotion.RedeemableUntil,
+		RemainingGrants: promotion.RemainingGrants,
+		Budget:          promotion.Budget,
+	}
+}
+
+// PromotionAudit records a single change made to a promotion
+type PromotionAudit struct {
+	ID          uuid.UUID       `json:"id" db:"id"`
+	PromotionID uuid.UUID       `json:"promotionId" db:"promotion_id"`
+	CreatedAt   time.Time       `json:"createdAt" db:"created_at"`
+	Actor       string          `json:"actor" db:"actor"`
+	Action      string          `json:"action" db:"action"`
+	Previous    json.RawMessage `json:"previous" db:"previous"`
+	Updated     json.RawMessage `json:"updated" db:"updated"`
+}
+
+// PromotionStats includes a promotion along with a summary of its claims
+type PromotionStats struct {
+	Promotion
+	ClaimCount  int             `db:"claim_count"`
+	IssuedValue decimal.Decimal `db:"issued_value"`
+}
+
+// UpdatePromotion applies the changes to the promotion, recording who made them
+func (service *Service) UpdatePromotion(ctx context.Context, promotionID uuid.UUID, action string, actor string, changes PromotionChanges) (*Promotion, error) {
+	return service.datastore.UpdatePromotion(ctx, promotionID, action, actor, changes)
+}
+
+// ListPromotions returns all promotions along with a summary of their claims
+func (service *Service) ListPromotions(ctx context.Context) ([]PromotionStats, error) {
+	return service.datastore.GetPromotionsWithStats(ctx)
+}
+
+// GetPromotionAudit returns the changes made to a promotion, most recent first
+func (service *Service) GetPromotionAudit(ctx context.Context, promotionID uuid.UUID) ([]PromotionAudit, error) {
+	return service.datastore.GetPromotionAudit(ctx, promotionID)
+}
@@ -1,6 +1,37 @@
 package promotion
 
-const suggestionEventSchema = `{
+const suggestionEventSchemaV1 = `{
+  "namespace": "brave.grants",
+  "type": "record",
+  "name": "suggestion",
+  "doc": "This message is sent when a client suggests to 'spend' a grant",
+  "fields": [
+    { "name": "id", "type": "string" },
+    { "name": "type", "type": "string" },
+    { "name": "channel", "type": "string" },
+    { "name": "createdAt", "type": "string" },
+    { "name": "totalAmount", "type": "string" },
+    { "name": "funding",
+      "type": {
+        "type": "array",
+        "items": {
+          "type": "record",
+          "name": "funding",
+          "doc": "This record represents a funding source, currently a promotion.",
+          "fields": [
+            { "name": "type", "type": "string" },
+            { "name": "amount", "type": "string" },
+            { "name": "cohort", "type": "string" },
+            { "name": "promotion", "type": "string" }
+          ]
+        }
+      }
+    }
+  ]
+}`
+
+// suggestionEventSchemaV2 adds the order being paid for
+const suggestionEventSchemaV2 = `{
   "namespace": "brave.grants",
   "type": "record",
   "name": "suggestion",
@@ -30,3 +61,7 @@ const suggestionEventSchema = `{
     }
   ]
 }`
+
+// suggestionEventSchemas are the versions of the suggestion event schema, oldest first. Add a version rather
+// than changing an existing one, new versions must be able to read events written with the previous.
+var suggestionEventSchemas = []string{suggestionEventSchemaV1, suggestionEventSchemaV2}
@@ -0,0 +1,155 @@
+package promotion
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// PromotionBudgetExhausted is the audit action recorded when a promotion is deactivated because its budget is spent
+	PromotionBudgetExhausted = "budget-exhausted"
+	// budgetActor is recorded as the actor of changes made when a budget is exhausted
+	budgetActor = "budget"
+)
+
+var (
+	// ErrBudgetExceeded is returned when a claim would issue more value than remains in the budget of its promotion
+	ErrBudgetExceeded = errors.New("claim exceeds the remaining budget of the promotion")
+
+	promotionBudgetBat = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "promotion_budget_bat",
+			Help: "the BAT budget of each active promotion with a budget",
+		},
+		[]string{"promotion_id", "type"},
+	)
+	promotionBudgetSpentBat = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "promotion_budget_spent_bat",
+			Help: "the value of each active promotion with a budget which has been claimed, redeemed or drained",
+		},
+		[]string{"promotion_id", "type", "kind"},
+	)
+	countPromotionsBudgetExhausted = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "promotions_budget_exhausted_total",
+			Help: "A counter for the number of promotions deactivated because their budget was spent",
+		},
+		[]string{"type"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		promotionBudgetBat,
+		promotionBudgetSpentBat,
+		countPromotionsBudgetExhausted,
+	)
+}
+
+// PromotionBudget outlines how much of the budget of a promotion has been spent
+// Claimed value counts against the budget, redeemed and drained value is the part of it which has been paid out
+type PromotionBudget struct {
+	PromotionID uuid.UUID        `json:"promotionId" db:"promotion_id"`
+	Type        string           `json:"type" db:"promotion_type"`
+	Active      bool             `json:"active" db:"active"`
+	Budget      *decimal.Decimal `json:"budget" db:"budget"`
+	Claimed     decimal.Decimal  `json:"claimed" db:"claimed"`
+	Redeemed    decimal.Decimal  `json:"redeemed" db:"redeemed"`
+	Drained     decimal.Decimal  `json:"drained" db:"drained"`
+}
+
+// Remaining budget of the promotion, nil if the promotion has no budget
+func (budget *PromotionBudget) Remaining() *decimal.Decimal {
+	if budget.Budget == nil {
+		return nil
+	}
+	remaining := budget.Budget.Sub(budget.Claimed)
+	return &remaining
+}
+
+// ChargePromotionBudget with the value of a claim within the transaction making it. The claim is rejected if it
+// exceeds the budget, the promotion is deactivated once the remaining budget cannot cover another grant.
+func ChargePromotionBudget(tx *sqlx.Tx, promotionID uuid.UUID, value decimal.Decimal) error {
+	promotions := []Promotion{}
+	statement := `
+	update promotions
+	set claimed_value = claimed_value + $2
+	where id = $1
+	returning *`
+	err := tx.Select(&promotions, statement, promotionID, value)
+	if err != nil {
+		return err
+	}
+	if len(promotions) != 1 {
+		return errors.New("no matching promotion")
+	}
+	promotion := promotions[0]
+
+	if promotion.Budget == nil {
+		return nil
+	}
+	remaining := promotion.Budget.Sub(promotion.ClaimedValue)
+	if remaining.IsNegative() {
+		return ErrBudgetExceeded
+	}
+	if !promotion.Active || remaining.GreaterThanOrEqual(promotion.ApproximateValue) {
+		return nil
+	}
+
+	_, err = tx.Exec(`update promotions set active = false where id = $1`, promotionID)
+	if err != nil {
+		return err
+	}
+
+	previous := promotion.State()
+	updated := previous
+	updated.Active = false
+	err = insertPromotionAudit(tx, promotionID, PromotionBudgetExhausted, budgetActor, previous, updated)
+	if err != nil {
+		return err
+	}
+
+	countPromotionsBudgetExhausted.With(prometheus.Labels{"type": promotion.Type}).Inc()
+	return nil
+}
+
+// GetPromotionBudget returns how much of the budget of a promotion has been spent
+func (service *Service) GetPromotionBudget(ctx context.Context, promotionID uuid.UUID) (*PromotionBudget, error) {
+	return service.datastore.GetPromotionBudget(ctx, promotionID)
+}
+
+// RunNextBudgetGaugeJob updates the budget gauges of the active promotions with a budget
+// It always reports that no job was attempted so that it only runs at its cadence
+func (service *Service) RunNextBudgetGaugeJob(ctx context.Context) (bool, error) {
+	budgets, err := service.datastore.GetActivePromotionBudgets(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// deactivated promotions are removed from the gauges
+	promotionBudgetBat.Reset()
+	promotionBudgetSpentBat.Reset()
+	for _, budget := range budgets {
+		labels := prometheus.Labels{"promotion_id": budget.PromotionID.String(), "type": budget.Type}
+		total, _ := budget.Budget.Float64()
+		promotionBudgetBat.With(labels).Set(total)
+
+		spent := map[string]decimal.Decimal{
+			"claimed":  budget.Claimed,
+			"redeemed": budget.Redeemed,
+			"drained":  budget.Drained,
+		}
+		for kind, value := range spent {
+			labels := prometheus.Labels{"promotion_id": budget.PromotionID.String(), "type": budget.Type, "kind": kind}
+			v, _ := value.Float64()
+			promotionBudgetSpentBat.With(labels).Set(v)
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,17 @@
+package promotion
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPromotionBudgetRemaining(t *testing.T) {
+	budget := PromotionBudget{Claimed: decimal.NewFromFloat(12.5)}
+	assert.Nil(t, budget.Remaining(), "Promotions without a budget have no remaining budget")
+
+	total := decimal.NewFromFloat(20.0)
+	budget.Budget = &total
+	assert.True(t, decimal.NewFromFloat(7.5).Equal(*budget.Remaining()))
+}
@@ -27,6 +27,7 @@ type Claim struct {
 	LegacyClaimed    bool            `db:"legacy_claimed"`
 	RedeemedAt       pq.NullTime     `db:"redeemed_at"`
 	Drained          bool            `db:"drained"`
+	Cohort           string          `db:"cohort"`
 }
 
 // SuggestionsNeeded calculates the number of suggestion credentials needed to fulfill the value of this claim
@@ -52,7 +53,7 @@ type ClaimCreds struct {
 }
 
 // ClaimPromotionForWallet attempts to claim the promotion on behalf of a wallet and returning the ClaimID
-// It kicks off asynchronous signing of the credentials on success
+// The credentials are signed asynchronously by the claim job, which is notified when the claim is inserted
 func (service *Service) ClaimPromotionForWallet(
 	ctx context.Context,
 	promotionID uuid.UUID,
@@ -81,6 +82,9 @@ func (service *Service) ClaimPromotionForWallet(
 	if claim != nil && claim.Redeemed {
 		return &claim.ID, nil
 	}
+	if !promotion.Claimable(time.Now()) {
+		return nil, errPromotionNotClaimable
+	}
 	// This is skipped for legacy migration path as they passed a reputation check when originally claiming
 	if claim == nil || !claim.LegacyClaimed {
 		walletIsReputable, err := service.reputationClient.IsWalletReputable(ctx, walletID, promotion.Platform)
@@ -93,7 +97,12 @@ func (service *Service) ClaimPromotionForWallet(
 		}
 	}
 
-	cohort := "control"
+	cohorts, err := service.datastore.GetCohorts(promotionID)
+	if err != nil {
+		return nil, errorutils.Wrap(err, "error getting promotion cohorts")
+	}
+
+	cohort := AssignCohort(cohorts, promotionID, walletID)
 	issuer, err := service.GetOrCreateIssuer(ctx, promotionID, cohort)
 	if err != nil {
 		return nil, err
@@ -144,14 +153,6 @@ func (service *Service) ClaimPromotionForWallet(
 	countGrantsClaimedTotal.With(labels).Inc()
 	countGrantsClaimedBatTotal.With(labels).Add(value)
 
-	go func() {
-		_, err := service.RunNextClaimJob(ctx)
-		if err != nil {
-			sentry.CaptureException(err)
-			sentry.Flush(time.Second * 2)
-		}
-	}()
-
 	return &claim.ID, nil
 }
 
@@ -168,6 +169,7 @@ func (service *Service) SignClaimCreds(ctx context.Context, claimID uuid.UUID, i
 	}
 
 	signedTokens := jsonutils.JSONStringArray(resp.SignedTokens)
+	observeTokensSigned(issuer, len(signedTokens))
 
 	creds := &ClaimCreds{
 		ID:           claimID,
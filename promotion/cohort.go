@@ -0,0 +1,123 @@
+package promotion
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// defaultCohort is assigned to all wallets of promotions without cohorts
+	defaultCohort = "control"
+
+	// redemptionKindSuggestion is recorded for credentials redeemed toward a contribution
+	redemptionKindSuggestion = "suggestion"
+	// redemptionKindDrain is recorded for credentials drained into a verified wallet
+	redemptionKindDrain = "drain"
+)
+
+// Cohort is a weighted group of the wallets claiming a promotion, optionally with a different grant value
+type Cohort struct {
+	PromotionID uuid.UUID `json:"-" db:"promotion_id"`
+	Name        string    `json:"name" db:"name" valid:"required"`
+	Weight      int       `json:"weight" db:"weight" valid:"required"`
+	// Value overrides the value of ugp grants claimed by wallets in the cohort
+	Value *decimal.Decimal `json:"value,omitempty" db:"value" valid:"-"`
+}
+
+// CohortSummary outlines the claims and redemptions of a single cohort
+type CohortSummary struct {
+	Cohort        string           `json:"cohort" db:"cohort"`
+	Weight        *int             `json:"weight,omitempty" db:"weight"`
+	Value         *decimal.Decimal `json:"value,omitempty" db:"value"`
+	Claims        int              `json:"claims" db:"claims"`
+	ClaimedValue  decimal.Decimal  `json:"claimedValue" db:"claimed_value"`
+	Redemptions   int              `json:"redemptions" db:"redemptions"`
+	Contributions decimal.Decimal  `json:"contributions" db:"contributions"`
+	Drained       decimal.Decimal  `json:"drained" db:"drained"`
+}
+
+// cohortRedemption records the credentials of a cohort redeemed together
+type cohortRedemption struct {
+	PromotionID uuid.UUID       `json:"promotionId"`
+	Cohort      string          `json:"cohort"`
+	Credentials int             `json:"credentials"`
+	Amount      decimal.Decimal `json:"amount"`
+}
+
+// ValidateCohorts checks that cohorts have unique names, positive weights and positive value overrides
+func ValidateCohorts(cohorts []Cohort) error {
+	names := map[string]bool{}
+	for _, cohort := range cohorts {
+		if cohort.Name == "" {
+			return errors.New("cohorts must be named")
+		}
+		if names[cohort.Name] {
+			return fmt.Errorf("cohort %s is defined more than once", cohort.Name)
+		}
+		names[cohort.Name] = true
+		if cohort.Weight <= 0 {
+			return fmt.Errorf("cohort %s must have a positive weight", cohort.Name)
+		}
+		if cohort.Value != nil && !cohort.Value.IsPositive() {
+			return fmt.Errorf("cohort %s must have a positive value", cohort.Name)
+		}
+	}
+	return nil
+}
+
+// AssignCohort deterministically assigns the wallet to one of the weighted cohorts of a promotion
+func AssignCohort(cohorts []Cohort, promotionID uuid.UUID, walletID uuid.UUID) string {
+	if len(cohorts) == 0 {
+		return defaultCohort
+	}
+
+	sorted := make([]Cohort, len(cohorts))
+	copy(sorted, cohorts)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	total := uint64(0)
+	for _, cohort := range sorted {
+		total += uint64(cohort.Weight)
+	}
+
+	sum := sha256.Sum256(append(promotionID.Bytes(), walletID.Bytes()...))
+	n := binary.BigEndian.Uint64(sum[:8]) % total
+	for _, cohort := range sorted {
+		if n < uint64(cohort.Weight) {
+			return cohort.Name
+		}
+		n -= uint64(cohort.Weight)
+	}
+	panic("impossible cohort assignment")
+}
+
+// findCohort by name, returning nil if it is not one of the cohorts
+func findCohort(cohorts []Cohort, name string) *Cohort {
+	for i := range cohorts {
+		if cohorts[i].Name == name {
+			return &cohorts[i]
+		}
+	}
+	return nil
+}
+
+// CohortCredentialValue returns the approximate value of a credential issued to the cohort
+// Value overrides only apply to ugp promotions, ads claims are valued when they are pre-registered
+func (promotion *Promotion) CohortCredentialValue(cohort *Cohort) decimal.Decimal {
+	if promotion.Type == "ugp" && cohort != nil && cohort.Value != nil {
+		return cohort.Value.Div(decimal.New(int64(promotion.SuggestionsPerGrant), 0))
+	}
+	return promotion.CredentialValue()
+}
+
+// GetCohortSummary returns the claims and redemptions of each cohort of a promotion
+func (service *Service) GetCohortSummary(ctx context.Context, promotionID uuid.UUID) ([]CohortSummary, error) {
+	return service.datastore.GetCohortSummary(promotionID)
+}
@@ -0,0 +1,56 @@
+package promotion
+
+import (
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCohorts(t *testing.T) {
+	value := decimal.NewFromFloat(30.0)
+	zero := decimal.Zero
+
+	assert.NoError(t, ValidateCohorts(nil))
+	assert.NoError(t, ValidateCohorts([]Cohort{{Name: "control", Weight: 3}, {Name: "high", Weight: 1, Value: &value}}))
+	assert.Error(t, ValidateCohorts([]Cohort{{Name: "control", Weight: 1}, {Name: "control", Weight: 1}}), "names must be unique")
+	assert.Error(t, ValidateCohorts([]Cohort{{Name: "control", Weight: 0}}), "weights must be positive")
+	assert.Error(t, ValidateCohorts([]Cohort{{Name: "control", Weight: 1, Value: &zero}}), "values must be positive")
+}
+
+func TestAssignCohort(t *testing.T) {
+	promotionID := uuid.NewV4()
+	walletID := uuid.NewV4()
+
+	assert.Equal(t, defaultCohort, AssignCohort(nil, promotionID, walletID))
+
+	cohorts := []Cohort{{Name: "control", Weight: 3}, {Name: "high", Weight: 1}}
+	reordered := []Cohort{cohorts[1], cohorts[0]}
+	assert.Equal(t, AssignCohort(cohorts, promotionID, walletID), AssignCohort(reordered, promotionID, walletID),
+		"assignment should not depend on the order cohorts are listed in")
+
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		walletID := uuid.NewV4()
+		cohort := AssignCohort(cohorts, promotionID, walletID)
+		assert.Equal(t, cohort, AssignCohort(cohorts, promotionID, walletID), "assignment should be deterministic")
+		counts[cohort]++
+	}
+	assert.InDelta(t, 3000, counts["control"], 200, "wallets should be assigned according to cohort weight")
+	assert.InDelta(t, 1000, counts["high"], 200, "wallets should be assigned according to cohort weight")
+}
+
+func TestCohortCredentialValue(t *testing.T) {
+	value := decimal.NewFromFloat(30.0)
+	cohort := &Cohort{Name: "high", Weight: 1, Value: &value}
+
+	promotion := &Promotion{Type: "ugp", ApproximateValue: decimal.NewFromFloat(15.0), SuggestionsPerGrant: 60}
+	assert.True(t, decimal.NewFromFloat(0.5).Equal(promotion.CohortCredentialValue(cohort)))
+	assert.True(t, promotion.CredentialValue().Equal(promotion.CohortCredentialValue(nil)))
+	assert.True(t, promotion.CredentialValue().Equal(promotion.CohortCredentialValue(&Cohort{Name: "control", Weight: 1})))
+
+	promotion.Type = "ads"
+	assert.True(t, promotion.CredentialValue().Equal(promotion.CohortCredentialValue(cohort)),
+		"ads credentials are valued by the promotion")
+}
@@ -8,8 +8,10 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/asaskevich/govalidator"
+	"github.com/brave-intl/bat-go/datastore/grantserver"
 	"github.com/brave-intl/bat-go/middleware"
 	"github.com/brave-intl/bat-go/utils/clients"
 	errorutils "github.com/brave-intl/bat-go/utils/errors"
@@ -42,6 +44,50 @@ func Router(service *Service) chi.Router {
 	return r
 }
 
+// AdminRouter for promotion lifecycle endpoints
+func AdminRouter(service *Service) chi.Router {
+	r := chi.NewRouter()
+	if os.Getenv("ENV") != "local" {
+		r.Use(middleware.SimpleTokenAuthorizedOnly)
+	}
+
+	r.Method("GET", "/", middleware.InstrumentHandler("ListPromotions", ListPromotions(service)))
+	r.Method("GET", "/expired", middleware.InstrumentHandler("GetExpiryReports", GetExpiryReports(service)))
+	r.Method("POST", "/{promotionId}/activate", middleware.InstrumentHandler("ActivatePromotion", ActivatePromotion(service)))
+	r.Method("POST", "/{promotionId}/deactivate", middleware.InstrumentHandler("DeactivatePromotion", DeactivatePromotion(service)))
+	r.Method("PUT", "/{promotionId}/expiry", middleware.InstrumentHandler("ExtendPromotion", ExtendPromotion(service)))
+	r.Method("POST", "/{promotionId}/expire", middleware.InstrumentHandler("ExpirePromotion", ExpirePromotion(service)))
+	r.Method("POST", "/{promotionId}/grants", middleware.InstrumentHandler("TopUpPromotion", TopUpPromotion(service)))
+	r.Method("GET", "/{promotionId}/audit", middleware.InstrumentHandler("GetPromotionAudit", GetPromotionAudit(service)))
+	r.Method("GET", "/{promotionId}/cohorts", middleware.InstrumentHandler("GetCohortSummary", GetCohortSummary(service)))
+	r.Method("GET", "/{promotionId}/budget", middleware.InstrumentHandler("GetPromotionBudget", GetPromotionBudget(service)))
+	r.Method("PUT", "/{promotionId}/budget", middleware.InstrumentHandler("SetPromotionBudget", SetPromotionBudget(service)))
+	return r
+}
+
+// JobsAdminRouter for inspecting and requeueing dead-lettered jobs
+func JobsAdminRouter(service *Service) chi.Router {
+	r := chi.NewRouter()
+	if os.Getenv("ENV") != "local" {
+		r.Use(middleware.SimpleTokenAuthorizedOnly)
+	}
+
+	r.Method("GET", "/{queue}", middleware.InstrumentHandler("GetDeadLetters", GetDeadLetters(service)))
+	r.Method("POST", "/{queue}/{jobId}/requeue", middleware.InstrumentHandler("RequeueDeadLetter", RequeueDeadLetter(service)))
+	return r
+}
+
+// HotWalletAdminRouter for inspecting the balance checks of the hot wallets
+func HotWalletAdminRouter(service *Service) chi.Router {
+	r := chi.NewRouter()
+	if os.Getenv("ENV") != "local" {
+		r.Use(middleware.SimpleTokenAuthorizedOnly)
+	}
+
+	r.Method("GET", "/", middleware.InstrumentHandler("GetHotWalletStatus", GetHotWalletStatus(service)))
+	return r
+}
+
 // SuggestionsRouter for suggestions endpoints
 func SuggestionsRouter(service *Service) chi.Router {
 	r := chi.NewRouter()
@@ -429,6 +475,9 @@ type CreatePromotionRequest struct {
 	Value     decimal.Decimal `json:"value" valid:"required"`
 	Platform  string          `json:"platform" valid:"platform,optional"`
 	Active    bool            `json:"active" valid:"-"`
+	Cohorts   []Cohort        `json:"cohorts" valid:"-"`
+	// Budget is the BAT value which may be claimed before the promotion is deactivated
+	Budget *decimal.Decimal `json:"budget,omitempty" valid:"-"`
 }
 
 // CreatePromotionResponse includes information about the created promotion
@@ -450,11 +499,33 @@ func CreatePromotion(service *Service) handlers.AppHandler {
 			return handlers.WrapValidationError(err)
 		}
 
-		promotion, err := service.datastore.CreatePromotion(req.Type, req.NumGrants, req.Value, req.Platform)
+		err = ValidateCohorts(req.Cohorts)
+		if err != nil {
+			return handlers.ValidationError("Error validating request body", map[string]string{
+				"cohorts": err.Error(),
+			})
+		}
+
+		if req.Budget != nil && req.Budget.IsNegative() {
+			return handlers.ValidationError("Error validating request body", map[string]string{
+				"budget": errNegativeBudget.Error(),
+			})
+		}
+
+		actor := middleware.SimpleTokenIdentity(r.Context())
+		promotion, err := service.datastore.CreatePromotionWithCohortsAndBudget(req.Type, req.NumGrants, req.Value, req.Platform, req.Cohorts, req.Budget, actor)
 		if err != nil {
 			return handlers.WrapError(err, "Error creating promotion", http.StatusBadRequest)
 		}
 
+		cohorts := []string{defaultCohort}
+		if len(req.Cohorts) > 0 {
+			cohorts = []string{}
+			for _, cohort := range req.Cohorts {
+				cohorts = append(cohorts, cohort.Name)
+			}
+		}
+
 		if req.Active {
 			err = service.datastore.ActivatePromotion(promotion)
 			if err != nil {
@@ -462,9 +533,11 @@ func CreatePromotion(service *Service) handlers.AppHandler {
 			}
 		}
 
-		_, err = service.CreateIssuer(r.Context(), promotion.ID, "control")
-		if err != nil {
-			return handlers.WrapError(err, "Error making control issuer", http.StatusInternalServerError)
+		for _, cohort := range cohorts {
+			_, err = service.CreateIssuer(r.Context(), promotion.ID, cohort)
+			if err != nil {
+				return handlers.WrapError(err, "Error making "+cohort+" issuer", http.StatusInternalServerError)
+			}
 		}
 
 		w.WriteHeader(http.StatusOK)
@@ -503,3 +576,398 @@ func PostReportClobberedClaims(service *Service) handlers.AppHandler {
 		return nil
 	})
 }
+
+// AdminPromotion includes the lifecycle state of a promotion which is hidden from clients
+type AdminPromotion struct {
+	Promotion
+	Active          bool             `json:"active"`
+	RemainingGrants int              `json:"remainingGrants"`
+	Budget          *decimal.Decimal `json:"budget,omitempty"`
+	ClaimedValue    decimal.Decimal  `json:"claimedValue"`
+}
+
+func newAdminPromotion(promotion Promotion) AdminPromotion {
+	return AdminPromotion{
+		Promotion:       promotion,
+		Active:          promotion.Active,
+		RemainingGrants: promotion.RemainingGrants,
+		Budget:          promotion.Budget,
+		ClaimedValue:    promotion.ClaimedValue,
+	}
+}
+
+// AdminPromotionStats includes a promotion along with a summary of its claims
+type AdminPromotionStats struct {
+	AdminPromotion
+	ClaimCount  int             `json:"claimCount"`
+	IssuedValue decimal.Decimal `json:"issuedValue"`
+}
+
+// ListPromotionsResponse is the list of all promotions with their claim statistics
+type ListPromotionsResponse struct {
+	Promotions []AdminPromotionStats `json:"promotions"`
+}
+
+// ListPromotions is the handler for listing all promotions with their claim statistics
+func ListPromotions(service *Service) handlers.AppHandler {
+	return handlers.AppHandler(func(w http.ResponseWriter, r *http.Request) *handlers.AppError {
+		stats, err := service.ListPromotions(r.Context())
+		if err != nil {
+			return handlers.WrapError(err, "Error listing promotions", http.StatusInternalServerError)
+		}
+
+		resp := ListPromotionsResponse{Promotions: make([]AdminPromotionStats, len(stats))}
+		for i := range stats {
+			resp.Promotions[i] = AdminPromotionStats{
+				AdminPromotion: newAdminPromotion(stats[i].Promotion),
+				ClaimCount:     stats[i].ClaimCount,
+				IssuedValue:    stats[i].IssuedValue,
+			}
+		}
+
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(&resp); err != nil {
+			panic(err)
+		}
+		return nil
+	})
+}
+
+// promotionIDFromURL validates and parses the promotionId url parameter
+func promotionIDFromURL(r *http.Request) (uuid.UUID, *handlers.AppError) {
+	promotionID := chi.URLParam(r, "promotionId")
+	if promotionID == "" || !govalidator.IsUUIDv4(promotionID) {
+		return uuid.Nil, handlers.ValidationError("Error validating request url parameter", map[string]string{
+			"promotionId": "promotionId must be a uuidv4",
+		})
+	}
+
+	pID, err := uuid.FromString(promotionID)
+	if err != nil {
+		panic(err) // Should not be possible
+	}
+	return pID, nil
+}
+
+// updatePromotion returns a handler which applies the changes read from the request to a promotion
+func updatePromotion(service *Service, action string, readChanges func(r *http.Request) (PromotionChanges, *handlers.AppError)) handlers.AppHandler {
+	return handlers.AppHandler(func(w http.ResponseWriter, r *http.Request) *handlers.AppError {
+		promotionID, appErr := promotionIDFromURL(r)
+		if appErr != nil {
+			return appErr
+		}
+
+		changes, appErr := readChanges(r)
+		if appErr != nil {
+			return appErr
+		}
+
+		actor := middleware.SimpleTokenIdentity(r.Context())
+		promotion, err := service.UpdatePromotion(r.Context(), promotionID, action, actor, changes)
+		if err != nil {
+			if errors.Is(err, errNegativeRemainingGrants) || errors.Is(err, errInvalidDeadlines) || errors.Is(err, errNegativeBudget) {
+				return handlers.WrapError(err, "Error updating promotion", http.StatusBadRequest)
+			}
+			return handlers.WrapError(err, "Error updating promotion", http.StatusInternalServerError)
+		}
+		if promotion == nil {
+			return handlers.WrapError(errors.New("promotion not found"), "Error updating promotion", http.StatusNotFound)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(newAdminPromotion(*promotion)); err != nil {
+			panic(err)
+		}
+		return nil
+	})
+}
+
+// ActivatePromotion is the handler for making a promotion available to claim
+func ActivatePromotion(service *Service) handlers.AppHandler {
+	active := true
+	return updatePromotion(service, PromotionActivated, func(r *http.Request) (PromotionChanges, *handlers.AppError) {
+		return PromotionChanges{Active: &active}, nil
+	})
+}
+
+// DeactivatePromotion is the handler for stopping further claims of a promotion
+func DeactivatePromotion(service *Service) handlers.AppHandler {
+	active := false
+	return updatePromotion(service, PromotionDeactivated, func(r *http.Request) (PromotionChanges, *handlers.AppError) {
+		return PromotionChanges{Active: &active}, nil
+	})
+}
+
+// ExtendPromotionRequest includes the new expiry and deadlines of a promotion, unset fields are left unchanged
+type ExtendPromotionRequest struct {
+	ExpiresAt       *time.Time `json:"expiresAt,omitempty" valid:"-"`
+	ClaimableUntil  *time.Time `json:"claimableUntil,omitempty" valid:"-"`
+	RedeemableUntil *time.Time `json:"redeemableUntil,omitempty" valid:"-"`
+}
+
+// ExtendPromotion is the handler for changing when a promotion expires and until when it can be claimed and redeemed
+func ExtendPromotion(service *Service) handlers.AppHandler {
+	return updatePromotion(service, PromotionExtended, func(r *http.Request) (PromotionChanges, *handlers.AppError) {
+		var req ExtendPromotionRequest
+		err := requestutils.ReadJSON(r.Body, &req)
+		if err != nil {
+			return PromotionChanges{}, handlers.WrapError(err, "Error in request body", http.StatusBadRequest)
+		}
+
+		now := time.Now()
+		fields := map[string]*time.Time{
+			"expiresAt":       req.ExpiresAt,
+			"claimableUntil":  req.ClaimableUntil,
+			"redeemableUntil": req.RedeemableUntil,
+		}
+		invalid := map[string]string{}
+		set := 0
+		for name, value := range fields {
+			if value == nil {
+				continue
+			}
+			set++
+			if !value.After(now) {
+				invalid[name] = name + " must be in the future"
+			}
+		}
+		if set == 0 {
+			invalid["expiresAt"] = "one of expiresAt, claimableUntil or redeemableUntil is required"
+		}
+		if len(invalid) > 0 {
+			return PromotionChanges{}, handlers.ValidationError("Error validating request body", invalid)
+		}
+		return PromotionChanges{
+			ExpiresAt:       req.ExpiresAt,
+			ClaimableUntil:  req.ClaimableUntil,
+			RedeemableUntil: req.RedeemableUntil,
+		}, nil
+	})
+}
+
+// ExpirePromotion is the handler for expiring a promotion immediately, credentials already claimed remain
+// redeemable until the redemption deadline
+func ExpirePromotion(service *Service) handlers.AppHandler {
+	return updatePromotion(service, PromotionExpired, func(r *http.Request) (PromotionChanges, *handlers.AppError) {
+		now := time.Now().UTC()
+		return PromotionChanges{ExpiresAt: &now, ClaimableUntil: &now}, nil
+	})
+}
+
+// TopUpPromotionRequest includes the number of grants to add to, or remove from, a promotion
+type TopUpPromotionRequest struct {
+	NumGrants int `json:"numGrants" valid:"required"`
+}
+
+// TopUpPromotion is the handler for adjusting the remaining grants of a promotion
+func TopUpPromotion(service *Service) handlers.AppHandler {
+	return updatePromotion(service, PromotionToppedUp, func(r *http.Request) (PromotionChanges, *handlers.AppError) {
+		var req TopUpPromotionRequest
+		err := requestutils.ReadJSON(r.Body, &req)
+		if err != nil {
+			return PromotionChanges{}, handlers.WrapError(err, "Error in request body", http.StatusBadRequest)
+		}
+
+		_, err = govalidator.ValidateStruct(req)
+		if err != nil {
+			return PromotionChanges{}, handlers.WrapValidationError(err)
+		}
+		return PromotionChanges{RemainingGrantsDelta: req.NumGrants}, nil
+	})
+}
+
+// SetPromotionBudgetRequest includes the new budget of a promotion
+type SetPromotionBudgetRequest struct {
+	Budget decimal.Decimal `json:"budget" valid:"-"`
+}
+
+// SetPromotionBudget is the handler for changing the BAT value which may be claimed from a promotion
+func SetPromotionBudget(service *Service) handlers.AppHandler {
+	return updatePromotion(service, PromotionBudgeted, func(r *http.Request) (PromotionChanges, *handlers.AppError) {
+		var req SetPromotionBudgetRequest
+		err := requestutils.ReadJSON(r.Body, &req)
+		if err != nil {
+			return PromotionChanges{}, handlers.WrapError(err, "Error in request body", http.StatusBadRequest)
+		}
+		return PromotionChanges{Budget: &req.Budget}, nil
+	})
+}
+
+// PromotionBudgetResponse includes the budget of a promotion and how much of it has been spent
+type PromotionBudgetResponse struct {
+	PromotionBudget
+	Remaining *decimal.Decimal `json:"remaining"`
+}
+
+// GetPromotionBudget is the handler for the budget burn-down of a promotion
+func GetPromotionBudget(service *Service) handlers.AppHandler {
+	return handlers.AppHandler(func(w http.ResponseWriter, r *http.Request) *handlers.AppError {
+		promotionID, appErr := promotionIDFromURL(r)
+		if appErr != nil {
+			return appErr
+		}
+
+		budget, err := service.GetPromotionBudget(r.Context(), promotionID)
+		if err != nil {
+			return handlers.WrapError(err, "Error getting promotion budget", http.StatusInternalServerError)
+		}
+		if budget == nil {
+			return handlers.WrapError(errors.New("promotion not found"), "Error getting promotion budget", http.StatusNotFound)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(&PromotionBudgetResponse{PromotionBudget: *budget, Remaining: budget.Remaining()}); err != nil {
+			panic(err)
+		}
+		return nil
+	})
+}
+
+// PromotionAuditResponse is the list of changes made to a promotion
+type PromotionAuditResponse struct {
+	Audit []PromotionAudit `json:"audit"`
+}
+
+// GetPromotionAudit is the handler for listing the changes made to a promotion
+func GetPromotionAudit(service *Service) handlers.AppHandler {
+	return handlers.AppHandler(func(w http.ResponseWriter, r *http.Request) *handlers.AppError {
+		promotionID, appErr := promotionIDFromURL(r)
+		if appErr != nil {
+			return appErr
+		}
+
+		audit, err := service.GetPromotionAudit(r.Context(), promotionID)
+		if err != nil {
+			return handlers.WrapError(err, "Error getting promotion audit", http.StatusInternalServerError)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(&PromotionAuditResponse{Audit: audit}); err != nil {
+			panic(err)
+		}
+		return nil
+	})
+}
+
+// ExpiryReportsResponse is the unredeemed value of each promotion swept after its redemption deadline
+type ExpiryReportsResponse struct {
+	Reports []ExpiryReport `json:"reports"`
+}
+
+// GetExpiryReports is the handler for listing the unredeemed value of expired promotions
+func GetExpiryReports(service *Service) handlers.AppHandler {
+	return handlers.AppHandler(func(w http.ResponseWriter, r *http.Request) *handlers.AppError {
+		reports, err := service.GetExpiryReports(r.Context())
+		if err != nil {
+			return handlers.WrapError(err, "Error getting expiry reports", http.StatusInternalServerError)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(&ExpiryReportsResponse{Reports: reports}); err != nil {
+			panic(err)
+		}
+		return nil
+	})
+}
+
+// CohortSummaryResponse includes the claims and redemptions of each cohort of a promotion
+type CohortSummaryResponse struct {
+	Cohorts []CohortSummary `json:"cohorts"`
+}
+
+// GetCohortSummary is the handler for summarizing the claims and redemptions of each cohort of a promotion
+func GetCohortSummary(service *Service) handlers.AppHandler {
+	return handlers.AppHandler(func(w http.ResponseWriter, r *http.Request) *handlers.AppError {
+		promotionID, appErr := promotionIDFromURL(r)
+		if appErr != nil {
+			return appErr
+		}
+
+		summary, err := service.GetCohortSummary(r.Context(), promotionID)
+		if err != nil {
+			return handlers.WrapError(err, "Error getting cohort summary", http.StatusInternalServerError)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(&CohortSummaryResponse{Cohorts: summary}); err != nil {
+			panic(err)
+		}
+		return nil
+	})
+}
+
+// DeadLettersResponse is the list of dead-lettered jobs of a queue
+type DeadLettersResponse struct {
+	Jobs []grantserver.DeadLetter `json:"jobs"`
+}
+
+// GetDeadLetters is the handler for listing the dead-lettered jobs of a queue
+func GetDeadLetters(service *Service) handlers.AppHandler {
+	return handlers.AppHandler(func(w http.ResponseWriter, r *http.Request) *handlers.AppError {
+		jobs, err := service.GetDeadLetters(r.Context(), chi.URLParam(r, "queue"))
+		if err != nil {
+			if errors.Is(err, grantserver.ErrUnknownQueue) {
+				return handlers.WrapError(err, "Error getting dead-lettered jobs", http.StatusNotFound)
+			}
+			return handlers.WrapError(err, "Error getting dead-lettered jobs", http.StatusInternalServerError)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(&DeadLettersResponse{Jobs: jobs}); err != nil {
+			panic(err)
+		}
+		return nil
+	})
+}
+
+// RequeueDeadLetter is the handler for retrying a dead-lettered job
+func RequeueDeadLetter(service *Service) handlers.AppHandler {
+	return handlers.AppHandler(func(w http.ResponseWriter, r *http.Request) *handlers.AppError {
+		jobID := chi.URLParam(r, "jobId")
+		if jobID == "" || !govalidator.IsUUIDv4(jobID) {
+			return handlers.ValidationError("Error validating request url parameter", map[string]string{
+				"jobId": "jobId must be a uuidv4",
+			})
+		}
+
+		requeued, err := service.RequeueDeadLetter(r.Context(), chi.URLParam(r, "queue"), uuid.Must(uuid.FromString(jobID)))
+		if err != nil {
+			if errors.Is(err, grantserver.ErrUnknownQueue) {
+				return handlers.WrapError(err, "Error requeueing job", http.StatusNotFound)
+			}
+			return handlers.WrapError(err, "Error requeueing job", http.StatusInternalServerError)
+		}
+		if !requeued {
+			return &handlers.AppError{
+				Message: "Job is not dead-lettered",
+				Code:    http.StatusNotFound,
+				Data:    map[string]interface{}{},
+			}
+		}
+
+		w.WriteHeader(http.StatusOK)
+		return nil
+	})
+}
+
+// HotWalletStatusResponse includes the last balance check of each hot wallet and whether drains are paused
+type HotWalletStatusResponse struct {
+	DrainsPaused bool              `json:"drainsPaused"`
+	Wallets      []HotWalletStatus `json:"wallets"`
+}
+
+// GetHotWalletStatus is the handler for the last balance check of each hot wallet
+func GetHotWalletStatus(service *Service) handlers.AppHandler {
+	return handlers.AppHandler(func(w http.ResponseWriter, r *http.Request) *handlers.AppError {
+		resp := HotWalletStatusResponse{
+			DrainsPaused: service.drainsPaused(),
+			Wallets:      service.HotWalletStatuses(),
+		}
+
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(&resp); err != nil {
+			panic(err)
+		}
+		return nil
+	})
+}
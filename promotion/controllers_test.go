@@ -10,6 +10,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -20,27 +21,31 @@ import (
 
 	"github.com/brave-intl/bat-go/middleware"
 	"github.com/brave-intl/bat-go/utils/altcurrency"
+	"github.com/brave-intl/bat-go/utils/avro"
 	mockbalance "github.com/brave-intl/bat-go/utils/clients/balance/mock"
 	cbr "github.com/brave-intl/bat-go/utils/clients/cbr"
 	mockcb "github.com/brave-intl/bat-go/utils/clients/cbr/mock"
 	mockledger "github.com/brave-intl/bat-go/utils/clients/ledger/mock"
 	mockreputation "github.com/brave-intl/bat-go/utils/clients/reputation/mock"
 	"github.com/brave-intl/bat-go/utils/httpsignature"
+	"github.com/brave-intl/bat-go/utils/issuerpolicy"
 	"github.com/brave-intl/bat-go/utils/jsonutils"
+	kafkautils "github.com/brave-intl/bat-go/utils/kafka"
 	"github.com/brave-intl/bat-go/wallet"
 	"github.com/brave-intl/bat-go/wallet/provider/uphold"
 	walletservice "github.com/brave-intl/bat-go/wallet/service"
 	"github.com/go-chi/chi"
 	"github.com/golang/mock/gomock"
-	"github.com/rs/zerolog/log"
 	uuid "github.com/satori/go.uuid"
-	kafka "github.com/segmentio/kafka-go"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/suite"
 )
 
 type ControllersTestSuite struct {
 	suite.Suite
+	// registry is a file backed schema registry shared by the suite
+	registry    *avro.FileRegistry
+	registryDir string
 }
 
 func TestControllersTestSuite(t *testing.T) {
@@ -64,11 +69,18 @@ func (suite *ControllersTestSuite) SetupSuite() {
 
 	suite.Require().NoError(pg.Migrate(), "Failed to fully migrate")
 
-	enableSuggestionJob = true
+	suite.registryDir, err = ioutil.TempDir("", "schema-registry")
+	suite.Require().NoError(err)
+	suite.registry, err = avro.NewFileRegistry(suite.registryDir)
+	suite.Require().NoError(err)
+}
+
+func (suite *ControllersTestSuite) TearDownSuite() {
+	suite.Require().NoError(os.RemoveAll(suite.registryDir))
 }
 
 func (suite *ControllersTestSuite) SetupTest() {
-	tables := []string{"claim_creds", "claims", "wallets", "issuers", "promotions"}
+	tables := []string{"claim_creds", "claims", "wallets", "issuers", "promotion_audit", "promotion_expiry_reports", "cohort_redemptions", "promotion_cohorts", "promotions"}
 
 	pg, err := NewPostgres("", false)
 	suite.Require().NoError(err, "Failed to get postgres conn")
@@ -123,6 +135,8 @@ func (suite *ControllersTestSuite) TestGetPromotions() {
 			"available": ` + strconv.FormatBool(available) + `,
 			"createdAt": "` + promotion.CreatedAt.Format(time.RFC3339Nano) + `",
 			"expiresAt": "` + promotion.ExpiresAt.Format(time.RFC3339Nano) + `",
+			"claimableUntil": "` + promotion.ClaimableUntil.Format(time.RFC3339Nano) + `",
+			"redeemableUntil": "` + promotion.RedeemableUntil.Format(time.RFC3339Nano) + `",
 			"id": "` + promotion.ID.String() + `",
 			"legacyClaimed": ` + strconv.FormatBool(promotion.LegacyClaimed) + `,
 			"platform": "` + promotion.Platform + `",
@@ -276,6 +290,10 @@ func (suite *ControllersTestSuite) ClaimGrant(service *Service, wallet wallet.In
 	handler.ServeHTTP(rr, req)
 	suite.Require().Equal(http.StatusOK, rr.Code)
 
+	// signing is run by the job runner in the server
+	_, err = service.RunNextClaimJob(context.Background())
+	suite.Require().NoError(err)
+
 	var claimResp ClaimResponse
 	err = json.Unmarshal(rr.Body.Bytes(), &claimResp)
 	suite.Require().NoError(err)
@@ -468,20 +486,6 @@ func (suite *ControllersTestSuite) TestSuggest() {
 	// Set a random suggestion topic each so the test suite doesn't fail when re-ran
 	SetSuggestionTopic(uuid.NewV4().String() + ".grant.suggestion")
 
-	// FIXME stick kafka setup in suite setup
-	kafkaBrokers := os.Getenv("KAFKA_BROKERS")
-
-	dialer, err := tlsDialer()
-	suite.Require().NoError(err)
-	conn, err := dialer.DialLeader(context.Background(), "tcp", strings.Split(kafkaBrokers, ",")[0], "suggestion", 0)
-	suite.Require().NoError(err)
-
-	err = conn.CreateTopics(kafka.TopicConfig{Topic: suggestionTopic, NumPartitions: 1, ReplicationFactor: 1})
-	suite.Require().NoError(err)
-
-	offset, err := conn.ReadLastOffset()
-	suite.Require().NoError(err)
-
 	mockCtrl := gomock.NewController(suite.T())
 	defer mockCtrl.Finish()
 
@@ -523,8 +527,10 @@ func (suite *ControllersTestSuite) TestSuggest() {
 		reputationClient: mockReputation,
 	}
 
-	err = service.InitKafka()
-	suite.Require().NoError(err, "Failed to initialize kafka")
+	publisher := kafkautils.NewMemoryPublisher()
+	service.publisher = publisher
+	err = service.InitCodecs(suite.registry)
+	suite.Require().NoError(err, "Failed to initialize codecs")
 
 	promotion, err := service.datastore.CreatePromotion("ugp", 2, decimal.NewFromFloat(0.25), "")
 	suite.Require().NoError(err, "Failed to create promotion")
@@ -539,7 +545,7 @@ func (suite *ControllersTestSuite) TestSuggest() {
 	sig := "PsavkSWaqsTzZjmoDBmSu6YxQ7NZVrs2G8DQ+LkW5xOejRF6whTiuUJhr9dJ1KlA+79MDbFeex38X5KlnLzvJw=="
 	preimage := "125KIuuwtHGEl35cb5q1OLSVepoDTgxfsvwTc7chSYUM2Zr80COP19EuMpRQFju1YISHlnB04XJzZYN2ieT9Ng=="
 
-	mockCB.EXPECT().CreateIssuer(gomock.Any(), gomock.Eq(issuerName), gomock.Eq(defaultMaxTokensPerIssuer)).Return(nil)
+	mockCB.EXPECT().CreateIssuer(gomock.Any(), gomock.Eq(issuerName), gomock.Eq(issuerpolicy.DefaultMaxTokens)).Return(nil)
 	mockCB.EXPECT().GetIssuer(gomock.Any(), gomock.Eq(issuerName)).Return(&cbr.IssuerResponse{
 		Name:      issuerName,
 		PublicKey: issuerPublicKey,
@@ -583,32 +589,28 @@ func (suite *ControllersTestSuite) TestSuggest() {
 	req, err := http.NewRequest("POST", "/suggestion", bytes.NewBuffer(body))
 	suite.Require().NoError(err)
 
-	r := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:          strings.Split(kafkaBrokers, ","),
-		Topic:            suggestionTopic,
-		Dialer:           service.kafkaDialer,
-		MaxWait:          time.Second,
-		RebalanceTimeout: time.Second,
-		Logger:           kafka.LoggerFunc(log.Printf),
-	})
 	codec := service.codecs["suggestion"]
 
-	// :cry:
-
-	err = r.SetOffset(offset)
-	suite.Require().NoError(err)
-
 	rr := httptest.NewRecorder()
 	handler.ServeHTTP(rr, req)
 	suite.Require().Equal(http.StatusOK, rr.Code)
 
-	suggestionEventBinary, err := r.ReadMessage(context.Background())
+	_, err = service.RunNextSuggestionJob(context.Background())
+	suite.Require().NoError(err)
+	_, err = service.RunNextSuggestionOutboxJob(context.Background())
+	suite.Require().NoError(err)
+
+	events := publisher.Events()
+	suite.Require().Len(events, 1, "Suggestion event should be published")
+	suggestionEventBinary := events[0]
+	schemaID, _, err := avro.DecodeWireFormat(suggestionEventBinary.Value)
 	suite.Require().NoError(err)
+	suite.Assert().Equal(codec.ID(), schemaID, "Published events should carry the schema id")
 
-	suggestionEvent, _, err := codec.NativeFromBinary(suggestionEventBinary.Value)
+	suggestionEvent, err := codec.Decode(context.Background(), suggestionEventBinary.Value)
 	suite.Require().NoError(err)
 
-	suggestionEventJSON, err := codec.TextualFromNative(nil, suggestionEvent)
+	suggestionEventJSON, err := codec.TextualFromNative(suggestionEvent)
 	suite.Require().NoError(err)
 
 	eventMap, ok := suggestionEvent.(map[string]interface{})
@@ -766,7 +768,7 @@ func (suite *ControllersTestSuite) TestCreatePromotion() {
 	}
 	var issuerName string
 	mockCB.EXPECT().
-		CreateIssuer(gomock.Any(), gomock.Any(), gomock.Eq(defaultMaxTokensPerIssuer)).
+		CreateIssuer(gomock.Any(), gomock.Any(), gomock.Eq(issuerpolicy.DefaultMaxTokens)).
 		DoAndReturn(func(ctx context.Context, name string, maxTokens int) error {
 			issuerName = name
 			return nil
@@ -800,6 +802,103 @@ func (suite *ControllersTestSuite) TestCreatePromotion() {
 	suite.Require().Equal(http.StatusOK, rr.Code, fmt.Sprintf("failure body: %s", rr.Body.String()))
 }
 
+func (suite *ControllersTestSuite) TestPromotionLifecycle() {
+	pg, err := NewPostgres("", false)
+	suite.Require().NoError(err, "Failed to get postgres conn")
+
+	service := &Service{
+		datastore: pg,
+	}
+
+	promotion, err := pg.CreatePromotion("ugp", 2, decimal.NewFromFloat(15.0), "")
+	suite.Require().NoError(err, "Failed to create promotion")
+	suite.Require().NoError(pg.ActivatePromotion(promotion), "Failed to activate promotion")
+
+	walletID := uuid.NewV4()
+	w := &wallet.Info{ID: walletID.String(), Provider: "uphold", ProviderID: "-", PublicKey: "-"}
+	suite.Require().NoError(pg.UpsertWallet(w), "Failed to insert wallet")
+	issuer, err := pg.InsertIssuer(&Issuer{PromotionID: promotion.ID, Cohort: "control", PublicKey: "-"})
+	suite.Require().NoError(err, "Failed to insert issuer")
+	_, err = pg.ClaimForWallet(promotion, issuer, w, jsonutils.JSONStringArray{})
+	suite.Require().NoError(err, "Failed to claim promotion")
+
+	tokenList := middleware.TokenList
+	middleware.TokenList = []string{"admin-token"}
+	defer func() {
+		middleware.TokenList = tokenList
+	}()
+
+	handler := middleware.BearerToken(AdminRouter(service))
+	request := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		suite.Require().NoError(err)
+		req.Header.Set("Authorization", "Bearer admin-token")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	promotionPath := "/" + promotion.ID.String()
+
+	rr := request("POST", "/"+uuid.NewV4().String()+"/deactivate", "")
+	suite.Require().Equal(http.StatusNotFound, rr.Code, rr.Body.String())
+
+	rr = request("POST", promotionPath+"/grants", `{"numGrants": -2}`)
+	suite.Require().Equal(http.StatusBadRequest, rr.Code, "Remaining grants should not become negative")
+
+	rr = request("POST", promotionPath+"/grants", `{"numGrants": 10}`)
+	suite.Require().Equal(http.StatusOK, rr.Code, rr.Body.String())
+	var updated AdminPromotion
+	suite.Require().NoError(json.Unmarshal(rr.Body.Bytes(), &updated))
+	suite.Assert().Equal(11, updated.RemainingGrants)
+
+	rr = request("PUT", promotionPath+"/expiry", `{"expiresAt": "2000-01-01T00:00:00Z"}`)
+	suite.Require().Equal(http.StatusBadRequest, rr.Code, "Promotions should not be extended into the past")
+
+	expiresAt := time.Now().Add(365 * 24 * time.Hour).UTC().Truncate(time.Second)
+	rr = request("PUT", promotionPath+"/expiry", `{"expiresAt": "`+expiresAt.Format(time.RFC3339)+`"}`)
+	suite.Require().Equal(http.StatusOK, rr.Code, rr.Body.String())
+	suite.Require().NoError(json.Unmarshal(rr.Body.Bytes(), &updated))
+	suite.Assert().True(expiresAt.Equal(updated.ExpiresAt))
+
+	rr = request("POST", promotionPath+"/deactivate", "")
+	suite.Require().Equal(http.StatusOK, rr.Code, rr.Body.String())
+	suite.Require().NoError(json.Unmarshal(rr.Body.Bytes(), &updated))
+	suite.Assert().False(updated.Active)
+
+	rr = request("POST", promotionPath+"/expire", "")
+	suite.Require().Equal(http.StatusOK, rr.Code, rr.Body.String())
+	suite.Require().NoError(json.Unmarshal(rr.Body.Bytes(), &updated))
+	suite.Assert().True(updated.ExpiresAt.Before(time.Now()))
+	suite.Assert().True(updated.ClaimableUntil.Before(time.Now()), "Expired promotions can no longer be claimed")
+	suite.Assert().True(updated.RedeemableUntil.After(time.Now()), "Claimed credentials remain redeemable")
+
+	rr = request("GET", "/", "")
+	suite.Require().Equal(http.StatusOK, rr.Code, rr.Body.String())
+	var list ListPromotionsResponse
+	suite.Require().NoError(json.Unmarshal(rr.Body.Bytes(), &list))
+	suite.Require().Len(list.Promotions, 1)
+	suite.Assert().Equal(1, list.Promotions[0].ClaimCount)
+	suite.Assert().True(decimal.NewFromFloat(15.0).Equal(list.Promotions[0].IssuedValue))
+	suite.Assert().False(list.Promotions[0].Active)
+
+	rr = request("GET", promotionPath+"/audit", "")
+	suite.Require().Equal(http.StatusOK, rr.Code, rr.Body.String())
+	var audit PromotionAuditResponse
+	suite.Require().NoError(json.Unmarshal(rr.Body.Bytes(), &audit))
+	suite.Require().Len(audit.Audit, 4, "Only successful changes should be audited")
+	suite.Assert().Equal(PromotionExpired, audit.Audit[0].Action)
+	suite.Assert().Equal(PromotionToppedUp, audit.Audit[3].Action)
+	var previous, next PromotionState
+	suite.Require().NoError(json.Unmarshal(audit.Audit[3].Previous, &previous))
+	suite.Require().NoError(json.Unmarshal(audit.Audit[3].Updated, &next))
+	suite.Assert().Equal(1, previous.RemainingGrants)
+	suite.Assert().Equal(11, next.RemainingGrants)
+	suite.Assert().True(promotion.ExpiresAt.Equal(next.ExpiresAt))
+	for _, entry := range audit.Audit {
+		suite.Assert().True(strings.HasPrefix(entry.Actor, "token:"), "Changes should record who made them")
+	}
+}
+
 func (suite *ControllersTestSuite) TestReportClobberedClaims() {
 	mockCtrl := gomock.NewController(suite.T())
 	defer mockCtrl.Finish()
@@ -1012,7 +1111,7 @@ func (suite *ControllersTestSuite) TestSuggestionDrain() {
 	pg, err := NewPostgres("", false)
 	suite.Require().NoError(err, "Failed to get postgres conn")
 
-	ch := make(chan *wallet.TransactionInfo)
+	ch := make(chan *wallet.TransactionInfo, 1)
 
 	mockCtrl := gomock.NewController(suite.T())
 	defer mockCtrl.Finish()
@@ -1035,7 +1134,7 @@ func (suite *ControllersTestSuite) TestSuggestionDrain() {
 		PrivKey: privKey,
 		PubKey:  publicKey,
 	}
-	err = wal.Register("drain-card-test")
+	err = wal.Register(context.Background(), "drain-card-test")
 	suite.Require().NoError(err, "Failed to register wallet")
 
 	mockReputation := mockreputation.NewMockClient(mockCtrl)
@@ -1085,7 +1184,7 @@ func (suite *ControllersTestSuite) TestSuggestionDrain() {
 	sig := "PsavkSWaqsTzZjmoDBmSu6YxQ7NZVrs2G8DQ+LkW5xOejRF6whTiuUJhr9dJ1KlA+79MDbFeex38X5KlnLzvJw=="
 	preimage := "125KIuuwtHGEl35cb5q1OLSVepoDTgxfsvwTc7chSYUM2Zr80COP19EuMpRQFju1YISHlnB04XJzZYN2ieT9Ng=="
 
-	mockCB.EXPECT().CreateIssuer(gomock.Any(), gomock.Eq(issuerName), gomock.Eq(defaultMaxTokensPerIssuer)).Return(nil)
+	mockCB.EXPECT().CreateIssuer(gomock.Any(), gomock.Eq(issuerName), gomock.Eq(issuerpolicy.DefaultMaxTokens)).Return(nil)
 	mockCB.EXPECT().GetIssuer(gomock.Any(), gomock.Eq(issuerName)).Return(&cbr.IssuerResponse{
 		Name:      issuerName,
 		PublicKey: issuerPublicKey,
@@ -1148,11 +1247,14 @@ func (suite *ControllersTestSuite) TestSuggestionDrain() {
 	handler.ServeHTTP(rr, req)
 	suite.Require().Equal(http.StatusOK, rr.Code)
 
+	_, err = service.RunNextDrainJob(context.Background())
+	suite.Require().NoError(err)
+
 	tx := <-ch
 	suite.Require().True(grantAmount.Equals(altcurrency.BAT.FromProbi(tx.Probi)))
 
 	settlementAddr := os.Getenv("BAT_SETTLEMENT_ADDRESS")
-	_, err = wal.Transfer(altcurrency.BAT, altcurrency.BAT.ToProbi(grantAmount), settlementAddr)
+	_, err = wal.Transfer(context.Background(), altcurrency.BAT, altcurrency.BAT.ToProbi(grantAmount), settlementAddr)
 	suite.Require().NoError(err)
 }
 
@@ -1187,20 +1289,6 @@ func (suite *ControllersTestSuite) TestBraveFundsTransaction() {
 	pg, err := NewPostgres("", false)
 	suite.Require().NoError(err, "Failed to get postgres conn")
 
-	// FIXME stick kafka setup in suite setup
-	kafkaBrokers := os.Getenv("KAFKA_BROKERS")
-
-	dialer, err := tlsDialer()
-	suite.Require().NoError(err)
-	conn, err := dialer.DialLeader(context.Background(), "tcp", strings.Split(kafkaBrokers, ",")[0], "suggestion", 0)
-	suite.Require().NoError(err)
-
-	err = conn.CreateTopics(kafka.TopicConfig{Topic: suggestionTopic, NumPartitions: 1, ReplicationFactor: 1})
-	suite.Require().NoError(err)
-
-	offset, err := conn.ReadLastOffset()
-	suite.Require().NoError(err)
-
 	mockCtrl := gomock.NewController(suite.T())
 	defer mockCtrl.Finish()
 
@@ -1242,8 +1330,10 @@ func (suite *ControllersTestSuite) TestBraveFundsTransaction() {
 		reputationClient: mockReputation,
 	}
 
-	err = service.InitKafka()
-	suite.Require().NoError(err, "Failed to initialize kafka")
+	publisher := kafkautils.NewMemoryPublisher()
+	service.publisher = publisher
+	err = service.InitCodecs(suite.registry)
+	suite.Require().NoError(err, "Failed to initialize codecs")
 
 	promotion, err := service.datastore.CreatePromotion("ugp", 2, decimal.NewFromFloat(0.25), "")
 	suite.Require().NoError(err, "Failed to create promotion")
@@ -1258,7 +1348,7 @@ func (suite *ControllersTestSuite) TestBraveFundsTransaction() {
 	sig := "PsavkSWaqsTzZjmoDBmSu6YxQ7NZVrs2G8DQ+LkW5xOejRF6whTiuUJhr9dJ1KlA+79MDbFeex38X5KlnLzvJw=="
 	preimage := "125KIuuwtHGEl35cb5q1OLSVepoDTgxfsvwTc7chSYUM2Zr80COP19EuMpRQFju1YISHlnB04XJzZYN2ieT9Ng=="
 
-	mockCB.EXPECT().CreateIssuer(gomock.Any(), gomock.Eq(issuerName), gomock.Eq(defaultMaxTokensPerIssuer)).Return(nil)
+	mockCB.EXPECT().CreateIssuer(gomock.Any(), gomock.Eq(issuerName), gomock.Eq(issuerpolicy.DefaultMaxTokens)).Return(nil)
 	mockCB.EXPECT().GetIssuer(gomock.Any(), gomock.Eq(issuerName)).Return(&cbr.IssuerResponse{
 		Name:      issuerName,
 		PublicKey: issuerPublicKey,
@@ -1311,29 +1401,23 @@ func (suite *ControllersTestSuite) TestBraveFundsTransaction() {
 	req, err := http.NewRequest("POST", "/suggestion", bytes.NewBuffer(body))
 	suite.Require().NoError(err)
 
-	r := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:          strings.Split(kafkaBrokers, ","),
-		Topic:            suggestionTopic,
-		Dialer:           service.kafkaDialer,
-		MaxWait:          time.Second,
-		RebalanceTimeout: time.Second,
-		Logger:           kafka.LoggerFunc(log.Printf),
-	})
-
-	// :cry:
-	err = r.SetOffset(offset)
-	suite.Require().NoError(err)
 
 	rr := httptest.NewRecorder()
 	handler.ServeHTTP(rr, req)
 	suite.Require().Equal(http.StatusOK, rr.Code)
 
+	_, err = service.RunNextSuggestionJob(context.Background())
+	suite.Require().NoError(err)
+	_, err = service.RunNextSuggestionOutboxJob(context.Background())
+	suite.Require().NoError(err)
+
 	codec := service.codecs["suggestion"]
 
-	suggestionEventBinary, err := r.ReadMessage(context.Background())
-	suite.Require().NoError(err)
+	events := publisher.Events()
+	suite.Require().Len(events, 1, "Suggestion event should be published")
+	suggestionEventBinary := events[0]
 
-	suggestionEvent, _, err := codec.NativeFromBinary(suggestionEventBinary.Value)
+	suggestionEvent, err := codec.Decode(context.Background(), suggestionEventBinary.Value)
 	suite.Require().NoError(err)
 	suite.Require().NotNil(suggestionEvent)
 
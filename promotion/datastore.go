@@ -10,9 +10,15 @@ import (
 
 	"github.com/brave-intl/bat-go/datastore/grantserver"
 	"github.com/brave-intl/bat-go/utils/clients/cbr"
+	"github.com/brave-intl/bat-go/utils/issuerpolicy"
 	"github.com/brave-intl/bat-go/utils/jsonutils"
+	kafkautils "github.com/brave-intl/bat-go/utils/kafka"
+	srv "github.com/brave-intl/bat-go/utils/service"
 	"github.com/brave-intl/bat-go/wallet"
 	walletservice "github.com/brave-intl/bat-go/wallet/service"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
 	uuid "github.com/satori/go.uuid"
 	"github.com/shopspring/decimal"
 )
@@ -38,6 +44,8 @@ type Datastore interface {
 	GetPreClaim(promotionID uuid.UUID, walletID string) (*Claim, error)
 	// CreatePromotion given the promotion type, initial number of grants and the desired value of those grants
 	CreatePromotion(promotionType string, numGrants int, value decimal.Decimal, platform string) (*Promotion, error)
+	// CreatePromotionWithCohortsAndBudget creates a promotion along with its weighted cohorts and budget
+	CreatePromotionWithCohortsAndBudget(promotionType string, numGrants int, value decimal.Decimal, platform string, cohorts []Cohort, budget *decimal.Decimal, actor string) (*Promotion, error)
 	// GetAvailablePromotionsForWallet returns the list of available promotions for the wallet
 	GetAvailablePromotionsForWallet(wallet *wallet.Info, platform string, legacy bool) ([]Promotion, error)
 	// GetAvailablePromotions returns the list of available promotions for all wallets
@@ -62,7 +70,7 @@ type Datastore interface {
 	// RunNextClaimJob to sign claim credentials if there is a claim waiting
 	RunNextClaimJob(ctx context.Context, worker ClaimWorker) (bool, error)
 	// InsertSuggestion inserts a transaction awaiting validation
-	InsertSuggestion(credentials []cbr.CredentialRedemption, suggestionText string, suggestion []byte) error
+	InsertSuggestion(credentials []cbr.CredentialRedemption, suggestionText string, suggestion []byte, funding []FundingSource) error
 	// RunNextSuggestionJob to process a suggestion if there is one waiting
 	RunNextSuggestionJob(ctx context.Context, worker SuggestionWorker) (bool, error)
 	// InsertClobberedClaims inserts clobbered claim ids into the clobbered_claims table
@@ -71,6 +79,49 @@ type Datastore interface {
 	DrainClaim(claim *Claim, credentials []cbr.CredentialRedemption, wallet *wallet.Info, total decimal.Decimal) error
 	// RunNextDrainJob to process deposits if there is one waiting
 	RunNextDrainJob(ctx context.Context, worker DrainWorker) (bool, error)
+	// RunNextDrainRedemptionJob to redeem the credentials of a drain which will be transferred in a batch
+	RunNextDrainRedemptionJob(ctx context.Context, worker DrainWorker) (bool, error)
+	// RunNextDrainConfirmJob to confirm a drain transfer which was submitted before batching was enabled
+	RunNextDrainConfirmJob(ctx context.Context, worker DrainWorker) (bool, error)
+	// RunNextDrainBatchJob to transfer a batch of drains or aggregate redeemed drains into a new batch,
+	// no new batches are created when policy is nil
+	RunNextDrainBatchJob(ctx context.Context, worker DrainBatchWorker, policy *DrainBatchPolicy) (bool, error)
+	// RunNextDrainReconcileJob to check the status of a drain transfer which has not yet completed
+	RunNextDrainReconcileJob(ctx context.Context, worker ReconcileWorker) (bool, error)
+	// RunNextDrainBatchReconcileJob to check the status of a batch transfer which has not yet completed
+	RunNextDrainBatchReconcileJob(ctx context.Context, worker ReconcileWorker) (bool, error)
+	// CountStuckDrains returns the number of drain transfers which have not completed after the passed age
+	CountStuckDrains(age time.Duration, batching bool) (int, error)
+	// GetPendingOutflow returns the value of the drains and ads claims which have not yet been transferred
+	GetPendingOutflow(ctx context.Context) (*PendingOutflow, error)
+	// UpdateDrainTransactionStatus of the drain with the passed transaction id, returning true if one exists
+	UpdateDrainTransactionStatus(transactionID string, status string) (bool, error)
+	// UpdatePromotion applies the changes to a promotion and records them in the audit log
+	UpdatePromotion(ctx context.Context, promotionID uuid.UUID, action string, actor string, changes PromotionChanges) (*Promotion, error)
+	// GetPromotionsWithStats returns all promotions along with the number and value of their claims
+	GetPromotionsWithStats(ctx context.Context) ([]PromotionStats, error)
+	// GetPromotionBudget returns how much of the budget of a promotion has been spent
+	GetPromotionBudget(ctx context.Context, promotionID uuid.UUID) (*PromotionBudget, error)
+	// GetActivePromotionBudgets returns how much of their budget each active promotion with a budget has spent
+	GetActivePromotionBudgets(ctx context.Context) ([]PromotionBudget, error)
+	// GetPromotionAudit returns the audit log of a promotion
+	GetPromotionAudit(ctx context.Context, promotionID uuid.UUID) ([]PromotionAudit, error)
+	// GetCohorts of a promotion
+	GetCohorts(promotionID uuid.UUID) ([]Cohort, error)
+	// GetCohortSummary returns the claims and redemptions of each cohort of a promotion
+	GetCohortSummary(promotionID uuid.UUID) ([]CohortSummary, error)
+	// RunNextPromotionExpiryJob to deactivate a promotion past its redemption deadline and report its unredeemed value
+	RunNextPromotionExpiryJob(ctx context.Context, worker ExpiryWorker) (bool, error)
+	// GetExpiryReports returns the unredeemed value of each swept promotion
+	GetExpiryReports(ctx context.Context) ([]ExpiryReport, error)
+	// GetDeadLetters returns the jobs of a queue which failed terminally or exhausted their attempts
+	GetDeadLetters(ctx context.Context, queue string) ([]grantserver.DeadLetter, error)
+	// RequeueDeadLetter resets a dead-lettered job so it is run again
+	RequeueDeadLetter(ctx context.Context, queue string, id uuid.UUID) (bool, error)
+	// RunNextOutboxJob publishes the next batch of events of the topic queued in the outbox
+	RunNextOutboxJob(ctx context.Context, topic string, publisher kafkautils.EventPublisher) (bool, error)
+	// DeletePublishedOutboxEvents deletes the next batch of events of the topic published before the passed time
+	DeletePublishedOutboxEvents(ctx context.Context, topic string, before time.Time) (bool, error)
 
 	// Remove once this is completed https://github.com/brave-intl/bat-go/issues/263
 
@@ -106,6 +157,8 @@ type ReadOnlyDatastore interface {
 	// GetClaimByWalletAndPromotion gets whether a wallet has a claimed grants
 	// with the given promotion and returns the grant if so
 	GetClaimByWalletAndPromotion(wallet *wallet.Info, promotionID *Promotion) (*Claim, error)
+	// GetCohorts of a promotion
+	GetCohorts(promotionID uuid.UUID) ([]Cohort, error)
 }
 
 // Postgres is a Datastore wrapper around a postgres database
@@ -124,13 +177,51 @@ func NewPostgres(databaseURL string, performMigration bool, dbStatsPrefix ...str
 
 // CreatePromotion given the promotion type, initial number of grants and the desired value of those grants
 func (pg *Postgres) CreatePromotion(promotionType string, numGrants int, value decimal.Decimal, platform string) (*Promotion, error) {
+	return pg.CreatePromotionWithCohortsAndBudget(promotionType, numGrants, value, platform, nil, nil, "")
+}
+
+// CreatePromotionWithCohortsAndBudget creates a promotion along with its weighted cohorts and budget in one
+// transaction, so a promotion never exists with only some of its cohorts or claimable without its budget.
+// Setting the budget is recorded in the audit log as made by actor.
+func (pg *Postgres) CreatePromotionWithCohortsAndBudget(promotionType string, numGrants int, value decimal.Decimal, platform string, cohorts []Cohort, budget *decimal.Decimal, actor string) (*Promotion, error) {
+	if budget != nil && budget.IsNegative() {
+		return nil, errNegativeBudget
+	}
+
+
+	tx, err := pg.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer pg.RollbackTx(tx)
+
 	statement := `
-	insert into promotions (promotion_type, remaining_grants, approximate_value, suggestions_per_grant, platform)
-	values ($1, $2, $3, $4, $5)
+	insert into promotions (promotion_type, remaining_grants, approximate_value, suggestions_per_grant, platform, budget)
+	values ($1, $2, $3, $4, $5, $6)
 	returning *`
 	promotions := []Promotion{}
 	suggestionsPerGrant := value.Div(defaultVoteValue)
-	err := pg.DB.Select(&promotions, statement, promotionType, numGrants, value, suggestionsPerGrant, platform)
+	err = tx.Select(&promotions, statement, promotionType, numGrants, value, suggestionsPerGrant, platform, budget)
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertCohorts(tx, promotions[0].ID, cohorts)
+	if err != nil {
+		return nil, err
+	}
+
+	if budget != nil {
+		updated := promotions[0].State()
+		previous := updated
+		previous.Budget = nil
+		err = insertPromotionAudit(tx, promotions[0].ID, PromotionBudgeted, actor, previous, updated)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
 	if err != nil {
 		return nil, err
 	}
@@ -181,14 +272,267 @@ func (pg *Postgres) ActivatePromotion(promotion *Promotion) error {
 	return nil
 }
 
+// UpdatePromotion applies the changes to a promotion and records them in the audit log
+func (pg *Postgres) UpdatePromotion(ctx context.Context, promotionID uuid.UUID, action string, actor string, changes PromotionChanges) (*Promotion, error) {
+	tx, err := pg.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer pg.RollbackTx(tx)
+
+	promotions := []Promotion{}
+	err = tx.Select(&promotions, "select * from promotions where id = $1 for update", promotionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(promotions) == 0 {
+		return nil, nil
+	}
+	previous := promotions[0].State()
+
+	updated := previous
+	if changes.Active != nil {
+		updated.Active = *changes.Active
+	}
+	if changes.ExpiresAt != nil {
+		updated.ExpiresAt = *changes.ExpiresAt
+	}
+	if changes.ClaimableUntil != nil {
+		updated.ClaimableUntil = *changes.ClaimableUntil
+	}
+	if changes.RedeemableUntil != nil {
+		updated.RedeemableUntil = *changes.RedeemableUntil
+	}
+	if updated.RedeemableUntil.Before(updated.ClaimableUntil) {
+		return nil, errInvalidDeadlines
+	}
+	updated.RemainingGrants += changes.RemainingGrantsDelta
+	if updated.RemainingGrants < 0 {
+		return nil, errNegativeRemainingGrants
+	}
+	if changes.Budget != nil {
+		if changes.Budget.IsNegative() {
+			return nil, errNegativeBudget
+		}
+		updated.Budget = changes.Budget
+	}
+
+	statement := `
+	update promotions
+	set active = $2, expires_at = $3, claimable_until = $4, redeemable_until = $5, remaining_grants = $6, budget = $7
+	where id = $1
+	returning *`
+	promotions = []Promotion{}
+	err = tx.Select(&promotions, statement, promotionID, updated.Active, updated.ExpiresAt,
+		updated.ClaimableUntil, updated.RedeemableUntil, updated.RemainingGrants, updated.Budget)
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertPromotionAudit(tx, promotionID, action, actor, previous, updated)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return &promotions[0], nil
+}
+
+// insertPromotionAudit records a change made to a promotion within the transaction making it
+func insertPromotionAudit(tx *sqlx.Tx, promotionID uuid.UUID, action string, actor string, previous PromotionState, updated PromotionState) error {
+	previousJSON, err := json.Marshal(previous)
+	if err != nil {
+		return err
+	}
+	updatedJSON, err := json.Marshal(updated)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+	insert into promotion_audit (promotion_id, actor, action, previous, updated)
+	values ($1, $2, $3, $4, $5)`, promotionID, actor, action, previousJSON, updatedJSON)
+	return err
+}
+
+// GetPromotionsWithStats returns all promotions along with the number and value of their claims
+func (pg *Postgres) GetPromotionsWithStats(ctx context.Context) ([]PromotionStats, error) {
+	statement := `
+	select
+		promotions.*,
+		count(claims.id) as claim_count,
+		coalesce(sum(claims.approximate_value), 0.0) as issued_value
+	from promotions
+	left join claims on claims.promotion_id = promotions.id and claims.redeemed
+	group by promotions.id
+	order by promotions.created_at desc`
+	stats := []PromotionStats{}
+	err := pg.DB.SelectContext(ctx, &stats, statement)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// promotionBudgetStatement selects the spent budget of the promotions matching the condition
+const promotionBudgetStatement = `
+	select
+		promotions.id as promotion_id,
+		promotions.promotion_type,
+		promotions.active,
+		promotions.budget,
+		promotions.claimed_value as claimed,
+		coalesce(sum(case when cohort_redemptions.kind = 'suggestion' then cohort_redemptions.amount else 0.0 end), 0.0) as redeemed,
+		coalesce(sum(case when cohort_redemptions.kind = 'drain' then cohort_redemptions.amount else 0.0 end), 0.0) as drained
+	from promotions
+	left join cohort_redemptions on cohort_redemptions.promotion_id = promotions.id
+	where %s
+	group by promotions.id`
+
+// GetPromotionBudget returns how much of the budget of a promotion has been spent
+func (pg *Postgres) GetPromotionBudget(ctx context.Context, promotionID uuid.UUID) (*PromotionBudget, error) {
+	budgets := []PromotionBudget{}
+	err := pg.DB.SelectContext(ctx, &budgets, fmt.Sprintf(promotionBudgetStatement, "promotions.id = $1"), promotionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(budgets) > 0 {
+		return &budgets[0], nil
+	}
+
+	return nil, nil
+}
+
+// GetActivePromotionBudgets returns how much of their budget each active promotion with a budget has spent
+func (pg *Postgres) GetActivePromotionBudgets(ctx context.Context) ([]PromotionBudget, error) {
+	budgets := []PromotionBudget{}
+	err := pg.DB.SelectContext(ctx, &budgets, fmt.Sprintf(promotionBudgetStatement, "promotions.active and promotions.budget is not null"))
+	if err != nil {
+		return nil, err
+	}
+
+	return budgets, nil
+}
+
+// GetPromotionAudit returns the audit log of a promotion, most recent first
+func (pg *Postgres) GetPromotionAudit(ctx context.Context, promotionID uuid.UUID) ([]PromotionAudit, error) {
+	statement := `
+	select * from promotion_audit
+	where promotion_id = $1
+	order by created_at desc`
+	audit := []PromotionAudit{}
+	err := pg.DB.SelectContext(ctx, &audit, statement, promotionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return audit, nil
+}
+
+// insertCohorts defines the weighted cohorts of a promotion within the transaction creating it
+func insertCohorts(tx *sqlx.Tx, promotionID uuid.UUID, cohorts []Cohort) error {
+	for _, cohort := range cohorts {
+		_, err := tx.Exec(`
+		insert into promotion_cohorts (promotion_id, name, weight, value)
+		values ($1, $2, $3, $4)`, promotionID, cohort.Name, cohort.Weight, cohort.Value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetCohorts of a promotion
+func (pg *Postgres) GetCohorts(promotionID uuid.UUID) ([]Cohort, error) {
+	statement := "select * from promotion_cohorts where promotion_id = $1 order by name"
+	cohorts := []Cohort{}
+	err := pg.DB.Select(&cohorts, statement, promotionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return cohorts, nil
+}
+
+// GetCohortSummary returns the claims and redemptions of each cohort of a promotion
+func (pg *Postgres) GetCohortSummary(promotionID uuid.UUID) ([]CohortSummary, error) {
+	statement := `
+	with claimed as (
+		select cohort, count(*) as claims, sum(approximate_value) as claimed_value
+		from claims
+		where promotion_id = $1 and redeemed
+		group by cohort
+	), redeemed as (
+		select
+			cohort,
+			sum(credentials) as redemptions,
+			sum(case when kind = 'suggestion' then amount else 0.0 end) as contributions,
+			sum(case when kind = 'drain' then amount else 0.0 end) as drained
+		from cohort_redemptions
+		where promotion_id = $1
+		group by cohort
+	), cohorts as (
+		select name as cohort from promotion_cohorts where promotion_id = $1
+		union select cohort from claimed
+		union select cohort from redeemed
+	)
+	select
+		cohorts.cohort,
+		promotion_cohorts.weight,
+		promotion_cohorts.value,
+		coalesce(claimed.claims, 0) as claims,
+		coalesce(claimed.claimed_value, 0.0) as claimed_value,
+		coalesce(redeemed.redemptions, 0) as redemptions,
+		coalesce(redeemed.contributions, 0.0) as contributions,
+		coalesce(redeemed.drained, 0.0) as drained
+	from cohorts
+	left join promotion_cohorts on promotion_cohorts.promotion_id = $1 and promotion_cohorts.name = cohorts.cohort
+	left join claimed on claimed.cohort = cohorts.cohort
+	left join redeemed on redeemed.cohort = cohorts.cohort
+	order by cohorts.cohort`
+	summary := []CohortSummary{}
+	err := pg.DB.Select(&summary, statement, promotionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+func insertCohortRedemption(tx *sqlx.Tx, kind string, redemption cohortRedemption) error {
+	_, err := tx.Exec(`
+	insert into cohort_redemptions (promotion_id, cohort, kind, credentials, amount)
+	values ($1, $2, $3, $4, $5)`, redemption.PromotionID, redemption.Cohort, kind, redemption.Credentials, redemption.Amount)
+	return err
+}
+
 // InsertIssuer inserts the given issuer
 func (pg *Postgres) InsertIssuer(issuer *Issuer) (*Issuer, error) {
+	version := issuer.Version
+	if version == 0 {
+		version = 1
+	}
+	expiresAt := issuer.ExpiresAt
+	if expiresAt.IsZero() {
+		expiresAt = time.Now().Add(issuerpolicy.Validity)
+	}
+	maxTokens := issuer.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = issuerpolicy.DefaultMaxTokens
+	}
+
 	statement := `
-	insert into issuers (promotion_id, cohort, public_key)
-	values ($1, $2, $3)
+	insert into issuers (promotion_id, cohort, public_key, version, expires_at, max_tokens)
+	values ($1, $2, $3, $4, $5, $6)
 	returning *`
 	issuers := []Issuer{}
-	err := pg.DB.Select(&issuers, statement, issuer.PromotionID, issuer.Cohort, issuer.PublicKey)
+	err := pg.DB.Select(&issuers, statement, issuer.PromotionID, issuer.Cohort, issuer.PublicKey, version, expiresAt, maxTokens)
 	if err != nil {
 		return nil, err
 	}
@@ -200,9 +544,9 @@ func (pg *Postgres) InsertIssuer(issuer *Issuer) (*Issuer, error) {
 	return &issuers[0], nil
 }
 
-// GetIssuer by PromotionID and cohort
+// GetIssuer returns the latest version of the issuer by PromotionID and cohort
 func (pg *Postgres) GetIssuer(promotionID uuid.UUID, cohort string) (*Issuer, error) {
-	statement := "select * from issuers where promotion_id = $1 and cohort = $2"
+	statement := "select * from issuers where promotion_id = $1 and cohort = $2 order by version desc limit 1"
 	issuers := []Issuer{}
 	err := pg.DB.Select(&issuers, statement, promotionID.String(), cohort)
 	if err != nil {
@@ -216,11 +560,11 @@ func (pg *Postgres) GetIssuer(promotionID uuid.UUID, cohort string) (*Issuer, er
 	return nil, nil
 }
 
-// GetIssuerByPublicKey or return an error
+// GetIssuerByPublicKey or return an error, issuers which expired before the redemption grace period are not returned
 func (pg *Postgres) GetIssuerByPublicKey(publicKey string) (*Issuer, error) {
-	statement := "select * from issuers where public_key = $1"
+	statement := "select * from issuers where public_key = $1 and expires_at > $2"
 	issuers := []Issuer{}
-	err := pg.DB.Select(&issuers, statement, publicKey)
+	err := pg.DB.Select(&issuers, statement, publicKey, time.Now().Add(-issuerpolicy.RedemptionGracePeriod))
 	if err != nil {
 		return nil, err
 	}
@@ -309,16 +653,20 @@ func (pg *Postgres) ClaimForWallet(promotion *Promotion, issuer *Issuer, wallet
 	if promotion.Type == "ads" || legacyClaimExists {
 		statement := `
 		update claims
-		set redeemed = true
+		set redeemed = true, cohort = $3
 		where promotion_id = $1 and wallet_id = $2 and not redeemed
 		returning *`
-		err = tx.Select(&claims, statement, promotion.ID, wallet.ID)
+		err = tx.Select(&claims, statement, promotion.ID, wallet.ID, issuer.Cohort)
 	} else {
+		// the cohort value, if any, overrides the promotion value
 		statement := `
-		insert into claims (promotion_id, wallet_id, approximate_value, redeemed)
-		values ($1, $2, $3, true)
+		insert into claims (promotion_id, wallet_id, approximate_value, redeemed, cohort)
+		values ($1, $2, coalesce(
+			(select value from promotion_cohorts where promotion_id = $1 and name = $4),
+			$3
+		), true, $4)
 		returning *`
-		err = tx.Select(&claims, statement, promotion.ID, wallet.ID, promotion.ApproximateValue)
+		err = tx.Select(&claims, statement, promotion.ID, wallet.ID, promotion.ApproximateValue, issuer.Cohort)
 	}
 
 	if err != nil {
@@ -328,6 +676,13 @@ func (pg *Postgres) ClaimForWallet(promotion *Promotion, issuer *Issuer, wallet
 	}
 	claim := claims[0]
 
+	if !legacyClaimExists {
+		err = ChargePromotionBudget(tx, promotion.ID, claim.ApproximateValue)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// This will error if user has already claimed due to uniqueness constraint
 	_, err = tx.Exec(`insert into claim_creds (issuer_id, claim_id, blinded_creds) values ($1, $2, $3)`, issuer.ID, claim.ID, blindedCredsJSON)
 	if err != nil {
@@ -355,6 +710,8 @@ func (pg *Postgres) GetAvailablePromotionsForWallet(wallet *wallet.Info, platfor
 			promos.promotion_type,
 			promos.created_at,
 			promos.expires_at,
+			promos.claimable_until,
+			promos.redeemable_until,
 			promos.version,
 			coalesce(wallet_claims.approximate_value, promos.approximate_value) as approximate_value,
 			greatest(1, (coalesce(wallet_claims.approximate_value, promos.approximate_value) /
@@ -380,7 +737,8 @@ func (pg *Postgres) GetAvailablePromotionsForWallet(wallet *wallet.Info, platfor
 				select * from claims where claims.wallet_id = $1
 			) wallet_claims on promos.id = wallet_claims.promotion_id
 		where
-			promos.active and wallet_claims.redeemed is distinct from true and
+			promos.active and promos.claimable_until > current_timestamp and
+			wallet_claims.redeemed is distinct from true and
 			( wallet_claims.legacy_claimed is true or
 				( promos.promotion_type = 'ugp' and promos.remaining_grants > 0 ) or
 				( promos.promotion_type = 'ads' and wallet_claims.id is not null )
@@ -397,7 +755,8 @@ func (pg *Postgres) GetAvailablePromotionsForWallet(wallet *wallet.Info, platfor
 			select * from claims where claims.wallet_id = $1
 		) wallet_claims on promotions.id = wallet_claims.promotion_id
 		where
-			promotions.active and wallet_claims.redeemed is distinct from true and
+			promotions.active and promotions.claimable_until > current_timestamp and
+			wallet_claims.redeemed is distinct from true and
 			( promotions.platform = '' or promotions.platform = $2) and
 			wallet_claims.legacy_claimed is distinct from true and
 			( ( promotions.promotion_type = 'ugp' and promotions.remaining_grants > 0 ) or
@@ -433,7 +792,8 @@ func (pg *Postgres) GetAvailablePromotions(platform string, legacy bool) ([]Prom
 		promotions left join issuers on promotions.id = issuers.promotion_id
 		where promotions.promotion_type = 'ugp' and
 			( promotions.platform = '' or promotions.platform = $1) and
-			promotions.active and promotions.remaining_grants > 0
+			promotions.active and promotions.claimable_until > current_timestamp and
+			promotions.remaining_grants > 0
 		group by promotions.id
 		order by promotions.created_at;`
 
@@ -447,6 +807,7 @@ func (pg *Postgres) GetAvailablePromotions(platform string, legacy bool) ([]Prom
 		from
 		promotions left join issuers on promotions.id = issuers.promotion_id
 		where promotions.promotion_type = 'ugp' and promotions.active and
+			promotions.claimable_until > current_timestamp and
 			promotions.remaining_grants > 0 and
 			( promotions.platform = '' or promotions.platform = $1 )
 		group by promotions.id
@@ -466,7 +827,10 @@ func (pg *Postgres) GetAvailablePromotions(platform string, legacy bool) ([]Prom
 // GetClaimCreds returns the claim credentials for a ClaimID
 func (pg *Postgres) GetClaimCreds(claimID uuid.UUID) (*ClaimCreds, error) {
 	claimCreds := []ClaimCreds{}
-	err := pg.DB.Select(&claimCreds, "select * from claim_creds where claim_id = $1", claimID)
+	err := pg.DB.Select(&claimCreds, `
+	select claim_id, issuer_id, blinded_creds, signed_creds, batch_proof, public_key
+	from claim_creds
+	where claim_id = $1`, claimID)
 	if err != nil {
 		return nil, err
 	}
@@ -541,6 +905,19 @@ ORDER BY created_at DESC
 	return nil, nil
 }
 
+// failJob records the failed attempt of a job and commits, returning the error which caused the failure
+func (pg *Postgres) failJob(tx *sqlx.Tx, queue string, id uuid.UUID, attempts int, cause error) error {
+	_, err := grantserver.RecordJobFailure(tx, queue, id, attempts, cause)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	return cause
+}
+
 // RunNextClaimJob to sign claim credentials if there is a claim waiting, returning true if a job was attempted
 func (pg *Postgres) RunNextClaimJob(ctx context.Context, worker ClaimWorker) (bool, error) {
 	tx, err := pg.DB.Beginx()
@@ -554,17 +931,19 @@ func (pg *Postgres) RunNextClaimJob(ctx context.Context, worker ClaimWorker) (bo
 		Issuer
 		ClaimID      uuid.UUID                 `db:"claim_id"`
 		BlindedCreds jsonutils.JSONStringArray `db:"blinded_creds"`
+		Attempts     int                       `db:"attempts"`
 	}
 
 	statement := `
 select
 	issuers.*,
 	claim_cred.claim_id,
-	claim_cred.blinded_creds
+	claim_cred.blinded_creds,
+	claim_cred.attempts
 from
 	(select *
 	from claim_creds
-	where batch_proof is null
+	where batch_proof is null and not erred and next_attempt_at <= current_timestamp
 	for update skip locked
 	limit 1
 ) claim_cred
@@ -586,8 +965,7 @@ on claim_cred.issuer_id = issuers.id`
 	attempted = true
 	creds, err := worker.SignClaimCreds(ctx, job.ClaimID, job.Issuer, job.BlindedCreds)
 	if err != nil {
-		// FIXME certain errors are not recoverable
-		return attempted, err
+		return attempted, pg.failJob(tx, grantserver.ClaimCredsQueue, job.ClaimID, job.Attempts, err)
 	}
 
 	_, err = tx.Exec(`update claim_creds set signed_creds = $1, batch_proof = $2, public_key = $3 where claim_id = $4`, creds.SignedCreds, creds.BatchProof, creds.PublicKey, creds.ID)
@@ -595,6 +973,11 @@ on claim_cred.issuer_id = issuers.id`
 		return attempted, err
 	}
 
+	_, err = tx.Exec(`update issuers set tokens_signed = tokens_signed + $1 where id = $2`, len(*creds.SignedCreds), job.Issuer.ID)
+	if err != nil {
+		return attempted, err
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		return attempted, err
@@ -604,17 +987,32 @@ on claim_cred.issuer_id = issuers.id`
 }
 
 // InsertSuggestion inserts a transaction awaiting validation
-func (pg *Postgres) InsertSuggestion(credentials []cbr.CredentialRedemption, suggestionText string, suggestionEvent []byte) error {
+func (pg *Postgres) InsertSuggestion(credentials []cbr.CredentialRedemption, suggestionText string, suggestionEvent []byte, funding []FundingSource) error {
 	credentialsJSON, err := json.Marshal(credentials)
 	if err != nil {
 		return err
 	}
 
+	// the funding is recorded per cohort once the credentials have been redeemed
+	redemptions := make([]cohortRedemption, len(funding))
+	for i, source := range funding {
+		redemptions[i] = cohortRedemption{
+			PromotionID: source.PromotionID,
+			Cohort:      source.Cohort,
+			Credentials: len(source.Credentials),
+			Amount:      source.Amount,
+		}
+	}
+	fundingJSON, err := json.Marshal(redemptions)
+	if err != nil {
+		return err
+	}
+
 	statement := `
-	insert into suggestion_drain (credentials, suggestion_text, suggestion_event)
-	values ($1, $2, $3)
+	insert into suggestion_drain (credentials, suggestion_text, suggestion_event, funding)
+	values ($1, $2, $3, $4)
 	returning *`
-	_, err = pg.DB.Exec(statement, credentialsJSON, suggestionText, suggestionEvent)
+	_, err = pg.DB.Exec(statement, credentialsJSON, suggestionText, suggestionEvent, fundingJSON)
 	if err != nil {
 		return err
 	}
@@ -638,12 +1036,16 @@ func (pg *Postgres) RunNextSuggestionJob(ctx context.Context, worker SuggestionW
 		SuggestionText  string    `db:"suggestion_text"`
 		SuggestionEvent []byte    `db:"suggestion_event"`
 		Erred           bool      `db:"erred"`
+		Funding         *string   `db:"funding"`
+		Attempts        int       `db:"attempts"`
+		NextAttemptAt   time.Time `db:"next_attempt_at"`
+		LastError       *string   `db:"last_error"`
 	}
 
 	statement := `
 select *
 from suggestion_drain
-where not erred
+where not erred and next_attempt_at <= current_timestamp
 for update skip locked
 limit 1`
 
@@ -662,17 +1064,34 @@ limit 1`
 
 	var credentials []cbr.CredentialRedemption
 	err = json.Unmarshal([]byte(job.Credentials), &credentials)
+	if err != nil {
+		return attempted, pg.failJob(tx, grantserver.SuggestionDrainQueue, job.ID, job.Attempts, srv.Terminal(err))
+	}
+
+	// claim the credentials before redeeming them so that a retry can tell its own redemption from a duplicate,
+	// a previous attempt may have redeemed the credentials and failed before the event was queued
+	retry, err := pg.ClaimCredentialRedemptions(ctx, job.ID, credentials)
+	if errors.Is(err, grantserver.ErrCredentialsClaimed) {
+		return attempted, pg.failJob(tx, grantserver.SuggestionDrainQueue, job.ID, job.Attempts, srv.Terminal(err))
+	}
 	if err != nil {
 		return attempted, err
 	}
 
-	err = worker.RedeemAndCreateSuggestionEvent(ctx, credentials, job.SuggestionText, job.SuggestionEvent)
+	event, err := worker.RedeemAndCreateSuggestionEvent(ctx, credentials, job.SuggestionText, job.SuggestionEvent, retry)
 	if err != nil {
-		// FIXME only non-retriable errors should set erred
-		_, err = tx.Exec(`update suggestion_drain set erred = true where id = $1`, job.ID)
-		if err == nil {
-			err = tx.Commit()
+		if !retry && cbr.IsDuplicateRedemption(err) {
+			// redeemed elsewhere, release the claim so a requeued job does not take the redemption for its own
+			releaseErr := pg.ReleaseCredentialRedemptions(ctx, job.ID)
+			if releaseErr != nil {
+				return attempted, releaseErr
+			}
 		}
+		return attempted, pg.failJob(tx, grantserver.SuggestionDrainQueue, job.ID, job.Attempts, err)
+	}
+
+	err = grantserver.InsertOutboxEvent(tx, *event)
+	if err != nil {
 		return attempted, err
 	}
 
@@ -681,6 +1100,20 @@ limit 1`
 		return attempted, err
 	}
 
+	if job.Funding != nil {
+		var redemptions []cohortRedemption
+		err = json.Unmarshal([]byte(*job.Funding), &redemptions)
+		if err != nil {
+			return attempted, err
+		}
+		for _, redemption := range redemptions {
+			err = insertCohortRedemption(tx, redemptionKindSuggestion, redemption)
+			if err != nil {
+				return attempted, err
+			}
+		}
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		return attempted, err
@@ -733,10 +1166,20 @@ func (pg *Postgres) DrainClaim(claim *Claim, credentials []cbr.CredentialRedempt
 	}
 
 	statement := `
-	insert into claim_drain (credentials, wallet_id, total)
-	values ($1, $2, $3)
+	insert into claim_drain (credentials, wallet_id, total, payout_address)
+	values ($1, $2, $3, $4)
 	returning *`
-	_, err = tx.Exec(statement, credentialsJSON, wallet.ID, total)
+	_, err = tx.Exec(statement, credentialsJSON, wallet.ID, total, wallet.PayoutAddress)
+	if err != nil {
+		return err
+	}
+
+	err = insertCohortRedemption(tx, redemptionKindDrain, cohortRedemption{
+		PromotionID: claim.PromotionID,
+		Cohort:      claim.Cohort,
+		Credentials: len(credentials),
+		Amount:      total,
+	})
 	if err != nil {
 		return err
 	}
@@ -749,7 +1192,48 @@ func (pg *Postgres) DrainClaim(claim *Claim, credentials []cbr.CredentialRedempt
 	return nil
 }
 
+// drainJob is a drain of the credentials of an ads claim into a verified wallet
+type drainJob struct {
+	ID             uuid.UUID       `db:"id"`
+	Credentials    string          `db:"credentials"`
+	WalletID       uuid.UUID       `db:"wallet_id"`
+	Total          decimal.Decimal `db:"total"`
+	TransactionID  *string         `db:"transaction_id"`
+	Erred          bool            `db:"erred"`
+	IdempotencyKey uuid.UUID       `db:"idempotency_key"`
+	Redeemed       bool            `db:"redeemed"`
+	Completed      bool            `db:"completed"`
+	CreatedAt      time.Time       `db:"created_at"`
+	Status         *string         `db:"transaction_status"`
+	CheckedAt      *time.Time      `db:"status_checked_at"`
+	Attempts       int             `db:"attempts"`
+	NextAttemptAt  time.Time       `db:"next_attempt_at"`
+	LastError      *string         `db:"last_error"`
+	PayoutAddress  *string         `db:"payout_address"`
+	BatchID        *uuid.UUID      `db:"batch_id"`
+}
+
+// redeemDrain redeems the credentials of the drain within the transaction which claimed it, recording the
+// failure and committing if they cannot be redeemed
+func (pg *Postgres) redeemDrain(ctx context.Context, tx *sqlx.Tx, worker DrainWorker, job drainJob) error {
+	var credentials []cbr.CredentialRedemption
+	err := json.Unmarshal([]byte(job.Credentials), &credentials)
+	if err != nil {
+		return pg.failJob(tx, grantserver.ClaimDrainQueue, job.ID, job.Attempts, srv.Terminal(err))
+	}
+
+	err = worker.RedeemCredentials(ctx, credentials, job.WalletID)
+	if err != nil {
+		return pg.failJob(tx, grantserver.ClaimDrainQueue, job.ID, job.Attempts, err)
+	}
+
+	_, err = tx.Exec(`update claim_drain set redeemed = true where id = $1`, job.ID)
+	return err
+}
+
 // RunNextDrainJob to process deposits if there is one waiting
+// The transfer is submitted and its transaction id persisted before it is confirmed, a retried job
+// resumes the existing transaction instead of creating a new transfer
 func (pg *Postgres) RunNextDrainJob(ctx context.Context, worker DrainWorker) (bool, error) {
 	tx, err := pg.DB.Beginx()
 	attempted := false
@@ -758,24 +1242,17 @@ func (pg *Postgres) RunNextDrainJob(ctx context.Context, worker DrainWorker) (bo
 	}
 	defer pg.RollbackTx(tx)
 
-	// FIXME maybe useful to later move definition outside of this method scope
-	type DrainJob struct {
-		ID            uuid.UUID       `db:"id"`
-		Credentials   string          `db:"credentials"`
-		WalletID      uuid.UUID       `db:"wallet_id"`
-		Total         decimal.Decimal `db:"total"`
-		TransactionID *string         `db:"transaction_id"`
-		Erred         bool            `db:"erred"`
-	}
-
+	// confirmed transfers which have not yet completed are handled by RunNextDrainReconcileJob
+	// and drains aggregated into a batch are transferred by RunNextDrainBatchJob
 	statement := `
 select *
 from claim_drain
-where not erred and transaction_id is null
+where not erred and not completed and transaction_status is null and batch_id is null
+	and next_attempt_at <= current_timestamp
 for update skip locked
 limit 1`
 
-	jobs := []DrainJob{}
+	jobs := []drainJob{}
 	err = tx.Select(&jobs, statement)
 	if err != nil {
 		return attempted, err
@@ -788,36 +1265,734 @@ limit 1`
 	job := jobs[0]
 	attempted = true
 
-	var credentials []cbr.CredentialRedemption
-	err = json.Unmarshal([]byte(job.Credentials), &credentials)
+	if !job.Redeemed {
+		err = pg.redeemDrain(ctx, tx, worker, job)
+		if err != nil {
+			return attempted, err
+		}
+	}
+
+	if job.TransactionID == nil {
+		txn, err := worker.SubmitTransfer(ctx, job.WalletID, job.Total, job.IdempotencyKey)
+		if err == nil && txn == nil {
+			err = errors.New("drain transfer was not submitted")
+		}
+		if err != nil {
+			// the redemption is kept so that the retry only resubmits the transfer
+			return attempted, pg.failJob(tx, grantserver.ClaimDrainQueue, job.ID, job.Attempts, err)
+		}
+
+		_, err = tx.Exec(`update claim_drain set transaction_id = $1 where id = $2`, txn.ID, job.ID)
+		if err != nil {
+			return attempted, err
+		}
+		job.TransactionID = &txn.ID
+	}
+
+	// persist redemption and the submitted transaction before moving any funds
+	err = tx.Commit()
 	if err != nil {
 		return attempted, err
 	}
 
-	txn, err := worker.RedeemAndTransferFunds(ctx, credentials, job.WalletID, job.Total)
-	if err != nil || txn == nil {
-		// FIXME only non-retriable errors should set erred
-		{
-			_, err := tx.Exec(`update claim_drain set erred = true where id = $1`, job.ID)
-			if err != nil {
-				pg.RollbackTx(tx)
-			}
-			_ = tx.Commit()
-		}
+	tx, err = pg.DB.Beginx()
+	if err != nil {
+		return attempted, err
+	}
+	defer pg.RollbackTx(tx)
+
+	statement = `
+select id
+from claim_drain
+where id = $1 and transaction_id = $2 and not erred and not completed and transaction_status is null
+for update skip locked`
+
+	ids := []uuid.UUID{}
+	err = tx.Select(&ids, statement, job.ID, *job.TransactionID)
+	if err != nil {
 		return attempted, err
 	}
 
-	_, err = tx.Exec(`update claim_drain set transaction_id = $1 where id = $2`, txn.ID, job.ID)
+	if len(ids) != 1 {
+		// another worker has picked up the job in the meantime
+		return attempted, nil
+	}
+
+	return attempted, pg.confirmDrainTransfer(ctx, tx, worker, job)
+}
+
+// RunNextDrainConfirmJob to confirm a drain transfer which was submitted but not yet confirmed, used while
+// batching for the drains whose transfer was submitted by RunNextDrainJob before batching was enabled
+func (pg *Postgres) RunNextDrainConfirmJob(ctx context.Context, worker DrainWorker) (bool, error) {
+	tx, err := pg.DB.Beginx()
+	attempted := false
 	if err != nil {
 		return attempted, err
 	}
+	defer pg.RollbackTx(tx)
 
-	err = tx.Commit()
+	statement := `
+select *
+from claim_drain
+where not erred and not completed and transaction_id is not null and transaction_status is null
+	and batch_id is null and next_attempt_at <= current_timestamp
+for update skip locked
+limit 1`
+
+	jobs := []drainJob{}
+	err = tx.Select(&jobs, statement)
 	if err != nil {
 		return attempted, err
 	}
 
-	return attempted, nil
+	if len(jobs) != 1 {
+		return attempted, nil
+	}
+
+	attempted = true
+	return attempted, pg.confirmDrainTransfer(ctx, tx, worker, jobs[0])
+}
+
+// confirmDrainTransfer confirms the submitted transfer of the locked drain and commits its status. A transfer
+// which expired before it was confirmed is cleared so that the drain is transferred again.
+func (pg *Postgres) confirmDrainTransfer(ctx context.Context, tx *sqlx.Tx, worker DrainWorker, job drainJob) error {
+	txn, err := worker.ConfirmTransfer(ctx, *job.TransactionID)
+	if errors.Is(err, errDrainTransferExpired) {
+		// funds were never moved, clear the transaction so the next attempt resubmits
+		_, err = tx.Exec(`update claim_drain set transaction_id = null where id = $1`, job.ID)
+		if err != nil {
+			return err
+		}
+		return tx.Commit()
+	} else if err != nil {
+		return pg.failJob(tx, grantserver.ClaimDrainQueue, job.ID, job.Attempts, err)
+	}
+
+	err = updateDrainTransactionStatus(tx, job.ID, txn.Status)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// updateDrainTransactionStatus records the latest transaction status, marking the drain completed or erred
+// once the transaction reaches a terminal status
+func updateDrainTransactionStatus(tx *sqlx.Tx, id uuid.UUID, status string) error {
+	statement := `
+update claim_drain
+set transaction_status = $2,
+	status_checked_at = current_timestamp,
+	completed = $3,
+	erred = $4
+where id = $1`
+	_, err := tx.Exec(statement, id, status,
+		status == wallet.TransactionCompleted,
+		status == wallet.TransactionFailed || status == wallet.TransactionCancelled)
+	return err
+}
+
+// RunNextDrainRedemptionJob to redeem the credentials of a drain if there is one waiting, leaving the
+// transfer to RunNextDrainBatchJob once the drain is aggregated with others to the same payout address
+func (pg *Postgres) RunNextDrainRedemptionJob(ctx context.Context, worker DrainWorker) (bool, error) {
+	tx, err := pg.DB.Beginx()
+	attempted := false
+	if err != nil {
+		return attempted, err
+	}
+	defer pg.RollbackTx(tx)
+
+	statement := `
+select *
+from claim_drain
+where not erred and not completed and not redeemed and next_attempt_at <= current_timestamp
+for update skip locked
+limit 1`
+
+	jobs := []drainJob{}
+	err = tx.Select(&jobs, statement)
+	if err != nil {
+		return attempted, err
+	}
+
+	if len(jobs) != 1 {
+		return attempted, nil
+	}
+
+	job := jobs[0]
+	attempted = true
+
+	err = pg.redeemDrain(ctx, tx, worker, job)
+	if err != nil {
+		return attempted, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return attempted, err
+	}
+
+	return attempted, nil
+}
+
+// drainBatch is the aggregated transfer of the redeemed drains to a payout address
+type drainBatch struct {
+	ID             uuid.UUID       `db:"id"`
+	PayoutAddress  string          `db:"payout_address"`
+	Total          decimal.Decimal `db:"total"`
+	IdempotencyKey uuid.UUID       `db:"idempotency_key"`
+	TransactionID  *string         `db:"transaction_id"`
+	Status         *string         `db:"transaction_status"`
+	CheckedAt      *time.Time      `db:"status_checked_at"`
+	Completed      bool            `db:"completed"`
+	Erred          bool            `db:"erred"`
+	Attempts       int             `db:"attempts"`
+	NextAttemptAt  time.Time       `db:"next_attempt_at"`
+	LastError      *string         `db:"last_error"`
+	CreatedAt      time.Time       `db:"created_at"`
+}
+
+// RunNextDrainBatchJob to transfer a batch if there is one waiting, otherwise to aggregate the redeemed
+// drains to the payout address whose oldest drain has waited longer than the batch window into a new batch.
+// Payout addresses whose drains total less than the minimum payout are left to accumulate further drains.
+// Like RunNextDrainJob the transfer is submitted and its transaction id persisted before it is confirmed.
+// Without a policy batching is disabled, batches which are already open are still transferred but no new
+// batches are created.
+func (pg *Postgres) RunNextDrainBatchJob(ctx context.Context, worker DrainBatchWorker, policy *DrainBatchPolicy) (bool, error) {
+	tx, err := pg.DB.Beginx()
+	attempted := false
+	if err != nil {
+		return attempted, err
+	}
+	defer pg.RollbackTx(tx)
+
+	statement := `
+select *
+from drain_batch
+where not erred and not completed and transaction_status is null and next_attempt_at <= current_timestamp
+for update skip locked
+limit 1`
+
+	batches := []drainBatch{}
+	err = tx.Select(&batches, statement)
+	if err != nil {
+		return attempted, err
+	}
+
+	if len(batches) != 1 {
+		if policy == nil {
+			return attempted, nil
+		}
+		return pg.createDrainBatch(tx, *policy)
+	}
+
+	batch := batches[0]
+	attempted = true
+
+	if batch.TransactionID == nil {
+		txn, err := worker.SubmitBatchTransfer(ctx, batch.PayoutAddress, batch.Total, batch.IdempotencyKey)
+		if err == nil && txn == nil {
+			err = errors.New("drain batch transfer was not submitted")
+		}
+		if err != nil {
+			return attempted, pg.failDrainBatchSubmission(tx, batch, err)
+		}
+
+		_, err = tx.Exec(`update drain_batch set transaction_id = $1 where id = $2`, txn.ID, batch.ID)
+		if err != nil {
+			return attempted, err
+		}
+		batch.TransactionID = &txn.ID
+	}
+
+	// persist the submitted transaction before moving any funds
+	err = tx.Commit()
+	if err != nil {
+		return attempted, err
+	}
+
+	tx, err = pg.DB.Beginx()
+	if err != nil {
+		return attempted, err
+	}
+	defer pg.RollbackTx(tx)
+
+	statement = `
+select id
+from drain_batch
+where id = $1 and transaction_id = $2 and not erred and not completed and transaction_status is null
+for update skip locked`
+
+	ids := []uuid.UUID{}
+	err = tx.Select(&ids, statement, batch.ID, *batch.TransactionID)
+	if err != nil {
+		return attempted, err
+	}
+
+	if len(ids) != 1 {
+		// another worker has picked up the batch in the meantime
+		return attempted, nil
+	}
+
+	txn, err := worker.ConfirmTransfer(ctx, *batch.TransactionID)
+	if errors.Is(err, errDrainTransferExpired) {
+		// funds were never moved, clear the transaction so the next attempt resubmits
+		_, err = tx.Exec(`update drain_batch set transaction_id = null where id = $1`, batch.ID)
+		if err != nil {
+			return attempted, err
+		}
+		return attempted, tx.Commit()
+	} else if err != nil {
+		return attempted, pg.failJob(tx, grantserver.DrainBatchQueue, batch.ID, batch.Attempts, err)
+	}
+
+	err = updateDrainBatchStatus(tx, batch.ID, txn.Status)
+	if err != nil {
+		return attempted, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return attempted, err
+	}
+
+	return attempted, nil
+}
+
+// failDrainBatchSubmission records the failure to submit the transfer of the batch. Once the batch is
+// dead-lettered its drains are released, no funds were moved so they are transferred in a later batch.
+func (pg *Postgres) failDrainBatchSubmission(tx *sqlx.Tx, batch drainBatch, cause error) error {
+	deadLettered, err := grantserver.RecordJobFailure(tx, grantserver.DrainBatchQueue, batch.ID, batch.Attempts, cause)
+	if err != nil {
+		return err
+	}
+	if deadLettered {
+		err = releaseDrainBatch(tx, batch.ID)
+		if err != nil {
+			return err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	return cause
+}
+
+// releaseDrainBatch removes the drains from a batch whose transfer failed so that they are batched again
+func releaseDrainBatch(tx *sqlx.Tx, id uuid.UUID) error {
+	_, err := tx.Exec(`update claim_drain set batch_id = null where batch_id = $1 and not completed`, id)
+	return err
+}
+
+// createDrainBatch aggregates the redeemed drains of the next payout address which is due a payout,
+// returning true if a batch was created
+func (pg *Postgres) createDrainBatch(tx *sqlx.Tx, policy DrainBatchPolicy) (bool, error) {
+	statement := `
+select payout_address
+from claim_drain
+where redeemed and batch_id is null and transaction_id is null and not erred and not completed
+	and payout_address is not null
+group by payout_address
+having min(created_at) <= current_timestamp - $1 * interval '1 second' and sum(total) >= $2
+order by min(created_at) asc
+limit 1`
+
+	addresses := []string{}
+	err := tx.Select(&addresses, statement, policy.Window.Seconds(), policy.MinimumPayout)
+	if err != nil {
+		return false, err
+	}
+
+	if len(addresses) != 1 {
+		return false, nil
+	}
+
+	statement = `
+select *
+from claim_drain
+where payout_address = $1 and redeemed and batch_id is null and transaction_id is null and not erred and not completed
+for update skip locked`
+
+	drains := []drainJob{}
+	err = tx.Select(&drains, statement, addresses[0])
+	if err != nil {
+		return false, err
+	}
+
+	total := decimal.Zero
+	ids := []uuid.UUID{}
+	for _, drain := range drains {
+		total = total.Add(drain.Total)
+		ids = append(ids, drain.ID)
+	}
+
+	if len(drains) == 0 || total.LessThan(policy.MinimumPayout) || !total.IsPositive() {
+		// some of the drains are being worked on elsewhere, they will be aggregated on a later run
+		return false, nil
+	}
+
+	var batchID uuid.UUID
+	err = tx.Get(&batchID, `insert into drain_batch (payout_address, total) values ($1, $2) returning id`, addresses[0], total)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(`update claim_drain set batch_id = $1 where id = any($2::uuid[])`, batchID, pq.Array(ids))
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	countDrainsBatched.Add(float64(len(drains)))
+	return true, nil
+}
+
+// updateDrainBatchStatus records the latest transaction status of the batch, marking it completed or erred once
+// the transaction reaches a terminal status. The transaction of a completed batch is recorded against each drain
+// it aggregates so that every claim can be traced to the transfer which paid it out, the drains of a failed batch
+// are released to be batched again.
+func updateDrainBatchStatus(tx *sqlx.Tx, id uuid.UUID, status string) error {
+	statement := `
+update drain_batch
+set transaction_status = $2,
+	status_checked_at = current_timestamp,
+	completed = $3,
+	erred = $4
+where id = $1`
+	_, err := tx.Exec(statement, id, status,
+		status == wallet.TransactionCompleted,
+		status == wallet.TransactionFailed || status == wallet.TransactionCancelled)
+	if err != nil {
+		return err
+	}
+
+	if status == wallet.TransactionFailed || status == wallet.TransactionCancelled {
+		// funds were never moved, the drains are transferred in a later batch
+		return releaseDrainBatch(tx, id)
+	}
+
+	if status != wallet.TransactionCompleted {
+		return nil
+	}
+
+	statement = `
+update claim_drain
+set transaction_id = drain_batch.transaction_id,
+	transaction_status = drain_batch.transaction_status,
+	status_checked_at = current_timestamp,
+	completed = true
+from drain_batch
+where claim_drain.batch_id = drain_batch.id and drain_batch.id = $1`
+	_, err = tx.Exec(statement, id)
+	return err
+}
+
+// transferReconciliation holds the per table statements used to reconcile confirmed transfers
+type transferReconciliation struct {
+	// next locks the next transfer which has not yet completed and is due a status check
+	next string
+	// checked defers the next status check of a transfer whose transaction could not be looked up
+	checked string
+	// update records the latest transaction status of a transfer
+	update func(tx *sqlx.Tx, id uuid.UUID, status string) error
+}
+
+var (
+	drainReconciliation = transferReconciliation{
+		next: `
+select id, transaction_id, transaction_status
+from claim_drain
+where not erred and not completed and transaction_status is not null
+	and (status_checked_at is null or status_checked_at < current_timestamp - $1 * interval '1 second')
+order by status_checked_at asc nulls first
+for update skip locked
+limit 1`,
+		checked: `update claim_drain set status_checked_at = current_timestamp where id = $1`,
+		update:  updateDrainTransactionStatus,
+	}
+
+	drainBatchReconciliation = transferReconciliation{
+		next: `
+select id, transaction_id, transaction_status
+from drain_batch
+where not erred and not completed and transaction_status is not null
+	and (status_checked_at is null or status_checked_at < current_timestamp - $1 * interval '1 second')
+order by status_checked_at asc nulls first
+for update skip locked
+limit 1`,
+		checked: `update drain_batch set status_checked_at = current_timestamp where id = $1`,
+		update:  updateDrainBatchStatus,
+	}
+)
+
+// RunNextDrainReconcileJob to check the status of a confirmed drain transfer which has not yet completed
+func (pg *Postgres) RunNextDrainReconcileJob(ctx context.Context, worker ReconcileWorker) (bool, error) {
+	return pg.reconcileNextTransfer(ctx, worker, drainReconciliation)
+}
+
+// RunNextDrainBatchReconcileJob to check the status of a confirmed batch transfer which has not yet completed
+func (pg *Postgres) RunNextDrainBatchReconcileJob(ctx context.Context, worker ReconcileWorker) (bool, error) {
+	return pg.reconcileNextTransfer(ctx, worker, drainBatchReconciliation)
+}
+
+// reconcileNextTransfer looks up the transaction of the next transfer due a status check and records its status
+func (pg *Postgres) reconcileNextTransfer(ctx context.Context, worker ReconcileWorker, reconciliation transferReconciliation) (bool, error) {
+	tx, err := pg.DB.Beginx()
+	attempted := false
+	if err != nil {
+		return attempted, err
+	}
+	defer pg.RollbackTx(tx)
+
+	type ReconcileJob struct {
+		ID            uuid.UUID `db:"id"`
+		TransactionID string    `db:"transaction_id"`
+		Status        string    `db:"transaction_status"`
+	}
+
+	jobs := []ReconcileJob{}
+	err = tx.Select(&jobs, reconciliation.next, reconcileInterval.Seconds())
+	if err != nil {
+		return attempted, err
+	}
+
+	if len(jobs) != 1 {
+		return attempted, nil
+	}
+
+	job := jobs[0]
+	attempted = true
+
+	txn, err := worker.LookupTransaction(ctx, job.TransactionID)
+	if err != nil {
+		// wait for the next interval before checking this transaction again
+		{
+			_, err := tx.Exec(reconciliation.checked, job.ID)
+			if err != nil {
+				pg.RollbackTx(tx)
+			}
+			_ = tx.Commit()
+		}
+		return attempted, err
+	}
+
+	err = reconciliation.update(tx, job.ID, txn.Status)
+	if err != nil {
+		return attempted, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return attempted, err
+	}
+
+	if txn.Status != job.Status {
+		countDrainTransactionsReconciled.With(prometheus.Labels{"status": txn.Status}).Inc()
+	}
+
+	return attempted, nil
+}
+
+// UpdateDrainTransactionStatus of the drain or drain batch with the passed transaction id, returning true if one exists
+// Transfers which have not yet been confirmed by the drain job are only updated once the transaction is terminal
+func (pg *Postgres) UpdateDrainTransactionStatus(transactionID string, status string) (bool, error) {
+	tx, err := pg.DB.Beginx()
+	if err != nil {
+		return false, err
+	}
+	defer pg.RollbackTx(tx)
+
+	statement := `
+update claim_drain
+set transaction_status = $2,
+	status_checked_at = current_timestamp,
+	completed = $3,
+	erred = $4
+where transaction_id = $1 and not completed and not erred and (transaction_status is not null or $3 or $4)`
+	_, err = tx.Exec(statement, transactionID, status,
+		status == wallet.TransactionCompleted,
+		status == wallet.TransactionFailed || status == wallet.TransactionCancelled)
+	if err != nil {
+		return false, err
+	}
+
+	statement = `
+select id
+from drain_batch
+where transaction_id = $1 and not completed and not erred and (transaction_status is not null or $2 or $3)
+for update`
+	batchIDs := []uuid.UUID{}
+	err = tx.Select(&batchIDs, statement, transactionID,
+		status == wallet.TransactionCompleted,
+		status == wallet.TransactionFailed || status == wallet.TransactionCancelled)
+	if err != nil {
+		return false, err
+	}
+	for _, id := range batchIDs {
+		err = updateDrainBatchStatus(tx, id, status)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	var exists bool
+	statement = `
+select exists(select 1 from claim_drain where transaction_id = $1)
+	or exists(select 1 from drain_batch where transaction_id = $1)`
+	err = tx.Get(&exists, statement, transactionID)
+	if err != nil {
+		return false, err
+	}
+
+	return exists, tx.Commit()
+}
+
+// CountStuckDrains returns the number of drain transfers which have not completed after the passed age
+// When batching, redeemed drains waiting to be aggregated are not stuck and batches are counted instead
+func (pg *Postgres) CountStuckDrains(age time.Duration, batching bool) (int, error) {
+	statement := `
+select
+	(select count(*)
+	from claim_drain
+	where not erred and not completed and created_at < current_timestamp - $1 * interval '1 second'
+		and batch_id is null and not ($2 and redeemed and transaction_id is null))
+	+
+	(select count(*)
+	from drain_batch
+	where not erred and not completed and created_at < current_timestamp - $1 * interval '1 second')`
+
+	var count int
+	err := pg.DB.Get(&count, statement, age.Seconds(), batching)
+	return count, err
+}
+
+// GetPendingOutflow returns the value of the drains which have not yet been transferred and of the claimed ads
+// grants which may still be drained before their promotion stops being redeemable. Drains whose transfer, or
+// whose batch transfer, has been confirmed have already left the hot wallet and are not counted.
+func (pg *Postgres) GetPendingOutflow(ctx context.Context) (*PendingOutflow, error) {
+	statement := `
+select
+	(select coalesce(sum(claim_drain.total), 0.0)
+	from claim_drain left join drain_batch on drain_batch.id = claim_drain.batch_id
+	where not claim_drain.erred and not claim_drain.completed and claim_drain.transaction_status is null
+		and drain_batch.transaction_status is null) as drains,
+	(select coalesce(sum(claims.approximate_value), 0.0)
+	from claims join promotions on promotions.id = claims.promotion_id
+	where promotions.promotion_type = 'ads' and promotions.redeemable_until > current_timestamp
+		and claims.redeemed and not claims.drained) as claims`
+
+	var outflow PendingOutflow
+	err := pg.DB.GetContext(ctx, &outflow, statement)
+	if err != nil {
+		return nil, err
+	}
+	return &outflow, nil
+}
+
+// RunNextPromotionExpiryJob to deactivate a promotion past its redemption deadline and report its unredeemed value
+func (pg *Postgres) RunNextPromotionExpiryJob(ctx context.Context, worker ExpiryWorker) (bool, error) {
+	tx, err := pg.DB.Beginx()
+	attempted := false
+	if err != nil {
+		return attempted, err
+	}
+	defer pg.RollbackTx(tx)
+
+	statement := `
+select *
+from promotions
+where redeemable_until < current_timestamp and
+	not exists (select 1 from promotion_expiry_reports where promotion_expiry_reports.promotion_id = promotions.id)
+for update skip locked
+limit 1`
+
+	promotions := []Promotion{}
+	err = tx.Select(&promotions, statement)
+	if err != nil {
+		return attempted, err
+	}
+
+	if len(promotions) != 1 {
+		return attempted, nil
+	}
+
+	promotion := promotions[0]
+	attempted = true
+
+	var issued struct {
+		Claims int             `db:"claims"`
+		Value  decimal.Decimal `db:"value"`
+	}
+	err = tx.Get(&issued, `
+select count(*) as claims, coalesce(sum(approximate_value), 0.0) as value
+from claims
+where promotion_id = $1 and redeemed`, promotion.ID)
+	if err != nil {
+		return attempted, err
+	}
+
+	var redeemed decimal.Decimal
+	err = tx.Get(&redeemed, `select coalesce(sum(amount), 0.0) from cohort_redemptions where promotion_id = $1`, promotion.ID)
+	if err != nil {
+		return attempted, err
+	}
+
+	unredeemed := issued.Value.Sub(redeemed)
+	if unredeemed.IsNegative() {
+		unredeemed = decimal.Zero
+	}
+
+	report := ExpiryReport{}
+	err = tx.Get(&report, `
+insert into promotion_expiry_reports (promotion_id, claims, issued_value, redeemed_value, unredeemed_value)
+values ($1, $2, $3, $4, $5)
+returning *`, promotion.ID, issued.Claims, issued.Value, redeemed, unredeemed)
+	if err != nil {
+		return attempted, err
+	}
+
+	previous := promotion.State()
+	updated := previous
+	updated.Active = false
+
+	_, err = tx.Exec(`update promotions set active = false where id = $1`, promotion.ID)
+	if err != nil {
+		return attempted, err
+	}
+
+	err = insertPromotionAudit(tx, promotion.ID, PromotionSwept, sweeperActor, previous, updated)
+	if err != nil {
+		return attempted, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return attempted, err
+	}
+
+	// report only once the sweep is committed so a rolled back sweep is not counted
+	err = worker.ReportExpiredPromotion(ctx, &promotion, &report)
+	if err != nil {
+		return attempted, err
+	}
+
+	return attempted, nil
+}
+
+// GetExpiryReports returns the unredeemed value of each swept promotion, most recent first
+func (pg *Postgres) GetExpiryReports(ctx context.Context) ([]ExpiryReport, error) {
+	reports := []ExpiryReport{}
+	err := pg.DB.SelectContext(ctx, &reports, "select * from promotion_expiry_reports order by created_at desc")
+	if err != nil {
+		return nil, err
+	}
+
+	return reports, nil
 }
 
 // UpdateOrder updates the orders status.
@@ -5,11 +5,17 @@ package promotion
 import (
 	"context"
 	"errors"
+	"net/http"
 	"testing"
+	"time"
 
+	"github.com/brave-intl/bat-go/datastore/grantserver"
 	"github.com/brave-intl/bat-go/utils/altcurrency"
+	"github.com/brave-intl/bat-go/utils/clients"
 	"github.com/brave-intl/bat-go/utils/clients/cbr"
+	"github.com/brave-intl/bat-go/utils/issuerpolicy"
 	"github.com/brave-intl/bat-go/utils/jsonutils"
+	kafkautils "github.com/brave-intl/bat-go/utils/kafka"
 	testutils "github.com/brave-intl/bat-go/utils/test"
 	"github.com/brave-intl/bat-go/wallet"
 	gomock "github.com/golang/mock/gomock"
@@ -45,7 +51,7 @@ func (suite *PostgresTestSuite) SetupTest() {
 }
 
 func (suite *PostgresTestSuite) CleanDB() {
-	tables := []string{"claim_creds", "claims", "wallets", "issuers", "promotions"}
+	tables := []string{"event_outbox", "claim_drain", "drain_batch", "suggestion_drain", "claim_creds", "claims", "wallets", "issuers", "promotion_audit", "promotion_expiry_reports", "cohort_redemptions", "promotion_cohorts", "promotions"}
 
 	pg, err := NewPostgres("", false)
 	suite.Require().NoError(err, "Failed to get postgres conn")
@@ -141,6 +147,56 @@ func (suite *PostgresTestSuite) TestGetIssuer() {
 	suite.Assert().Equal(origIssuer, issuerByPublicKey)
 }
 
+func (suite *PostgresTestSuite) TestIssuerVersions() {
+	pg, err := NewPostgres("", false)
+	suite.Require().NoError(err)
+
+	promotion, err := pg.CreatePromotion("ugp", 10, decimal.NewFromFloat(25.0), "")
+	suite.Require().NoError(err, "Create promotion should succeed")
+
+	expired, err := pg.InsertIssuer(&Issuer{
+		PromotionID: promotion.ID,
+		Cohort:      "test",
+		PublicKey:   "hBrtClwIppLmu/qZ8EhGM1TQZUwDUosbOrVu3jMwryY=",
+		ExpiresAt:   time.Now().Add(-issuerpolicy.RedemptionGracePeriod - time.Hour),
+	})
+	suite.Require().NoError(err, "Insert issuer should succeed")
+	suite.Assert().Equal(1, expired.Version)
+	suite.Assert().Equal(issuerpolicy.DefaultMaxTokens, expired.MaxTokens)
+
+	grace, err := pg.InsertIssuer(&Issuer{
+		PromotionID: promotion.ID,
+		Cohort:      "test",
+		PublicKey:   "dvhAc5rh1kTWIi6l2mlgIeCsGQ84Bl1VtR1TR/JoMUs=",
+		Version:     2,
+		ExpiresAt:   time.Now().Add(-time.Hour),
+	})
+	suite.Require().NoError(err, "Insert issuer should succeed")
+
+	latest, err := pg.InsertIssuer(&Issuer{
+		PromotionID: promotion.ID,
+		Cohort:      "test",
+		PublicKey:   "SJbDnt+BbkyFmUvDhOYnVJvQLTGZXi4/b34tBkYBIVk=",
+		Version:     3,
+	})
+	suite.Require().NoError(err, "Insert issuer should succeed")
+
+	_, err = pg.InsertIssuer(&Issuer{PromotionID: promotion.ID, Cohort: "test", PublicKey: "duplicate", Version: 3})
+	suite.Require().Error(err, "Versions must be unique per cohort")
+
+	issuer, err := pg.GetIssuer(promotion.ID, "test")
+	suite.Require().NoError(err, "Get issuer should succeed")
+	suite.Assert().Equal(latest, issuer, "Latest version should be returned")
+
+	issuer, err = pg.GetIssuerByPublicKey(grace.PublicKey)
+	suite.Require().NoError(err, "Get issuer by public key should succeed")
+	suite.Assert().Equal(grace, issuer, "Expired issuers are accepted during the grace period")
+
+	issuer, err = pg.GetIssuerByPublicKey(expired.PublicKey)
+	suite.Require().NoError(err, "Get issuer by public key should succeed")
+	suite.Assert().Nil(issuer, "Issuers are not accepted after the grace period")
+}
+
 func (suite *PostgresTestSuite) TestUpsertWallet() {
 	pg, err := NewPostgres("", false)
 	suite.Require().NoError(err)
@@ -707,18 +763,181 @@ func (suite *PostgresTestSuite) TestRunNextClaimJob() {
 	suite.Assert().Equal(true, attempted)
 	suite.Require().Error(err)
 
+	// Signing job should not rerun until its backoff has elapsed
+	attempted, err = pg.RunNextClaimJob(context.Background(), mockClaimWorker)
+	suite.Assert().Equal(false, attempted)
+	suite.Require().NoError(err)
+
+	var attempts int
+	suite.Require().NoError(pg.DB.Get(&attempts, `select attempts from claim_creds where claim_id = $1`, claim.ID))
+	suite.Assert().Equal(1, attempts, "Failed attempt should be recorded")
+
+	_, err = pg.DB.Exec(`update claim_creds set next_attempt_at = current_timestamp where claim_id = $1`, claim.ID)
+	suite.Require().NoError(err)
+
 	// Signing job should rerun on failure
 	mockClaimWorker.EXPECT().SignClaimCreds(gomock.Any(), gomock.Eq(claim.ID), gomock.Eq(*issuer), gomock.Eq([]string(blindedCreds))).Return(creds, nil)
 	attempted, err = pg.RunNextClaimJob(context.Background(), mockClaimWorker)
 	suite.Assert().Equal(true, attempted)
 	suite.Require().NoError(err)
 
+	issuer, err = pg.GetIssuer(promotion.ID, "control")
+	suite.Require().NoError(err, "Get issuer should succeed")
+	suite.Assert().Equal(len(signedCreds), issuer.TokensSigned, "Signed credentials should count toward the issuer capacity")
+
 	// No further jobs should run after success
 	attempted, err = pg.RunNextClaimJob(context.Background(), mockClaimWorker)
 	suite.Assert().Equal(false, attempted)
 	suite.Require().NoError(err)
 }
 
+func (suite *PostgresTestSuite) TestRunNextClaimJobDeadLetter() {
+	pg, err := NewPostgres("", false)
+	suite.Require().NoError(err)
+
+	mockCtrl := gomock.NewController(suite.T())
+	defer mockCtrl.Finish()
+
+	mockClaimWorker := NewMockClaimWorker(mockCtrl)
+
+	publicKey := "hBrtClwIppLmu/qZ8EhGM1TQZUwDUosbOrVu3jMwryY="
+	blindedCreds := jsonutils.JSONStringArray([]string{"hBrtClwIppLmu/qZ8EhGM1TQZUwDUosbOrVu3jMwryY="})
+
+	promotion, err := pg.CreatePromotion("ugp", 2, decimal.NewFromFloat(25.0), "")
+	suite.Require().NoError(err, "Create promotion should succeed")
+	suite.Require().NoError(pg.ActivatePromotion(promotion), "Activate promotion should succeed")
+
+	issuer, err := pg.InsertIssuer(&Issuer{PromotionID: promotion.ID, Cohort: "control", PublicKey: publicKey})
+	suite.Require().NoError(err, "Insert issuer should succeed")
+
+	w := &wallet.Info{ID: uuid.NewV4().String(), Provider: "uphold", ProviderID: uuid.NewV4().String(), PublicKey: publicKey}
+	suite.Require().NoError(pg.UpsertWallet(w), "Save wallet should succeed")
+
+	claim, err := pg.ClaimForWallet(promotion, issuer, w, blindedCreds)
+	suite.Require().NoError(err, "Claim for wallet should succeed, promotion is active and has grants left")
+
+	// A rejected request will never succeed and is dead-lettered immediately
+	rejected := clients.NewHTTPError(errors.New("invalid blinded credentials"), "response", http.StatusBadRequest, nil)
+	mockClaimWorker.EXPECT().SignClaimCreds(gomock.Any(), gomock.Eq(claim.ID), gomock.Eq(*issuer), gomock.Eq([]string(blindedCreds))).Return(nil, rejected)
+	attempted, err := pg.RunNextClaimJob(context.Background(), mockClaimWorker)
+	suite.Assert().Equal(true, attempted)
+	suite.Require().Error(err)
+
+	deadLetters, err := pg.GetDeadLetters(context.Background(), grantserver.ClaimCredsQueue)
+	suite.Require().NoError(err)
+	suite.Require().Len(deadLetters, 1)
+	suite.Assert().Equal(claim.ID, deadLetters[0].ID)
+	suite.Assert().Equal(1, deadLetters[0].Attempts)
+	suite.Require().NotNil(deadLetters[0].LastError)
+
+	_, err = pg.DB.Exec(`update claim_creds set next_attempt_at = current_timestamp where claim_id = $1`, claim.ID)
+	suite.Require().NoError(err)
+
+	// Dead-lettered jobs are not run again
+	attempted, err = pg.RunNextClaimJob(context.Background(), mockClaimWorker)
+	suite.Assert().Equal(false, attempted)
+	suite.Require().NoError(err)
+
+	_, err = pg.GetDeadLetters(context.Background(), "claims")
+	suite.Require().True(errors.Is(err, grantserver.ErrUnknownQueue))
+
+	requeued, err := pg.RequeueDeadLetter(context.Background(), grantserver.ClaimCredsQueue, uuid.NewV4())
+	suite.Require().NoError(err)
+	suite.Assert().False(requeued, "Only dead-lettered jobs can be requeued")
+
+	requeued, err = pg.RequeueDeadLetter(context.Background(), grantserver.ClaimCredsQueue, claim.ID)
+	suite.Require().NoError(err)
+	suite.Assert().True(requeued)
+
+	deadLetters, err = pg.GetDeadLetters(context.Background(), grantserver.ClaimCredsQueue)
+	suite.Require().NoError(err)
+	suite.Assert().Len(deadLetters, 0)
+
+	// Requeued jobs run again
+	mockClaimWorker.EXPECT().SignClaimCreds(gomock.Any(), gomock.Eq(claim.ID), gomock.Eq(*issuer), gomock.Eq([]string(blindedCreds))).Return(nil, errors.New("Worker failed"))
+	attempted, err = pg.RunNextClaimJob(context.Background(), mockClaimWorker)
+	suite.Assert().Equal(true, attempted)
+	suite.Require().Error(err)
+}
+
+func (suite *PostgresTestSuite) TestRunNextPromotionExpiryJob() {
+	pg, err := NewPostgres("", false)
+	suite.Require().NoError(err)
+
+	service := &Service{datastore: pg}
+
+	attempted, err := pg.RunNextPromotionExpiryJob(context.Background(), service)
+	suite.Assert().Equal(false, attempted)
+	suite.Require().NoError(err)
+
+	publicKey := "hBrtClwIppLmu/qZ8EhGM1TQZUwDUosbOrVu3jMwryY="
+	blindedCreds := jsonutils.JSONStringArray([]string{"hBrtClwIppLmu/qZ8EhGM1TQZUwDUosbOrVu3jMwryY="})
+
+	promotion, err := pg.CreatePromotion("ugp", 2, decimal.NewFromFloat(25.0), "")
+	suite.Require().NoError(err, "Create promotion should succeed")
+	suite.Require().NoError(pg.ActivatePromotion(promotion), "Activate promotion should succeed")
+
+	current, err := pg.CreatePromotion("ugp", 2, decimal.NewFromFloat(25.0), "")
+	suite.Require().NoError(err, "Create promotion should succeed")
+
+	issuer, err := pg.InsertIssuer(&Issuer{PromotionID: promotion.ID, Cohort: "control", PublicKey: publicKey})
+	suite.Require().NoError(err, "Insert issuer should succeed")
+
+	w := &wallet.Info{ID: uuid.NewV4().String(), Provider: "uphold", ProviderID: uuid.NewV4().String(), PublicKey: publicKey}
+	suite.Require().NoError(pg.UpsertWallet(w), "Save wallet should succeed")
+
+	_, err = pg.ClaimForWallet(promotion, issuer, w, blindedCreds)
+	suite.Require().NoError(err, "Claim for wallet should succeed, promotion is active and has grants left")
+
+	_, err = pg.DB.Exec(`update promotions set claimable_until = current_timestamp - interval '2 days',
+		redeemable_until = current_timestamp - interval '1 day' where id = $1`, promotion.ID)
+	suite.Require().NoError(err)
+
+	other := &wallet.Info{ID: uuid.NewV4().String(), Provider: "uphold", ProviderID: uuid.NewV4().String(), PublicKey: publicKey}
+	suite.Require().NoError(pg.UpsertWallet(other), "Save wallet should succeed")
+	_, err = service.ClaimPromotionForWallet(context.Background(), promotion.ID, uuid.Must(uuid.FromString(other.ID)), blindedCreds)
+	suite.Require().True(errors.Is(err, errPromotionNotClaimable), "Claims after the claim deadline should be rejected")
+
+	_, _, _, _, err = service.GetCredentialRedemptions(context.Background(), []CredentialBinding{{
+		PublicKey:     publicKey,
+		TokenPreimage: "hBrtClwIppLmu/qZ8EhGM1TQZUwDUosbOrVu3jMwryY=",
+		Signature:     "hBrtClwIppLmu/qZ8EhGM1TQZUwDUosbOrVu3jMwryY=",
+	}})
+	suite.Require().True(errors.Is(err, errPromotionNotRedeemable), "Redemptions after the redemption deadline should be rejected")
+
+	attempted, err = pg.RunNextPromotionExpiryJob(context.Background(), service)
+	suite.Assert().Equal(true, attempted)
+	suite.Require().NoError(err)
+
+	// Each promotion is only swept once
+	attempted, err = pg.RunNextPromotionExpiryJob(context.Background(), service)
+	suite.Assert().Equal(false, attempted)
+	suite.Require().NoError(err)
+
+	swept, err := pg.GetPromotion(promotion.ID)
+	suite.Require().NoError(err, "Get promotion should succeed")
+	suite.Assert().False(swept.Active)
+
+	current, err = pg.GetPromotion(current.ID)
+	suite.Require().NoError(err, "Get promotion should succeed")
+	suite.Assert().True(current.Redeemable(time.Now()), "Promotions default to a redemption deadline in the future")
+
+	reports, err := pg.GetExpiryReports(context.Background())
+	suite.Require().NoError(err, "Get expiry reports should succeed")
+	suite.Require().Len(reports, 1)
+	suite.Assert().Equal(promotion.ID, reports[0].PromotionID)
+	suite.Assert().Equal(1, reports[0].Claims)
+	suite.Assert().True(decimal.NewFromFloat(25.0).Equal(reports[0].UnredeemedValue))
+	suite.Assert().True(reports[0].RedeemedValue.IsZero())
+	suite.Assert().False(reports[0].Backfilled)
+
+	audit, err := pg.GetPromotionAudit(context.Background(), promotion.ID)
+	suite.Require().NoError(err, "Get promotion audit should succeed")
+	suite.Require().Len(audit, 1)
+	suite.Assert().Equal(PromotionSwept, audit[0].Action)
+	suite.Assert().Equal(sweeperActor, audit[0].Actor)
+}
+
 func (suite *PostgresTestSuite) TestInsertClobberedClaims() {
 	ctx := context.Background()
 	id1 := uuid.NewV4()
@@ -799,7 +1018,8 @@ func (suite *PostgresTestSuite) TestDrainClaim() {
 	mockDrainWorker := NewMockDrainWorker(mockCtrl)
 
 	// One drain job should run
-	mockDrainWorker.EXPECT().RedeemAndTransferFunds(gomock.Any(), gomock.Eq(credentials), gomock.Eq(walletID), testutils.DecEq(total)).Return(nil, errors.New("Worker failed"))
+	mockDrainWorker.EXPECT().RedeemCredentials(gomock.Any(), gomock.Eq(credentials), gomock.Eq(walletID)).Return(nil)
+	mockDrainWorker.EXPECT().SubmitTransfer(gomock.Any(), gomock.Eq(walletID), testutils.DecEq(total), gomock.Any()).Return(nil, errors.New("Worker failed"))
 	attempted, err := pg.RunNextDrainJob(context.Background(), mockDrainWorker)
 	suite.Assert().Equal(true, attempted)
 	suite.Require().Error(err)
@@ -808,10 +1028,676 @@ func (suite *PostgresTestSuite) TestDrainClaim() {
 	attempted, err = pg.RunNextDrainJob(context.Background(), mockDrainWorker)
 	suite.Assert().Equal(false, attempted)
 	suite.Require().NoError(err)
+}
 
-	// FIXME add test for successful drain job
+func (suite *PostgresTestSuite) TestRunNextDrainJob() {
+	pg, err := NewPostgres("", false)
+	suite.Require().NoError(err)
+
+	publicKey := "hBrtClwIppLmu/qZ8EhGM1TQZUwDUosbOrVu3jMwryY="
+	blindedCreds := jsonutils.JSONStringArray([]string{"hBrtClwIppLmu/qZ8EhGM1TQZUwDUosbOrVu3jMwryY="})
+	walletID := uuid.NewV4()
+	w := &wallet.Info{
+		ID:         walletID.String(),
+		Provider:   "uphold",
+		ProviderID: uuid.NewV4().String(),
+		PublicKey:  publicKey,
+	}
+	{
+		tmp := uuid.NewV4().String()
+		w.PayoutAddress = &tmp
+	}
+	err = pg.UpsertWallet(w)
+	suite.Require().NoError(err, "Upsert wallet should succeed")
+
+	total := decimal.NewFromFloat(50.0)
+	promotion, err := pg.CreatePromotion("ads", 2, total, "")
+	suite.Require().NoError(err, "Create promotion should succeed")
+	suite.Require().NoError(pg.ActivatePromotion(promotion), "Activate promotion should succeed")
+
+	issuer := &Issuer{PromotionID: promotion.ID, Cohort: "control", PublicKey: publicKey}
+	issuer, err = pg.InsertIssuer(issuer)
+	suite.Require().NoError(err, "Insert issuer should succeed")
+
+	claim, err := pg.ClaimForWallet(promotion, issuer, w, blindedCreds)
+	suite.Require().NoError(err, "Claim creation should succeed")
+
+	credentials := []cbr.CredentialRedemption{}
+	err = pg.DrainClaim(claim, credentials, w, total)
+	suite.Require().NoError(err, "Drain claim should succeed")
+
+	var idempotencyKey uuid.UUID
+	err = pg.DB.Get(&idempotencyKey, `select idempotency_key from claim_drain where wallet_id = $1`, walletID)
+	suite.Require().NoError(err)
+
+	mockCtrl := gomock.NewController(suite.T())
+	defer mockCtrl.Finish()
+
+	mockDrainWorker := NewMockDrainWorker(mockCtrl)
+	txn := &wallet.TransactionInfo{ID: uuid.NewV4().String(), Status: "completed"}
+
+	// The first attempt is interrupted after the transfer was submitted
+	mockDrainWorker.EXPECT().RedeemCredentials(gomock.Any(), gomock.Eq(credentials), gomock.Eq(walletID)).Return(nil)
+	mockDrainWorker.EXPECT().SubmitTransfer(gomock.Any(), gomock.Eq(walletID), testutils.DecEq(total), gomock.Eq(idempotencyKey)).Return(txn, nil)
+	mockDrainWorker.EXPECT().ConfirmTransfer(gomock.Any(), gomock.Eq(txn.ID)).Return(nil, errDrainTransferExpired)
+	attempted, err := pg.RunNextDrainJob(context.Background(), mockDrainWorker)
+	suite.Assert().Equal(true, attempted)
+	suite.Require().NoError(err)
+
+	// The retry must not redeem again and resubmits with the same idempotency key
+	mockDrainWorker.EXPECT().SubmitTransfer(gomock.Any(), gomock.Eq(walletID), testutils.DecEq(total), gomock.Eq(idempotencyKey)).Return(txn, nil)
+	mockDrainWorker.EXPECT().ConfirmTransfer(gomock.Any(), gomock.Eq(txn.ID)).Return(txn, nil)
+	attempted, err = pg.RunNextDrainJob(context.Background(), mockDrainWorker)
+	suite.Assert().Equal(true, attempted)
+	suite.Require().NoError(err)
+
+	var transactionID string
+	err = pg.DB.Get(&transactionID, `select transaction_id from claim_drain where wallet_id = $1 and completed`, walletID)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(txn.ID, transactionID)
+
+	// Completed drains are not run again
+	attempted, err = pg.RunNextDrainJob(context.Background(), mockDrainWorker)
+	suite.Assert().Equal(false, attempted)
+	suite.Require().NoError(err)
+}
+
+type transactionLookup map[string]*wallet.TransactionInfo
+
+func (l transactionLookup) LookupTransaction(ctx context.Context, transactionID string) (*wallet.TransactionInfo, error) {
+	return l[transactionID], nil
+}
+
+func (suite *PostgresTestSuite) TestRunNextDrainReconcileJob() {
+	pg, err := NewPostgres("", false)
+	suite.Require().NoError(err)
+
+	walletID := uuid.NewV4()
+	txn := &wallet.TransactionInfo{ID: uuid.NewV4().String(), Status: "processing"}
+	lookup := transactionLookup{txn.ID: txn}
+
+	_, err = pg.DB.Exec(`
+	insert into claim_drain (credentials, wallet_id, total, redeemed, transaction_id, transaction_status, created_at)
+	values ('[]', $1, 1, true, $2, 'pending', current_timestamp - interval '2 hours')`, walletID, txn.ID)
+	suite.Require().NoError(err)
+
+	stuck, err := pg.CountStuckDrains(stuckTransactionAge, false)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(1, stuck)
+
+	// Confirmed transfers are not picked up by the drain job
+	mockCtrl := gomock.NewController(suite.T())
+	defer mockCtrl.Finish()
+	attempted, err := pg.RunNextDrainJob(context.Background(), NewMockDrainWorker(mockCtrl))
+	suite.Require().NoError(err)
+	suite.Assert().Equal(false, attempted)
+
+	attempted, err = pg.RunNextDrainReconcileJob(context.Background(), lookup)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(true, attempted)
+
+	var status string
+	err = pg.DB.Get(&status, `select transaction_status from claim_drain where wallet_id = $1 and not completed`, walletID)
+	suite.Require().NoError(err)
+	suite.Assert().Equal("processing", status)
+
+	// The same transaction is not checked again until the reconcile interval has passed
+	attempted, err = pg.RunNextDrainReconcileJob(context.Background(), lookup)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(false, attempted)
+
+	_, err = pg.DB.Exec(`update claim_drain set status_checked_at = null where wallet_id = $1`, walletID)
+	suite.Require().NoError(err)
+
+	txn.Status = "completed"
+	attempted, err = pg.RunNextDrainReconcileJob(context.Background(), lookup)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(true, attempted)
+
+	stuck, err = pg.CountStuckDrains(stuckTransactionAge, false)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(0, stuck)
+
+	// Completed drains are not changed by later notifications
+	found, err := pg.UpdateDrainTransactionStatus(txn.ID, "failed")
+	suite.Require().NoError(err)
+	suite.Assert().True(found)
+	err = pg.DB.Get(&status, `select transaction_status from claim_drain where wallet_id = $1 and completed and not erred`, walletID)
+	suite.Require().NoError(err)
+	suite.Assert().Equal("completed", status)
+
+	found, err = pg.UpdateDrainTransactionStatus(uuid.NewV4().String(), "completed")
+	suite.Require().NoError(err)
+	suite.Assert().False(found)
+}
+
+// suggestionWorkerFunc adapts a function to the SuggestionWorker interface
+type suggestionWorkerFunc func(ctx context.Context, credentials []cbr.CredentialRedemption, suggestionText string, suggestion []byte, retry bool) (*grantserver.OutboxEvent, error)
+
+func (f suggestionWorkerFunc) RedeemAndCreateSuggestionEvent(ctx context.Context, credentials []cbr.CredentialRedemption, suggestionText string, suggestion []byte, retry bool) (*grantserver.OutboxEvent, error) {
+	return f(ctx, credentials, suggestionText, suggestion, retry)
+}
+
+func (suite *PostgresTestSuite) TestRunNextOutboxJob() {
+	pg, err := NewPostgres("", false)
+	suite.Require().NoError(err)
+
+	topic := "test.outbox." + uuid.NewV4().String()
+	publisher := kafkautils.NewMemoryPublisher()
+
+	attempted, err := pg.RunNextOutboxJob(context.Background(), topic, publisher)
+	suite.Require().NoError(err)
+	suite.Assert().False(attempted)
+
+	events := []grantserver.OutboxEvent{
+		{ID: uuid.NewV4(), Topic: topic, Key: "brave.com", Payload: []byte("first")},
+		{ID: uuid.NewV4(), Topic: topic, Key: "example.com", Payload: []byte("second")},
+		{ID: uuid.NewV4(), Topic: topic, Key: "brave.com", Payload: []byte("third")},
+	}
+	for _, event := range events {
+		tx, err := pg.DB.Beginx()
+		suite.Require().NoError(err)
+		suite.Require().NoError(grantserver.InsertOutboxEvent(tx, event))
+		// queueing the same event again has no effect
+		suite.Require().NoError(grantserver.InsertOutboxEvent(tx, event))
+		suite.Require().NoError(tx.Commit())
+	}
+
+	publisher.Err = errors.New("broker unavailable")
+	attempted, err = pg.RunNextOutboxJob(context.Background(), topic, publisher)
+	suite.Require().Error(err)
+	suite.Assert().True(attempted)
+
+	var attempts int
+	suite.Require().NoError(pg.DB.Get(&attempts, `select attempts from event_outbox where id = $1`, events[0].ID))
+	suite.Assert().Equal(1, attempts, "Failed publication should be recorded")
+
+	publisher.Err = nil
+	attempted, err = pg.RunNextOutboxJob(context.Background(), topic, publisher)
+	suite.Require().NoError(err)
+	suite.Assert().True(attempted)
+
+	published := publisher.Events()
+	suite.Require().Len(published, 3, "Each event should be published once")
+	for i, event := range published {
+		suite.Assert().Equal(events[i].Payload, event.Value, "Events should be published in order")
+		suite.Assert().Equal(events[i].Key, string(event.Key))
+		suite.Assert().Equal(events[i].ID.String(), event.ID)
+	}
+
+	attempted, err = pg.RunNextOutboxJob(context.Background(), topic, publisher)
+	suite.Require().NoError(err)
+	suite.Assert().False(attempted, "Published events should not be published again")
+
+	replayed, err := pg.ReplayOutboxEvents(context.Background(), topic, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	suite.Require().NoError(err)
+	suite.Assert().Equal(int64(3), replayed)
+
+	attempted, err = pg.RunNextOutboxJob(context.Background(), topic, publisher)
+	suite.Require().NoError(err)
+	suite.Assert().True(attempted)
+	suite.Assert().Len(publisher.Events(), 6, "Replayed events should be published again")
+
+	unpublished := grantserver.OutboxEvent{ID: uuid.NewV4(), Topic: topic, Key: "brave.com", Payload: []byte("fourth")}
+	tx, err := pg.DB.Beginx()
+	suite.Require().NoError(err)
+	suite.Require().NoError(grantserver.InsertOutboxEvent(tx, unpublished))
+	suite.Require().NoError(tx.Commit())
+
+	deleted, err := pg.DeletePublishedOutboxEvents(context.Background(), topic, time.Now().Add(-grantserver.OutboxRetention))
+	suite.Require().NoError(err)
+	suite.Assert().False(deleted, "Events published within the retention should be kept")
+
+	_, err = pg.DB.Exec(`update event_outbox set created_at = created_at - interval '60 days', published_at = published_at - interval '60 days' where topic = $1`, topic)
+	suite.Require().NoError(err)
+
+	deleted, err = pg.DeletePublishedOutboxEvents(context.Background(), topic, time.Now().Add(-grantserver.OutboxRetention))
+	suite.Require().NoError(err)
+	suite.Assert().True(deleted)
+
+	var remaining []uuid.UUID
+	suite.Require().NoError(pg.DB.Select(&remaining, `select id from event_outbox where topic = $1`, topic))
+	suite.Assert().Equal([]uuid.UUID{unpublished.ID}, remaining, "Unpublished events should never be deleted")
+}
+
+func (suite *PostgresTestSuite) TestCohorts() {
+	pg, err := NewPostgres("", false)
+	suite.Require().NoError(err)
+
+	high := decimal.NewFromFloat(30.0)
+	cohorts := []Cohort{{Name: "control", Weight: 1}, {Name: "high", Weight: 1, Value: &high}}
+
+	_, err = pg.CreatePromotionWithCohortsAndBudget("ugp", 10, decimal.NewFromFloat(15.0), "", append(cohorts, cohorts[0]), nil, "test")
+	suite.Require().Error(err, "Cohort names should be unique per promotion")
+	var promotions int
+	suite.Require().NoError(pg.DB.Get(&promotions, "select count(*) from promotions"))
+	suite.Assert().Equal(0, promotions, "A promotion should not be created without all of its cohorts")
+
+	budget := decimal.NewFromFloat(100.0)
+	promotion, err := pg.CreatePromotionWithCohortsAndBudget("ugp", 10, decimal.NewFromFloat(15.0), "", cohorts, &budget, "test")
+	suite.Require().NoError(err, "Create promotion should succeed")
+	suite.Require().NotNil(promotion.Budget, "The budget should be set when the promotion is created")
+	suite.Assert().True(budget.Equal(*promotion.Budget))
+
+	audit, err := pg.GetPromotionAudit(context.Background(), promotion.ID)
+	suite.Require().NoError(err)
+	suite.Require().Len(audit, 1)
+	suite.Assert().Equal(PromotionBudgeted, audit[0].Action)
+	suite.Assert().Equal("test", audit[0].Actor)
+	suite.Require().NoError(pg.ActivatePromotion(promotion), "Activate promotion should succeed")
+
+	stored, err := pg.GetCohorts(promotion.ID)
+	suite.Require().NoError(err)
+	suite.Require().Len(stored, 2)
+	suite.Assert().True(high.Equal(*stored[1].Value))
+	suite.Assert().Nil(stored[0].Value)
+
+	for _, cohort := range cohorts {
+		issuer, err := pg.InsertIssuer(&Issuer{PromotionID: promotion.ID, Cohort: cohort.Name, PublicKey: cohort.Name})
+		suite.Require().NoError(err, "Insert issuer should succeed")
+
+		w := &wallet.Info{ID: uuid.NewV4().String(), Provider: "uphold", ProviderID: uuid.NewV4().String(), PublicKey: "-"}
+		suite.Require().NoError(pg.UpsertWallet(w), "Save wallet should succeed")
+
+		claim, err := pg.ClaimForWallet(promotion, issuer, w, jsonutils.JSONStringArray{})
+		suite.Require().NoError(err, "Claim for wallet should succeed")
+		suite.Assert().Equal(cohort.Name, claim.Cohort, "The cohort should be recorded on the claim")
+		if cohort.Value != nil {
+			suite.Assert().True(cohort.Value.Equal(claim.ApproximateValue), "The cohort value should override the promotion value")
+		} else {
+			suite.Assert().True(promotion.ApproximateValue.Equal(claim.ApproximateValue))
+		}
+	}
+
+	funding := []FundingSource{{
+		Type:        "ugp",
+		Amount:      decimal.NewFromFloat(1.0),
+		Cohort:      "high",
+		PromotionID: promotion.ID,
+		Credentials: []cbr.CredentialRedemption{{}, {}},
+	}}
+	suite.Require().NoError(pg.InsertSuggestion(funding[0].Credentials, "", []byte{}, funding), "Insert suggestion should succeed")
+
+	summary, err := pg.GetCohortSummary(promotion.ID)
+	suite.Require().NoError(err)
+	suite.Require().Len(summary, 2)
+	suite.Assert().Equal(0, summary[1].Redemptions, "Suggestions are only counted once their credentials are redeemed")
+
+	// the first attempt fails after redeeming, the retry must accept the credentials as already redeemed
+	attempted, err := pg.RunNextSuggestionJob(context.Background(), suggestionWorkerFunc(
+		func(ctx context.Context, credentials []cbr.CredentialRedemption, suggestionText string, suggestion []byte, retry bool) (*grantserver.OutboxEvent, error) {
+			suite.Assert().False(retry, "The first attempt should not be a retry")
+			return nil, errors.New("connection reset")
+		}))
+	suite.Require().Error(err)
+	suite.Require().True(attempted)
+
+	_, err = pg.DB.Exec(`update suggestion_drain set next_attempt_at = current_timestamp`)
+	suite.Require().NoError(err)
+
+	eventID := uuid.NewV4()
+	attempted, err = pg.RunNextSuggestionJob(context.Background(), suggestionWorkerFunc(
+		func(ctx context.Context, credentials []cbr.CredentialRedemption, suggestionText string, suggestion []byte, retry bool) (*grantserver.OutboxEvent, error) {
+			suite.Assert().True(retry, "The credentials may have been redeemed by the first attempt")
+			return &grantserver.OutboxEvent{ID: eventID, Topic: "test.suggestion", Key: "brave.com", Payload: suggestion}, nil
+		}))
+	suite.Require().NoError(err)
+	suite.Require().True(attempted)
+
+	var queued int
+	suite.Require().NoError(pg.DB.Get(&queued, `select count(*) from event_outbox where id = $1 and published_at is null`, eventID))
+	suite.Assert().Equal(1, queued, "The suggestion event should be queued with the redemption")
+
+	summary, err = pg.GetCohortSummary(promotion.ID)
+	suite.Require().NoError(err)
+	suite.Require().Len(summary, 2)
+
+	suite.Assert().Equal("control", summary[0].Cohort)
+	suite.Assert().Equal(1, *summary[0].Weight)
+	suite.Assert().Equal(1, summary[0].Claims)
+	suite.Assert().True(decimal.NewFromFloat(15.0).Equal(summary[0].ClaimedValue))
+	suite.Assert().Equal(0, summary[0].Redemptions)
+
+	suite.Assert().Equal("high", summary[1].Cohort)
+	suite.Assert().Equal(1, summary[1].Claims)
+	suite.Assert().True(high.Equal(summary[1].ClaimedValue))
+	suite.Assert().Equal(2, summary[1].Redemptions)
+	suite.Assert().True(decimal.NewFromFloat(1.0).Equal(summary[1].Contributions))
+	suite.Assert().True(decimal.Zero.Equal(summary[1].Drained))
+
+	// a second suggestion with the same credentials must not be taken for the redemption of the first
+	suite.Require().NoError(pg.InsertSuggestion(funding[0].Credentials, "", []byte{}, funding), "Insert suggestion should succeed")
+	attempted, err = pg.RunNextSuggestionJob(context.Background(), suggestionWorkerFunc(
+		func(ctx context.Context, credentials []cbr.CredentialRedemption, suggestionText string, suggestion []byte, retry bool) (*grantserver.OutboxEvent, error) {
+			suite.Fail("Credentials claimed by another suggestion should not be redeemed")
+			return nil, nil
+		}))
+	suite.Require().True(errors.Is(err, grantserver.ErrCredentialsClaimed))
+	suite.Require().True(attempted)
+
+	var erred bool
+	suite.Require().NoError(pg.DB.Get(&erred, `select erred from suggestion_drain`))
+	suite.Assert().True(erred, "The duplicate suggestion should be dead-lettered")
+
+	summary, err = pg.GetCohortSummary(promotion.ID)
+	suite.Require().NoError(err)
+	suite.Assert().True(decimal.NewFromFloat(1.0).Equal(summary[1].Contributions), "The duplicate should not be counted")
 }
 
 func TestPostgresTestSuite(t *testing.T) {
 	suite.Run(t, new(PostgresTestSuite))
 }
+
+type batchTransfers struct {
+	txn       *wallet.TransactionInfo
+	submitted map[string]decimal.Decimal
+}
+
+func (b *batchTransfers) SubmitBatchTransfer(ctx context.Context, payoutAddress string, total decimal.Decimal, idempotencyKey uuid.UUID) (*wallet.TransactionInfo, error) {
+	b.submitted[payoutAddress] = total
+	return b.txn, nil
+}
+
+func (b *batchTransfers) ConfirmTransfer(ctx context.Context, transactionID string) (*wallet.TransactionInfo, error) {
+	return b.txn, nil
+}
+
+func (suite *PostgresTestSuite) TestRunNextDrainBatchJob() {
+	pg, err := NewPostgres("", false)
+	suite.Require().NoError(err)
+
+	policy := DrainBatchPolicy{Window: time.Hour, MinimumPayout: decimal.NewFromFloat(2.0)}
+	payoutAddress := uuid.NewV4().String()
+	smallPayoutAddress := uuid.NewV4().String()
+
+	statement := `
+	insert into claim_drain (credentials, wallet_id, total, payout_address)
+	values ('[]', $1, $2, $3)`
+	_, err = pg.DB.Exec(statement, uuid.NewV4(), decimal.NewFromFloat(1.0), payoutAddress)
+	suite.Require().NoError(err)
+	_, err = pg.DB.Exec(statement, uuid.NewV4(), decimal.NewFromFloat(2.0), payoutAddress)
+	suite.Require().NoError(err)
+	_, err = pg.DB.Exec(statement, uuid.NewV4(), decimal.NewFromFloat(0.5), smallPayoutAddress)
+	suite.Require().NoError(err)
+
+	mockCtrl := gomock.NewController(suite.T())
+	defer mockCtrl.Finish()
+
+	// Only the credentials are redeemed, the transfers wait to be batched
+	mockDrainWorker := NewMockDrainWorker(mockCtrl)
+	mockDrainWorker.EXPECT().RedeemCredentials(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(3)
+	for i := 0; i < 3; i++ {
+		attempted, err := pg.RunNextDrainRedemptionJob(context.Background(), mockDrainWorker)
+		suite.Require().NoError(err)
+		suite.Assert().True(attempted)
+	}
+	attempted, err := pg.RunNextDrainRedemptionJob(context.Background(), mockDrainWorker)
+	suite.Require().NoError(err)
+	suite.Assert().False(attempted)
+
+	worker := &batchTransfers{
+		txn:       &wallet.TransactionInfo{ID: uuid.NewV4().String(), Status: "completed"},
+		submitted: map[string]decimal.Decimal{},
+	}
+
+	// Drains wait for the batch window before they are aggregated
+	attempted, err = pg.RunNextDrainBatchJob(context.Background(), worker, &policy)
+	suite.Require().NoError(err)
+	suite.Assert().False(attempted)
+
+	stuck, err := pg.CountStuckDrains(0, true)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(0, stuck, "Drains waiting to be batched are not stuck")
+
+	_, err = pg.DB.Exec(`update claim_drain set created_at = current_timestamp - interval '2 hours'`)
+	suite.Require().NoError(err)
+
+	attempted, err = pg.RunNextDrainBatchJob(context.Background(), worker, &policy)
+	suite.Require().NoError(err)
+	suite.Assert().True(attempted, "A batch should be created for the payout address")
+
+	attempted, err = pg.RunNextDrainBatchJob(context.Background(), worker, &policy)
+	suite.Require().NoError(err)
+	suite.Assert().True(attempted, "The batch should be transferred")
+	suite.Require().Len(worker.submitted, 1)
+	suite.Assert().True(decimal.NewFromFloat(3.0).Equal(worker.submitted[payoutAddress]))
+
+	// Each drain records the batch and transaction which paid it out
+	type attribution struct {
+		BatchID       *uuid.UUID `db:"batch_id"`
+		TransactionID *string    `db:"transaction_id"`
+		Completed     bool       `db:"completed"`
+	}
+	drains := []attribution{}
+	err = pg.DB.Select(&drains, `select batch_id, transaction_id, completed from claim_drain where payout_address = $1`, payoutAddress)
+	suite.Require().NoError(err)
+	suite.Require().Len(drains, 2)
+	for _, drain := range drains {
+		suite.Require().NotNil(drain.BatchID)
+		suite.Assert().Equal(*drains[0].BatchID, *drain.BatchID)
+		suite.Require().NotNil(drain.TransactionID)
+		suite.Assert().Equal(worker.txn.ID, *drain.TransactionID)
+		suite.Assert().True(drain.Completed)
+	}
+
+	// Balances below the minimum payout are carried forward
+	drains = []attribution{}
+	err = pg.DB.Select(&drains, `select batch_id, transaction_id, completed from claim_drain where payout_address = $1`, smallPayoutAddress)
+	suite.Require().NoError(err)
+	suite.Require().Len(drains, 1)
+	suite.Assert().Nil(drains[0].BatchID)
+	suite.Assert().False(drains[0].Completed)
+
+	attempted, err = pg.RunNextDrainBatchJob(context.Background(), worker, &policy)
+	suite.Require().NoError(err)
+	suite.Assert().False(attempted)
+
+	stuck, err = pg.CountStuckDrains(stuckTransactionAge, true)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(0, stuck)
+
+	found, err := pg.UpdateDrainTransactionStatus(worker.txn.ID, "failed")
+	suite.Require().NoError(err)
+	suite.Assert().True(found, "Batch transfers should be found by transaction id")
+}
+
+func (suite *PostgresTestSuite) TestRunNextDrainBatchJobFailure() {
+	pg, err := NewPostgres("", false)
+	suite.Require().NoError(err)
+
+	policy := DrainBatchPolicy{Window: time.Hour, MinimumPayout: decimal.NewFromFloat(1.0)}
+	payoutAddress := uuid.NewV4().String()
+
+	_, err = pg.DB.Exec(`
+	insert into claim_drain (credentials, wallet_id, total, payout_address, redeemed, created_at)
+	values ('[]', $1, 2, $2, true, current_timestamp - interval '2 hours')`, uuid.NewV4(), payoutAddress)
+	suite.Require().NoError(err)
+
+	worker := &batchTransfers{
+		txn:       &wallet.TransactionInfo{ID: uuid.NewV4().String(), Status: "failed"},
+		submitted: map[string]decimal.Decimal{},
+	}
+
+	attempted, err := pg.RunNextDrainBatchJob(context.Background(), worker, nil)
+	suite.Require().NoError(err)
+	suite.Assert().False(attempted, "No batches are created while batching is disabled")
+
+	attempted, err = pg.RunNextDrainBatchJob(context.Background(), worker, &policy)
+	suite.Require().NoError(err)
+	suite.Require().True(attempted, "A batch should be created for the payout address")
+
+	var batchID uuid.UUID
+	suite.Require().NoError(pg.DB.Get(&batchID, `select batch_id from claim_drain where payout_address = $1`, payoutAddress))
+
+	// the open batch is still transferred once batching is disabled
+	attempted, err = pg.RunNextDrainBatchJob(context.Background(), worker, nil)
+	suite.Require().NoError(err)
+	suite.Assert().True(attempted, "Open batches should be transferred while batching is disabled")
+	suite.Assert().Len(worker.submitted, 1)
+
+	var released *uuid.UUID
+	suite.Require().NoError(pg.DB.Get(&released, `select batch_id from claim_drain where payout_address = $1`, payoutAddress))
+	suite.Assert().Nil(released, "The drains of a failed batch should be released")
+
+	requeued, err := pg.RequeueDeadLetter(context.Background(), grantserver.DrainBatchQueue, batchID)
+	suite.Require().NoError(err)
+	suite.Assert().False(requeued, "A batch whose drains were released should not be transferred again")
+
+	attempted, err = pg.RunNextDrainBatchJob(context.Background(), worker, &policy)
+	suite.Require().NoError(err)
+	suite.Assert().True(attempted, "The released drains should be batched again")
+
+	var rebatched uuid.UUID
+	suite.Require().NoError(pg.DB.Get(&rebatched, `select batch_id from claim_drain where payout_address = $1`, payoutAddress))
+	suite.Assert().NotEqual(batchID, rebatched)
+}
+
+func (suite *PostgresTestSuite) TestRunNextDrainConfirmJob() {
+	pg, err := NewPostgres("", false)
+	suite.Require().NoError(err)
+
+	policy := DrainBatchPolicy{Window: time.Hour, MinimumPayout: decimal.NewFromFloat(0.1)}
+	payoutAddress := uuid.NewV4().String()
+	txn := &wallet.TransactionInfo{ID: uuid.NewV4().String(), Status: "completed"}
+
+	// The transfer was submitted by the drain job before batching was enabled
+	_, err = pg.DB.Exec(`
+	insert into claim_drain (credentials, wallet_id, total, payout_address, redeemed, transaction_id, created_at)
+	values ('[]', $1, 1, $2, true, $3, current_timestamp - interval '2 hours')`, uuid.NewV4(), payoutAddress, txn.ID)
+	suite.Require().NoError(err)
+
+	mockCtrl := gomock.NewController(suite.T())
+	defer mockCtrl.Finish()
+	mockDrainWorker := NewMockDrainWorker(mockCtrl)
+
+	attempted, err := pg.RunNextDrainRedemptionJob(context.Background(), mockDrainWorker)
+	suite.Require().NoError(err)
+	suite.Assert().False(attempted, "Redeemed drains are not redeemed again")
+
+	worker := &batchTransfers{txn: txn, submitted: map[string]decimal.Decimal{}}
+	attempted, err = pg.RunNextDrainBatchJob(context.Background(), worker, &policy)
+	suite.Require().NoError(err)
+	suite.Assert().False(attempted, "Submitted drains are not batched")
+
+	mockDrainWorker.EXPECT().ConfirmTransfer(gomock.Any(), gomock.Eq(txn.ID)).Return(txn, nil)
+	attempted, err = pg.RunNextDrainConfirmJob(context.Background(), mockDrainWorker)
+	suite.Require().NoError(err)
+	suite.Assert().True(attempted, "The submitted transfer should be confirmed")
+
+	var completed bool
+	err = pg.DB.Get(&completed, `select completed from claim_drain where transaction_id = $1`, txn.ID)
+	suite.Require().NoError(err)
+	suite.Assert().True(completed)
+
+	attempted, err = pg.RunNextDrainConfirmJob(context.Background(), mockDrainWorker)
+	suite.Require().NoError(err)
+	suite.Assert().False(attempted)
+}
+
+func (suite *PostgresTestSuite) TestGetPendingOutflow() {
+	pg, err := NewPostgres("", false)
+	suite.Require().NoError(err)
+
+	var confirmedBatch, submittedBatch uuid.UUID
+	statement := `insert into drain_batch (payout_address, total, transaction_id, transaction_status) values ($1, $2, $3, $4) returning id`
+	suite.Require().NoError(pg.DB.Get(&confirmedBatch, statement, uuid.NewV4().String(), 8, uuid.NewV4().String(), "pending"))
+	suite.Require().NoError(pg.DB.Get(&submittedBatch, statement, uuid.NewV4().String(), 16, uuid.NewV4().String(), nil))
+
+	statement = `
+	insert into claim_drain (credentials, wallet_id, total, redeemed, transaction_id, transaction_status, batch_id)
+	values ('[]', $1, $2, true, $3, $4, $5)`
+	// waiting to be transferred
+	_, err = pg.DB.Exec(statement, uuid.NewV4(), 1, nil, nil, nil)
+	suite.Require().NoError(err)
+	// confirmed, the funds have left the hot wallet
+	_, err = pg.DB.Exec(statement, uuid.NewV4(), 2, uuid.NewV4().String(), "pending", nil)
+	suite.Require().NoError(err)
+	// submitted but not yet confirmed
+	_, err = pg.DB.Exec(statement, uuid.NewV4(), 4, uuid.NewV4().String(), nil, nil)
+	suite.Require().NoError(err)
+	_, err = pg.DB.Exec(statement, uuid.NewV4(), 8, nil, nil, confirmedBatch)
+	suite.Require().NoError(err)
+	_, err = pg.DB.Exec(statement, uuid.NewV4(), 16, nil, nil, submittedBatch)
+	suite.Require().NoError(err)
+
+	outflow, err := pg.GetPendingOutflow(context.Background())
+	suite.Require().NoError(err)
+	suite.Assert().True(decimal.NewFromFloat(21.0).Equal(outflow.Drains), "Only drains which have not been confirmed should be counted")
+}
+
+func (suite *PostgresTestSuite) TestPromotionBudget() {
+	pg, err := NewPostgres("", false)
+	suite.Require().NoError(err)
+
+	publicKey := "hBrtClwIppLmu/qZ8EhGM1TQZUwDUosbOrVu3jMwryY="
+	blindedCreds := jsonutils.JSONStringArray([]string{"hBrtClwIppLmu/qZ8EhGM1TQZUwDUosbOrVu3jMwryY="})
+
+	value := decimal.NewFromFloat(15.0)
+	promotion, err := pg.CreatePromotion("ugp", 10, value, "")
+	suite.Require().NoError(err, "Create promotion should succeed")
+	suite.Require().NoError(pg.ActivatePromotion(promotion), "Activate promotion should succeed")
+
+	budget := decimal.NewFromFloat(30.0)
+	promotion, err = pg.UpdatePromotion(context.Background(), promotion.ID, PromotionBudgeted, "test", PromotionChanges{Budget: &budget})
+	suite.Require().NoError(err, "Setting the budget should succeed")
+	suite.Require().NotNil(promotion.Budget)
+	suite.Assert().True(budget.Equal(*promotion.Budget))
+
+	negative := decimal.NewFromFloat(-1.0)
+	_, err = pg.UpdatePromotion(context.Background(), promotion.ID, PromotionBudgeted, "test", PromotionChanges{Budget: &negative})
+	suite.Assert().Equal(errNegativeBudget, err)
+
+	issuer, err := pg.InsertIssuer(&Issuer{PromotionID: promotion.ID, Cohort: "control", PublicKey: publicKey})
+	suite.Require().NoError(err, "Insert issuer should succeed")
+
+	claim := func() error {
+		w := &wallet.Info{
+			ID:         uuid.NewV4().String(),
+			Provider:   "uphold",
+			ProviderID: uuid.NewV4().String(),
+			PublicKey:  publicKey,
+		}
+		suite.Require().NoError(pg.UpsertWallet(w), "Upsert wallet should succeed")
+		_, err := pg.ClaimForWallet(promotion, issuer, w, blindedCreds)
+		return err
+	}
+
+	suite.Require().NoError(claim(), "Claims within the budget should succeed")
+	promotion, err = pg.GetPromotion(promotion.ID)
+	suite.Require().NoError(err)
+	suite.Assert().True(promotion.Active)
+
+	// The promotion is deactivated once the remaining budget cannot cover another grant
+	suite.Require().NoError(claim(), "Claims within the budget should succeed")
+	promotion, err = pg.GetPromotion(promotion.ID)
+	suite.Require().NoError(err)
+	suite.Assert().False(promotion.Active)
+	suite.Assert().True(decimal.NewFromFloat(30.0).Equal(promotion.ClaimedValue))
+
+	audit, err := pg.GetPromotionAudit(context.Background(), promotion.ID)
+	suite.Require().NoError(err)
+	suite.Require().NotEmpty(audit)
+	suite.Assert().Equal(PromotionBudgetExhausted, audit[0].Action)
+	suite.Assert().Equal(budgetActor, audit[0].Actor)
+
+	summary, err := pg.GetPromotionBudget(context.Background(), promotion.ID)
+	suite.Require().NoError(err)
+	suite.Assert().True(decimal.NewFromFloat(30.0).Equal(summary.Claimed))
+	suite.Assert().True(decimal.Zero.Equal(*summary.Remaining()))
+
+	budgets, err := pg.GetActivePromotionBudgets(context.Background())
+	suite.Require().NoError(err)
+	suite.Assert().Len(budgets, 0, "Deactivated promotions should not be reported")
+
+	// Claims which would exceed the budget are rejected even if the promotion is reactivated
+	budget = decimal.NewFromFloat(40.0)
+	active := true
+	_, err = pg.UpdatePromotion(context.Background(), promotion.ID, PromotionBudgeted, "test", PromotionChanges{Budget: &budget, Active: &active})
+	suite.Require().NoError(err)
+	suite.Assert().Equal(ErrBudgetExceeded, claim())
+
+	promotion, err = pg.GetPromotion(promotion.ID)
+	suite.Require().NoError(err)
+	suite.Assert().True(decimal.NewFromFloat(30.0).Equal(promotion.ClaimedValue), "Rejected claims should not be charged")
+	suite.Assert().Equal(8, promotion.RemainingGrants)
+}
@@ -4,12 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/brave-intl/bat-go/utils/altcurrency"
 	"github.com/brave-intl/bat-go/utils/clients/cbr"
 	"github.com/brave-intl/bat-go/wallet"
-	sentry "github.com/getsentry/sentry-go"
 	uuid "github.com/satori/go.uuid"
 	"github.com/shopspring/decimal"
 )
@@ -73,27 +73,51 @@ func (service *Service) Drain(ctx context.Context, credentials []CredentialBindi
 			if err != nil {
 				return fmt.Errorf("error draining claim: %w", err)
 			}
-
-			go func() {
-				_, err := service.RunNextDrainJob(ctx)
-				if err != nil {
-					sentry.CaptureException(err)
-					sentry.Flush(time.Second * 2)
-				}
-			}()
 		}
 	}
 
 	return nil
 }
 
+const (
+	// reconcileInterval is the minimum time between status checks of the same transaction
+	reconcileInterval = time.Minute
+	// stuckTransactionAge is the age after which an unfinished drain transfer is considered stuck
+	stuckTransactionAge = time.Hour
+)
+
+// errDrainTransferExpired indicates a submitted drain transfer can no longer be confirmed and must be resubmitted
+var errDrainTransferExpired = errors.New("drain transfer quote has expired")
+
 // DrainWorker attempts to work on a drain job by redeeming the credentials and transferring funds
+// Transfers are split into submit and confirm steps so that the transaction id can be persisted
+// before funds are moved, allowing a retried job to resume the existing transfer
 type DrainWorker interface {
-	RedeemAndTransferFunds(ctx context.Context, credentials []cbr.CredentialRedemption, walletID uuid.UUID, total decimal.Decimal) (*wallet.TransactionInfo, error)
+	// RedeemCredentials for the wallet, marking them as spent
+	RedeemCredentials(ctx context.Context, credentials []cbr.CredentialRedemption, walletID uuid.UUID) error
+	// SubmitTransfer of total to the wallet payout address without confirming it
+	SubmitTransfer(ctx context.Context, walletID uuid.UUID, total decimal.Decimal, idempotencyKey uuid.UUID) (*wallet.TransactionInfo, error)
+	// ConfirmTransfer previously submitted with SubmitTransfer, moving funds
+	ConfirmTransfer(ctx context.Context, transactionID string) (*wallet.TransactionInfo, error)
+}
+
+// RedeemCredentials after validating that the wallet is verified
+func (service *Service) RedeemCredentials(ctx context.Context, credentials []cbr.CredentialRedemption, walletID uuid.UUID) error {
+	wallet, err := service.datastore.GetWallet(walletID)
+	if err != nil {
+		return err
+	}
+
+	if wallet == nil || wallet.PayoutAddress == nil {
+		return errors.New("missing wallet")
+	}
+
+	return service.cbClient.RedeemCredentials(ctx, credentials, walletID.String())
 }
 
-// RedeemAndTransferFunds after validating that all the credential bindings
-func (service *Service) RedeemAndTransferFunds(ctx context.Context, credentials []cbr.CredentialRedemption, walletID uuid.UUID, total decimal.Decimal) (*wallet.TransactionInfo, error) {
+// SubmitTransfer of total from the hot wallet to the wallet payout address, the idempotency key
+// is included as the transaction message so the transfer can be traced back to its drain
+func (service *Service) SubmitTransfer(ctx context.Context, walletID uuid.UUID, total decimal.Decimal, idempotencyKey uuid.UUID) (*wallet.TransactionInfo, error) {
 	wallet, err := service.datastore.GetWallet(walletID)
 	if err != nil {
 		return nil, err
@@ -103,20 +127,103 @@ func (service *Service) RedeemAndTransferFunds(ctx context.Context, credentials
 		return nil, errors.New("missing wallet")
 	}
 
-	err = service.cbClient.RedeemCredentials(ctx, credentials, walletID.String())
+	signedTx, err := service.hotWallet.PrepareTransaction(altcurrency.BAT, altcurrency.BAT.ToProbi(total), *wallet.PayoutAddress, "drain:"+idempotencyKey.String())
 	if err != nil {
 		return nil, err
 	}
 
-	// FIXME should use idempotency key
-	tx, err := service.hotWallet.Transfer(altcurrency.BAT, altcurrency.BAT.ToProbi(total), *wallet.PayoutAddress)
+	return service.hotWallet.SubmitTransaction(ctx, signedTx, false)
+}
+
+// ConfirmTransfer previously submitted, resuming if it has already been confirmed
+func (service *Service) ConfirmTransfer(ctx context.Context, transactionID string) (*wallet.TransactionInfo, error) {
+	// unconfirmed transactions appear as "not found"
+	tx, err := service.hotWallet.GetTransaction(ctx, transactionID)
 	if err != nil {
-		return nil, err
+		if !wallet.IsNotFound(err) {
+			return nil, err
+		}
+
+		tx, err = service.hotWallet.ConfirmTransaction(ctx, transactionID)
+		if wallet.AlreadyExists(err) {
+			// a previous attempt confirmed the transaction but we did not see the result
+			tx, err = service.hotWallet.GetTransaction(ctx, transactionID)
+		}
+		if wallet.IsNotFound(err) {
+			return nil, errDrainTransferExpired
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	if service.drainChannel != nil {
 		service.drainChannel <- tx
 	}
 
-	return tx, err
+	return tx, nil
+}
+
+// DrainBatchPolicy controls how redeemed drains are aggregated into a single transfer per payout address
+type DrainBatchPolicy struct {
+	// Window is how long the oldest drain to a payout address waits for others to join its batch
+	Window time.Duration
+	// MinimumPayout of a batch, smaller balances are carried forward until enough has accumulated
+	MinimumPayout decimal.Decimal
+}
+
+// drainBatchPolicyFromEnvironment returns the batching policy, or nil when drains are transferred individually
+func drainBatchPolicyFromEnvironment() (*DrainBatchPolicy, error) {
+	window := os.Getenv("DRAIN_BATCH_WINDOW")
+	if len(window) == 0 {
+		return nil, nil
+	}
+
+	policy := DrainBatchPolicy{MinimumPayout: decimal.Zero}
+	var err error
+	policy.Window, err = time.ParseDuration(window)
+	if err != nil {
+		return nil, fmt.Errorf("DRAIN_BATCH_WINDOW is invalid: %w", err)
+	}
+	if minimumPayout := os.Getenv("DRAIN_BATCH_MINIMUM_PAYOUT"); len(minimumPayout) > 0 {
+		policy.MinimumPayout, err = decimal.NewFromString(minimumPayout)
+		if err != nil {
+			return nil, fmt.Errorf("DRAIN_BATCH_MINIMUM_PAYOUT is invalid: %w", err)
+		}
+	}
+	return &policy, nil
+}
+
+// DrainBatchWorker transfers the aggregated total of a batch of redeemed drains
+type DrainBatchWorker interface {
+	// SubmitBatchTransfer of total to the payout address without confirming it
+	SubmitBatchTransfer(ctx context.Context, payoutAddress string, total decimal.Decimal, idempotencyKey uuid.UUID) (*wallet.TransactionInfo, error)
+	// ConfirmTransfer previously submitted with SubmitBatchTransfer, moving funds
+	ConfirmTransfer(ctx context.Context, transactionID string) (*wallet.TransactionInfo, error)
+}
+
+// SubmitBatchTransfer of total from the hot wallet to the payout address, the idempotency key of the batch
+// is included as the transaction message so the transfer can be traced back to the drains it aggregates
+func (service *Service) SubmitBatchTransfer(ctx context.Context, payoutAddress string, total decimal.Decimal, idempotencyKey uuid.UUID) (*wallet.TransactionInfo, error) {
+	signedTx, err := service.hotWallet.PrepareTransaction(altcurrency.BAT, altcurrency.BAT.ToProbi(total), payoutAddress, "drain-batch:"+idempotencyKey.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return service.hotWallet.SubmitTransaction(ctx, signedTx, false)
+}
+
+// ReconcileWorker looks up the current state of a confirmed transfer from the wallet provider
+type ReconcileWorker interface {
+	LookupTransaction(ctx context.Context, transactionID string) (*wallet.TransactionInfo, error)
+}
+
+// LookupTransaction made from the hot wallet
+func (service *Service) LookupTransaction(ctx context.Context, transactionID string) (*wallet.TransactionInfo, error) {
+	return service.hotWallet.GetTransaction(ctx, transactionID)
+}
+
+// UpdateTransactionStatus of a drain transfer as notified by the wallet provider
+func (service *Service) UpdateTransactionStatus(ctx context.Context, transactionID string, status string) (bool, error) {
+	return service.datastore.UpdateDrainTransactionStatus(transactionID, status)
 }
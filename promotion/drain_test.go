@@ -0,0 +1,66 @@
+package promotion
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/brave-intl/bat-go/utils/altcurrency"
+	"github.com/brave-intl/bat-go/utils/httpsignature"
+	"github.com/brave-intl/bat-go/wallet"
+	"github.com/brave-intl/bat-go/wallet/provider/uphold"
+	"github.com/brave-intl/bat-go/wallet/provider/uphold/fake"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfirmTransfer(t *testing.T) {
+	srv := fake.New()
+	defer srv.Close()
+	defer uphold.UseAPI(srv.URL, srv.Client())()
+	ctx := context.Background()
+
+	publicKey, privateKey, err := httpsignature.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+
+	var info wallet.Info
+	info.Provider = "uphold"
+	info.ProviderID = srv.CreateCard("BAT", publicKey, decimal.NewFromFloat(100))
+	{
+		tmp := altcurrency.BAT
+		info.AltCurrency = &tmp
+	}
+	hotWallet, err := uphold.New(info, privateKey, publicKey)
+	require.NoError(t, err)
+
+	destination := srv.CreateCard("BAT", publicKey, decimal.Zero)
+	service := &Service{hotWallet: hotWallet}
+
+	submit := func() string {
+		signedTx, err := hotWallet.PrepareTransaction(altcurrency.BAT, altcurrency.BAT.ToProbi(decimal.NewFromFloat(10)), destination, "drain:test")
+		require.NoError(t, err)
+		txn, err := hotWallet.SubmitTransaction(ctx, signedTx, false)
+		require.NoError(t, err)
+		return txn.ID
+	}
+
+	id := submit()
+	txn, err := service.ConfirmTransfer(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, id, txn.ID)
+	assert.True(t, srv.Balance(destination).Equals(decimal.NewFromFloat(10)))
+
+	// confirming a second time resumes the already completed transfer
+	txn, err = service.ConfirmTransfer(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, id, txn.ID)
+	assert.True(t, srv.Balance(destination).Equals(decimal.NewFromFloat(10)), "funds should only be moved once")
+
+	srv.QuoteTTL = -time.Second
+	id = submit()
+	_, err = service.ConfirmTransfer(ctx, id)
+	assert.True(t, errors.Is(err, errDrainTransferExpired), "expired transfers must be resubmitted")
+	assert.True(t, srv.Balance(destination).Equals(decimal.NewFromFloat(10)))
+}
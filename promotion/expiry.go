@@ -0,0 +1,103 @@
+package promotion
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// PromotionSwept is the audit action recorded when an expired promotion is deactivated by the sweeper
+	PromotionSwept = "sweep"
+	// sweeperActor is recorded as the actor of changes made by the sweeper
+	sweeperActor = "sweeper"
+)
+
+var (
+	errPromotionNotClaimable  = errors.New("promotion is no longer claimable, the claim deadline has passed")
+	errPromotionNotRedeemable = errors.New("promotion credentials can no longer be redeemed, the redemption deadline has passed")
+	errInvalidDeadlines       = errors.New("redemption deadline cannot be before the claim deadline")
+
+	countPromotionsSwept = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "promotions_swept_total",
+			Help: "A counter for the number of expired promotions deactivated by the sweeper",
+		},
+		[]string{"type"},
+	)
+	countUnredeemedBatTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "promotion_unredeemed_bat_total",
+			Help: "A counter for the value of credentials which were not redeemed before their promotion expired",
+		},
+		[]string{"type"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		countPromotionsSwept,
+		countUnredeemedBatTotal,
+	)
+}
+
+// ExpiryReport summarizes the value of a promotion which was not redeemed before the redemption deadline
+type ExpiryReport struct {
+	PromotionID     uuid.UUID       `json:"promotionId" db:"promotion_id"`
+	CreatedAt       time.Time       `json:"createdAt" db:"created_at"`
+	Claims          int             `json:"claims" db:"claims"`
+	IssuedValue     decimal.Decimal `json:"issuedValue" db:"issued_value"`
+	RedeemedValue   decimal.Decimal `json:"redeemedValue" db:"redeemed_value"`
+	UnredeemedValue decimal.Decimal `json:"unredeemedValue" db:"unredeemed_value"`
+	// Backfilled reports were recorded for promotions which predate per promotion redemption tracking,
+	// their unredeemed value is unknown and reported as zero
+	Backfilled bool `json:"backfilled" db:"backfilled"`
+}
+
+// Claimable returns true if the claim deadline of the promotion has not passed
+func (promotion *Promotion) Claimable(now time.Time) bool {
+	return now.Before(promotion.ClaimableUntil)
+}
+
+// Redeemable returns true if the redemption deadline of the promotion has not passed
+func (promotion *Promotion) Redeemable(now time.Time) bool {
+	return now.Before(promotion.RedeemableUntil)
+}
+
+// ExpiryWorker reports the unredeemed value of promotions swept after their redemption deadline
+type ExpiryWorker interface {
+	ReportExpiredPromotion(ctx context.Context, promotion *Promotion, report *ExpiryReport) error
+}
+
+// ReportExpiredPromotion records the unredeemed value of a swept promotion for finance
+func (service *Service) ReportExpiredPromotion(ctx context.Context, promotion *Promotion, report *ExpiryReport) error {
+	labels := prometheus.Labels{"type": promotion.Type}
+	countPromotionsSwept.With(labels).Inc()
+	unredeemed, _ := report.UnredeemedValue.Float64()
+	countUnredeemedBatTotal.With(labels).Add(unredeemed)
+
+	log.Ctx(ctx).Info().
+		Str("promotion_id", promotion.ID.String()).
+		Str("type", promotion.Type).
+		Int("claims", report.Claims).
+		Str("issued_value", report.IssuedValue.String()).
+		Str("redeemed_value", report.RedeemedValue.String()).
+		Str("unredeemed_value", report.UnredeemedValue.String()).
+		Msg("promotion expired")
+	return nil
+}
+
+// RunNextPromotionExpiryJob deactivates the next promotion past its redemption deadline and reports its unredeemed value
+func (service *Service) RunNextPromotionExpiryJob(ctx context.Context) (bool, error) {
+	return service.datastore.RunNextPromotionExpiryJob(ctx, service)
+}
+
+// GetExpiryReports returns the unredeemed value of each swept promotion, most recent first
+func (service *Service) GetExpiryReports(ctx context.Context) ([]ExpiryReport, error) {
+	return service.datastore.GetExpiryReports(ctx)
+}
@@ -0,0 +1,243 @@
+package promotion
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/brave-intl/bat-go/utils/altcurrency"
+	w "github.com/brave-intl/bat-go/wallet"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// hotWalletGrant is the name of the hot wallet funding grants and drains
+	hotWalletGrant = "grant"
+	// hotWalletMonitorInterval is the time between balance checks of the hot wallets
+	hotWalletMonitorInterval = time.Minute
+)
+
+var (
+	hotWalletBalanceBat = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "hot_wallet_balance_bat",
+			Help: "the spendable balance of each hot wallet as of the last balance check",
+		},
+		[]string{"wallet"},
+	)
+	hotWalletProjectedOutflowBat = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "hot_wallet_projected_outflow_bat",
+			Help: "the value expected to be transferred out of the hot wallets broken down by pending drains and undrained claims",
+		},
+		[]string{"kind"},
+	)
+	hotWalletLowFunds = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "hot_wallet_low_funds",
+			Help: "1 if the hot wallet cannot cover the projected outflow of pending drains and undrained claims",
+		},
+		[]string{"wallet"},
+	)
+	hotWalletDrainsPaused = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "hot_wallet_drains_paused",
+			Help: "1 if drain transfers from the hot wallet are paused because it cannot cover the pending drains",
+		},
+		[]string{"wallet"},
+	)
+	countHotWalletCheckFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "hot_wallet_balance_check_failures_total",
+			Help: "A counter for the number of hot wallet balance checks which failed",
+		},
+		[]string{"wallet"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		hotWalletBalanceBat,
+		hotWalletProjectedOutflowBat,
+		hotWalletLowFunds,
+		hotWalletDrainsPaused,
+		countHotWalletCheckFailures,
+	)
+}
+
+// PendingOutflow is the value which is expected to be transferred out of the hot wallet
+type PendingOutflow struct {
+	// Drains which have not yet been transferred
+	Drains decimal.Decimal `json:"drains" db:"drains"`
+	// Claims of ads grants which may still be drained
+	Claims decimal.Decimal `json:"claims" db:"claims"`
+}
+
+// Total projected outflow
+func (outflow PendingOutflow) Total() decimal.Decimal {
+	return outflow.Drains.Add(outflow.Claims)
+}
+
+// HotWalletStatus is the result of the last balance check of a hot wallet
+type HotWalletStatus struct {
+	Wallet    string          `json:"wallet"`
+	CheckedAt time.Time       `json:"checkedAt"`
+	Spendable decimal.Decimal `json:"spendable"`
+	Reserve   decimal.Decimal `json:"reserve"`
+	Outflow   PendingOutflow  `json:"outflow"`
+	// LowFunds is true when the balance cannot cover the pending drains and undrained claims
+	LowFunds bool `json:"lowFunds"`
+	// DrainsPaused is true when the balance cannot cover the pending drains, transfers would start failing
+	DrainsPaused bool `json:"drainsPaused"`
+	// Reason drains are paused or funds are low, if any
+	Reason string `json:"reason,omitempty"`
+	// Error of the last balance check, the previous result is kept until a check succeeds
+	Error string `json:"error,omitempty"`
+}
+
+// evaluate the spendable balance against the projected outflow, keeping the reserve untouched
+func (status *HotWalletStatus) evaluate() {
+	available := status.Spendable.Sub(status.Reserve)
+	status.DrainsPaused = available.LessThan(status.Outflow.Drains)
+	status.LowFunds = available.LessThan(status.Outflow.Total())
+
+	switch {
+	case status.DrainsPaused:
+		status.Reason = fmt.Sprintf("spendable balance %s less reserve %s cannot cover pending drains of %s",
+			status.Spendable, status.Reserve, status.Outflow.Drains)
+	case status.LowFunds:
+		status.Reason = fmt.Sprintf("spendable balance %s less reserve %s cannot cover pending drains and undrained claims of %s",
+			status.Spendable, status.Reserve, status.Outflow.Total())
+	default:
+		status.Reason = ""
+	}
+}
+
+// hotWalletReserveFromEnvironment returns the BAT which must remain in the hot wallets after all pending drains
+func hotWalletReserveFromEnvironment() (decimal.Decimal, error) {
+	reserve := os.Getenv("HOT_WALLET_RESERVE")
+	if len(reserve) == 0 {
+		return decimal.Zero, nil
+	}
+	value, err := decimal.NewFromString(reserve)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("HOT_WALLET_RESERVE is invalid: %w", err)
+	}
+	return value, nil
+}
+
+// HotWalletStatuses returns the result of the last balance check of each hot wallet
+func (service *Service) HotWalletStatuses() []HotWalletStatus {
+	service.hotWalletMu.RLock()
+	defer service.hotWalletMu.RUnlock()
+
+	statuses := []HotWalletStatus{}
+	for _, status := range service.hotWalletStatus {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Wallet < statuses[j].Wallet })
+	return statuses
+}
+
+// drainsPaused returns true if any hot wallet cannot cover the pending drains
+func (service *Service) drainsPaused() bool {
+	service.hotWalletMu.RLock()
+	defer service.hotWalletMu.RUnlock()
+
+	for _, status := range service.hotWalletStatus {
+		if status.DrainsPaused {
+			return true
+		}
+	}
+	return false
+}
+
+// RunHotWalletMonitorJob checks the balance of each hot wallet against the projected outflow, pausing drain
+// transfers while a wallet cannot cover the pending drains. It always reports that no job was attempted so
+// that it only runs at its cadence.
+func (service *Service) RunHotWalletMonitorJob(ctx context.Context) (bool, error) {
+	if len(service.hotWallets) == 0 {
+		return false, nil
+	}
+
+	outflow, err := service.datastore.GetPendingOutflow(ctx)
+	if err != nil {
+		return false, err
+	}
+	drains, _ := outflow.Drains.Float64()
+	hotWalletProjectedOutflowBat.With(prometheus.Labels{"kind": "drains"}).Set(drains)
+	claims, _ := outflow.Claims.Float64()
+	hotWalletProjectedOutflowBat.With(prometheus.Labels{"kind": "claims"}).Set(claims)
+
+	var checkErr error
+	for name, hotWallet := range service.hotWallets {
+		status := HotWalletStatus{Wallet: name, CheckedAt: time.Now().UTC(), Reserve: service.hotWalletReserve, Outflow: *outflow}
+
+		balance, err := hotWallet.GetBalance(ctx, true)
+		if err != nil {
+			countHotWalletCheckFailures.With(prometheus.Labels{"wallet": name}).Inc()
+			service.hotWalletMu.Lock()
+			previous := service.hotWalletStatus[name]
+			previous.Wallet = name
+			previous.Error = err.Error()
+			service.hotWalletStatus[name] = previous
+			service.hotWalletMu.Unlock()
+			checkErr = fmt.Errorf("failed to check balance of %s hot wallet: %w", name, err)
+			continue
+		}
+
+		currency := altcurrency.BAT
+		if info := hotWallet.GetWalletInfo(); info.AltCurrency != nil {
+			currency = *info.AltCurrency
+		}
+		status.Spendable = currency.FromProbi(balance.SpendableProbi)
+		status.evaluate()
+
+		service.hotWalletMu.Lock()
+		previous := service.hotWalletStatus[name]
+		service.hotWalletStatus[name] = status
+		service.hotWalletMu.Unlock()
+
+		labels := prometheus.Labels{"wallet": name}
+		spendable, _ := status.Spendable.Float64()
+		hotWalletBalanceBat.With(labels).Set(spendable)
+		hotWalletLowFunds.With(labels).Set(boolGauge(status.LowFunds))
+		hotWalletDrainsPaused.With(labels).Set(boolGauge(status.DrainsPaused))
+
+		if status.DrainsPaused && !previous.DrainsPaused {
+			log.Ctx(ctx).Error().Str("wallet", name).Msg("pausing drains: " + status.Reason)
+		} else if !status.DrainsPaused && previous.DrainsPaused {
+			log.Ctx(ctx).Info().Str("wallet", name).Msg("resuming drains")
+		}
+		if status.LowFunds && !status.DrainsPaused && !previous.LowFunds {
+			log.Ctx(ctx).Warn().Str("wallet", name).Msg("hot wallet funds are low: " + status.Reason)
+		}
+	}
+
+	return false, checkErr
+}
+
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// monitorHotWallet adds the wallet to those whose balance is checked by RunHotWalletMonitorJob
+func (service *Service) monitorHotWallet(name string, hotWallet w.Wallet) {
+	service.hotWalletMu.Lock()
+	defer service.hotWalletMu.Unlock()
+
+	if service.hotWallets == nil {
+		service.hotWallets = map[string]w.Wallet{}
+	}
+	if service.hotWalletStatus == nil {
+		service.hotWalletStatus = map[string]HotWalletStatus{}
+	}
+	service.hotWallets[name] = hotWallet
+}
@@ -0,0 +1,94 @@
+package promotion
+
+import (
+	"context"
+	"testing"
+
+	"github.com/brave-intl/bat-go/utils/altcurrency"
+	"github.com/brave-intl/bat-go/utils/httpsignature"
+	"github.com/brave-intl/bat-go/wallet"
+	"github.com/brave-intl/bat-go/wallet/provider/uphold"
+	"github.com/brave-intl/bat-go/wallet/provider/uphold/fake"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// outflowDatastore reports a fixed pending outflow
+type outflowDatastore struct {
+	Datastore
+	outflow PendingOutflow
+}
+
+func (d *outflowDatastore) GetPendingOutflow(ctx context.Context) (*PendingOutflow, error) {
+	outflow := d.outflow
+	return &outflow, nil
+}
+
+func TestHotWalletStatusEvaluate(t *testing.T) {
+	status := HotWalletStatus{
+		Spendable: decimal.NewFromFloat(100),
+		Reserve:   decimal.NewFromFloat(10),
+		Outflow:   PendingOutflow{Drains: decimal.NewFromFloat(50), Claims: decimal.NewFromFloat(30)},
+	}
+	status.evaluate()
+	assert.False(t, status.LowFunds)
+	assert.False(t, status.DrainsPaused)
+	assert.Empty(t, status.Reason)
+
+	status.Outflow.Claims = decimal.NewFromFloat(45)
+	status.evaluate()
+	assert.True(t, status.LowFunds, "Funds are low once undrained claims cannot be covered")
+	assert.False(t, status.DrainsPaused)
+	assert.NotEmpty(t, status.Reason)
+
+	status.Outflow.Drains = decimal.NewFromFloat(95)
+	status.evaluate()
+	assert.True(t, status.LowFunds)
+	assert.True(t, status.DrainsPaused, "Drains are paused once they would dip into the reserve")
+}
+
+func TestRunHotWalletMonitorJob(t *testing.T) {
+	srv := fake.New()
+	defer srv.Close()
+	defer uphold.UseAPI(srv.URL, srv.Client())()
+	ctx := context.Background()
+
+	publicKey, privateKey, err := httpsignature.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+
+	var info wallet.Info
+	info.Provider = "uphold"
+	info.ProviderID = srv.CreateCard("BAT", publicKey, decimal.NewFromFloat(100))
+	{
+		tmp := altcurrency.BAT
+		info.AltCurrency = &tmp
+	}
+	hotWallet, err := uphold.New(info, privateKey, publicKey)
+	require.NoError(t, err)
+
+	datastore := &outflowDatastore{outflow: PendingOutflow{Drains: decimal.NewFromFloat(60), Claims: decimal.Zero}}
+	service := &Service{datastore: datastore, hotWallet: hotWallet}
+	service.monitorHotWallet(hotWalletGrant, hotWallet)
+	assert.False(t, service.drainsPaused(), "Drains should not be paused before the first check")
+
+	attempted, err := service.RunHotWalletMonitorJob(ctx)
+	require.NoError(t, err)
+	assert.False(t, attempted)
+	assert.False(t, service.drainsPaused())
+
+	statuses := service.HotWalletStatuses()
+	require.Len(t, statuses, 1)
+	assert.Equal(t, hotWalletGrant, statuses[0].Wallet)
+	assert.True(t, decimal.NewFromFloat(100).Equal(statuses[0].Spendable))
+
+	srv.SetBalance(info.ProviderID, decimal.NewFromFloat(40))
+	_, err = service.RunHotWalletMonitorJob(ctx)
+	require.NoError(t, err)
+	assert.True(t, service.drainsPaused(), "Drains should be paused once the balance cannot cover them")
+
+	srv.SetBalance(info.ProviderID, decimal.NewFromFloat(80))
+	_, err = service.RunHotWalletMonitorJob(ctx)
+	require.NoError(t, err)
+	assert.False(t, service.drainsPaused(), "Drains should resume once the wallet is funded")
+}
@@ -2,27 +2,50 @@ package promotion
 
 import (
 	"context"
+	"strconv"
+	"time"
 
+	"github.com/brave-intl/bat-go/utils/issuerpolicy"
+	"github.com/prometheus/client_golang/prometheus"
 	uuid "github.com/satori/go.uuid"
 )
 
-const (
-	defaultMaxTokensPerIssuer = 4000000 // ~1M BAT
-)
+var issuerMetrics = issuerpolicy.NewMetrics("promotion")
+
+func init() {
+	prometheus.MustRegister(issuerMetrics.Collectors()...)
+}
 
 // Issuer includes information about a particular credential issuer
 type Issuer struct {
-	ID          uuid.UUID `db:"id"`
-	PromotionID uuid.UUID `db:"promotion_id"`
-	Cohort      string
-	PublicKey   string `db:"public_key"`
+	ID           uuid.UUID `db:"id"`
+	PromotionID  uuid.UUID `db:"promotion_id"`
+	Cohort       string
+	PublicKey    string    `db:"public_key"`
+	Version      int       `db:"version"`
+	CreatedAt    time.Time `db:"created_at"`
+	ExpiresAt    time.Time `db:"expires_at"`
+	MaxTokens    int       `db:"max_tokens"`
+	TokensSigned int       `db:"tokens_signed"`
 }
 
 // CreateIssuer creates a new challenge bypass credential issuer, saving it's information into the datastore
 func (service *Service) CreateIssuer(ctx context.Context, promotionID uuid.UUID, cohort string) (*Issuer, error) {
-	issuer := &Issuer{PromotionID: promotionID, Cohort: cohort, PublicKey: ""}
+	return service.createIssuerVersion(ctx, promotionID, cohort, 1)
+}
+
+// createIssuerVersion creates a particular version of the issuer for a promotion cohort
+func (service *Service) createIssuerVersion(ctx context.Context, promotionID uuid.UUID, cohort string, version int) (*Issuer, error) {
+	issuer := &Issuer{
+		PromotionID: promotionID,
+		Cohort:      cohort,
+		PublicKey:   "",
+		Version:     version,
+		ExpiresAt:   time.Now().Add(issuerpolicy.Validity),
+		MaxTokens:   issuerpolicy.DefaultMaxTokens,
+	}
 
-	err := service.cbClient.CreateIssuer(ctx, issuer.Name(), defaultMaxTokensPerIssuer)
+	err := service.cbClient.CreateIssuer(ctx, issuer.Name(), issuer.MaxTokens)
 	if err != nil {
 		return nil, err
 	}
@@ -39,19 +62,56 @@ func (service *Service) CreateIssuer(ctx context.Context, promotionID uuid.UUID,
 
 // Name returns the name of the issuer as known by the challenge bypass server
 func (issuer *Issuer) Name() string {
-	return issuer.PromotionID.String() + ":" + issuer.Cohort
+	name := issuer.PromotionID.String() + ":" + issuer.Cohort
+	if issuer.Version > 1 {
+		name += ":v" + strconv.Itoa(issuer.Version)
+	}
+	return name
 }
 
-// GetOrCreateIssuer gets a matching issuer if one exists and otherwise creates one
-func (service *Service) GetOrCreateIssuer(ctx context.Context, promotionID uuid.UUID, cohort string) (*Issuer, error) {
-	issuer, err := service.datastore.GetIssuer(promotionID, cohort)
-	if err != nil {
+// VersionNumber of the issuer
+func (issuer *Issuer) VersionNumber() int {
+	return issuer.Version
+}
+
+// Usage of the issuer version which the rotation policy is applied to
+func (issuer *Issuer) Usage() issuerpolicy.Usage {
+	return issuerpolicy.Usage{ExpiresAt: issuer.ExpiresAt, MaxTokens: issuer.MaxTokens, TokensSigned: issuer.TokensSigned}
+}
+
+// cohortIssuers are the versions of the issuer of a promotion cohort
+type cohortIssuers struct {
+	ctx         context.Context
+	service     *Service
+	promotionID uuid.UUID
+	cohort      string
+}
+
+// Latest version of the issuer of the promotion cohort
+func (c *cohortIssuers) Latest() (issuerpolicy.Version, error) {
+	issuer, err := c.service.datastore.GetIssuer(c.promotionID, c.cohort)
+	if err != nil || issuer == nil {
 		return nil, err
 	}
+	return issuer, nil
+}
 
-	if issuer == nil {
-		issuer, err = service.CreateIssuer(ctx, promotionID, cohort)
+// Create a version of the issuer of the promotion cohort
+func (c *cohortIssuers) Create(number int) (issuerpolicy.Version, error) {
+	return c.service.createIssuerVersion(c.ctx, c.promotionID, c.cohort, number)
+}
+
+// GetOrCreateIssuer gets the latest matching issuer if one exists and otherwise creates one,
+// a new version of the issuer is created when the latest is near capacity or expiry
+func (service *Service) GetOrCreateIssuer(ctx context.Context, promotionID uuid.UUID, cohort string) (*Issuer, error) {
+	issuer, err := issuerpolicy.GetOrCreate(&cohortIssuers{ctx: ctx, service: service, promotionID: promotionID, cohort: cohort}, issuerMetrics)
+	if err != nil {
+		return nil, err
 	}
+	return issuer.(*Issuer), nil
+}
 
-	return issuer, err
+// observeTokensSigned updates the issuer metrics after count credentials were signed
+func observeTokensSigned(issuer Issuer, count int) {
+	issuerMetrics.ObserveTokensSigned(issuer.Name(), issuer.Usage(), count)
 }
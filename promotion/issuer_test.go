@@ -0,0 +1,34 @@
+package promotion
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brave-intl/bat-go/utils/issuerpolicy"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIssuerName(t *testing.T) {
+	promotionID := uuid.NewV4()
+
+	issuer := Issuer{PromotionID: promotionID, Cohort: "control", Version: 1}
+	assert.Equal(t, promotionID.String()+":control", issuer.Name(), "first version keeps the original name")
+
+	issuer.Version = 2
+	assert.Equal(t, promotionID.String()+":control:v2", issuer.Name())
+}
+
+func TestIssuerRotationReason(t *testing.T) {
+	now := time.Now()
+
+	issuer := Issuer{ExpiresAt: now.Add(issuerpolicy.Validity), MaxTokens: 100}
+	assert.Equal(t, "", issuer.Usage().RotationReason(now))
+	assert.True(t, issuer.Usage().IsUsable(now))
+
+	issuer.TokensSigned = 90
+	assert.Equal(t, "capacity", issuer.Usage().RotationReason(now), "the usage should reflect the tokens signed by the issuer")
+
+	issuer = Issuer{ExpiresAt: now.Add(issuerpolicy.RotationLeadTime / 2), MaxTokens: 100}
+	assert.Equal(t, "expiry", issuer.Usage().RotationReason(now))
+}
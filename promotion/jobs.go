@@ -0,0 +1,18 @@
+package promotion
+
+import (
+	"context"
+
+	"github.com/brave-intl/bat-go/datastore/grantserver"
+	uuid "github.com/satori/go.uuid"
+)
+
+// GetDeadLetters returns the jobs of a queue which failed terminally or exhausted their attempts
+func (service *Service) GetDeadLetters(ctx context.Context, queue string) ([]grantserver.DeadLetter, error) {
+	return service.datastore.GetDeadLetters(ctx, queue)
+}
+
+// RequeueDeadLetter resets a dead-lettered job so it is run again, returning false if it was not dead-lettered
+func (service *Service) RequeueDeadLetter(ctx context.Context, queue string, id uuid.UUID) (bool, error) {
+	return service.datastore.RequeueDeadLetter(ctx, queue, id)
+}
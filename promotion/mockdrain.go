@@ -37,17 +37,46 @@ func (m *MockDrainWorker) EXPECT() *MockDrainWorkerMockRecorder {
 	return m.recorder
 }
 
-// RedeemAndTransferFunds mocks base method
-func (m *MockDrainWorker) RedeemAndTransferFunds(arg0 context.Context, arg1 []cbr.CredentialRedemption, arg2 go_uuid.UUID, arg3 decimal.Decimal) (*wallet.TransactionInfo, error) {
+// ConfirmTransfer mocks base method
+func (m *MockDrainWorker) ConfirmTransfer(arg0 context.Context, arg1 string) (*wallet.TransactionInfo, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "RedeemAndTransferFunds", arg0, arg1, arg2, arg3)
+	ret := m.ctrl.Call(m, "ConfirmTransfer", arg0, arg1)
 	ret0, _ := ret[0].(*wallet.TransactionInfo)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// RedeemAndTransferFunds indicates an expected call of RedeemAndTransferFunds
-func (mr *MockDrainWorkerMockRecorder) RedeemAndTransferFunds(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+// ConfirmTransfer indicates an expected call of ConfirmTransfer
+func (mr *MockDrainWorkerMockRecorder) ConfirmTransfer(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RedeemAndTransferFunds", reflect.TypeOf((*MockDrainWorker)(nil).RedeemAndTransferFunds), arg0, arg1, arg2, arg3)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmTransfer", reflect.TypeOf((*MockDrainWorker)(nil).ConfirmTransfer), arg0, arg1)
+}
+
+// RedeemCredentials mocks base method
+func (m *MockDrainWorker) RedeemCredentials(arg0 context.Context, arg1 []cbr.CredentialRedemption, arg2 go_uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RedeemCredentials", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RedeemCredentials indicates an expected call of RedeemCredentials
+func (mr *MockDrainWorkerMockRecorder) RedeemCredentials(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RedeemCredentials", reflect.TypeOf((*MockDrainWorker)(nil).RedeemCredentials), arg0, arg1, arg2)
+}
+
+// SubmitTransfer mocks base method
+func (m *MockDrainWorker) SubmitTransfer(arg0 context.Context, arg1 go_uuid.UUID, arg2 decimal.Decimal, arg3 go_uuid.UUID) (*wallet.TransactionInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubmitTransfer", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*wallet.TransactionInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SubmitTransfer indicates an expected call of SubmitTransfer
+func (mr *MockDrainWorkerMockRecorder) SubmitTransfer(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubmitTransfer", reflect.TypeOf((*MockDrainWorker)(nil).SubmitTransfer), arg0, arg1, arg2, arg3)
 }
@@ -51,7 +51,10 @@ type Promotion struct {
 	Platform            string                    `json:"platform" db:"platform"`
 	PublicKeys          jsonutils.JSONStringArray `json:"publicKeys" db:"public_keys"`
 	LegacyClaimed       bool                      `json:"legacyClaimed" db:"legacy_claimed"`
-	//ClaimableUntil      time.Time
+	ClaimableUntil      time.Time                 `json:"claimableUntil" db:"claimable_until"`
+	RedeemableUntil     time.Time                 `json:"redeemableUntil" db:"redeemable_until"`
+	Budget              *decimal.Decimal          `json:"-" db:"budget"`
+	ClaimedValue        decimal.Decimal           `json:"-" db:"claimed_value"`
 }
 
 // Filter promotions to all that satisfy the function passed
@@ -2,31 +2,27 @@ package promotion
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
 	"encoding/hex"
-	"encoding/json"
-	"encoding/pem"
 	"errors"
-	"io/ioutil"
-	"log"
 	"os"
-	"strings"
+	"sync"
 	"time"
 
+	"github.com/brave-intl/bat-go/datastore/grantserver"
 	"github.com/brave-intl/bat-go/utils/altcurrency"
+	"github.com/brave-intl/bat-go/utils/avro"
 	"github.com/brave-intl/bat-go/utils/clients/balance"
 	"github.com/brave-intl/bat-go/utils/clients/cbr"
 	"github.com/brave-intl/bat-go/utils/clients/reputation"
 	errorutils "github.com/brave-intl/bat-go/utils/errors"
 	"github.com/brave-intl/bat-go/utils/httpsignature"
+	kafkautils "github.com/brave-intl/bat-go/utils/kafka"
 	srv "github.com/brave-intl/bat-go/utils/service"
 	w "github.com/brave-intl/bat-go/wallet"
-	"github.com/brave-intl/bat-go/wallet/provider/uphold"
+	"github.com/brave-intl/bat-go/wallet/provider"
 	wallet "github.com/brave-intl/bat-go/wallet/service"
-	"github.com/linkedin/goavro"
 	"github.com/prometheus/client_golang/prometheus"
-	kafka "github.com/segmentio/kafka-go"
+	"github.com/shopspring/decimal"
 	"golang.org/x/crypto/ed25519"
 )
 
@@ -35,22 +31,6 @@ const localEnv = "local"
 var (
 	suggestionTopic = os.Getenv("ENV") + ".grant.suggestion"
 
-	// kafkaCertNotAfter checks when the kafka certificate becomes valid
-	kafkaCertNotBefore = prometheus.NewGauge(
-		prometheus.GaugeOpts{
-			Name: "kafka_cert_not_before",
-			Help: "Date when the kafka certificate becomes valid.",
-		},
-	)
-
-	// kafkaCertNotAfter checks when the kafka certificate expires
-	kafkaCertNotAfter = prometheus.NewGauge(
-		prometheus.GaugeOpts{
-			Name: "kafka_cert_not_after",
-			Help: "Date when the kafka certificate expires.",
-		},
-	)
-
 	// countContributionsTotal counts the number of contributions made broken down by funding and type
 	countContributionsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
@@ -86,6 +66,31 @@ var (
 		},
 		[]string{"platform", "type", "legacy"},
 	)
+
+	// drainTransactionsStuck counts the drain transfers which have not completed after stuckTransactionAge
+	drainTransactionsStuck = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "drain_transactions_stuck",
+			Help: "number of drain transfers which have not completed within the expected time",
+		},
+	)
+
+	// countDrainTransactionsReconciled counts the drain transaction status changes found by reconciliation, broken down by status
+	countDrainTransactionsReconciled = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "drain_transactions_reconciled_total",
+			Help: "count of drain transaction status changes found by reconciliation ( since last start ) broken down by status",
+		},
+		[]string{"status"},
+	)
+
+	// countDrainsBatched counts the drains aggregated into a batch transfer
+	countDrainsBatched = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "drains_batched_total",
+			Help: "count of drains aggregated into batch transfers ( since last start )",
+		},
+	)
 )
 
 // SetSuggestionTopic allows for a new topic to be suggested
@@ -99,8 +104,9 @@ func init() {
 		countContributionsBatTotal,
 		countGrantsClaimedTotal,
 		countGrantsClaimedBatTotal,
-		kafkaCertNotBefore,
-		kafkaCertNotAfter,
+		drainTransactionsStuck,
+		countDrainTransactionsReconciled,
+		countDrainsBatched,
 	)
 }
 
@@ -112,11 +118,15 @@ type Service struct {
 	cbClient         cbr.Client
 	reputationClient reputation.Client
 	balanceClient    balance.Client
-	codecs           map[string]*goavro.Codec
-	kafkaWriter      *kafka.Writer
-	kafkaDialer      *kafka.Dialer
-	hotWallet        *uphold.Wallet
+	codecs           map[string]*avro.Codec
+	publisher        kafkautils.EventPublisher
+	hotWallet        w.TransactionPreparer
 	drainChannel     chan *w.TransactionInfo
+	drainBatch       *DrainBatchPolicy
+	hotWallets       map[string]w.Wallet
+	hotWalletReserve decimal.Decimal
+	hotWalletMu      sync.RWMutex
+	hotWalletStatus  map[string]HotWalletStatus
 	jobs             []srv.Job
 }
 
@@ -125,116 +135,11 @@ func (s *Service) Jobs() []srv.Job {
 	return s.jobs
 }
 
-func readFileFromEnvLoc(env string, required bool) ([]byte, error) {
-	loc := os.Getenv(env)
-	if len(loc) == 0 {
-		if !required {
-			return []byte{}, nil
-		}
-		return []byte{}, errors.New(env + " must be passed")
-	}
-	buf, err := ioutil.ReadFile(loc)
-	if err != nil {
-		return []byte{}, err
-	}
-	return buf, nil
-}
-
-func tlsDialer() (*kafka.Dialer, error) {
-	keyPasswordEnv := "KAFKA_SSL_KEY_PASSWORD"
-	keyPassword := os.Getenv(keyPasswordEnv)
-
-	caPEM, err := readFileFromEnvLoc("KAFKA_SSL_CA_LOCATION", false)
-	if err != nil {
-		return nil, err
-	}
-
-	certEnv := "KAFKA_SSL_CERTIFICATE"
-	certPEM := []byte(os.Getenv(certEnv))
-	if len(certPEM) == 0 {
-		certPEM, err = readFileFromEnvLoc("KAFKA_SSL_CERTIFICATE_LOCATION", true)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	keyEnv := "KAFKA_SSL_KEY"
-	encryptedKeyPEM := []byte(os.Getenv(keyEnv))
-
-	// Check to see if KAFKA_SSL_CERTIFICATE includes both certificate and key
-	if certPEM[0] == '{' {
-		type Certificate struct {
-			Certificate string `json:"certificate"`
-			Key         string `json:"key"`
-		}
-		var cert Certificate
-		err := json.Unmarshal(certPEM, &cert)
-		if err != nil {
-			return nil, err
-		}
-		certPEM = []byte(cert.Certificate)
-		encryptedKeyPEM = []byte(cert.Key)
-	}
-
-	if len(encryptedKeyPEM) == 0 {
-		encryptedKeyPEM, err = readFileFromEnvLoc("KAFKA_SSL_KEY_LOCATION", true)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	block, rest := pem.Decode(encryptedKeyPEM)
-	if len(rest) > 0 {
-		return nil, errors.New("Extra data in KAFKA_SSL_KEY")
-	}
-
-	keyPEM := pem.EncodeToMemory(block)
-	if len(keyPassword) != 0 {
-		keyDER, err := x509.DecryptPEMBlock(block, []byte(keyPassword))
-		if err != nil {
-			return nil, errorutils.Wrap(err, "decrypt KAFKA_SSL_KEY failed")
-		}
-
-		keyPEM = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: keyDER})
-	}
-
-	certificate, err := tls.X509KeyPair([]byte(certPEM), keyPEM)
-	if err != nil {
-		return nil, errorutils.Wrap(err, "Could not parse x509 keypair")
-	}
-
-	// Define TLS configuration
-	config := &tls.Config{
-		Certificates: []tls.Certificate{certificate},
-	}
-
-	// Instrument kafka cert expiration information
-	x509Cert, err := x509.ParseCertificate(certificate.Certificate[0])
-	if err != nil {
-		return nil, errorutils.Wrap(err, "Could not parse certificate")
-	}
-	kafkaCertNotBefore.Set(float64(x509Cert.NotBefore.Unix()))
-	kafkaCertNotAfter.Set(float64(x509Cert.NotAfter.Unix()))
-
-	if len(caPEM) > 0 {
-		caCertPool := x509.NewCertPool()
-		if ok := caCertPool.AppendCertsFromPEM([]byte(caPEM)); !ok {
-			return nil, errors.New("Could not add custom CA from KAFKA_SSL_CA_LOCATION")
-		}
-		config.RootCAs = caCertPool
-	}
-
-	return &kafka.Dialer{
-		Timeout:   10 * time.Second,
-		DualStack: true,
-		TLS:       config}, nil
-}
-
-// InitCodecs used for Avro encoding / decoding
-func (s *Service) InitCodecs() error {
-	s.codecs = make(map[string]*goavro.Codec)
+// InitCodecs used for Avro encoding / decoding, registering the schema versions with the registry
+func (s *Service) InitCodecs(registry avro.Registry) error {
+	s.codecs = make(map[string]*avro.Codec)
 
-	suggestionEventCodec, err := goavro.NewCodec(string(suggestionEventSchema))
+	suggestionEventCodec, err := avro.NewCodec(context.Background(), registry, avro.Subject(suggestionTopic), suggestionEventSchemas...)
 	s.codecs["suggestion"] = suggestionEventCodec
 	if err != nil {
 		return err
@@ -242,26 +147,19 @@ func (s *Service) InitCodecs() error {
 	return nil
 }
 
-// InitKafka by creating a kafka writer and creating local copies of codecs
+// InitKafka by creating the suggestion event publisher and creating local copies of codecs
 func (s *Service) InitKafka() error {
-	dialer, err := tlsDialer()
+	publisher, err := kafkautils.NewPublisherFromEnv(suggestionTopic)
 	if err != nil {
 		return err
 	}
-	s.kafkaDialer = dialer
-
-	kafkaBrokers := os.Getenv("KAFKA_BROKERS")
-	kafkaWriter := kafka.NewWriter(kafka.WriterConfig{
-		// by default we are waitng for acks from all nodes
-		Brokers:  strings.Split(kafkaBrokers, ","),
-		Topic:    suggestionTopic,
-		Balancer: &kafka.LeastBytes{},
-		Dialer:   dialer,
-		Logger:   kafka.LoggerFunc(log.Printf), // FIXME
-	})
-
-	s.kafkaWriter = kafkaWriter
-	err = s.InitCodecs()
+	s.publisher = publisher
+
+	registry, err := avro.NewRegistryFromEnvironment()
+	if err != nil {
+		return err
+	}
+	err = s.InitCodecs(registry)
 	if err != nil {
 		return err
 	}
@@ -297,10 +195,15 @@ func (s *Service) InitHotWallet() error {
 			return errorutils.Wrap(err, "grantWalletPrivateKeyHex is invalid")
 		}
 
-		s.hotWallet, err = uphold.New(info, privKey, pubKey)
+		if !provider.Supports(info.Provider, provider.PrepareSubmitConfirm) {
+			return errors.New("hot wallet provider must support prepared transactions")
+		}
+		hotWallet, err := provider.NewWallet(info, privKey, pubKey)
 		if err != nil {
 			return err
 		}
+		s.hotWallet = hotWallet.(w.TransactionPreparer)
+		s.monitorHotWallet(hotWalletGrant, s.hotWallet)
 	} else if os.Getenv("ENV") != localEnv {
 		return errors.New("GRANT_WALLET_CARD_ID must be set in production")
 	}
@@ -309,7 +212,7 @@ func (s *Service) InitHotWallet() error {
 
 // InitService creates a service using the passed datastore and clients configured from the environment
 func InitService(datastore Datastore, roDatastore ReadOnlyDatastore) (*Service, error) {
-	cbClient, err := cbr.New()
+	cbClient, err := cbr.NewFromEnvironment()
 	if err != nil {
 		return nil, err
 	}
@@ -332,6 +235,16 @@ func InitService(datastore Datastore, roDatastore ReadOnlyDatastore) (*Service,
 		return nil, err
 	}
 
+	drainBatch, err := drainBatchPolicyFromEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	hotWalletReserve, err := hotWalletReserveFromEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
 	service := &Service{
 		datastore:        datastore,
 		roDatastore:      roDatastore,
@@ -339,24 +252,76 @@ func InitService(datastore Datastore, roDatastore ReadOnlyDatastore) (*Service,
 		reputationClient: reputationClient,
 		balanceClient:    balanceClient,
 		wallet:           *walletService,
+		drainBatch:       drainBatch,
+		hotWalletReserve: hotWalletReserve,
 	}
 
 	// setup runnable jobs
 	service.jobs = []srv.Job{
 		{
+			Name:    "claim_creds",
 			Func:    service.RunNextClaimJob,
 			Cadence: 5 * time.Second,
 			Workers: 1,
+			Channel: "claim_creds",
 		},
 		{
+			Name:    "suggestion_drain",
 			Func:    service.RunNextSuggestionJob,
 			Cadence: 5 * time.Second,
 			Workers: 1,
+			Channel: "suggestion_drain",
 		},
 		{
+			Name:    "suggestion_outbox",
+			Func:    service.RunNextSuggestionOutboxJob,
+			Cadence: 5 * time.Second,
+			Workers: 1,
+			Channel: grantserver.OutboxChannel,
+		},
+		{
+			Name:    "suggestion_outbox_retention",
+			Func:    service.RunNextSuggestionOutboxRetentionJob,
+			Cadence: time.Hour,
+			Workers: 1,
+		},
+		{
+			Name:    "claim_drain",
 			Func:    service.RunNextDrainJob,
 			Cadence: 5 * time.Second,
 			Workers: 1,
+			Channel: "claim_drain",
+		},
+		{
+			Name:    "drain_batch",
+			Func:    service.RunNextDrainBatchJob,
+			Cadence: time.Minute,
+			Workers: 1,
+			Channel: "drain_batch",
+		},
+		{
+			Name:    "drain_reconcile",
+			Func:    service.RunNextDrainReconcileJob,
+			Cadence: 5 * time.Second,
+			Workers: 1,
+		},
+		{
+			Name:    "promotion_expiry",
+			Func:    service.RunNextPromotionExpiryJob,
+			Cadence: time.Minute,
+			Workers: 1,
+		},
+		{
+			Name:    "hot_wallet_monitor",
+			Func:    service.RunHotWalletMonitorJob,
+			Cadence: hotWalletMonitorInterval,
+			Workers: 1,
+		},
+		{
+			Name:    "promotion_budget_gauges",
+			Func:    service.RunNextBudgetGaugeJob,
+			Cadence: time.Minute,
+			Workers: 1,
 		},
 	}
 
@@ -389,7 +354,54 @@ func (s *Service) RunNextSuggestionJob(ctx context.Context) (bool, error) {
 	return s.datastore.RunNextSuggestionJob(ctx, s)
 }
 
-// RunNextDrainJob takes the next drain job and completes it
+// RunNextSuggestionOutboxJob publishes the next batch of suggestion events queued in the outbox
+func (s *Service) RunNextSuggestionOutboxJob(ctx context.Context) (bool, error) {
+	return s.datastore.RunNextOutboxJob(ctx, suggestionTopic, s.publisher)
+}
+
+// RunNextSuggestionOutboxRetentionJob deletes the next batch of suggestion events published longer ago than the retention
+func (s *Service) RunNextSuggestionOutboxRetentionJob(ctx context.Context) (bool, error) {
+	return s.datastore.DeletePublishedOutboxEvents(ctx, suggestionTopic, time.Now().Add(-grantserver.OutboxRetention))
+}
+
+// RunNextDrainJob takes the next drain job and completes it, when batching only the credentials are
+// redeemed and the transfer is left to RunNextDrainBatchJob. Transfers which were submitted before batching
+// was enabled are still confirmed individually. While drains are paused because the hot wallet cannot
+// cover them credentials are still redeemed and the transfers are made once drains resume.
 func (s *Service) RunNextDrainJob(ctx context.Context) (bool, error) {
+	if s.drainsPaused() {
+		return s.datastore.RunNextDrainRedemptionJob(ctx, s)
+	}
+	if s.drainBatch != nil {
+		attempted, err := s.datastore.RunNextDrainRedemptionJob(ctx, s)
+		if attempted || err != nil {
+			return attempted, err
+		}
+		return s.datastore.RunNextDrainConfirmJob(ctx, s)
+	}
 	return s.datastore.RunNextDrainJob(ctx, s)
 }
+
+// RunNextDrainBatchJob transfers the next batch of drains or aggregates the drains due a payout into a batch.
+// Batches which were opened before batching was disabled are still transferred.
+func (s *Service) RunNextDrainBatchJob(ctx context.Context) (bool, error) {
+	if s.drainsPaused() {
+		return false, nil
+	}
+	return s.datastore.RunNextDrainBatchJob(ctx, s, s.drainBatch)
+}
+
+// RunNextDrainReconcileJob updates the stuck drain gauge and checks the next unfinished drain or batch transfer
+func (s *Service) RunNextDrainReconcileJob(ctx context.Context) (bool, error) {
+	stuck, err := s.datastore.CountStuckDrains(stuckTransactionAge, s.drainBatch != nil)
+	if err != nil {
+		return false, err
+	}
+	drainTransactionsStuck.Set(float64(stuck))
+
+	attempted, err := s.datastore.RunNextDrainReconcileJob(ctx, s)
+	if attempted || err != nil {
+		return attempted, err
+	}
+	return s.datastore.RunNextDrainBatchReconcileJob(ctx, s)
+}
@@ -4,24 +4,21 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/asaskevich/govalidator"
+	"github.com/brave-intl/bat-go/datastore/grantserver"
 	"github.com/brave-intl/bat-go/utils/clients/cbr"
-	contextutil "github.com/brave-intl/bat-go/utils/context"
 	errorutils "github.com/brave-intl/bat-go/utils/errors"
-	"github.com/getsentry/sentry-go"
+	"github.com/lib/pq"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
 	uuid "github.com/satori/go.uuid"
-	kafka "github.com/segmentio/kafka-go"
 	"github.com/shopspring/decimal"
 )
 
-// FIXME temporary until event producer is hooked up
-var enableSuggestionJob = true
-
 // CredentialBinding includes info needed to redeem a single credential
 type CredentialBinding struct {
 	PublicKey     string `json:"publicKey" valid:"base64"`
@@ -82,7 +79,8 @@ type SuggestionEvent struct {
 	Funding     []FundingSource `json:"funding"`
 }
 
-// TryUpgradeSuggestionEvent from JSON format to Avro, filling in any potentially missing fields
+// TryUpgradeSuggestionEvent from JSON format to Avro, filling in any potentially missing fields. Avro events
+// are returned as is, they are resolved to the latest schema version when decoded.
 func (service *Service) TryUpgradeSuggestionEvent(suggestion []byte) ([]byte, error) {
 	var event SuggestionEvent
 
@@ -102,12 +100,12 @@ func (service *Service) TryUpgradeSuggestionEvent(suggestion []byte) ([]byte, er
 			return []byte{}, err
 		}
 
-		native, _, err := service.codecs["suggestion"].NativeFromTextual(eventJSON)
+		native, err := service.codecs["suggestion"].NativeFromTextual(eventJSON)
 		if err != nil {
 			return []byte{}, err
 		}
 
-		binary, err := service.codecs["suggestion"].BinaryFromNative(nil, native)
+		binary, err := service.codecs["suggestion"].Encode(native)
 		if err != nil {
 			return []byte{}, err
 		}
@@ -116,9 +114,11 @@ func (service *Service) TryUpgradeSuggestionEvent(suggestion []byte) ([]byte, er
 	return suggestion, nil
 }
 
-// SuggestionWorker attempts to work on a suggestion job by redeeming the credentials and emitting the event
+// SuggestionWorker attempts to work on a suggestion job by redeeming the credentials and creating the event,
+// the event is queued for publication in the transaction which completes the job. On retry the credentials
+// may already have been redeemed by the previous attempt, in which case a duplicate redemption is not an error.
 type SuggestionWorker interface {
-	RedeemAndCreateSuggestionEvent(ctx context.Context, credentials []cbr.CredentialRedemption, suggestionText string, suggestion []byte) error
+	RedeemAndCreateSuggestionEvent(ctx context.Context, credentials []cbr.CredentialRedemption, suggestionText string, suggestion []byte, retry bool) (*grantserver.OutboxEvent, error)
 }
 
 // GetCredentialRedemptions as well as total and funding sources from a list of credential bindings
@@ -130,6 +130,7 @@ func (service *Service) GetCredentialRedemptions(ctx context.Context, credential
 	err = nil
 
 	issuers := make(map[string]*Issuer)
+	cohorts := make(map[uuid.UUID][]Cohort)
 
 	for i := 0; i < len(credentials); i++ {
 		var ok bool
@@ -144,6 +145,11 @@ func (service *Service) GetCredentialRedemptions(ctx context.Context, credential
 				err = errorutils.Wrap(err, "error finding issuer")
 				return
 			}
+			if issuer == nil {
+				err = fmt.Errorf("issuer not found or expired for public key %s", publicKey)
+				return
+			}
+			issuers[publicKey] = issuer
 		}
 
 		requestCredentials[i].Issuer = issuer.Name()
@@ -156,9 +162,26 @@ func (service *Service) GetCredentialRedemptions(ctx context.Context, credential
 				err = errorutils.Wrap(err, "error finding promotion")
 				return
 			}
+			if promotion == nil {
+				err = fmt.Errorf("promotion %s not found", issuer.PromotionID)
+				return
+			}
+			if !promotion.Redeemable(time.Now()) {
+				err = fmt.Errorf("%w: promotion %s", errPromotionNotRedeemable, promotion.ID)
+				return
+			}
 			promotions[publicKey] = promotion
 		}
-		value := promotion.CredentialValue()
+		promotionCohorts, ok := cohorts[promotion.ID]
+		if !ok {
+			promotionCohorts, err = service.datastore.GetCohorts(promotion.ID)
+			if err != nil {
+				err = errorutils.Wrap(err, "error finding promotion cohorts")
+				return
+			}
+			cohorts[promotion.ID] = promotionCohorts
+		}
+		value := promotion.CohortCredentialValue(findCohort(promotionCohorts, issuer.Cohort))
 		total = total.Add(value)
 
 		fundingSource, ok := fundingSources[publicKey]
@@ -166,7 +189,7 @@ func (service *Service) GetCredentialRedemptions(ctx context.Context, credential
 		fundingSource.Credentials = append(fundingSource.Credentials, requestCredentials[i])
 		if !ok {
 			fundingSource.Type = promotion.Type
-			fundingSource.Cohort = "control"
+			fundingSource.Cohort = issuer.Cohort
 			fundingSource.PromotionID = promotion.ID
 		}
 		fundingSources[publicKey] = fundingSource
@@ -198,9 +221,11 @@ func (service *Service) Suggest(ctx context.Context, credentials []CredentialBin
 	}
 
 	fundings := []map[string]interface{}{}
+	funding := []FundingSource{}
 	metrics := map[string]decimal.Decimal{}
 	fundingTypes := []string{}
 	for _, v := range fundingSources {
+		funding = append(funding, v)
 		val, existed := metrics[v.Type]
 		if !existed {
 			fundingTypes = append(fundingTypes, v.Type)
@@ -230,12 +255,12 @@ func (service *Service) Suggest(ctx context.Context, credentials []CredentialBin
 		"funding":     fundings,
 	}
 
-	eventBinary, err := service.codecs["suggestion"].BinaryFromNative(nil, eventMap)
+	eventBinary, err := service.codecs["suggestion"].Encode(eventMap)
 	if err != nil {
 		return err
 	}
 
-	err = service.datastore.InsertSuggestion(requestCredentials, suggestionText, eventBinary)
+	err = service.datastore.InsertSuggestion(requestCredentials, suggestionText, eventBinary, funding)
 	if err != nil {
 		return err
 	}
@@ -251,22 +276,6 @@ func (service *Service) Suggest(ctx context.Context, credentials []CredentialBin
 		countContributionsBatTotal.With(labels).Add(value)
 	}
 
-	if enableSuggestionJob {
-		asyncCtx, asyncCancel := context.WithTimeout(context.Background(), time.Minute)
-		ctx = contextutil.Wrap(ctx, asyncCtx)
-		go func() {
-			defer asyncCancel()
-			_, err := service.datastore.RunNextSuggestionJob(ctx, service)
-			if err != nil {
-				log.Ctx(ctx).
-					Error().
-					Err(err).
-					Msg("error processing suggestion job")
-				sentry.CaptureException(errorutils.Wrap(err, "error processing suggestion job"))
-			}
-		}()
-	}
-
 	return nil
 }
 
@@ -296,53 +305,71 @@ func (service *Service) UpdateOrderStatus(orderID uuid.UUID) error {
 }
 
 // RedeemAndCreateSuggestionEvent after validating that all the credential bindings
-func (service *Service) RedeemAndCreateSuggestionEvent(ctx context.Context, credentials []cbr.CredentialRedemption, suggestionText string, suggestion []byte) error {
+func (service *Service) RedeemAndCreateSuggestionEvent(ctx context.Context, credentials []cbr.CredentialRedemption, suggestionText string, suggestion []byte, retry bool) (*grantserver.OutboxEvent, error) {
 	suggestion, err := service.TryUpgradeSuggestionEvent(suggestion)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	err = service.cbClient.RedeemCredentials(ctx, credentials, suggestionText)
+	codec := service.codecs["suggestion"]
+	newInterface, err := codec.Decode(ctx, suggestion)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	eventMap := newInterface.(map[string]interface{})
 
-	// write the message
-	err = service.kafkaWriter.WriteMessages(ctx,
-		kafka.Message{
-			Value: suggestion,
-		},
-	)
+	// the event is published with the latest schema version
+	payload, err := codec.Encode(eventMap)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Delete this section once the issue is completed
-	// https://github.com/brave-intl/bat-go/issues/263
+	eventID, err := uuid.FromString(eventMap["id"].(string))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing suggestion event id: %w", err)
+	}
 
-	newInterface, _, err := service.codecs["suggestion"].NativeFromBinary(suggestion)
-	eventMap := newInterface.(map[string]interface{})
+	err = service.cbClient.RedeemCredentials(ctx, credentials, suggestionText)
 	if err != nil {
-		return err
+		if !retry || !cbr.IsDuplicateRedemption(err) {
+			return nil, err
+		}
+		// the previous attempt redeemed the credentials but failed before queueing the event, emit it now
+		log.Ctx(ctx).Warn().Err(err).Str("event_id", eventID.String()).Msg("suggestion credentials already redeemed, queueing event")
 	}
 
+	// Delete this section once the issue is completed
+	// https://github.com/brave-intl/bat-go/issues/263
+
 	if eventMap["orderId"] != nil && eventMap["orderId"] != "" {
 		orderID := uuid.Must(uuid.FromString(eventMap["orderId"].(string)))
 		amount, err := decimal.NewFromString(eventMap["totalAmount"].(string))
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		_, err = service.datastore.CreateTransaction(orderID, eventMap["id"].(string), "completed", "BAT", "virtual-grant", amount)
-		if err != nil {
-			return fmt.Errorf("Error recording order transaction : %w", err)
+		if err != nil && !(retry && isUniqueViolation(err)) {
+			return nil, fmt.Errorf("Error recording order transaction : %w", err)
 		}
 
 		err = service.UpdateOrderStatus(orderID)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	return nil
+	// events for a channel are published in order
+	return &grantserver.OutboxEvent{
+		ID:      eventID,
+		Topic:   suggestionTopic,
+		Key:     eventMap["channel"].(string),
+		Payload: payload,
+	}, nil
+}
+
+// isUniqueViolation returns true if the insert failed because the row already exists
+func isUniqueViolation(err error) bool {
+	var pgErr *pq.Error
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
 }
@@ -1,10 +1,21 @@
 package promotion
 
 import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
 	"testing"
 	"time"
 
+	"github.com/brave-intl/bat-go/utils/avro"
+	"github.com/brave-intl/bat-go/utils/clients"
+	"github.com/brave-intl/bat-go/utils/clients/cbr"
+	mockcb "github.com/brave-intl/bat-go/utils/clients/cbr/mock"
+	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestUnmarshalText(t *testing.T) {
@@ -19,16 +30,29 @@ func TestUnmarshalText(t *testing.T) {
 }
 
 func TestTryUpgradeSuggestionEvent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schema-registry")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	registry, err := avro.NewFileRegistry(dir)
+	require.NoError(t, err)
+
 	var service Service
-	err := service.InitCodecs()
+	err = service.InitCodecs(registry)
 	assert.NoError(t, err, "Failed to initialize codecs")
+	codec := service.codecs["suggestion"]
 
 	suggestion := `{"id":"d6e6f7f2-8975-4105-8fef-2ad89e299add","type":"oneoff-tip","channel":"3zsistemi.si","totalAmount":"10","funding":[{"type":"ugp","amount":"10","cohort":"control","promotion":"1d54793b-e8e7-4e96-890f-a1836cab9533"}]}`
 
 	upgraded, err := service.TryUpgradeSuggestionEvent([]byte(suggestion))
 	assert.NoError(t, err, "Failed to upgrade suggestion event")
 
-	native, _, err := service.codecs["suggestion"].NativeFromBinary(upgraded)
+	id, _, err := avro.DecodeWireFormat(upgraded)
+	assert.NoError(t, err)
+	assert.Equal(t, codec.ID(), id, "Upgraded event should carry the schema id")
+
+	native, err := codec.Decode(context.Background(), upgraded)
 	assert.NoError(t, err)
 
 	createdAt, err := time.Parse(time.RFC3339, native.(map[string]interface{})["createdAt"].(string))
@@ -42,4 +66,38 @@ func TestTryUpgradeSuggestionEvent(t *testing.T) {
 	assert.NoError(t, err, "Failed to upgrade suggestion event")
 
 	assert.Equal(t, suggestionBytes, upgraded)
+
+	native, err = codec.Decode(context.Background(), upgraded)
+	assert.NoError(t, err, "Events written before schemas were registered should decode")
+	assert.Equal(t, "3zsistemi.si", native.(map[string]interface{})["channel"])
+	assert.Equal(t, "", native.(map[string]interface{})["orderId"], "Events without an order should be upgraded")
+}
+
+func TestRedeemAndCreateSuggestionEventRetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schema-registry")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	registry, err := avro.NewFileRegistry(dir)
+	require.NoError(t, err)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockCB := mockcb.NewMockClient(mockCtrl)
+
+	service := Service{cbClient: mockCB}
+	require.NoError(t, service.InitCodecs(registry))
+
+	suggestion := []byte(`{"id":"d6e6f7f2-8975-4105-8fef-2ad89e299add","type":"oneoff-tip","channel":"brave.com","totalAmount":"10","funding":[{"type":"ugp","amount":"10","cohort":"control","promotion":"1d54793b-e8e7-4e96-890f-a1836cab9533"}]}`)
+	duplicate := clients.NewHTTPError(errors.New("duplicate redemption"), "response", http.StatusConflict, nil)
+	mockCB.EXPECT().RedeemCredentials(gomock.Any(), gomock.Any(), gomock.Any()).Return(duplicate).Times(2)
+
+	_, err = service.RedeemAndCreateSuggestionEvent(context.Background(), []cbr.CredentialRedemption{{}}, "", suggestion, false)
+	assert.Error(t, err, "A duplicate redemption should fail the first attempt")
+
+	event, err := service.RedeemAndCreateSuggestionEvent(context.Background(), []cbr.CredentialRedemption{{}}, "", suggestion, true)
+	require.NoError(t, err, "A duplicate redemption on retry was made by the previous attempt")
+	assert.Equal(t, "d6e6f7f2-8975-4105-8fef-2ad89e299add", event.ID.String())
+	assert.Equal(t, "brave.com", event.Key)
 }
@@ -1,6 +1,7 @@
 package settlement
 
 import (
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"os"
@@ -10,6 +11,8 @@ import (
 	"github.com/brave-intl/bat-go/utils/httpsignature"
 	"github.com/brave-intl/bat-go/wallet"
 	"github.com/brave-intl/bat-go/wallet/provider/uphold"
+	"github.com/brave-intl/bat-go/wallet/provider/uphold/fake"
+	"github.com/shopspring/decimal"
 	"golang.org/x/crypto/ed25519"
 )
 
@@ -86,7 +89,7 @@ func TestTransactions(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	err = SubmitPreparedTransactions(donorWallet, settlements)
+	err = SubmitPreparedTransactions(context.Background(), donorWallet, settlements)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -97,7 +100,7 @@ func TestTransactions(t *testing.T) {
 	}
 
 	// Multiple submit should have no effect
-	err = SubmitPreparedTransactions(donorWallet, settlements)
+	err = SubmitPreparedTransactions(context.Background(), donorWallet, settlements)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -108,14 +111,14 @@ func TestTransactions(t *testing.T) {
 		}
 	}
 
-	err = ConfirmPreparedTransactions(donorWallet, settlements)
+	err = ConfirmPreparedTransactions(context.Background(), donorWallet, settlements)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	for i := 0; i < len(settlements); i++ {
 		var txInfo *wallet.TransactionInfo
-		txInfo, err = donorWallet.GetTransaction(settlements[i].ProviderID)
+		txInfo, err = donorWallet.GetTransaction(context.Background(), settlements[i].ProviderID)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -126,12 +129,88 @@ func TestTransactions(t *testing.T) {
 	}
 
 	// Multiple confirm should not error
-	err = ConfirmPreparedTransactions(donorWallet, settlements)
+	err = ConfirmPreparedTransactions(context.Background(), donorWallet, settlements)
 	if err != nil {
 		t.Fatal(err)
 	}
 }
 
+func TestTransactionsFake(t *testing.T) {
+	srv := fake.New()
+	defer srv.Close()
+	defer uphold.UseAPI(srv.URL, srv.Client())()
+
+	donorPublicKey, donorPrivateKey, err := httpsignature.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var donorInfo wallet.Info
+	donorInfo.Provider = "uphold"
+	donorInfo.ProviderID = srv.CreateCard("BAT", donorPublicKey, decimal.NewFromFloat(100))
+	{
+		tmp := altcurrency.BAT
+		donorInfo.AltCurrency = &tmp
+	}
+	donorWallet := &uphold.Wallet{Info: donorInfo, PrivKey: donorPrivateKey, PubKey: donorPublicKey}
+	publisherCard := srv.CreateCard("BAT", nil, decimal.Zero)
+
+	settlementJSON := []byte(`
+	[
+    {
+        "address": "` + publisherCard + `",
+        "altcurrency": "BAT",
+        "probi": "25444211185665096101",
+        "publisher": "example.com",
+        "transactionId": "0f7377cc-73ef-4e94-b69a-7086a4f3b2a8",
+        "type": "referral"
+    }
+	]
+	`)
+
+	var settlements []Transaction
+	err = json.Unmarshal(settlementJSON, &settlements)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = PrepareTransactions(donorWallet, settlements)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = SubmitPreparedTransactions(context.Background(), donorWallet, settlements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := settlements[0].ProviderID
+
+	// Multiple submit should have no effect
+	err = SubmitPreparedTransactions(context.Background(), donorWallet, settlements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != settlements[0].ProviderID {
+		t.Fatal("Hash for settlement failed")
+	}
+
+	err = ConfirmPreparedTransactions(context.Background(), donorWallet, settlements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !settlements[0].IsComplete() {
+		t.Fatal("Settlement should be complete after confirm")
+	}
+
+	// Multiple confirm should not move funds again
+	err = ConfirmPreparedTransactions(context.Background(), donorWallet, settlements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !srv.Balance(publisherCard).Equals(altcurrency.BAT.FromProbi(settlements[0].Probi)) {
+		t.Fatal("Publisher card should have received the settlement exactly once")
+	}
+}
+
 func TestDeterministicSigning(t *testing.T) {
 	usdCard := "03aeafb8-555d-4840-90d1-ff0f99426475"
 
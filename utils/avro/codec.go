@@ -0,0 +1,189 @@
+package avro
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/linkedin/goavro"
+)
+
+const (
+	// magicByte starts data in the schema registry wire format
+	magicByte = 0
+	// headerSize is the size of the magic byte and schema id which precede the avro binary
+	headerSize = 5
+)
+
+// ErrNotWireFormat is returned when decoding data which does not start with a schema id
+var ErrNotWireFormat = errors.New("data is not in the schema registry wire format")
+
+// EncodeWireFormat prefixes the avro binary with the id of the schema it was written with
+func EncodeWireFormat(id int, avroBinary []byte) []byte {
+	data := make([]byte, headerSize, headerSize+len(avroBinary))
+	data[0] = magicByte
+	binary.BigEndian.PutUint32(data[1:headerSize], uint32(id))
+	return append(data, avroBinary...)
+}
+
+// DecodeWireFormat returns the id of the schema the data was written with and its avro binary
+func DecodeWireFormat(data []byte) (int, []byte, error) {
+	if len(data) < headerSize || data[0] != magicByte {
+		return 0, nil, ErrNotWireFormat
+	}
+	return int(binary.BigEndian.Uint32(data[1:headerSize])), data[headerSize:], nil
+}
+
+// version of a schema with its codec
+type version struct {
+	schema *schema
+	codec  *goavro.Codec
+}
+
+func newVersion(text string) (*version, error) {
+	s, err := parseSchema(text)
+	if err != nil {
+		return nil, err
+	}
+	codec, err := goavro.NewCodec(text)
+	if err != nil {
+		return nil, err
+	}
+	return &version{schema: s, codec: codec}, nil
+}
+
+// Codec writes data with the latest version of a schema and reads data written with any registered version,
+// resolving it to the latest so that events can evolve without breaking their consumers
+type Codec struct {
+	registry Registry
+	id       int
+	latest   *version
+	// versions passed to the codec, newest first, used for data written before schemas were registered
+	versions []*version
+
+	mu      sync.RWMutex
+	writers map[int]*version
+}
+
+// NewCodec registers each version of the schema under the subject, oldest first, and returns a codec which
+// writes with the last. Existing versions should never be changed, evolve the schema by adding a version.
+func NewCodec(ctx context.Context, registry Registry, subject string, versions ...string) (*Codec, error) {
+	if len(versions) == 0 {
+		return nil, errors.New("at least one schema version is required")
+	}
+
+	c := &Codec{registry: registry, writers: map[int]*version{}}
+	for i, text := range versions {
+		v, err := newVersion(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %d of %s: %w", i+1, subject, err)
+		}
+		id, err := registry.RegisterSchema(ctx, subject, text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to register version %d of %s: %w", i+1, subject, err)
+		}
+		c.writers[id] = v
+		c.id = id
+		c.latest = v
+		c.versions = append([]*version{v}, c.versions...)
+	}
+	return c, nil
+}
+
+// ID of the schema data is written with
+func (c *Codec) ID() int {
+	return c.id
+}
+
+// Encode the native data in the wire format
+func (c *Codec) Encode(native interface{}) ([]byte, error) {
+	avroBinary, err := c.latest.codec.BinaryFromNative(nil, native)
+	if err != nil {
+		return nil, err
+	}
+	return EncodeWireFormat(c.id, avroBinary), nil
+}
+
+// Decode data written with any version of the schema, returning native data of the latest version.
+// Avro binary without a schema id was written before schemas were registered, it is decoded with the
+// newest version of those passed to the codec which reads it exactly.
+func (c *Codec) Decode(ctx context.Context, data []byte) (interface{}, error) {
+	id, avroBinary, err := DecodeWireFormat(data)
+	if err != nil {
+		return c.decodeUnregistered(data)
+	}
+
+	writer, err := c.writer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	native, _, err := writer.codec.NativeFromBinary(avroBinary)
+	if err != nil {
+		return nil, err
+	}
+	return c.resolve(writer, native)
+}
+
+func (c *Codec) decodeUnregistered(avroBinary []byte) (interface{}, error) {
+	var err error
+	for _, writer := range c.versions {
+		var native interface{}
+		var remaining []byte
+		native, remaining, err = writer.codec.NativeFromBinary(avroBinary)
+		if err == nil && len(remaining) > 0 {
+			err = fmt.Errorf("%d bytes remaining after decoding", len(remaining))
+		}
+		if err == nil {
+			return c.resolve(writer, native)
+		}
+	}
+	return nil, err
+}
+
+// resolve native data decoded with the writer version to the latest version
+func (c *Codec) resolve(writer *version, native interface{}) (interface{}, error) {
+	if writer == c.latest {
+		return native, nil
+	}
+	return resolve(c.latest.schema, writer.schema, native)
+}
+
+// writer returns the version with the id, fetching it from the registry if it is not known
+func (c *Codec) writer(ctx context.Context, id int) (*version, error) {
+	c.mu.RLock()
+	v, ok := c.writers[id]
+	c.mu.RUnlock()
+	if ok {
+		return v, nil
+	}
+
+	text, err := c.registry.GetSchema(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get writer schema %d: %w", id, err)
+	}
+	v, err = newVersion(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid writer schema %d: %w", id, err)
+	}
+	if err := checkCompatible(c.latest.schema, v.schema); err != nil {
+		return nil, fmt.Errorf("writer schema %d cannot be read: %w", id, err)
+	}
+
+	c.mu.Lock()
+	c.writers[id] = v
+	c.mu.Unlock()
+	return v, nil
+}
+
+// NativeFromTextual decodes JSON data of the latest version
+func (c *Codec) NativeFromTextual(text []byte) (interface{}, error) {
+	native, _, err := c.latest.codec.NativeFromTextual(text)
+	return native, err
+}
+
+// TextualFromNative encodes native data of the latest version as JSON
+func (c *Codec) TextualFromNative(native interface{}) ([]byte, error) {
+	return c.latest.codec.TextualFromNative(nil, native)
+}
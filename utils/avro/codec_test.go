@@ -0,0 +1,200 @@
+package avro
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/brave-intl/bat-go/utils/clients"
+	errorutils "github.com/brave-intl/bat-go/utils/errors"
+	"github.com/linkedin/goavro"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	eventSchemaV1 = `{
+  "namespace": "brave.test",
+  "type": "record",
+  "name": "event",
+  "fields": [
+    { "name": "id", "type": "string" },
+    { "name": "count", "type": "int" },
+    { "name": "removed", "type": "string" },
+    { "name": "items",
+      "type": {
+        "type": "array",
+        "items": {
+          "type": "record",
+          "name": "item",
+          "fields": [ { "name": "name", "type": "string" } ]
+        }
+      }
+    }
+  ]
+}`
+	// eventSchemaV2 removes a field, promotes count to a long and adds fields with defaults
+	eventSchemaV2 = `{
+  "namespace": "brave.test",
+  "type": "record",
+  "name": "event",
+  "fields": [
+    { "name": "id", "type": "string" },
+    { "name": "count", "type": "long" },
+    { "name": "source", "type": "string", "default": "uphold" },
+    { "name": "note", "type": ["null", "string"], "default": null },
+    { "name": "items",
+      "type": {
+        "type": "array",
+        "items": {
+          "type": "record",
+          "name": "item",
+          "fields": [
+            { "name": "name", "type": "string" },
+            { "name": "amount", "type": "string", "default": "0" }
+          ]
+        }
+      }
+    }
+  ]
+}`
+	// eventSchemaIncompatible adds a field without a default
+	eventSchemaIncompatible = `{
+  "namespace": "brave.test",
+  "type": "record",
+  "name": "event",
+  "fields": [
+    { "name": "id", "type": "string" },
+    { "name": "count", "type": "long" },
+    { "name": "required", "type": "string" }
+  ]
+}`
+)
+
+func newTestRegistry(t *testing.T) (*FileRegistry, func()) {
+	dir, err := ioutil.TempDir("", "schema-registry")
+	require.NoError(t, err)
+	registry, err := NewFileRegistry(dir)
+	require.NoError(t, err)
+	return registry, func() {
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func assertStatus(t *testing.T, err error, status int) {
+	httpError, ok := err.(*errorutils.ErrorBundle)
+	require.True(t, ok, "should be able to coerce to an error bundle")
+	httpState, ok := httpError.Data().(clients.HTTPState)
+	require.True(t, ok, "should contain an HTTPState")
+	assert.Equal(t, status, httpState.Status)
+}
+
+func TestWireFormat(t *testing.T) {
+	data := EncodeWireFormat(258, []byte{1, 2, 3})
+	assert.Equal(t, []byte{0, 0, 0, 1, 2, 1, 2, 3}, data)
+
+	id, avroBinary, err := DecodeWireFormat(data)
+	assert.NoError(t, err)
+	assert.Equal(t, 258, id)
+	assert.Equal(t, []byte{1, 2, 3}, avroBinary)
+
+	_, _, err = DecodeWireFormat([]byte{72, 100})
+	assert.Equal(t, ErrNotWireFormat, err)
+}
+
+func TestCodecEvolution(t *testing.T) {
+	ctx := context.Background()
+	registry, cleanup := newTestRegistry(t)
+	defer cleanup()
+	subject := Subject("test.event")
+
+	v1, err := NewCodec(ctx, registry, subject, eventSchemaV1)
+	require.NoError(t, err)
+	written, err := v1.Encode(map[string]interface{}{
+		"id":      "1",
+		"count":   int32(2),
+		"removed": "gone",
+		"items":   []interface{}{map[string]interface{}{"name": "a"}},
+	})
+	require.NoError(t, err)
+
+	id, _, err := DecodeWireFormat(written)
+	require.NoError(t, err)
+	assert.Equal(t, v1.ID(), id, "Data should carry the id of the schema it was written with")
+
+	v2, err := NewCodec(ctx, registry, subject, eventSchemaV1, eventSchemaV2)
+	require.NoError(t, err)
+	assert.NotEqual(t, v1.ID(), v2.ID())
+
+	again, err := NewCodec(ctx, registry, subject, eventSchemaV1, eventSchemaV2)
+	require.NoError(t, err)
+	assert.Equal(t, v2.ID(), again.ID(), "Registering the same versions again should not add versions")
+
+	native, err := v2.Decode(ctx, written)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"id":     "1",
+		"count":  int64(2),
+		"source": "uphold",
+		"note":   nil,
+		"items":  []interface{}{map[string]interface{}{"name": "a", "amount": "0"}},
+	}, native, "Data written with the old version should be resolved to the new version")
+
+	_, err = v2.Encode(native)
+	assert.NoError(t, err, "Resolved data should be valid for the new version")
+
+	// a codec which only knows the old version fetches the writer schema from the registry
+	written, err = v2.Encode(map[string]interface{}{
+		"id":     "2",
+		"count":  int64(3),
+		"source": "gemini",
+		"note":   goavro.Union("string", "hello"),
+		"items":  []interface{}{},
+	})
+	require.NoError(t, err)
+	_, err = v1.Decode(ctx, written)
+	assert.Error(t, err, "Old readers cannot fill in the field removed by the new version")
+
+	// avro binary written before schemas were registered is read with the version which reads it exactly
+	legacyCodec, err := goavro.NewCodec(eventSchemaV1)
+	require.NoError(t, err)
+	legacy, err := legacyCodec.BinaryFromNative(nil, map[string]interface{}{
+		"id":      "3",
+		"count":   int32(4),
+		"removed": "gone",
+		"items":   []interface{}{},
+	})
+	require.NoError(t, err)
+	native, err = v2.Decode(ctx, legacy)
+	require.NoError(t, err)
+	assert.Equal(t, "3", native.(map[string]interface{})["id"])
+	assert.Equal(t, int64(4), native.(map[string]interface{})["count"])
+}
+
+func TestFileRegistryCompatibility(t *testing.T) {
+	ctx := context.Background()
+	registry, cleanup := newTestRegistry(t)
+	defer cleanup()
+	subject := Subject("test.event")
+
+	id, err := registry.RegisterSchema(ctx, subject, eventSchemaV2)
+	require.NoError(t, err)
+
+	_, err = registry.RegisterSchema(ctx, subject, eventSchemaIncompatible)
+	assertStatus(t, err, http.StatusConflict)
+
+	otherID, err := registry.RegisterSchema(ctx, Subject("test.other"), eventSchemaIncompatible)
+	assert.NoError(t, err, "Compatibility should only be checked within a subject")
+	assert.NotEqual(t, id, otherID)
+
+	schema, err := registry.GetSchema(ctx, id)
+	require.NoError(t, err)
+	text, err := canonical(eventSchemaV2)
+	require.NoError(t, err)
+	assert.Equal(t, text, schema)
+
+	_, err = registry.GetSchema(ctx, 100)
+	assertStatus(t, err, http.StatusNotFound)
+}
@@ -0,0 +1,180 @@
+package avro
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/brave-intl/bat-go/utils/clients"
+)
+
+// FileRegistry stands in for the schema registry server in tests and local runs, storing each registered
+// schema version as a file in a directory. New versions must be able to read data written with the latest.
+type FileRegistry struct {
+	dir string
+}
+
+// registeredSchema is the file stored for each schema version
+type registeredSchema struct {
+	ID      int    `json:"id"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+	Schema  string `json:"schema"`
+}
+
+// NewFileRegistry returns a FileRegistry stored in dir, creating it if needed
+func NewFileRegistry(dir string) (*FileRegistry, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileRegistry{dir: dir}, nil
+}
+
+// newFileRegistryError mimics the errors returned by the HTTPRegistry for the equivalent server response
+func newFileRegistryError(err error, status int) error {
+	return clients.NewHTTPError(err, "response", status, nil)
+}
+
+func (r *FileRegistry) path(id int) string {
+	return filepath.Join(r.dir, strconv.Itoa(id)+".json")
+}
+
+// schemas returns the registered schemas in order of id
+func (r *FileRegistry) schemas() ([]registeredSchema, error) {
+	schemas := []registeredSchema{}
+	for id := 1; ; id++ {
+		buf, err := ioutil.ReadFile(r.path(id))
+		if os.IsNotExist(err) {
+			return schemas, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var registered registeredSchema
+		if err := json.Unmarshal(buf, &registered); err != nil {
+			return nil, fmt.Errorf("failed to read schema %d: %w", id, err)
+		}
+		schemas = append(schemas, registered)
+	}
+}
+
+// canonical returns the schema with insignificant whitespace and key order removed
+func canonical(schema string) (string, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(schema), &v); err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// RegisterSchema under the subject
+func (r *FileRegistry) RegisterSchema(ctx context.Context, subject string, schema string) (int, error) {
+	text, err := canonical(schema)
+	if err != nil {
+		return 0, newFileRegistryError(fmt.Errorf("invalid schema: %w", err), http.StatusUnprocessableEntity)
+	}
+	reader, err := parseSchema(text)
+	if err != nil {
+		return 0, newFileRegistryError(fmt.Errorf("invalid schema: %w", err), http.StatusUnprocessableEntity)
+	}
+
+	for {
+		schemas, err := r.schemas()
+		if err != nil {
+			return 0, err
+		}
+
+		var latest *registeredSchema
+		for i := range schemas {
+			if schemas[i].Subject != subject {
+				continue
+			}
+			if schemas[i].Schema == text {
+				return schemas[i].ID, nil
+			}
+			latest = &schemas[i]
+		}
+
+		version := 1
+		if latest != nil {
+			writer, err := parseSchema(latest.Schema)
+			if err != nil {
+				return 0, err
+			}
+			if err := checkCompatible(reader, writer); err != nil {
+				return 0, newFileRegistryError(
+					fmt.Errorf("schema is incompatible with version %d of %s: %w", latest.Version, subject, err),
+					http.StatusConflict)
+			}
+			version = latest.Version + 1
+		}
+
+		registered := registeredSchema{ID: len(schemas) + 1, Subject: subject, Version: version, Schema: text}
+		buf, err := json.Marshal(registered)
+		if err != nil {
+			return 0, err
+		}
+		claimed, err := r.claim(registered.ID, buf)
+		if err != nil {
+			return 0, err
+		}
+		if claimed {
+			return registered.ID, nil
+		}
+		// another registration claimed the id first
+	}
+}
+
+// claim the id by linking a complete file to its path, which fails if the id is already taken
+func (r *FileRegistry) claim(id int, buf []byte) (bool, error) {
+	f, err := ioutil.TempFile(r.dir, ".schema")
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = os.Remove(f.Name())
+	}()
+	_, err = f.Write(buf)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return false, err
+	}
+
+	err = os.Link(f.Name(), r.path(id))
+	if os.IsExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GetSchema with the id
+func (r *FileRegistry) GetSchema(ctx context.Context, id int) (string, error) {
+	buf, err := ioutil.ReadFile(r.path(id))
+	if os.IsNotExist(err) {
+		return "", newFileRegistryError(errors.New("schema not found"), http.StatusNotFound)
+	}
+	if err != nil {
+		return "", err
+	}
+	var registered registeredSchema
+	if err := json.Unmarshal(buf, &registered); err != nil {
+		return "", err
+	}
+	return registered.Schema, nil
+}
@@ -0,0 +1,98 @@
+package avro
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/brave-intl/bat-go/utils/clients"
+)
+
+// Registry stores the versions of the schemas registered under each subject, following the API of the
+// Confluent schema registry
+type Registry interface {
+	// RegisterSchema under the subject and return its id. Registering a schema which is already registered
+	// returns the existing id, a schema which cannot read data written with the latest version is rejected.
+	RegisterSchema(ctx context.Context, subject string, schema string) (int, error)
+	// GetSchema with the id
+	GetSchema(ctx context.Context, id int) (string, error)
+}
+
+// Subject returns the subject of the schema for the values of a topic
+func Subject(topic string) string {
+	return topic + "-value"
+}
+
+// NewRegistryFromEnvironment returns a FileRegistry when SCHEMA_REGISTRY_URL is a file URL or when running
+// locally without SCHEMA_REGISTRY_URL, otherwise an HTTPRegistry
+func NewRegistryFromEnvironment() (Registry, error) {
+	serverURL := os.Getenv("SCHEMA_REGISTRY_URL")
+	if strings.HasPrefix(serverURL, "file://") {
+		return NewFileRegistry(strings.TrimPrefix(serverURL, "file://"))
+	}
+	if len(serverURL) == 0 {
+		if os.Getenv("ENV") != "local" {
+			return nil, errors.New("SCHEMA_REGISTRY_URL is missing in production environment")
+		}
+		return NewFileRegistry(filepath.Join(os.TempDir(), "schema-registry"))
+	}
+	return NewHTTPRegistry(serverURL, os.Getenv("SCHEMA_REGISTRY_TOKEN"))
+}
+
+// HTTPRegistry wraps http.Client for interacting with the schema registry server
+type HTTPRegistry struct {
+	client *clients.SimpleHTTPClient
+}
+
+// NewHTTPRegistry returns a new HTTPRegistry for the server
+func NewHTTPRegistry(serverURL string, authToken string) (*HTTPRegistry, error) {
+	client, err := clients.New(serverURL, authToken, "schema_registry")
+	if err != nil {
+		return nil, err
+	}
+	return &HTTPRegistry{client}, nil
+}
+
+// schemaRequest is the body of a schema registration
+type schemaRequest struct {
+	Schema string `json:"schema"`
+}
+
+// schemaResponse is returned by the registry for schema registrations and lookups
+type schemaResponse struct {
+	ID     int    `json:"id,omitempty"`
+	Schema string `json:"schema,omitempty"`
+}
+
+// RegisterSchema under the subject
+func (r *HTTPRegistry) RegisterSchema(ctx context.Context, subject string, schema string) (int, error) {
+	req, err := r.client.NewRequest(ctx, "POST", "subjects/"+subject+"/versions", schemaRequest{Schema: schema})
+	if err != nil {
+		return 0, err
+	}
+
+	var resp schemaResponse
+	_, err = r.client.Do(ctx, req, &resp)
+	if err != nil {
+		return 0, err
+	}
+	return resp.ID, nil
+}
+
+// GetSchema with the id
+func (r *HTTPRegistry) GetSchema(ctx context.Context, id int) (string, error) {
+	req, err := r.client.NewRequest(ctx, "GET", fmt.Sprintf("schemas/ids/%d", id), nil)
+	if err != nil {
+		return "", err
+	}
+
+	var resp schemaResponse
+	_, err = r.client.Do(ctx, req, &resp)
+	if err != nil {
+		return "", err
+	}
+	return resp.Schema, nil
+}
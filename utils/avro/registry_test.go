@@ -0,0 +1,46 @@
+package avro
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPRegistry(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/subjects/test.event-value/versions":
+			var req schemaRequest
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, eventSchemaV1, req.Schema)
+			_, _ = w.Write([]byte(`{"id":7}`))
+		case r.Method == "GET" && r.URL.Path == "/schemas/ids/7":
+			body, err := json.Marshal(schemaResponse{Schema: eventSchemaV1})
+			assert.NoError(t, err)
+			_, _ = w.Write(body)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error_code":40403,"message":"Schema not found"}`))
+		}
+	}))
+	defer ts.Close()
+
+	registry, err := NewHTTPRegistry(ts.URL, "")
+	require.NoError(t, err)
+
+	id, err := registry.RegisterSchema(context.Background(), Subject("test.event"), eventSchemaV1)
+	require.NoError(t, err)
+	assert.Equal(t, 7, id)
+
+	schema, err := registry.GetSchema(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, eventSchemaV1, schema)
+
+	_, err = registry.GetSchema(context.Background(), 8)
+	assertStatus(t, err, http.StatusNotFound)
+}
@@ -0,0 +1,526 @@
+package avro
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// schema is the parsed form of an avro schema, used to check that schema versions are compatible and to
+// resolve data written with one version to another
+type schema struct {
+	// typ is the primitive or complex type, references to named types point at their definition
+	typ string
+	// name is the full name of named types
+	name     string
+	fields   []field
+	items    *schema
+	values   *schema
+	branches []*schema
+	symbols  []string
+	size     int
+}
+
+// field of a record schema
+type field struct {
+	name       string
+	typ        *schema
+	def        interface{}
+	hasDefault bool
+}
+
+var primitives = map[string]bool{
+	"null":    true,
+	"boolean": true,
+	"int":     true,
+	"long":    true,
+	"float":   true,
+	"double":  true,
+	"bytes":   true,
+	"string":  true,
+}
+
+// promotions lists the types each writer type can be read as besides itself
+var promotions = map[string][]string{
+	"int":    {"long", "float", "double"},
+	"long":   {"float", "double"},
+	"float":  {"double"},
+	"string": {"bytes"},
+	"bytes":  {"string"},
+}
+
+func parseSchema(text string) (*schema, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(text), &v); err != nil {
+		return nil, fmt.Errorf("schema is not valid JSON: %w", err)
+	}
+	return parseType(v, "", map[string]*schema{})
+}
+
+func fullName(name, namespace string) string {
+	if strings.Contains(name, ".") || len(namespace) == 0 {
+		return name
+	}
+	return namespace + "." + name
+}
+
+func shortName(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+func parseType(v interface{}, namespace string, named map[string]*schema) (*schema, error) {
+	switch t := v.(type) {
+	case string:
+		if primitives[t] {
+			return &schema{typ: t}, nil
+		}
+		if s, ok := named[fullName(t, namespace)]; ok {
+			return s, nil
+		}
+		if s, ok := named[t]; ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("unknown type %q", t)
+	case []interface{}:
+		s := &schema{typ: "union"}
+		for _, branch := range t {
+			b, err := parseType(branch, namespace, named)
+			if err != nil {
+				return nil, err
+			}
+			s.branches = append(s.branches, b)
+		}
+		return s, nil
+	case map[string]interface{}:
+		typ, ok := t["type"].(string)
+		if !ok {
+			return parseType(t["type"], namespace, named)
+		}
+		switch typ {
+		case "record", "error", "enum", "fixed":
+			return parseNamed(typ, t, namespace, named)
+		case "array":
+			items, err := parseType(t["items"], namespace, named)
+			if err != nil {
+				return nil, err
+			}
+			return &schema{typ: typ, items: items}, nil
+		case "map":
+			values, err := parseType(t["values"], namespace, named)
+			if err != nil {
+				return nil, err
+			}
+			return &schema{typ: typ, values: values}, nil
+		}
+		// a primitive, possibly annotated with a logical type
+		return parseType(typ, namespace, named)
+	}
+	return nil, fmt.Errorf("invalid schema %v", v)
+}
+
+func parseNamed(typ string, t map[string]interface{}, namespace string, named map[string]*schema) (*schema, error) {
+	name, _ := t["name"].(string)
+	if len(name) == 0 {
+		return nil, fmt.Errorf("%s must have a name", typ)
+	}
+	if ns, ok := t["namespace"].(string); ok && len(ns) > 0 {
+		namespace = ns
+	}
+	s := &schema{typ: typ, name: fullName(name, namespace)}
+	if typ == "error" {
+		s.typ = "record"
+	}
+	named[s.name] = s
+	// types defined within a named type default to its namespace
+	namespace = ""
+	if i := strings.LastIndex(s.name, "."); i >= 0 {
+		namespace = s.name[:i]
+	}
+
+	switch s.typ {
+	case "enum":
+		symbols, _ := t["symbols"].([]interface{})
+		for _, symbol := range symbols {
+			str, ok := symbol.(string)
+			if !ok {
+				return nil, fmt.Errorf("enum %s has an invalid symbol", s.name)
+			}
+			s.symbols = append(s.symbols, str)
+		}
+	case "fixed":
+		size, ok := t["size"].(float64)
+		if !ok {
+			return nil, fmt.Errorf("fixed %s must have a size", s.name)
+		}
+		s.size = int(size)
+	default:
+		fields, _ := t["fields"].([]interface{})
+		for _, f := range fields {
+			fieldMap, ok := f.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("record %s has an invalid field", s.name)
+			}
+			fieldName, _ := fieldMap["name"].(string)
+			fieldType, err := parseType(fieldMap["type"], namespace, named)
+			if err != nil {
+				return nil, fmt.Errorf("field %s.%s: %w", s.name, fieldName, err)
+			}
+			def, hasDefault := fieldMap["default"]
+			s.fields = append(s.fields, field{name: fieldName, typ: fieldType, def: def, hasDefault: hasDefault})
+		}
+	}
+	return s, nil
+}
+
+// unionName is the name identifying the branch of a union in native data
+func (s *schema) unionName() string {
+	if len(s.name) > 0 {
+		return s.name
+	}
+	return s.typ
+}
+
+func (s *schema) field(name string) *field {
+	for i := range s.fields {
+		if s.fields[i].name == name {
+			return &s.fields[i]
+		}
+	}
+	return nil
+}
+
+// matches reports whether data of the writer type can be read as the reader type, without looking inside
+func matches(reader, writer *schema) bool {
+	if reader.typ == writer.typ {
+		return len(reader.name) == 0 || shortName(reader.name) == shortName(writer.name)
+	}
+	for _, typ := range promotions[writer.typ] {
+		if typ == reader.typ {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCompatible returns an error if data written with the writer schema cannot be read with the reader schema
+func checkCompatible(reader, writer *schema) error {
+	return compatible(reader, writer, "", map[[2]*schema]bool{})
+}
+
+func compatible(reader, writer *schema, path string, seen map[[2]*schema]bool) error {
+	pair := [2]*schema{reader, writer}
+	if seen[pair] {
+		// recursive types are checked once
+		return nil
+	}
+	seen[pair] = true
+
+	if writer.typ == "union" {
+		for _, branch := range writer.branches {
+			if err := compatible(reader, branch, path, seen); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if reader.typ == "union" {
+		for _, branch := range reader.branches {
+			if matches(branch, writer) {
+				return compatible(branch, writer, path, seen)
+			}
+		}
+		return fmt.Errorf("%s: %s is not in the reader union", pathOrRoot(path), writer.unionName())
+	}
+	if !matches(reader, writer) {
+		return fmt.Errorf("%s: %s cannot be read as %s", pathOrRoot(path), writer.unionName(), reader.unionName())
+	}
+
+	switch reader.typ {
+	case "record":
+		for _, rf := range reader.fields {
+			wf := writer.field(rf.name)
+			if wf == nil {
+				if !rf.hasDefault {
+					return fmt.Errorf("%s.%s: new field must have a default", pathOrRoot(path), rf.name)
+				}
+				continue
+			}
+			if err := compatible(rf.typ, wf.typ, path+"."+rf.name, seen); err != nil {
+				return err
+			}
+		}
+	case "array":
+		return compatible(reader.items, writer.items, path+"[]", seen)
+	case "map":
+		return compatible(reader.values, writer.values, path+"{}", seen)
+	case "enum":
+		for _, symbol := range writer.symbols {
+			if !contains(reader.symbols, symbol) {
+				return fmt.Errorf("%s: enum symbol %s was removed", pathOrRoot(path), symbol)
+			}
+		}
+	case "fixed":
+		if reader.size != writer.size {
+			return fmt.Errorf("%s: fixed size changed from %d to %d", pathOrRoot(path), writer.size, reader.size)
+		}
+	}
+	return nil
+}
+
+func pathOrRoot(path string) string {
+	if len(path) == 0 {
+		return "."
+	}
+	return path
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve native data decoded with the writer schema to the shape of the reader schema. Fields added by the
+// reader are filled in with their default, fields removed by the reader are dropped.
+func resolve(reader, writer *schema, value interface{}) (interface{}, error) {
+	if writer.typ == "union" {
+		branch, inner, err := unionBranch(writer, value)
+		if err != nil {
+			return nil, err
+		}
+		return resolve(reader, branch, inner)
+	}
+	if reader.typ == "union" {
+		for _, branch := range reader.branches {
+			if !matches(branch, writer) {
+				continue
+			}
+			resolved, err := resolve(branch, writer, value)
+			if err != nil || branch.typ == "null" {
+				return nil, err
+			}
+			return map[string]interface{}{branch.unionName(): resolved}, nil
+		}
+		return nil, fmt.Errorf("%s is not in the reader union", writer.unionName())
+	}
+	if !matches(reader, writer) {
+		return nil, fmt.Errorf("%s cannot be read as %s", writer.unionName(), reader.unionName())
+	}
+
+	switch reader.typ {
+	case "record":
+		in, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected record %s, got %T", writer.name, value)
+		}
+		out := make(map[string]interface{}, len(reader.fields))
+		for _, rf := range reader.fields {
+			var err error
+			if wf := writer.field(rf.name); wf != nil {
+				out[rf.name], err = resolve(rf.typ, wf.typ, in[rf.name])
+			} else if rf.hasDefault {
+				out[rf.name], err = defaultNative(rf.typ, rf.def)
+			} else {
+				err = fmt.Errorf("field %s is missing and has no default", rf.name)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", reader.name, rf.name, err)
+			}
+		}
+		return out, nil
+	case "array":
+		in, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected array, got %T", value)
+		}
+		out := make([]interface{}, len(in))
+		for i, item := range in {
+			resolved, err := resolve(reader.items, writer.items, item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+	case "map":
+		in, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected map, got %T", value)
+		}
+		out := make(map[string]interface{}, len(in))
+		for k, v := range in {
+			resolved, err := resolve(reader.values, writer.values, v)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = resolved
+		}
+		return out, nil
+	case "enum":
+		symbol, ok := value.(string)
+		if !ok || !contains(reader.symbols, symbol) {
+			return nil, fmt.Errorf("enum %s has no symbol %v", reader.name, value)
+		}
+		return symbol, nil
+	}
+	return promote(reader.typ, value)
+}
+
+// unionBranch returns the branch of the union and the value within it
+func unionBranch(union *schema, value interface{}) (*schema, interface{}, error) {
+	if value == nil {
+		for _, branch := range union.branches {
+			if branch.typ == "null" {
+				return branch, nil, nil
+			}
+		}
+		return nil, nil, fmt.Errorf("union has no null branch")
+	}
+	m, ok := value.(map[string]interface{})
+	if ok && len(m) == 1 {
+		for name, inner := range m {
+			for _, branch := range union.branches {
+				if branch.unionName() == name {
+					return branch, inner, nil
+				}
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("invalid union value %v", value)
+}
+
+// promote a primitive value to the native type of the reader type
+func promote(typ string, value interface{}) (interface{}, error) {
+	switch typ {
+	case "long":
+		if v, ok := value.(int32); ok {
+			return int64(v), nil
+		}
+	case "float":
+		switch v := value.(type) {
+		case int32:
+			return float32(v), nil
+		case int64:
+			return float32(v), nil
+		}
+	case "double":
+		switch v := value.(type) {
+		case int32:
+			return float64(v), nil
+		case int64:
+			return float64(v), nil
+		case float32:
+			return float64(v), nil
+		}
+	case "bytes":
+		if v, ok := value.(string); ok {
+			return []byte(v), nil
+		}
+	case "string":
+		if v, ok := value.([]byte); ok {
+			return string(v), nil
+		}
+	}
+	return value, nil
+}
+
+// defaultNative converts the JSON default of a field to its native value
+func defaultNative(s *schema, def interface{}) (interface{}, error) {
+	invalid := fmt.Errorf("invalid default %v for %s", def, s.unionName())
+	switch s.typ {
+	case "null":
+		return nil, nil
+	case "boolean":
+		if v, ok := def.(bool); ok {
+			return v, nil
+		}
+	case "int", "long", "float", "double":
+		v, ok := def.(float64)
+		if !ok {
+			return nil, invalid
+		}
+		switch s.typ {
+		case "int":
+			return int32(v), nil
+		case "long":
+			return int64(v), nil
+		case "float":
+			return float32(v), nil
+		}
+		return v, nil
+	case "string", "enum":
+		if v, ok := def.(string); ok {
+			return v, nil
+		}
+	case "bytes", "fixed":
+		// bytes defaults are strings of code points 0-255
+		if v, ok := def.(string); ok {
+			b := make([]byte, 0, len(v))
+			for _, r := range v {
+				b = append(b, byte(r))
+			}
+			return b, nil
+		}
+	case "array":
+		items, ok := def.([]interface{})
+		if !ok {
+			return nil, invalid
+		}
+		out := make([]interface{}, len(items))
+		for i, item := range items {
+			v, err := defaultNative(s.items, item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	case "map":
+		values, ok := def.(map[string]interface{})
+		if !ok {
+			return nil, invalid
+		}
+		out := make(map[string]interface{}, len(values))
+		for k, value := range values {
+			v, err := defaultNative(s.values, value)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = v
+		}
+		return out, nil
+	case "record":
+		values, ok := def.(map[string]interface{})
+		if !ok {
+			return nil, invalid
+		}
+		out := make(map[string]interface{}, len(s.fields))
+		for _, f := range s.fields {
+			value, ok := values[f.name]
+			if !ok {
+				if !f.hasDefault {
+					return nil, invalid
+				}
+				value = f.def
+			}
+			v, err := defaultNative(f.typ, value)
+			if err != nil {
+				return nil, err
+			}
+			out[f.name] = v
+		}
+		return out, nil
+	case "union":
+		// union defaults are of the first branch
+		first := s.branches[0]
+		v, err := defaultNative(first, def)
+		if err != nil || first.typ == "null" {
+			return nil, err
+		}
+		return map[string]interface{}{first.unionName(): v}, nil
+	}
+	return nil, invalid
+}
@@ -26,7 +26,7 @@ func New() (*HTTPClient, error) {
 	if len(serverEnvKey) == 0 {
 		return nil, errors.New(serverEnvKey + " was empty")
 	}
-	client, err := clients.New(serverURL, os.Getenv("BALANCE_TOKEN"))
+	client, err := clients.New(serverURL, os.Getenv("BALANCE_TOKEN"), "balance")
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,137 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrCircuitOpen is returned without making a request when too many recent requests to the host failed
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerPolicy configures the per host circuit breaker
+type BreakerPolicy struct {
+	// FailureThreshold is the number of consecutive failures which open the circuit, 0 disables the breaker
+	FailureThreshold int
+	// Cooldown is how long the circuit stays open before a trial request is allowed
+	Cooldown time.Duration
+}
+
+// DefaultBreakerPolicy is used by clients which do not configure their own
+var DefaultBreakerPolicy = BreakerPolicy{
+	FailureThreshold: 5,
+	Cooldown:         30 * time.Second,
+}
+
+var countCircuitOpenRejections = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "client_circuit_open_rejections_total",
+		Help: "A counter for requests from the wrapped client rejected by an open circuit breaker.",
+	},
+	[]string{"service", "host"},
+)
+
+func init() {
+	prometheus.MustRegister(countCircuitOpenRejections)
+}
+
+// circuitBreaker tracks consecutive failures for a single host
+type circuitBreaker struct {
+	mu        sync.Mutex
+	policy    BreakerPolicy
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+// allow returns true if a request may be made
+func (b *circuitBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.policy.FailureThreshold {
+		return true
+	}
+	if now.Before(b.openUntil) || b.trial {
+		return false
+	}
+	// half open, let a single trial request through
+	b.trial = true
+	return true
+}
+
+// release a trial request without recording a result
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+// record the result of a request
+func (b *circuitBreaker) record(success bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+	if success {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.policy.FailureThreshold {
+		b.openUntil = now.Add(b.policy.Cooldown)
+	}
+}
+
+// breakerRoundTripper fails fast for hosts whose circuit is open
+type breakerRoundTripper struct {
+	next     http.RoundTripper
+	policy   BreakerPolicy
+	service  string
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+func (rt *breakerRoundTripper) breaker(host string) *circuitBreaker {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	if rt.breakers == nil {
+		rt.breakers = map[string]*circuitBreaker{}
+	}
+	b, ok := rt.breakers[host]
+	if !ok {
+		b = &circuitBreaker{policy: rt.policy}
+		rt.breakers[host] = b
+	}
+	return b
+}
+
+// RoundTrip implements http.RoundTripper
+func (rt *breakerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt.policy.FailureThreshold <= 0 {
+		return rt.next.RoundTrip(req)
+	}
+
+	b := rt.breaker(req.URL.Host)
+	if !b.allow(time.Now()) {
+		countCircuitOpenRejections.With(prometheus.Labels{"service": rt.service, "host": req.URL.Host}).Inc()
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, ErrCircuitOpen
+	}
+
+	resp, err := rt.next.RoundTrip(req)
+	if err != nil && errors.Is(err, context.Canceled) {
+		// the caller gave up, this says nothing about the health of the host
+		b.release()
+		return resp, err
+	}
+	b.record(err == nil && resp.StatusCode < http.StatusInternalServerError, time.Now())
+	return resp, err
+}
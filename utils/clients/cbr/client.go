@@ -3,9 +3,11 @@ package cbr
 import (
 	"context"
 	"errors"
+	"net/http"
 	"os"
 
 	"github.com/brave-intl/bat-go/utils/clients"
+	errorutils "github.com/brave-intl/bat-go/utils/errors"
 )
 
 // Client abstracts over the underlying client
@@ -29,7 +31,7 @@ func New() (*HTTPClient, error) {
 	if len(serverURL) == 0 {
 		return nil, errors.New(serverEnvKey + " was empty")
 	}
-	client, err := clients.New(serverURL, os.Getenv("CHALLENGE_BYPASS_TOKEN"))
+	client, err := clients.New(serverURL, os.Getenv("CHALLENGE_BYPASS_TOKEN"), "cbr")
 	if err != nil {
 		return nil, err
 	}
@@ -140,3 +142,13 @@ func (c *HTTPClient) RedeemCredentials(ctx context.Context, credentials []Creden
 
 	return err
 }
+
+// IsDuplicateRedemption returns true if the redemption was rejected because the credentials were already redeemed
+func IsDuplicateRedemption(err error) bool {
+	var bundle *errorutils.ErrorBundle
+	if !errors.As(err, &bundle) {
+		return false
+	}
+	state, ok := bundle.Data().(clients.HTTPState)
+	return ok && state.Status == http.StatusConflict
+}
@@ -0,0 +1,183 @@
+package cbr
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/brave-intl/bat-go/utils/clients"
+	"github.com/gtank/ristretto255"
+)
+
+var (
+	localClient     *LocalClient
+	localClientOnce sync.Once
+)
+
+// NewFromEnvironment returns a LocalClient shared by the process when running locally without
+// CHALLENGE_BYPASS_SERVER, otherwise an HTTPClient
+func NewFromEnvironment() (Client, error) {
+	if os.Getenv("ENV") == "local" && len(os.Getenv("CHALLENGE_BYPASS_SERVER")) == 0 {
+		localClientOnce.Do(func() {
+			localClient = NewLocal()
+		})
+		return localClient, nil
+	}
+	return New()
+}
+
+// localIssuer holds the signing key and usage of a single issuer
+type localIssuer struct {
+	signingKey *ristretto255.Scalar
+	publicKey  string
+	maxTokens  int
+	issued     int
+	// redeemed preimages mapped to the payload they were redeemed toward
+	redeemed map[string]string
+}
+
+func newLocalIssuer(signingKey *ristretto255.Scalar, maxTokens int) *localIssuer {
+	return &localIssuer{
+		signingKey: signingKey,
+		publicKey:  encodeElement(ristretto255.NewElement().ScalarBaseMult(signingKey)),
+		maxTokens:  maxTokens,
+		redeemed:   map[string]string{},
+	}
+}
+
+// LocalClient is an in process challenge bypass issuer, performing the VOPRF operations itself
+type LocalClient struct {
+	mu      sync.Mutex
+	issuers map[string]*localIssuer
+}
+
+// NewLocal returns a new LocalClient without any issuers
+func NewLocal() *LocalClient {
+	return &LocalClient{issuers: map[string]*localIssuer{}}
+}
+
+// newLocalError mimics the errors returned by the HTTPClient for the equivalent server response
+func newLocalError(message string, status int) error {
+	return clients.NewHTTPError(errors.New(message), "response", status, nil)
+}
+
+// CreateIssuer with the provided name and token cap
+func (c *LocalClient) CreateIssuer(ctx context.Context, issuer string, maxTokens int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.issuers[issuer]; ok {
+		return newLocalError("issuer already exists", http.StatusConflict)
+	}
+	signingKey, err := randomScalar()
+	if err != nil {
+		return err
+	}
+	c.issuers[issuer] = newLocalIssuer(signingKey, maxTokens)
+	return nil
+}
+
+// GetIssuer by name
+func (c *LocalClient) GetIssuer(ctx context.Context, issuer string) (*IssuerResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i, ok := c.issuers[issuer]
+	if !ok {
+		return nil, newLocalError("issuer not found", http.StatusNotFound)
+	}
+	return &IssuerResponse{Name: issuer, PublicKey: i.publicKey}, nil
+}
+
+// SignCredentials using a particular issuer
+func (c *LocalClient) SignCredentials(ctx context.Context, issuer string, creds []string) (*CredentialsIssueResponse, error) {
+	blinded, err := decodeElements(creds)
+	if err != nil {
+		return nil, newLocalError("could not decode blinded tokens", http.StatusBadRequest)
+	}
+	if len(blinded) == 0 {
+		return nil, newLocalError("no blinded tokens", http.StatusBadRequest)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i, ok := c.issuers[issuer]
+	if !ok {
+		return nil, newLocalError("issuer not found", http.StatusNotFound)
+	}
+	if i.maxTokens > 0 && i.issued+len(blinded) > i.maxTokens {
+		return nil, newLocalError("issuer token limit reached", http.StatusBadRequest)
+	}
+
+	signed := make([]*ristretto255.Element, len(blinded))
+	signedTokens := make([]string, len(blinded))
+	for j := range blinded {
+		signed[j] = ristretto255.NewElement().ScalarMult(i.signingKey, blinded[j])
+		signedTokens[j] = encodeElement(signed[j])
+	}
+	proof, err := newBatchProof(i.signingKey, blinded, signed)
+	if err != nil {
+		return nil, err
+	}
+	i.issued += len(blinded)
+
+	return &CredentialsIssueResponse{BatchProof: proof, SignedTokens: signedTokens}, nil
+}
+
+// verify that the signature over payload was made with a token signed by issuer
+func (i *localIssuer) verify(preimage string, signature string, payload string) error {
+	t, err := base64.StdEncoding.DecodeString(preimage)
+	if err != nil || len(t) != TokenPreimageLength {
+		return newLocalError("could not decode token preimage", http.StatusBadRequest)
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return newLocalError("could not decode signature", http.StatusBadRequest)
+	}
+	unblinded := ristretto255.NewElement().ScalarMult(i.signingKey, hashToElement(t))
+	if !hmac.Equal(signPayload(verificationKey(t, unblinded), payload), sig) {
+		return newLocalError("could not verify that token redemption is valid", http.StatusBadRequest)
+	}
+	return nil
+}
+
+// RedeemCredential that was issued by the specified issuer
+func (c *LocalClient) RedeemCredential(ctx context.Context, issuer string, preimage string, signature string, payload string) error {
+	return c.RedeemCredentials(ctx, []CredentialRedemption{{
+		Issuer:        issuer,
+		TokenPreimage: preimage,
+		Signature:     signature,
+	}}, payload)
+}
+
+// RedeemCredentials that were issued by the specified issuer, either all or none are redeemed
+func (c *LocalClient) RedeemCredentials(ctx context.Context, credentials []CredentialRedemption, payload string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	seen := map[CredentialRedemption]bool{}
+	for _, cred := range credentials {
+		i, ok := c.issuers[cred.Issuer]
+		if !ok {
+			return newLocalError("issuer not found", http.StatusNotFound)
+		}
+		if err := i.verify(cred.TokenPreimage, cred.Signature, payload); err != nil {
+			return err
+		}
+		key := CredentialRedemption{Issuer: cred.Issuer, TokenPreimage: cred.TokenPreimage}
+		if _, redeemed := i.redeemed[cred.TokenPreimage]; redeemed || seen[key] {
+			return newLocalError("duplicate redemption", http.StatusConflict)
+		}
+		seen[key] = true
+	}
+
+	for _, cred := range credentials {
+		c.issuers[cred.Issuer].redeemed[cred.TokenPreimage] = payload
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package cbr
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/brave-intl/bat-go/utils/clients"
+	errorutils "github.com/brave-intl/bat-go/utils/errors"
+	"github.com/gtank/ristretto255"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func assertStatus(t *testing.T, err error, status int) {
+	httpError, ok := err.(*errorutils.ErrorBundle)
+	require.True(t, ok, "should be able to coerce to an error bundle")
+	httpState, ok := httpError.Data().(clients.HTTPState)
+	require.True(t, ok, "should contain an HTTPState")
+	assert.Equal(t, status, httpState.Status)
+}
+
+func TestLocalCompatibility(t *testing.T) {
+	sKey := "fzJbqh6l/xWAjT6Ulmu+/Taxz8XZ7SDnJ/dUXPgtnQE="
+	pKey := "jKj71sdk2XYMwZNSxvUfNkSNCUQeBuUxuTbdjIbupmE="
+	blindedToken := "yoGo7zfMr5vAzwyyFKwoFEsUcyUlXKY75VvWLfYi7go="
+	signedToken := "ohwnBITMSphAFK/06LtbC+PYl6PmmEhOdybvsfqZjG4="
+	preimage := "Aa61pQzyxsy3Z6tSwccnOqiW23fNYp0z3xw6XGlA5FG8O/EqlxR87DWnas49U2JUau44dpiveAt7kBXDH5RjPQ=="
+	sig := "zx1zdMhN4Et8WnrkVQOad6xhUBAJ7Pq4b8A0n96CRE0QdAQ+tJe0/eFiJqIPMuKkyfQ6VncIkGj9VzkByh9uFA=="
+	payload := "test message"
+
+	b, err := base64.StdEncoding.DecodeString(sKey)
+	require.NoError(t, err)
+	signingKey := ristretto255.NewScalar()
+	require.NoError(t, signingKey.Decode(b))
+
+	client := NewLocal()
+	client.issuers["constant"] = newLocalIssuer(signingKey, 100)
+	ctx := context.Background()
+
+	issuer, err := client.GetIssuer(ctx, "constant")
+	require.NoError(t, err)
+	assert.Equal(t, pKey, issuer.PublicKey, "Public key should match the challenge bypass server")
+
+	resp, err := client.SignCredentials(ctx, "constant", []string{blindedToken})
+	require.NoError(t, err)
+	assert.Equal(t, []string{signedToken}, resp.SignedTokens, "Signed token should match the challenge bypass server")
+	assert.NoError(t, VerifyBatchProof(pKey, []string{blindedToken}, resp.SignedTokens, resp.BatchProof))
+
+	err = client.RedeemCredential(ctx, "constant", preimage, sig, "wrong message")
+	assertStatus(t, err, http.StatusBadRequest)
+
+	err = client.RedeemCredential(ctx, "constant", preimage, sig, payload)
+	assert.NoError(t, err, "Should be able to redeem tokens signed by the challenge bypass server")
+
+	// a batch proof produced by the challenge bypass server
+	err = VerifyBatchProof(
+		"dHuiBIasUO0khhXsWgygqpVasZhtQraDSZxzJW2FKQ4=",
+		[]string{"XhBPMjh4vMw+yoNjE7C5OtoTz2rCtfuOXO/Vk7UwWzY="},
+		[]string{"NJnOyyL6YAKMYo6kSAuvtG+/04zK1VNaD9KdKwuzAjU="},
+		"IiKqfk10e7SJ54Ud/8FnCf+sLYQzS4WiVtYAM5+RVgApY6B9x4CVbMEngkDifEBRD6szEqnNlc3KA8wokGV5Cw==",
+	)
+	assert.NoError(t, err, "Should be able to verify batch proofs from the challenge bypass server")
+}
+
+func TestLocalSignAndRedeemCredentials(t *testing.T) {
+	client := NewLocal()
+	ctx := context.Background()
+
+	_, err := client.GetIssuer(ctx, "test")
+	assertStatus(t, err, http.StatusNotFound)
+
+	require.NoError(t, client.CreateIssuer(ctx, "test", 3))
+	err = client.CreateIssuer(ctx, "test", 3)
+	assertStatus(t, err, http.StatusConflict)
+	require.NoError(t, client.CreateIssuer(ctx, "other", 3))
+
+	issuer, err := client.GetIssuer(ctx, "test")
+	require.NoError(t, err)
+
+	tokens := make([]*Token, 3)
+	blindedTokens := make([]string, len(tokens))
+	for i := range tokens {
+		tokens[i], err = GenerateToken()
+		require.NoError(t, err)
+		blindedTokens[i] = tokens[i].Blinded()
+	}
+
+	resp, err := client.SignCredentials(ctx, "test", blindedTokens)
+	require.NoError(t, err)
+	require.Len(t, resp.SignedTokens, 3)
+	assert.NoError(t, VerifyBatchProof(issuer.PublicKey, blindedTokens, resp.SignedTokens, resp.BatchProof))
+
+	other, err := client.GetIssuer(ctx, "other")
+	require.NoError(t, err)
+	assert.Error(t, VerifyBatchProof(other.PublicKey, blindedTokens, resp.SignedTokens, resp.BatchProof),
+		"batch proof should not verify against a different issuer")
+
+	_, err = client.SignCredentials(ctx, "test", blindedTokens[:1])
+	assertStatus(t, err, http.StatusBadRequest)
+
+	credentials := make([]CredentialRedemption, len(tokens))
+	for i := range tokens {
+		preimage, signature, err := tokens[i].Redemption(resp.SignedTokens[i], "payload")
+		require.NoError(t, err)
+		credentials[i] = CredentialRedemption{Issuer: "test", TokenPreimage: preimage, Signature: signature}
+	}
+
+	err = client.RedeemCredentials(ctx, []CredentialRedemption{credentials[0], credentials[0]}, "payload")
+	assertStatus(t, err, http.StatusConflict)
+
+	err = client.RedeemCredentials(ctx, []CredentialRedemption{credentials[0], {Issuer: "other", TokenPreimage: credentials[1].TokenPreimage, Signature: credentials[1].Signature}}, "payload")
+	assertStatus(t, err, http.StatusBadRequest)
+	assert.False(t, IsDuplicateRedemption(err))
+
+	require.NoError(t, client.RedeemCredentials(ctx, credentials[:2], "payload"))
+
+	err = client.RedeemCredential(ctx, "test", credentials[0].TokenPreimage, credentials[0].Signature, "payload")
+	assertStatus(t, err, http.StatusConflict)
+	assert.True(t, IsDuplicateRedemption(err))
+
+	err = client.RedeemCredentials(ctx, credentials[1:], "payload")
+	assertStatus(t, err, http.StatusConflict)
+
+	// the failed bulk redemption should not have spent the remaining token
+	assert.NoError(t, client.RedeemCredential(ctx, "test", credentials[2].TokenPreimage, credentials[2].Signature, "payload"))
+}
@@ -0,0 +1,281 @@
+package cbr
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+
+	"github.com/gtank/ristretto255"
+)
+
+// The constructions below are compatible with github.com/brave-intl/challenge-bypass-ristretto,
+// which backs the challenge bypass server
+
+const (
+	// TokenPreimageLength is the length in bytes of a token preimage
+	TokenPreimageLength = 64
+	// derivedKeyPrefix is hashed with the unblinded token when deriving a verification key
+	derivedKeyPrefix = "hash_derive_key"
+)
+
+// randomScalar returns a uniformly random scalar
+func randomScalar() (*ristretto255.Scalar, error) {
+	var b [64]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, err
+	}
+	return ristretto255.NewScalar().FromUniformBytes(b[:]), nil
+}
+
+// decodeElement from its base64 encoding
+func decodeElement(s string) (*ristretto255.Element, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	e := ristretto255.NewElement()
+	if err := e.Decode(b); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// encodeElement to base64
+func encodeElement(e *ristretto255.Element) string {
+	return base64.StdEncoding.EncodeToString(e.Encode(nil))
+}
+
+// hashToElement maps a token preimage to the element T
+func hashToElement(preimage []byte) *ristretto255.Element {
+	return ristretto255.NewElement().FromUniformBytes(preimage)
+}
+
+// verificationKey derives the key used to sign redemption payloads from an unblinded token W
+func verificationKey(preimage []byte, unblinded *ristretto255.Element) []byte {
+	h := sha512.New()
+	_, _ = h.Write([]byte(derivedKeyPrefix))
+	_, _ = h.Write(preimage)
+	_, _ = h.Write(unblinded.Encode(nil))
+	return h.Sum(nil)
+}
+
+// signPayload using the verification key
+func signPayload(key []byte, payload string) []byte {
+	mac := hmac.New(sha512.New, key)
+	_, _ = mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// chachaRng is the ChaCha20 keystream with a zero nonce, used to deterministically derive the
+// batch proof composite coefficients
+type chachaRng struct {
+	state   [16]uint32
+	counter uint64
+	buf     []byte
+}
+
+func newChachaRng(seed []byte) *chachaRng {
+	rng := &chachaRng{}
+	rng.state[0], rng.state[1], rng.state[2], rng.state[3] = 0x61707865, 0x3320646e, 0x79622d32, 0x6b206574
+	for i := 0; i < 8; i++ {
+		rng.state[4+i] = binary.LittleEndian.Uint32(seed[4*i:])
+	}
+	return rng
+}
+
+func quarterRound(a, b, c, d uint32) (uint32, uint32, uint32, uint32) {
+	a += b
+	d = bits.RotateLeft32(d^a, 16)
+	c += d
+	b = bits.RotateLeft32(b^c, 12)
+	a += b
+	d = bits.RotateLeft32(d^a, 8)
+	c += d
+	b = bits.RotateLeft32(b^c, 7)
+	return a, b, c, d
+}
+
+func (rng *chachaRng) block() []byte {
+	s := rng.state
+	s[12], s[13] = uint32(rng.counter), uint32(rng.counter>>32)
+	rng.counter++
+
+	x := s
+	for i := 0; i < 10; i++ {
+		x[0], x[4], x[8], x[12] = quarterRound(x[0], x[4], x[8], x[12])
+		x[1], x[5], x[9], x[13] = quarterRound(x[1], x[5], x[9], x[13])
+		x[2], x[6], x[10], x[14] = quarterRound(x[2], x[6], x[10], x[14])
+		x[3], x[7], x[11], x[15] = quarterRound(x[3], x[7], x[11], x[15])
+		x[0], x[5], x[10], x[15] = quarterRound(x[0], x[5], x[10], x[15])
+		x[1], x[6], x[11], x[12] = quarterRound(x[1], x[6], x[11], x[12])
+		x[2], x[7], x[8], x[13] = quarterRound(x[2], x[7], x[8], x[13])
+		x[3], x[4], x[9], x[14] = quarterRound(x[3], x[4], x[9], x[14])
+	}
+
+	out := make([]byte, 64)
+	for i := 0; i < 16; i++ {
+		binary.LittleEndian.PutUint32(out[4*i:], x[i]+s[i])
+	}
+	return out
+}
+
+// scalar returns the next uniformly random scalar from the keystream
+func (rng *chachaRng) scalar() *ristretto255.Scalar {
+	for len(rng.buf) < 64 {
+		rng.buf = append(rng.buf, rng.block()...)
+	}
+	s := ristretto255.NewScalar().FromUniformBytes(rng.buf[:64])
+	rng.buf = rng.buf[64:]
+	return s
+}
+
+// composites combines the blinded and signed tokens into a single pair M, Z so that one DLEQ proof covers the batch
+func composites(publicKey *ristretto255.Element, blinded, signed []*ristretto255.Element) (*ristretto255.Element, *ristretto255.Element, error) {
+	if len(blinded) != len(signed) || len(blinded) == 0 {
+		return nil, nil, errors.New("batch proof requires an equal, non-zero number of blinded and signed tokens")
+	}
+
+	h := sha512.New()
+	_, _ = h.Write(ristretto255.NewElement().Base().Encode(nil))
+	_, _ = h.Write(publicKey.Encode(nil))
+	for i := range blinded {
+		_, _ = h.Write(blinded[i].Encode(nil))
+		_, _ = h.Write(signed[i].Encode(nil))
+	}
+	rng := newChachaRng(h.Sum(nil)[:32])
+
+	coefficients := make([]*ristretto255.Scalar, len(blinded))
+	for i := range coefficients {
+		coefficients[i] = rng.scalar()
+	}
+	m := ristretto255.NewElement().VarTimeMultiScalarMult(coefficients, blinded)
+	z := ristretto255.NewElement().VarTimeMultiScalarMult(coefficients, signed)
+	return m, z, nil
+}
+
+// dleqChallenge hashes the proof transcript into the challenge scalar
+func dleqChallenge(elements ...*ristretto255.Element) *ristretto255.Scalar {
+	h := sha512.New()
+	for _, e := range elements {
+		_, _ = h.Write(e.Encode(nil))
+	}
+	return ristretto255.NewScalar().FromUniformBytes(h.Sum(nil))
+}
+
+// newBatchProof proves that every signed token is the corresponding blinded token multiplied by the signing key
+func newBatchProof(signingKey *ristretto255.Scalar, blinded, signed []*ristretto255.Element) (string, error) {
+	g := ristretto255.NewElement().Base()
+	y := ristretto255.NewElement().ScalarBaseMult(signingKey)
+	m, z, err := composites(y, blinded, signed)
+	if err != nil {
+		return "", err
+	}
+
+	t, err := randomScalar()
+	if err != nil {
+		return "", err
+	}
+	a := ristretto255.NewElement().ScalarBaseMult(t)
+	b := ristretto255.NewElement().ScalarMult(t, m)
+
+	c := dleqChallenge(g, y, m, z, a, b)
+	s := ristretto255.NewScalar().Subtract(t, ristretto255.NewScalar().Multiply(c, signingKey))
+
+	proof := append(c.Encode(nil), s.Encode(nil)...)
+	return base64.StdEncoding.EncodeToString(proof), nil
+}
+
+// VerifyBatchProof checks that the signed tokens were produced from the blinded tokens by the key matching publicKey
+func VerifyBatchProof(publicKey string, blindedTokens, signedTokens []string, batchProof string) error {
+	y, err := decodeElement(publicKey)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+	blinded, err := decodeElements(blindedTokens)
+	if err != nil {
+		return fmt.Errorf("invalid blinded token: %w", err)
+	}
+	signed, err := decodeElements(signedTokens)
+	if err != nil {
+		return fmt.Errorf("invalid signed token: %w", err)
+	}
+	proof, err := base64.StdEncoding.DecodeString(batchProof)
+	if err != nil {
+		return fmt.Errorf("invalid batch proof: %w", err)
+	}
+	if len(proof) != 64 {
+		return errors.New("invalid batch proof length")
+	}
+	c, s := ristretto255.NewScalar(), ristretto255.NewScalar()
+	if err := c.Decode(proof[:32]); err != nil {
+		return fmt.Errorf("invalid batch proof: %w", err)
+	}
+	if err := s.Decode(proof[32:]); err != nil {
+		return fmt.Errorf("invalid batch proof: %w", err)
+	}
+
+	m, z, err := composites(y, blinded, signed)
+	if err != nil {
+		return err
+	}
+	g := ristretto255.NewElement().Base()
+	a := ristretto255.NewElement().VarTimeMultiScalarMult([]*ristretto255.Scalar{s, c}, []*ristretto255.Element{g, y})
+	b := ristretto255.NewElement().VarTimeMultiScalarMult([]*ristretto255.Scalar{s, c}, []*ristretto255.Element{m, z})
+
+	if dleqChallenge(g, y, m, z, a, b).Equal(c) != 1 {
+		return errors.New("batch proof is not valid")
+	}
+	return nil
+}
+
+func decodeElements(in []string) ([]*ristretto255.Element, error) {
+	out := make([]*ristretto255.Element, len(in))
+	for i := range in {
+		e, err := decodeElement(in[i])
+		if err != nil {
+			return nil, err
+		}
+		out[i] = e
+	}
+	return out, nil
+}
+
+// Token is a client side credential, it is blinded before signing and unblinded to redeem
+type Token struct {
+	preimage []byte
+	blind    *ristretto255.Scalar
+}
+
+// GenerateToken with a random preimage and blinding factor
+func GenerateToken() (*Token, error) {
+	preimage := make([]byte, TokenPreimageLength)
+	if _, err := rand.Read(preimage); err != nil {
+		return nil, err
+	}
+	blind, err := randomScalar()
+	if err != nil {
+		return nil, err
+	}
+	return &Token{preimage: preimage, blind: blind}, nil
+}
+
+// Blinded token to be sent for signing
+func (t *Token) Blinded() string {
+	return encodeElement(ristretto255.NewElement().ScalarMult(t.blind, hashToElement(t.preimage)))
+}
+
+// Redemption of the token toward payload once signed by issuer, returning the encoded preimage and signature
+func (t *Token) Redemption(signedToken string, payload string) (string, string, error) {
+	signed, err := decodeElement(signedToken)
+	if err != nil {
+		return "", "", err
+	}
+	unblinded := ristretto255.NewElement().ScalarMult(ristretto255.NewScalar().Invert(t.blind), signed)
+	signature := signPayload(verificationKey(t.preimage, unblinded), payload)
+	return base64.StdEncoding.EncodeToString(t.preimage), base64.StdEncoding.EncodeToString(signature), nil
+}
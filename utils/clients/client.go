@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"time"
 
+	"github.com/brave-intl/bat-go/middleware"
 	"github.com/brave-intl/bat-go/utils/closers"
 	"github.com/brave-intl/bat-go/utils/errors"
 	"github.com/brave-intl/bat-go/utils/requestutils"
@@ -25,19 +26,76 @@ type SimpleHTTPClient struct {
 	client *http.Client
 }
 
-// New returns a new SimpleHTTPClient, retrieving the base URL from the environment
-func New(serverURL string, authToken string) (*SimpleHTTPClient, error) {
+// config holds the settings a SimpleHTTPClient is constructed with
+type config struct {
+	timeout   time.Duration
+	retry     RetryPolicy
+	breaker   BreakerPolicy
+	transport http.RoundTripper
+}
+
+// Option configures a SimpleHTTPClient
+type Option func(*config)
+
+// WithTimeout sets the overall timeout of a request, including any retries
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.timeout = timeout
+	}
+}
+
+// WithRetryPolicy sets how idempotent requests are retried
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *config) {
+		c.retry = policy
+	}
+}
+
+// WithBreakerPolicy sets when the per host circuit breaker opens
+func WithBreakerPolicy(policy BreakerPolicy) Option {
+	return func(c *config) {
+		c.breaker = policy
+	}
+}
+
+// WithTransport sets the underlying transport requests are made with
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *config) {
+		c.transport = transport
+	}
+}
+
+// New returns a new SimpleHTTPClient for the named service
+// Requests are instrumented with metrics labeled by service, idempotent requests are retried with
+// jittered backoff and requests fail fast while the circuit breaker for a host is open
+func New(serverURL string, authToken string, service string, options ...Option) (*SimpleHTTPClient, error) {
 	baseURL, err := url.Parse(serverURL)
 
 	if err != nil {
 		return nil, err
 	}
 
+	cfg := config{
+		timeout:   time.Second * 10,
+		retry:     DefaultRetryPolicy,
+		breaker:   DefaultBreakerPolicy,
+		transport: http.DefaultTransport,
+	}
+	for _, option := range options {
+		option(&cfg)
+	}
+
+	var transport http.RoundTripper
+	transport = middleware.InstrumentRoundTripper(cfg.transport, service)
+	transport = &breakerRoundTripper{next: transport, policy: cfg.breaker, service: service}
+	transport = &retryRoundTripper{next: transport, policy: cfg.retry, service: service}
+
 	return &SimpleHTTPClient{
 		BaseURL:   baseURL,
 		AuthToken: authToken,
 		client: &http.Client{
-			Timeout: time.Second * 10,
+			Timeout:   cfg.timeout,
+			Transport: transport,
 		},
 	}, nil
 }
@@ -153,7 +211,11 @@ func (c *SimpleHTTPClient) do(
 func (c *SimpleHTTPClient) Do(ctx context.Context, req *http.Request, v interface{}) (*http.Response, error) {
 	resp, err := c.do(ctx, req, v)
 	if err != nil {
-		return resp, NewHTTPError(err, "response", resp.StatusCode, v)
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		return resp, NewHTTPError(err, "response", status, v)
 	}
 	logOut(ctx, "response", *req.URL, resp.StatusCode, resp.Header, v)
 	return resp, nil
@@ -0,0 +1,96 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetries(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok": true}`))
+	}))
+	defer ts.Close()
+
+	retry := RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+	client, err := New(ts.URL, "token", "test", WithRetryPolicy(retry))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	req, err := client.NewRequest(ctx, "GET", "/", nil)
+	require.NoError(t, err)
+
+	var body struct {
+		OK bool `json:"ok"`
+	}
+	_, err = client.Do(ctx, req, &body)
+	require.NoError(t, err)
+	assert.True(t, body.OK)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls), "transient failures of idempotent requests should be retried")
+
+	atomic.StoreInt32(&calls, 0)
+	req, err = client.NewRequest(ctx, "POST", "/", map[string]string{"a": "b"})
+	require.NoError(t, err)
+	_, err = client.Do(ctx, req, nil)
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "non idempotent requests should not be retried")
+
+	// a second client for the same service should reuse its metrics
+	_, err = New(ts.URL, "token", "test")
+	require.NoError(t, err)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	client, err := New(ts.URL, "token", "breaker-test",
+		WithRetryPolicy(RetryPolicy{}),
+		WithBreakerPolicy(BreakerPolicy{FailureThreshold: 2, Cooldown: time.Hour}))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 4; i++ {
+		req, err := client.NewRequest(ctx, "GET", "/", nil)
+		require.NoError(t, err)
+		_, err = client.Do(ctx, req, nil)
+		require.Error(t, err)
+		if i >= 2 {
+			assert.True(t, errors.Is(err, ErrCircuitOpen), "requests should fail fast once the circuit is open")
+		}
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	b := &circuitBreaker{policy: BreakerPolicy{FailureThreshold: 1, Cooldown: time.Minute}}
+	now := time.Now()
+
+	assert.True(t, b.allow(now))
+	b.record(false, now)
+	assert.False(t, b.allow(now.Add(time.Second)))
+
+	// after the cooldown only a single trial is allowed
+	later := now.Add(2 * time.Minute)
+	assert.True(t, b.allow(later))
+	assert.False(t, b.allow(later))
+
+	b.record(true, later)
+	assert.True(t, b.allow(later))
+}
@@ -32,7 +32,7 @@ func New() (*HTTPClient, error) {
 		return nil, errors.New(serverEnvKey + " was empty")
 	}
 
-	client, err := clients.New(serverURL, os.Getenv("REPUTATION_TOKEN"))
+	client, err := clients.New(serverURL, os.Getenv("REPUTATION_TOKEN"), "reputation")
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,117 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RetryPolicy configures how idempotent requests are retried
+type RetryPolicy struct {
+	// MaxRetries is the number of additional attempts made after the first, 0 disables retries
+	MaxRetries int
+	// BaseDelay is the backoff before the first retry, doubling with every subsequent retry
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff between attempts
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy is used by clients which do not configure their own
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 2,
+	BaseDelay:  100 * time.Millisecond,
+	MaxDelay:   2 * time.Second,
+}
+
+var countClientRetries = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "client_retries_total",
+		Help: "A counter for retried requests from the wrapped client.",
+	},
+	[]string{"service"},
+)
+
+func init() {
+	prometheus.MustRegister(countClientRetries)
+}
+
+// backoff returns the delay before the passed retry using "full jitter"
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.BaseDelay << uint(retry)
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay)))
+}
+
+// isIdempotent returns true if the request method may safely be sent more than once
+func isIdempotent(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// isRetryable returns true if the result of an attempt indicates a transient failure
+func isRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, ErrCircuitOpen) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryRoundTripper retries idempotent requests which failed transiently
+type retryRoundTripper struct {
+	next    http.RoundTripper
+	policy  RetryPolicy
+	service string
+}
+
+// RoundTrip implements http.RoundTripper
+func (rt *retryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt.policy.MaxRetries <= 0 || !isIdempotent(req.Method) || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return rt.next.RoundTrip(req)
+	}
+
+	attemptReq := req
+	for retry := 0; ; retry++ {
+		resp, err := rt.next.RoundTrip(attemptReq)
+		if retry >= rt.policy.MaxRetries || !isRetryable(resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			// drain so the connection can be reused
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		select {
+		case <-time.After(rt.policy.backoff(retry)):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+
+		attemptReq = req.Clone(req.Context())
+		if req.GetBody != nil {
+			attemptReq.Body, err = req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+		countClientRetries.With(prometheus.Labels{"service": rt.service}).Inc()
+	}
+}
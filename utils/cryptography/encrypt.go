@@ -0,0 +1,67 @@
+package cryptography
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+const (
+	// KeySize is the size of the secret key used for encryption
+	KeySize = 32
+	// NonceSize is the size of the nonce each message is sealed with
+	NonceSize = 24
+)
+
+// ErrDecryptionFailed is returned when a message cannot be opened with the key and nonce
+var ErrDecryptionFailed = errors.New("failed to decrypt message")
+
+// EncryptMessage seals the message with the secret key, returning the ciphertext and the random nonce used
+func EncryptMessage(key [KeySize]byte, message []byte) ([]byte, [NonceSize]byte, error) {
+	var nonce [NonceSize]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return nil, nonce, err
+	}
+	return secretbox.Seal(nil, message, &nonce, &key), nonce, nil
+}
+
+// DecryptMessage opens a ciphertext sealed by EncryptMessage
+func DecryptMessage(key [KeySize]byte, ciphertext []byte, nonce []byte) ([]byte, error) {
+	if len(nonce) != NonceSize {
+		return nil, errors.New("invalid nonce size")
+	}
+	var n [NonceSize]byte
+	copy(n[:], nonce)
+	message, ok := secretbox.Open(nil, ciphertext, &n, &key)
+	if !ok {
+		return nil, ErrDecryptionFailed
+	}
+	return message, nil
+}
+
+// EncryptionKeyFromEnvironment returns the hex encoded secret key in ENCRYPTION_KEY. A fixed development key
+// is used when running locally without one.
+func EncryptionKeyFromEnvironment() ([KeySize]byte, error) {
+	var key [KeySize]byte
+	encoded := os.Getenv("ENCRYPTION_KEY")
+	if len(encoded) == 0 {
+		if os.Getenv("ENV") == "local" {
+			return sha256.Sum256([]byte("bat-go local encryption key")), nil
+		}
+		return key, errors.New("ENCRYPTION_KEY must be set")
+	}
+	decoded, err := hex.DecodeString(encoded)
+	if err != nil {
+		return key, errors.New("ENCRYPTION_KEY must be hex encoded")
+	}
+	if len(decoded) != KeySize {
+		return key, errors.New("ENCRYPTION_KEY must be 32 bytes")
+	}
+	copy(key[:], decoded)
+	return key, nil
+}
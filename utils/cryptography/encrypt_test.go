@@ -0,0 +1,48 @@
+package cryptography
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptMessage(t *testing.T) {
+	var key [KeySize]byte
+	copy(key[:], "0123456789abcdef0123456789abcdef")
+
+	ciphertext, nonce, err := EncryptMessage(key, []byte("root key"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(ciphertext), "root key")
+
+	message, err := DecryptMessage(key, ciphertext, nonce[:])
+	require.NoError(t, err)
+	assert.Equal(t, "root key", string(message))
+
+	var other [KeySize]byte
+	_, err = DecryptMessage(other, ciphertext, nonce[:])
+	assert.Equal(t, ErrDecryptionFailed, err, "Decrypting with another key should fail")
+
+	_, err = DecryptMessage(key, ciphertext, nonce[:4])
+	assert.Error(t, err)
+}
+
+func TestEncryptionKeyFromEnvironment(t *testing.T) {
+	defer os.Setenv("ENCRYPTION_KEY", os.Getenv("ENCRYPTION_KEY"))
+	defer os.Setenv("ENV", os.Getenv("ENV"))
+
+	os.Setenv("ENV", "production")
+	os.Setenv("ENCRYPTION_KEY", "")
+	_, err := EncryptionKeyFromEnvironment()
+	assert.Error(t, err, "A key is required outside of local")
+
+	os.Setenv("ENCRYPTION_KEY", "abcd")
+	_, err = EncryptionKeyFromEnvironment()
+	assert.Error(t, err, "The key must be 32 bytes")
+
+	os.Setenv("ENCRYPTION_KEY", "3031323334353637383961626364656630313233343536373839616263646566")
+	key, err := EncryptionKeyFromEnvironment()
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789abcdef0123456789abcdef", string(key[:]))
+}
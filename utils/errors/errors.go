@@ -26,6 +26,11 @@ func (err *ErrorBundle) Cause() error {
 	return err.cause
 }
 
+// Unwrap returns the associated cause, allowing use with errors.Is and errors.As
+func (err *ErrorBundle) Unwrap() error {
+	return err.cause
+}
+
 // Error turns into an error
 func (err *ErrorBundle) Error() string {
 	return err.message
@@ -0,0 +1,142 @@
+// Package issuerpolicy is the rotation policy shared by the challenge bypass credential issuers
+// of promotions and of orders, so both services create new issuer versions under the same rules
+package issuerpolicy
+
+import (
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// DefaultMaxTokens is the number of credentials a new issuer version may sign, ~1M BAT
+	DefaultMaxTokens = 4000000
+	// Validity is how long a new issuer version is used to sign credentials
+	Validity = 365 * 24 * time.Hour
+	// RotationCapacity is the fraction of max tokens signed after which a new issuer version is created
+	RotationCapacity = 0.9
+	// RotationLeadTime is how long before expiry a new issuer version is created
+	RotationLeadTime = 7 * 24 * time.Hour
+	// RedemptionGracePeriod is how long after expiry credentials signed by an issuer version are still accepted
+	RedemptionGracePeriod = 90 * 24 * time.Hour
+)
+
+// Usage of an issuer version, the part of an issuer the rotation policy looks at
+type Usage struct {
+	ExpiresAt    time.Time
+	MaxTokens    int
+	TokensSigned int
+}
+
+// RotationReason returns why a new version of the issuer should be created, or an empty string if it should not be
+func (u Usage) RotationReason(now time.Time) string {
+	if float64(u.TokensSigned) >= RotationCapacity*float64(u.MaxTokens) {
+		return "capacity"
+	}
+	if now.Add(RotationLeadTime).After(u.ExpiresAt) {
+		return "expiry"
+	}
+	return ""
+}
+
+// IsUsable returns true if the issuer can still sign credentials
+func (u Usage) IsUsable(now time.Time) bool {
+	return u.TokensSigned < u.MaxTokens && now.Before(u.ExpiresAt)
+}
+
+// Metrics tracks the credentials signed by and the rotations of the issuers of one service
+type Metrics struct {
+	tokensSigned *prometheus.CounterVec
+	capacityUsed *prometheus.GaugeVec
+	rotations    *prometheus.CounterVec
+}
+
+// NewMetrics for the issuers of a service, the metric names are prefixed with the service
+func NewMetrics(prefix string) *Metrics {
+	return &Metrics{
+		tokensSigned: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: prefix + "_issuer_tokens_signed_total",
+			Help: "Count of credentials signed, broken down by issuer.",
+		}, []string{"issuer"}),
+		capacityUsed: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prefix + "_issuer_capacity_used_ratio",
+			Help: "Fraction of the max tokens of each issuer which have been signed.",
+		}, []string{"issuer"}),
+		rotations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: prefix + "_issuer_rotations_total",
+			Help: "Count of new issuer versions created, broken down by reason.",
+		}, []string{"reason"}),
+	}
+}
+
+// Collectors returns the metrics to register
+func (m *Metrics) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.tokensSigned, m.capacityUsed, m.rotations}
+}
+
+// ObserveTokensSigned updates the metrics of the named issuer after count credentials were signed
+func (m *Metrics) ObserveTokensSigned(name string, u Usage, count int) {
+	labels := prometheus.Labels{"issuer": name}
+	m.tokensSigned.With(labels).Add(float64(count))
+	if u.MaxTokens > 0 {
+		m.capacityUsed.With(labels).Set(float64(u.TokensSigned+count) / float64(u.MaxTokens))
+	}
+}
+
+// ObserveRotation counts a new issuer version created for the reason
+func (m *Metrics) ObserveRotation(reason string) {
+	m.rotations.With(prometheus.Labels{"reason": reason}).Inc()
+}
+
+// Version of an issuer which the rotation policy is applied to
+type Version interface {
+	// VersionNumber of the issuer, the first version is 1
+	VersionNumber() int
+	// Usage of the issuer version
+	Usage() Usage
+}
+
+// Versions gets and creates the versions of one issuer
+type Versions interface {
+	// Latest version of the issuer, nil if none has been created
+	Latest() (Version, error)
+	// Create the numbered version of the issuer
+	Create(number int) (Version, error)
+}
+
+// GetOrCreate gets the latest version of the issuer if one exists and otherwise creates the first,
+// a new version is created when the latest is near capacity or expiry
+func GetOrCreate(versions Versions, metrics *Metrics) (Version, error) {
+	latest, err := versions.Latest()
+	if err != nil {
+		return nil, err
+	}
+
+	if latest == nil {
+		return versions.Create(1)
+	}
+
+	now := time.Now()
+	reason := latest.Usage().RotationReason(now)
+	if reason == "" {
+		return latest, nil
+	}
+
+	next, err := versions.Create(latest.VersionNumber() + 1)
+	if err != nil {
+		// the issuer may have been rotated concurrently
+		rotated, rotatedErr := versions.Latest()
+		if rotatedErr == nil && rotated != nil && rotated.VersionNumber() > latest.VersionNumber() {
+			return rotated, nil
+		}
+		if latest.Usage().IsUsable(now) {
+			sentry.CaptureException(err)
+			return latest, nil
+		}
+		return nil, err
+	}
+
+	metrics.ObserveRotation(reason)
+	return next, nil
+}
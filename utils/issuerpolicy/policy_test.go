@@ -0,0 +1,93 @@
+package issuerpolicy
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotationReason(t *testing.T) {
+	now := time.Now()
+
+	usage := Usage{ExpiresAt: now.Add(Validity), MaxTokens: 100}
+	assert.Equal(t, "", usage.RotationReason(now))
+	assert.True(t, usage.IsUsable(now))
+
+	usage.TokensSigned = 90
+	assert.Equal(t, "capacity", usage.RotationReason(now))
+	assert.True(t, usage.IsUsable(now), "issuer can sign until max tokens is reached")
+
+	usage.TokensSigned = 100
+	assert.False(t, usage.IsUsable(now))
+
+	usage = Usage{ExpiresAt: now.Add(RotationLeadTime / 2), MaxTokens: 100}
+	assert.Equal(t, "expiry", usage.RotationReason(now))
+	assert.True(t, usage.IsUsable(now))
+	assert.False(t, usage.IsUsable(usage.ExpiresAt.Add(time.Second)))
+}
+
+type version struct {
+	number int
+	usage  Usage
+}
+
+func (v *version) VersionNumber() int {
+	return v.number
+}
+
+func (v *version) Usage() Usage {
+	return v.usage
+}
+
+// versions of an issuer kept in memory, failing creation with createErr when set
+type versions struct {
+	created   []*version
+	createErr error
+}
+
+func (v *versions) Latest() (Version, error) {
+	if len(v.created) == 0 {
+		return nil, nil
+	}
+	return v.created[len(v.created)-1], nil
+}
+
+func (v *versions) Create(number int) (Version, error) {
+	if v.createErr != nil {
+		return nil, v.createErr
+	}
+	created := &version{number: number, usage: Usage{ExpiresAt: time.Now().Add(Validity), MaxTokens: 100}}
+	v.created = append(v.created, created)
+	return created, nil
+}
+
+func TestGetOrCreate(t *testing.T) {
+	metrics := NewMetrics("test")
+	issuer := &versions{}
+
+	latest, err := GetOrCreate(issuer, metrics)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, latest.VersionNumber(), "the first version should be created")
+
+	latest, err = GetOrCreate(issuer, metrics)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, latest.VersionNumber())
+
+	issuer.created[0].usage.TokensSigned = 95
+	issuer.createErr = errors.New("unavailable")
+	latest, err = GetOrCreate(issuer, metrics)
+	assert.NoError(t, err, "the latest version should be used while it can sign")
+	assert.Equal(t, 1, latest.VersionNumber())
+
+	issuer.createErr = nil
+	latest, err = GetOrCreate(issuer, metrics)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, latest.VersionNumber(), "a new version should be created near capacity")
+
+	issuer.created[1].usage.TokensSigned = 100
+	issuer.createErr = errors.New("unavailable")
+	_, err = GetOrCreate(issuer, metrics)
+	assert.Error(t, err, "unusable versions should not be returned")
+}
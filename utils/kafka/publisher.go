@@ -0,0 +1,163 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+const (
+	// EventIDHeader carries the id of the event so consumers can deduplicate redelivered events
+	EventIDHeader = "event-id"
+	// eventSinkEnv selects where events are published
+	eventSinkEnv = "EVENT_SINK"
+)
+
+// Event is a message published to a topic
+type Event struct {
+	// ID identifies the event so consumers can deduplicate redelivered events
+	ID string `json:"id"`
+	// Key determines the partition of the event, events with the same key are delivered in order
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// EventPublisher publishes events to a single topic
+type EventPublisher interface {
+	Publish(ctx context.Context, events ...Event) error
+}
+
+// NewPublisherFromEnv creates the publisher selected by EVENT_SINK. Events are published to the brokers in
+// KAFKA_BROKERS by default, "stdout" writes them to standard output and "file:<path>" appends them to a file.
+func NewPublisherFromEnv(topic string) (EventPublisher, error) {
+	sink := os.Getenv(eventSinkEnv)
+	switch {
+	case sink == "" || sink == "kafka":
+		dialer, err := TLSDialer()
+		if err != nil {
+			return nil, err
+		}
+		return NewPublisher(strings.Split(os.Getenv("KAFKA_BROKERS"), ","), topic, dialer), nil
+	case sink == "stdout":
+		return NewWriterPublisher(os.Stdout, topic), nil
+	case strings.HasPrefix(sink, "file:"):
+		f, err := os.OpenFile(strings.TrimPrefix(sink, "file:"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, err
+		}
+		return NewWriterPublisher(f, topic), nil
+	}
+	return nil, fmt.Errorf("unknown %s %q", eventSinkEnv, sink)
+}
+
+// Publisher publishes events to a kafka topic
+type Publisher struct {
+	// Dialer used to connect to the brokers
+	Dialer *kafkago.Dialer
+	writer *kafkago.Writer
+}
+
+// NewPublisher creates a publisher writing to the topic on the brokers
+func NewPublisher(brokers []string, topic string, dialer *kafkago.Dialer) *Publisher {
+	return &Publisher{
+		Dialer: dialer,
+		writer: kafkago.NewWriter(kafkago.WriterConfig{
+			// by default we are waitng for acks from all nodes
+			Brokers: brokers,
+			Topic:   topic,
+			// events with the same key are written to the same partition to preserve their order
+			Balancer: &kafkago.Hash{},
+			Dialer:   dialer,
+			Logger:   kafkago.LoggerFunc(log.Printf), // FIXME
+		}),
+	}
+}
+
+// Publish the events, returning once they are acknowledged
+func (p *Publisher) Publish(ctx context.Context, events ...Event) error {
+	messages := make([]kafkago.Message, len(events))
+	for i, event := range events {
+		messages[i] = kafkago.Message{
+			Key:     event.Key,
+			Value:   event.Value,
+			Headers: []kafkago.Header{{Key: EventIDHeader, Value: []byte(event.ID)}},
+		}
+	}
+	return p.writer.WriteMessages(ctx, messages...)
+}
+
+// Close the connections to the brokers
+func (p *Publisher) Close() error {
+	return p.writer.Close()
+}
+
+// MemoryPublisher keeps published events in memory so they can be inspected in tests
+type MemoryPublisher struct {
+	mu     sync.Mutex
+	events []Event
+	// Err is returned by Publish when set, without recording the events
+	Err error
+}
+
+// NewMemoryPublisher creates an empty in memory publisher
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+// Publish records the events
+func (p *MemoryPublisher) Publish(ctx context.Context, events ...Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.Err != nil {
+		return p.Err
+	}
+	p.events = append(p.events, events...)
+	return nil
+}
+
+// Events returns the events published so far, in order
+func (p *MemoryPublisher) Events() []Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	events := make([]Event, len(p.events))
+	copy(events, p.events)
+	return events
+}
+
+// WriterPublisher writes each event as a line of JSON, for inspecting events in local runs
+type WriterPublisher struct {
+	mu    sync.Mutex
+	topic string
+	w     io.Writer
+}
+
+// NewWriterPublisher creates a publisher writing events of the topic to w
+func NewWriterPublisher(w io.Writer, topic string) *WriterPublisher {
+	return &WriterPublisher{topic: topic, w: w}
+}
+
+// writtenEvent is the line written for each event
+type writtenEvent struct {
+	Topic string `json:"topic"`
+	Event
+}
+
+// Publish writes the events
+func (p *WriterPublisher) Publish(ctx context.Context, events ...Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	encoder := json.NewEncoder(p.w)
+	for _, event := range events {
+		if err := encoder.Encode(writtenEvent{Topic: p.topic, Event: event}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryPublisher(t *testing.T) {
+	publisher := NewMemoryPublisher()
+	events := []Event{
+		{ID: "1", Key: []byte("brave.com"), Value: []byte("first")},
+		{ID: "2", Key: []byte("brave.com"), Value: []byte("second")},
+	}
+
+	publisher.Err = errors.New("broker unavailable")
+	assert.Error(t, publisher.Publish(context.Background(), events...))
+	assert.Len(t, publisher.Events(), 0, "Failed events should not be recorded")
+
+	publisher.Err = nil
+	assert.NoError(t, publisher.Publish(context.Background(), events[0]))
+	assert.NoError(t, publisher.Publish(context.Background(), events[1]))
+	assert.Equal(t, events, publisher.Events())
+}
+
+func TestWriterPublisher(t *testing.T) {
+	var buf bytes.Buffer
+	publisher := NewWriterPublisher(&buf, "test.topic")
+	assert.NoError(t, publisher.Publish(context.Background(),
+		Event{ID: "1", Key: []byte("brave.com"), Value: []byte("first")},
+		Event{ID: "2", Key: []byte("example.com"), Value: []byte("second")},
+	))
+
+	decoder := json.NewDecoder(&buf)
+	for _, id := range []string{"1", "2"} {
+		var written writtenEvent
+		assert.NoError(t, decoder.Decode(&written))
+		assert.Equal(t, "test.topic", written.Topic)
+		assert.Equal(t, id, written.ID)
+	}
+	assert.False(t, decoder.More(), "Each event should be written on its own line")
+}
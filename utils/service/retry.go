@@ -0,0 +1,82 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/brave-intl/bat-go/utils/clients"
+	errorutils "github.com/brave-intl/bat-go/utils/errors"
+)
+
+const (
+	// MaxJobAttempts is the number of times a job is attempted before it is dead-lettered
+	MaxJobAttempts = 10
+	// baseJobBackoff is the delay before the first retry of a failed job
+	baseJobBackoff = 5 * time.Second
+	// maxJobBackoff caps the delay between attempts of a failed job
+	maxJobBackoff = time.Hour
+)
+
+// terminalError marks an error as one which will not succeed if retried
+type terminalError struct {
+	cause error
+}
+
+func (err *terminalError) Error() string {
+	return err.cause.Error()
+}
+
+func (err *terminalError) Unwrap() error {
+	return err.cause
+}
+
+// Terminal marks the error as one which will not succeed if the job is retried
+func Terminal(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &terminalError{cause: err}
+}
+
+// IsTerminal returns true if retrying the job which returned err cannot succeed, either because it was
+// marked as terminal or because a client request was rejected with a non retryable status
+func IsTerminal(err error) bool {
+	var terminal *terminalError
+	if errors.As(err, &terminal) {
+		return true
+	}
+
+	// the response state may be on any bundle in the chain
+	for ; err != nil; err = errors.Unwrap(err) {
+		bundle, ok := err.(*errorutils.ErrorBundle)
+		if !ok {
+			continue
+		}
+		if state, ok := bundle.Data().(clients.HTTPState); ok {
+			switch {
+			case state.Status == http.StatusRequestTimeout, state.Status == http.StatusTooManyRequests:
+				return false
+			case state.Status >= 400 && state.Status < 500:
+				return true
+			}
+			return false
+		}
+	}
+	return false
+}
+
+// Backoff returns the delay before the next attempt of a job which has failed attempts times
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		return 0
+	}
+	backoff := baseJobBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= maxJobBackoff {
+			return maxJobBackoff
+		}
+	}
+	return backoff
+}
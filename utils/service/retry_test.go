@@ -0,0 +1,34 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/brave-intl/bat-go/utils/clients"
+	errorutils "github.com/brave-intl/bat-go/utils/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTerminal(t *testing.T) {
+	cause := errors.New("failed")
+	assert.False(t, IsTerminal(cause))
+	assert.True(t, IsTerminal(Terminal(cause)))
+	assert.True(t, IsTerminal(fmt.Errorf("wrapped: %w", Terminal(cause))))
+	assert.True(t, errors.Is(Terminal(cause), cause), "terminal errors unwrap to their cause")
+	assert.Nil(t, Terminal(nil))
+
+	assert.True(t, IsTerminal(clients.NewHTTPError(cause, "response", http.StatusConflict, nil)))
+	assert.True(t, IsTerminal(errorutils.Wrap(clients.NewHTTPError(cause, "response", http.StatusBadRequest, nil), "error redeeming")))
+	assert.False(t, IsTerminal(clients.NewHTTPError(cause, "response", http.StatusTooManyRequests, nil)))
+	assert.False(t, IsTerminal(clients.NewHTTPError(cause, "response", http.StatusBadGateway, nil)))
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, time.Duration(0), Backoff(0))
+	assert.Equal(t, baseJobBackoff, Backoff(1))
+	assert.Equal(t, 4*baseJobBackoff, Backoff(3))
+	assert.Equal(t, maxJobBackoff, Backoff(MaxJobAttempts*10))
+}
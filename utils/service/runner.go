@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// listenerPingInterval is how often an idle listener connection is checked
+	listenerPingInterval = 90 * time.Second
+)
+
+var (
+	jobRunsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "job_runs_total",
+			Help: "A counter for the number of job runs, broken down by job and whether work was attempted",
+		},
+		[]string{"job", "attempted"},
+	)
+	jobRunFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "job_run_failures_total",
+			Help: "A counter for the number of job runs which returned an error or panicked",
+		},
+		[]string{"job", "reason"},
+	)
+	jobRunDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "job_run_duration_seconds",
+			Help:    "A histogram of the latency of job runs",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"job"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		jobRunsTotal,
+		jobRunFailuresTotal,
+		jobRunDuration,
+	)
+}
+
+// scheduledJob is a registered job along with the channel used to wake its idle workers
+type scheduledJob struct {
+	Job
+	wake chan struct{}
+}
+
+// Runner runs the jobs registered by services. Each job runs on a bounded number of workers which
+// run it again immediately while there is work, otherwise once the cadence elapses or they are woken
+// by a notification on the job channel.
+type Runner struct {
+	mu       sync.Mutex
+	jobs     []*scheduledJob
+	listener *pq.Listener
+	started  bool
+	stop     chan struct{}
+	stopOnce sync.Once
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewRunner creates a job runner
+func NewRunner() *Runner {
+	return &Runner{
+		stop: make(chan struct{}),
+	}
+}
+
+// Register jobs with the runner, jobs must be registered before the runner is started
+func (r *Runner) Register(jobs ...Job) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started {
+		panic("jobs must be registered before the runner is started")
+	}
+
+	for _, job := range jobs {
+		workers := job.Workers
+		if workers < 1 {
+			workers = 1
+		}
+		job.Workers = workers
+		r.jobs = append(r.jobs, &scheduledJob{
+			Job:  job,
+			wake: make(chan struct{}, workers),
+		})
+	}
+}
+
+// Listen for notifications on the channels of the registered jobs, waking their workers as soon as
+// work is queued instead of waiting for the cadence to elapse
+func (r *Runner) Listen(databaseURL string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	listener := pq.NewListener(databaseURL, 10*time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Error().Err(err).Int("event", int(event)).Msg("job listener connection event")
+		}
+	})
+
+	listening := map[string]bool{}
+	for _, job := range r.jobs {
+		if job.Channel == "" || listening[job.Channel] {
+			continue
+		}
+		if err := listener.Listen(job.Channel); err != nil {
+			_ = listener.Close()
+			return fmt.Errorf("failed to listen on job channel %s: %w", job.Channel, err)
+		}
+		listening[job.Channel] = true
+	}
+
+	r.listener = listener
+	return nil
+}
+
+// Start the workers of each registered job, jobs are passed a context derived from ctx which is only
+// cancelled if they do not finish before the shutdown deadline
+func (r *Runner) Start(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started {
+		return
+	}
+	r.started = true
+
+	ctx, r.cancel = context.WithCancel(ctx)
+	for _, job := range r.jobs {
+		for i := 0; i < job.Workers; i++ {
+			r.wg.Add(1)
+			go r.work(ctx, job)
+		}
+	}
+
+	if r.listener != nil {
+		go r.listen()
+	}
+}
+
+// Wake the idle workers of jobs on the passed channel, or of every job if the channel is empty
+func (r *Runner) Wake(channel string) {
+	for _, job := range r.jobs {
+		if channel == "" || job.Channel == channel {
+			wake(job)
+		}
+	}
+}
+
+// wake up to the number of workers of the job, workers which are busy will run the job again anyway
+func wake(job *scheduledJob) {
+	for i := 0; i < job.Workers; i++ {
+		select {
+		case job.wake <- struct{}{}:
+		default:
+			return
+		}
+	}
+}
+
+// Shutdown stops scheduling job runs and waits for runs in progress to finish. If ctx is done first
+// the context passed to the running jobs is cancelled and the error of ctx is returned.
+func (r *Runner) Shutdown(ctx context.Context) error {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+		if r.listener != nil {
+			_ = r.listener.Close()
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		if r.cancel != nil {
+			r.cancel()
+		}
+		return ctx.Err()
+	}
+}
+
+// listen dispatches notifications to the workers of the matching jobs
+func (r *Runner) listen() {
+	for {
+		select {
+		case <-r.stop:
+			return
+		case n, ok := <-r.listener.Notify:
+			if !ok {
+				return
+			}
+			if n == nil {
+				// the connection was re-established, notifications may have been missed
+				r.Wake("")
+				continue
+			}
+			r.Wake(n.Channel)
+		case <-time.After(listenerPingInterval):
+			go func() {
+				if err := r.listener.Ping(); err != nil {
+					log.Error().Err(err).Msg("job listener ping failed")
+				}
+			}()
+		}
+	}
+}
+
+// work runs the job until the runner is stopped, running again immediately while work was done
+func (r *Runner) work(ctx context.Context, job *scheduledJob) {
+	defer r.wg.Done()
+	for {
+		select {
+		case <-r.stop:
+			return
+		default:
+		}
+
+		attempted, err := r.run(ctx, job)
+		if attempted && err == nil {
+			continue
+		}
+
+		select {
+		case <-r.stop:
+			return
+		case <-job.wake:
+		case <-time.After(job.Cadence):
+		}
+	}
+}
+
+// run the job once, recovering from and reporting any panic
+func (r *Runner) run(ctx context.Context, job *scheduledJob) (attempted bool, err error) {
+	start := time.Now()
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("job %s panicked: %v", job.Name, rec)
+			log.Ctx(ctx).Error().Err(err).Str("job", job.Name).Bytes("stack", debug.Stack()).Msg("job panicked")
+			jobRunFailuresTotal.With(prometheus.Labels{"job": job.Name, "reason": "panic"}).Inc()
+		} else if err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("job", job.Name).Msg("job failed")
+			jobRunFailuresTotal.With(prometheus.Labels{"job": job.Name, "reason": "error"}).Inc()
+		}
+		if err != nil {
+			sentry.CaptureException(err)
+		}
+
+		jobRunsTotal.With(prometheus.Labels{"job": job.Name, "attempted": strconv.FormatBool(attempted)}).Inc()
+		jobRunDuration.With(prometheus.Labels{"job": job.Name}).Observe(time.Since(start).Seconds())
+	}()
+
+	return job.Func(ctx)
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunnerWake(t *testing.T) {
+	runs := make(chan struct{}, 10)
+	runner := NewRunner()
+	runner.Register(Job{
+		Name: "wake",
+		Func: func(ctx context.Context) (bool, error) {
+			runs <- struct{}{}
+			return false, nil
+		},
+		Cadence: time.Hour,
+		Channel: "wake",
+	})
+	runner.Start(context.Background())
+	defer func() {
+		assert.NoError(t, runner.Shutdown(context.Background()))
+	}()
+
+	// jobs run once on start
+	<-runs
+
+	runner.Wake("other")
+	select {
+	case <-runs:
+		t.Fatal("job should not be woken by other channels")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	runner.Wake("wake")
+	select {
+	case <-runs:
+	case <-time.After(time.Second):
+		t.Fatal("job should be woken by its channel")
+	}
+}
+
+func TestRunnerConcurrency(t *testing.T) {
+	var running, maxRunning, total int32
+	runner := NewRunner()
+	runner.Register(Job{
+		Name: "bounded",
+		Func: func(ctx context.Context) (bool, error) {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				max := atomic.LoadInt32(&maxRunning)
+				if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			// keep running while there is work
+			return atomic.AddInt32(&total, 1) < 20, nil
+		},
+		Cadence: time.Hour,
+		Workers: 3,
+	})
+	runner.Start(context.Background())
+
+	time.Sleep(200 * time.Millisecond)
+	assert.NoError(t, runner.Shutdown(context.Background()))
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(&maxRunning), "job should run on each of its workers and no more")
+	assert.True(t, atomic.LoadInt32(&total) >= 20, "job should run again immediately while it attempts work")
+}
+
+func TestRunnerPanic(t *testing.T) {
+	var calls int32
+	runner := NewRunner()
+	runner.Register(Job{
+		Name: "panic",
+		Func: func(ctx context.Context) (bool, error) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				panic("failed")
+			}
+			return false, nil
+		},
+		Cadence: time.Millisecond,
+	})
+	runner.Start(context.Background())
+
+	time.Sleep(50 * time.Millisecond)
+	assert.NoError(t, runner.Shutdown(context.Background()))
+	assert.True(t, atomic.LoadInt32(&calls) > 1, "worker should recover and keep running the job")
+}
+
+func TestRunnerShutdown(t *testing.T) {
+	started := make(chan struct{})
+	var finished int32
+	runner := NewRunner()
+	runner.Register(Job{
+		Name: "drain",
+		Func: func(ctx context.Context) (bool, error) {
+			close(started)
+			time.Sleep(50 * time.Millisecond)
+			atomic.StoreInt32(&finished, 1)
+			return true, nil
+		},
+		Cadence: time.Hour,
+	})
+	runner.Start(context.Background())
+	<-started
+
+	// runs in progress finish, no further runs are started
+	assert.NoError(t, runner.Shutdown(context.Background()))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&finished))
+
+	blockedStarted := make(chan struct{})
+	blocked := NewRunner()
+	blocked.Register(Job{
+		Name: "blocked",
+		Func: func(ctx context.Context) (bool, error) {
+			close(blockedStarted)
+			<-ctx.Done()
+			return false, ctx.Err()
+		},
+		Cadence: time.Hour,
+	})
+	blocked.Start(context.Background())
+	<-blockedStarted
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, blocked.Shutdown(ctx), "runs which do not finish before the deadline are cancelled")
+}
@@ -10,9 +10,13 @@ type JobFunc func(context.Context) (bool, error)
 
 // Job - Structure defining what a common job meta-information
 type Job struct {
+	// Name identifies the job in logs and metrics
+	Name    string
 	Func    JobFunc
 	Workers int
 	Cadence time.Duration
+	// Channel is the postgres notification channel on which work for the job is announced, if any
+	Channel string
 }
 
 // JobService - interface defining what can have jobs
@@ -1,24 +1,158 @@
 package provider
 
 import (
+	"crypto"
 	"fmt"
+	"sort"
+	"sync"
 
+	"github.com/asaskevich/govalidator"
+	"github.com/brave-intl/bat-go/utils/httpsignature"
 	"github.com/brave-intl/bat-go/wallet"
 	"github.com/brave-intl/bat-go/wallet/provider/uphold"
 )
 
+// Capability is an optional feature which a wallet provider may support
+type Capability string
+
+const (
+	// AnonCardVerify indicates the provider wallets implement wallet.AnonCardVerifier
+	AnonCardVerify Capability = "anon-card-verify"
+	// PrepareSubmitConfirm indicates the provider wallets implement wallet.TransactionPreparer
+	PrepareSubmitConfirm Capability = "prepare-submit-confirm"
+	// CardAddressCreation indicates the provider wallets implement wallet.CardAddressCreator
+	CardAddressCreation Capability = "card-address-creation"
+)
+
+// Constructor creates a wallet for the provider from the passed wallet info
+// signer and verifier are optional, a wallet without a signer cannot move funds
+type Constructor func(info wallet.Info, signer crypto.Signer, verifier httpsignature.Verifier) (wallet.Wallet, error)
+
+type registration struct {
+	constructor  Constructor
+	capabilities map[Capability]bool
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]registration{}
+)
+
+func init() {
+	Register("uphold", func(info wallet.Info, signer crypto.Signer, verifier httpsignature.Verifier) (wallet.Wallet, error) {
+		var uW *uphold.Wallet
+		var err error
+		if signer == nil {
+			// TODO once we can retrieve public key info from uphold
+			// err = uW.UpdatePublicKey()
+			uW, err = uphold.FromWalletInfo(info)
+		} else {
+			uW, err = uphold.New(info, signer, verifier)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return uW, nil
+	}, AnonCardVerify, PrepareSubmitConfirm, CardAddressCreation)
+
+	govalidator.TagMap["walletprovider"] = govalidator.Validator(IsRegistered)
+}
+
+// Register a wallet provider under name along with the capabilities its wallets support
+// Register panics if a provider with the same name has already been registered
+func Register(name string, constructor Constructor, capabilities ...Capability) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("wallet provider %s is already registered", name))
+	}
+	r := registration{constructor: constructor, capabilities: map[Capability]bool{}}
+	for _, capability := range capabilities {
+		r.capabilities[capability] = true
+	}
+	registry[name] = r
+}
+
+// Unregister removes the wallet provider registered under name, if any
+func Unregister(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	delete(registry, name)
+}
+
+// IsRegistered returns true if a wallet provider has been registered under name
+func IsRegistered(name string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	_, ok := registry[name]
+	return ok
+}
+
+// Names returns the sorted names of all registered wallet providers
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := []string{}
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Supports returns true if the named provider declared support for capability
+func Supports(name string, capability Capability) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	r, ok := registry[name]
+	return ok && r.capabilities[capability]
+}
+
 // GetWallet returns the wallet corresponding to the passed wallet info
 func GetWallet(info wallet.Info) (wallet.Wallet, error) {
-	switch info.Provider {
-	case "uphold":
-		uW, err := uphold.FromWalletInfo(info)
-		if err != nil {
-			return uW, err
+	return NewWallet(info, nil, nil)
+}
+
+// NewWallet returns the wallet corresponding to the passed wallet info, able to sign with signer
+func NewWallet(info wallet.Info, signer crypto.Signer, verifier httpsignature.Verifier) (wallet.Wallet, error) {
+	registryMu.RLock()
+	r, ok := registry[info.Provider]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("No such supported wallet provider %s", info.Provider)
+	}
+
+	w, err := r.constructor(info, signer, verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	// ensure the wallet actually implements what the provider declared so callers can rely on Supports
+	for capability := range r.capabilities {
+		if !implements(w, capability) {
+			return nil, fmt.Errorf("wallet provider %s declares %s but its wallet does not implement it", info.Provider, capability)
 		}
-		// TODO once we can retrieve public key info from uphold
-		// err = uW.UpdatePublicKey()
-		return uW, err
+	}
+	return w, nil
+}
+
+func implements(w wallet.Wallet, capability Capability) bool {
+	switch capability {
+	case AnonCardVerify:
+		_, ok := w.(wallet.AnonCardVerifier)
+		return ok
+	case PrepareSubmitConfirm:
+		_, ok := w.(wallet.TransactionPreparer)
+		return ok
+	case CardAddressCreation:
+		_, ok := w.(wallet.CardAddressCreator)
+		return ok
 	default:
-		return nil, fmt.Errorf("No such supported wallet provider %s", info.Provider)
+		return false
 	}
 }
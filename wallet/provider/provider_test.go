@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"crypto"
+	"testing"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/brave-intl/bat-go/utils/altcurrency"
+	"github.com/brave-intl/bat-go/utils/httpsignature"
+	"github.com/brave-intl/bat-go/wallet"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type minimalWallet struct {
+	wallet.Wallet
+}
+
+func TestRegistry(t *testing.T) {
+	assert.True(t, IsRegistered("uphold"))
+	assert.True(t, Supports("uphold", AnonCardVerify))
+	assert.True(t, Supports("uphold", PrepareSubmitConfirm))
+	assert.False(t, Supports("nonexistent", AnonCardVerify))
+
+	defer Unregister("minimal")
+	defer Unregister("liar")
+	Register("minimal", func(info wallet.Info, signer crypto.Signer, verifier httpsignature.Verifier) (wallet.Wallet, error) {
+		return &minimalWallet{}, nil
+	})
+	Register("liar", func(info wallet.Info, signer crypto.Signer, verifier httpsignature.Verifier) (wallet.Wallet, error) {
+		return &minimalWallet{}, nil
+	}, AnonCardVerify)
+
+	assert.Equal(t, []string{"liar", "minimal", "uphold"}, Names())
+	assert.False(t, Supports("minimal", AnonCardVerify))
+	assert.Panics(t, func() {
+		Register("minimal", nil)
+	})
+
+	_, err := GetWallet(wallet.Info{Provider: "minimal"})
+	assert.NoError(t, err)
+
+	_, err = GetWallet(wallet.Info{Provider: "liar"})
+	assert.Error(t, err, "wallets must implement the capabilities their provider declares")
+
+	_, err = GetWallet(wallet.Info{Provider: "nonexistent"})
+	assert.Error(t, err)
+
+	Unregister("liar")
+	assert.False(t, IsRegistered("liar"))
+
+	bat := altcurrency.BAT
+	info := wallet.Info{Provider: "uphold", ProviderID: uuid.NewV4().String(), AltCurrency: &bat}
+	w, err := GetWallet(info)
+	assert.NoError(t, err)
+	_, ok := w.(wallet.AnonCardVerifier)
+	assert.True(t, ok)
+}
+
+func TestProviderValidation(t *testing.T) {
+	info := wallet.Info{
+		ID:         uuid.NewV4().String(),
+		Provider:   "uphold",
+		ProviderID: uuid.NewV4().String(),
+		PublicKey:  "424073b208e97af51cab7a389bcfe6942a3b7c7520fe9dab84f311f7846f5fcf",
+	}
+	valid, err := govalidator.ValidateStruct(info)
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	info.Provider = "unregistered"
+	valid, err = govalidator.ValidateStruct(info)
+	assert.Error(t, err)
+	assert.False(t, valid)
+}
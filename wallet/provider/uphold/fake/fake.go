@@ -0,0 +1,601 @@
+// Package fake provides an in-process stand-in for the subset of the Uphold API used by the uphold wallet provider.
+// It simulates cards, balances, the transaction create / commit lifecycle and HTTP signature verification,
+// returning the same error payloads as the real API so tests can exercise transfers without network access.
+package fake
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brave-intl/bat-go/utils/httpsignature"
+	"github.com/brave-intl/bat-go/utils/requestutils"
+	"github.com/go-chi/chi"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	dateFormat = "2006-01-02T15:04:05.000Z"
+	// DefaultQuoteTTL is how long a submitted but uncommitted transaction may be committed for
+	DefaultQuoteTTL = 30 * time.Second
+)
+
+// Card is a simulated Uphold card
+type Card struct {
+	ID        string
+	Label     string
+	Currency  string
+	PublicKey httpsignature.Verifier
+	Balance   decimal.Decimal
+	Addresses map[string]string
+}
+
+// Transaction is a simulated Uphold transaction
+type Transaction struct {
+	ID          string
+	Status      string
+	Origin      string
+	Destination string
+	Amount      decimal.Decimal
+	Currency    string
+	Message     string
+	CreatedAt   time.Time
+	ValidUntil  time.Time
+}
+
+// Server is a fake Uphold API
+type Server struct {
+	*httptest.Server
+	// QuoteTTL is how long submitted transactions remain committable
+	QuoteTTL time.Duration
+
+	mu           sync.Mutex
+	cards        map[string]*Card
+	transactions map[string]*Transaction
+	// order of committed transactions, oldest first
+	committed []string
+}
+
+// New starts and returns a new fake Uphold API server, callers should Close it when done
+func New() *Server {
+	s := &Server{
+		QuoteTTL:     DefaultQuoteTTL,
+		cards:        map[string]*Card{},
+		transactions: map[string]*Transaction{},
+	}
+	s.Server = httptest.NewServer(s.router())
+	return s
+}
+
+func (s *Server) router() http.Handler {
+	r := chi.NewRouter()
+	r.Use(requireAuthorization)
+	r.Post("/v0/me/cards", s.createCard)
+	r.Get("/v0/me/cards/{cardID}", s.getCard)
+	r.Post("/v0/me/cards/{cardID}/addresses", s.createCardAddress)
+	r.Get("/v0/me/cards/{cardID}/transactions", s.listTransactions)
+	r.Post("/v0/me/cards/{cardID}/transactions", s.createTransaction)
+	r.Post("/v0/me/cards/{cardID}/transactions/{transactionID}/commit", s.commitTransaction)
+	r.Get("/v0/me/transactions/{transactionID}", s.getTransaction)
+	return r
+}
+
+// CreateCard adds a card with the passed public key and initial balance, returning the card id
+func (s *Server) CreateCard(currency string, publicKey httpsignature.Verifier, balance decimal.Decimal) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	card := &Card{
+		ID:        uuid.NewV4().String(),
+		Currency:  currency,
+		PublicKey: publicKey,
+		Balance:   balance,
+		Addresses: map[string]string{},
+	}
+	s.cards[card.ID] = card
+	return card.ID
+}
+
+// SetBalance of the card with id cardID
+func (s *Server) SetBalance(cardID string, balance decimal.Decimal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if card, ok := s.cards[cardID]; ok {
+		card.Balance = balance
+	}
+}
+
+// Balance of the card with id cardID
+func (s *Server) Balance(cardID string) decimal.Decimal {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if card, ok := s.cards[cardID]; ok {
+		return card.Balance
+	}
+	return decimal.Zero
+}
+
+// Transaction returns a copy of the transaction with id, including those which are not yet committed
+func (s *Server) Transaction(id string) (Transaction, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, ok := s.transactions[id]
+	if !ok {
+		return Transaction{}, false
+	}
+	return *tx, true
+}
+
+// SetTransactionStatus overrides the status of a transaction, for example to simulate a failed payout
+func (s *Server) SetTransactionStatus(id, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if tx, ok := s.transactions[id]; ok {
+		tx.Status = status
+	}
+}
+
+func requireAuthorization(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Basic ") {
+			writeError(w, http.StatusUnauthorized, apiError{Code: "unauthorized", Message: "Unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type baseError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type denominationValidationErrors struct {
+	AmountError []baseError `json:"amount,omitempty"`
+}
+
+type denominationErrors struct {
+	Code             string                        `json:"code,omitempty"`
+	ValidationErrors *denominationValidationErrors `json:"errors,omitempty"`
+}
+
+type validationErrors struct {
+	SignatureError     []baseError         `json:"signature,omitempty"`
+	DenominationErrors *denominationErrors `json:"denomination,omitempty"`
+}
+
+type apiError struct {
+	Message          string            `json:"error,omitempty"`
+	Code             string            `json:"code"`
+	ValidationErrors *validationErrors `json:"errors,omitempty"`
+}
+
+func notFound(w http.ResponseWriter, what string) {
+	writeError(w, http.StatusNotFound, apiError{Code: "not_found", Message: what + " not found"})
+}
+
+func invalidSignature(w http.ResponseWriter) {
+	writeError(w, http.StatusBadRequest, apiError{
+		Code: "validation_failed",
+		ValidationErrors: &validationErrors{
+			SignatureError: []baseError{{Code: "invalid", Message: "This value is not a valid signature"}},
+		},
+	})
+}
+
+func insufficientFunds(w http.ResponseWriter) {
+	writeError(w, http.StatusBadRequest, apiError{
+		Code: "validation_failed",
+		ValidationErrors: &validationErrors{
+			DenominationErrors: &denominationErrors{
+				Code: "validation_failed",
+				ValidationErrors: &denominationValidationErrors{
+					AmountError: []baseError{{Code: "sufficient_funds", Message: "Not enough funds for the specified amount"}},
+				},
+			},
+		},
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, e apiError) {
+	writeJSON(w, status, e)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// verifySignature checks the request was signed over its digest by verifier
+func verifySignature(r *http.Request, verifier httpsignature.Verifier) bool {
+	if verifier == nil {
+		return false
+	}
+	var sig httpsignature.Signature
+	err := sig.UnmarshalText([]byte(r.Header.Get("Signature")))
+	if err != nil {
+		return false
+	}
+	coversDigest := false
+	for _, header := range sig.Headers {
+		if header == httpsignature.DigestHeader {
+			coversDigest = true
+		}
+	}
+	if !coversDigest {
+		return false
+	}
+
+	// the digest sent must match the body actually sent
+	sent := r.Header.Get("Digest")
+	r.Header.Del("Digest")
+	valid, err := sig.Verify(verifier, crypto.Hash(0), r)
+	return err == nil && valid && sent == r.Header.Get("Digest")
+}
+
+type cardDetails struct {
+	Available decimal.Decimal `json:"available"`
+	Balance   decimal.Decimal `json:"balance"`
+	Currency  string          `json:"currency"`
+	ID        string          `json:"id"`
+	Label     string          `json:"label"`
+	Settings  struct {
+		Protected bool `json:"protected,omitempty"`
+	} `json:"settings"`
+}
+
+func (card *Card) details() cardDetails {
+	return cardDetails{
+		Available: card.Balance,
+		Balance:   card.Balance,
+		Currency:  card.Currency,
+		ID:        card.ID,
+		Label:     card.Label,
+	}
+}
+
+type createCardRequest struct {
+	Label     string `json:"label"`
+	Currency  string `json:"currency"`
+	PublicKey string `json:"publicKey"`
+}
+
+func (s *Server) createCard(w http.ResponseWriter, r *http.Request) {
+	body, err := requestutils.Read(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, apiError{Code: "bad_request", Message: err.Error()})
+		return
+	}
+	var req createCardRequest
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, apiError{Code: "bad_request", Message: err.Error()})
+		return
+	}
+
+	var publicKey httpsignature.Ed25519PubKey
+	publicKey, err = hex.DecodeString(req.PublicKey)
+	if err != nil {
+		invalidSignature(w)
+		return
+	}
+
+	// registrations are signed by the key being registered
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if !verifySignature(r, publicKey) {
+		invalidSignature(w)
+		return
+	}
+
+	s.mu.Lock()
+	card := &Card{
+		ID:        uuid.NewV4().String(),
+		Label:     req.Label,
+		Currency:  req.Currency,
+		PublicKey: publicKey,
+		Balance:   decimal.Zero,
+		Addresses: map[string]string{},
+	}
+	s.cards[card.ID] = card
+	details := card.details()
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, details)
+}
+
+func (s *Server) getCard(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	card, ok := s.cards[chi.URLParam(r, "cardID")]
+	var details cardDetails
+	if ok {
+		details = card.details()
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		notFound(w, "Card")
+		return
+	}
+	writeJSON(w, http.StatusOK, details)
+}
+
+type createCardAddressRequest struct {
+	Network string `json:"network"`
+}
+
+func (s *Server) createCardAddress(w http.ResponseWriter, r *http.Request) {
+	var req createCardAddressRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || len(req.Network) == 0 {
+		writeError(w, http.StatusBadRequest, apiError{Code: "validation_failed", Message: "network is required"})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	card, ok := s.cards[chi.URLParam(r, "cardID")]
+	if !ok {
+		notFound(w, "Card")
+		return
+	}
+	address, ok := card.Addresses[req.Network]
+	if !ok {
+		address = uuid.NewV4().String()
+		card.Addresses[req.Network] = address
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"id": address})
+}
+
+type denomination struct {
+	Amount   decimal.Decimal `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+type transactionRequest struct {
+	Denomination denomination `json:"denomination"`
+	Destination  string       `json:"destination"`
+	Message      string       `json:"message,omitempty"`
+}
+
+type transactionNode struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type transactionParty struct {
+	Type        string          `json:"type"`
+	CardID      string          `json:"CardId,omitempty"`
+	Node        transactionNode `json:"node,omitempty"`
+	Currency    string          `json:"currency"`
+	Amount      decimal.Decimal `json:"amount"`
+	ExchangeFee decimal.Decimal `json:"commission"`
+	TransferFee decimal.Decimal `json:"fee"`
+}
+
+type transactionParams struct {
+	TTL int64 `json:"ttl"`
+}
+
+type transactionResponse struct {
+	Status       string            `json:"status"`
+	ID           string            `json:"id"`
+	Denomination denomination      `json:"denomination"`
+	Destination  transactionParty  `json:"destination"`
+	Origin       transactionParty  `json:"origin"`
+	Params       transactionParams `json:"params"`
+	CreatedAt    string            `json:"createdAt"`
+	Message      string            `json:"message"`
+}
+
+// response must be called with the server lock held
+func (s *Server) response(tx *Transaction) transactionResponse {
+	resp := transactionResponse{
+		Status:       tx.Status,
+		ID:           tx.ID,
+		Denomination: denomination{Amount: tx.Amount, Currency: tx.Currency},
+		Origin: transactionParty{
+			Type:     "card",
+			CardID:   tx.Origin,
+			Currency: tx.Currency,
+			Amount:   tx.Amount,
+		},
+		Destination: transactionParty{
+			Currency: tx.Currency,
+			Amount:   tx.Amount,
+		},
+		CreatedAt: tx.CreatedAt.UTC().Format(dateFormat),
+		Message:   tx.Message,
+	}
+	if _, ok := s.cards[tx.Destination]; ok {
+		resp.Destination.Type = "card"
+		resp.Destination.CardID = tx.Destination
+	} else {
+		resp.Destination.Type = "anonymous"
+		resp.Destination.Node = transactionNode{Type: "anonymous", ID: tx.Destination}
+	}
+	if tx.Status == "pending" {
+		resp.Params.TTL = int64(time.Until(tx.ValidUntil) / time.Millisecond)
+	}
+	return resp
+}
+
+// commit must be called with the server lock held
+func (s *Server) commit(w http.ResponseWriter, tx *Transaction) {
+	origin := s.cards[tx.Origin]
+	if origin.Balance.LessThan(tx.Amount) {
+		delete(s.transactions, tx.ID)
+		insufficientFunds(w)
+		return
+	}
+	origin.Balance = origin.Balance.Sub(tx.Amount)
+	if destination, ok := s.cards[tx.Destination]; ok {
+		destination.Balance = destination.Balance.Add(tx.Amount)
+	}
+	tx.Status = "completed"
+	s.committed = append(s.committed, tx.ID)
+
+	writeJSON(w, http.StatusOK, s.response(tx))
+}
+
+func (s *Server) createTransaction(w http.ResponseWriter, r *http.Request) {
+	body, err := requestutils.Read(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, apiError{Code: "bad_request", Message: err.Error()})
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	card, ok := s.cards[chi.URLParam(r, "cardID")]
+	if !ok {
+		notFound(w, "Card")
+		return
+	}
+	if !verifySignature(r, card.PublicKey) {
+		invalidSignature(w)
+		return
+	}
+
+	var req transactionRequest
+	err = json.Unmarshal(body, &req)
+	if err != nil || len(req.Destination) == 0 {
+		writeError(w, http.StatusBadRequest, apiError{Code: "validation_failed", Message: "invalid transaction"})
+		return
+	}
+	if req.Denomination.Currency != card.Currency || !req.Denomination.Amount.GreaterThan(decimal.Zero) {
+		writeError(w, http.StatusBadRequest, apiError{
+			Code: "validation_failed",
+			ValidationErrors: &validationErrors{
+				DenominationErrors: &denominationErrors{Code: "validation_failed"},
+			},
+		})
+		return
+	}
+	if card.Balance.LessThan(req.Denomination.Amount) {
+		insufficientFunds(w)
+		return
+	}
+
+	now := time.Now()
+	tx := &Transaction{
+		ID:          uuid.NewV4().String(),
+		Status:      "pending",
+		Origin:      card.ID,
+		Destination: req.Destination,
+		Amount:      req.Denomination.Amount,
+		Currency:    req.Denomination.Currency,
+		Message:     req.Message,
+		CreatedAt:   now,
+		ValidUntil:  now.Add(s.QuoteTTL),
+	}
+	s.transactions[tx.ID] = tx
+
+	if commit, _ := strconv.ParseBool(r.URL.Query().Get("commit")); commit {
+		s.commit(w, tx)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.response(tx))
+}
+
+func (s *Server) commitTransaction(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, ok := s.transactions[chi.URLParam(r, "transactionID")]
+	if !ok || tx.Origin != chi.URLParam(r, "cardID") {
+		notFound(w, "Transaction")
+		return
+	}
+	if tx.Status != "pending" {
+		writeError(w, http.StatusConflict, apiError{Code: "transaction_already_exists", Message: "Transaction already exists"})
+		return
+	}
+	if time.Now().After(tx.ValidUntil) {
+		// expired quotes are discarded
+		delete(s.transactions, tx.ID)
+		notFound(w, "Transaction")
+		return
+	}
+	s.commit(w, tx)
+}
+
+func (s *Server) getTransaction(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, ok := s.transactions[chi.URLParam(r, "transactionID")]
+	// uncommitted transactions are not visible
+	if !ok || tx.Status == "pending" {
+		notFound(w, "Transaction")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.response(tx))
+}
+
+func (s *Server) listTransactions(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cardID := chi.URLParam(r, "cardID")
+	if _, ok := s.cards[cardID]; !ok {
+		notFound(w, "Card")
+		return
+	}
+
+	txs := []*Transaction{}
+	for _, id := range s.committed {
+		tx := s.transactions[id]
+		if tx.Origin == cardID || tx.Destination == cardID {
+			txs = append(txs, tx)
+		}
+	}
+	// newest first
+	sort.SliceStable(txs, func(i, j int) bool { return txs[i].CreatedAt.After(txs[j].CreatedAt) })
+
+	start, stop := 0, len(txs)-1
+	var err error
+	if itemRange := r.Header.Get("Range"); len(itemRange) > 0 {
+		start, stop, err = parseRange(itemRange)
+		if err != nil {
+			writeError(w, http.StatusRequestedRangeNotSatisfiable, apiError{Code: "invalid_range", Message: err.Error()})
+			return
+		}
+	}
+	if stop >= len(txs) {
+		stop = len(txs) - 1
+	}
+
+	resp := []transactionResponse{}
+	for i := start; i <= stop && i < len(txs); i++ {
+		resp = append(resp, s.response(txs[i]))
+	}
+	w.Header().Set("Content-Range", fmt.Sprintf("items %d-%d/%d", start, stop, len(txs)))
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func parseRange(itemRange string) (int, int, error) {
+	var start, stop int
+	_, err := fmt.Sscanf(itemRange, "items=%d-%d", &start, &stop)
+	if err != nil || start < 0 || stop < start {
+		return 0, 0, fmt.Errorf("invalid range %s", itemRange)
+	}
+	return start, stop, nil
+}
@@ -0,0 +1,136 @@
+package uphold
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/brave-intl/bat-go/utils/altcurrency"
+	"github.com/brave-intl/bat-go/utils/httpsignature"
+	"github.com/brave-intl/bat-go/wallet"
+	"github.com/brave-intl/bat-go/wallet/provider/uphold/fake"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFakeWallet(t *testing.T, srv *fake.Server, balance decimal.Decimal) *Wallet {
+	publicKey, privateKey, err := httpsignature.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+
+	var info wallet.Info
+	info.Provider = "uphold"
+	info.ProviderID = srv.CreateCard("BAT", publicKey, balance)
+	{
+		tmp := altcurrency.BAT
+		info.AltCurrency = &tmp
+	}
+	w, err := New(info, privateKey, publicKey)
+	require.NoError(t, err)
+	return w
+}
+
+func TestFakeTransactions(t *testing.T) {
+	srv := fake.New()
+	defer srv.Close()
+	defer UseAPI(srv.URL, srv.Client())()
+	ctx := context.Background()
+
+	donorWallet := newFakeWallet(t, srv, decimal.NewFromFloat(100))
+
+	var info wallet.Info
+	info.Provider = "uphold"
+	{
+		tmp := altcurrency.BAT
+		info.AltCurrency = &tmp
+	}
+	publicKey, privateKey, err := httpsignature.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	destWallet := &Wallet{Info: info, PrivKey: privateKey, PubKey: publicKey}
+	require.NoError(t, destWallet.Register(ctx, "bat-go test transaction card"))
+
+	value := altcurrency.BAT.ToProbi(decimal.NewFromFloat(10))
+	tx, err := donorWallet.PrepareTransaction(altcurrency.BAT, value, destWallet.ProviderID, "bat-go:uphold.TestFakeTransactions")
+	require.NoError(t, err)
+
+	submitInfo, err := donorWallet.SubmitTransaction(ctx, tx, false)
+	require.NoError(t, err)
+	assert.Equal(t, "pending", submitInfo.Status)
+	assert.True(t, submitInfo.ValidUntil.After(time.Now()))
+
+	balance, err := destWallet.GetBalance(ctx, true)
+	require.NoError(t, err)
+	assert.True(t, balance.TotalProbi.Equals(decimal.Zero), "submit without confirm should not result in a balance")
+
+	_, err = donorWallet.GetTransaction(ctx, submitInfo.ID)
+	assert.True(t, wallet.IsNotFound(err), "unconfirmed transactions cannot be retrieved")
+
+	commitInfo, err := donorWallet.ConfirmTransaction(ctx, submitInfo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, submitInfo.ID, commitInfo.ID)
+	assert.Equal(t, destWallet.ProviderID, commitInfo.Destination)
+	assert.Equal(t, "bat-go:uphold.TestFakeTransactions", commitInfo.Note)
+
+	_, err = donorWallet.ConfirmTransaction(ctx, submitInfo.ID)
+	assert.True(t, wallet.AlreadyExists(err), "confirming twice should fail")
+
+	getInfo, err := donorWallet.GetTransaction(ctx, submitInfo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "completed", getInfo.Status)
+	assert.True(t, getInfo.Probi.Equals(value))
+
+	balance, err = destWallet.GetBalance(ctx, true)
+	require.NoError(t, err)
+	assert.True(t, balance.TotalProbi.Equals(value))
+
+	txInfo, err := destWallet.Transfer(ctx, altcurrency.BAT, value, donorWallet.ProviderID)
+	require.NoError(t, err)
+	assert.NotEmpty(t, txInfo.ID)
+	assert.True(t, srv.Balance(destWallet.ProviderID).Equals(decimal.Zero))
+	assert.True(t, srv.Balance(donorWallet.ProviderID).Equals(decimal.NewFromFloat(100)))
+
+	txs, err := donorWallet.ListTransactions(ctx, 10, time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, txs, 2)
+}
+
+func TestFakeErrors(t *testing.T) {
+	srv := fake.New()
+	defer srv.Close()
+	defer UseAPI(srv.URL, srv.Client())()
+	ctx := context.Background()
+
+	donorWallet := newFakeWallet(t, srv, decimal.NewFromFloat(1))
+	destWallet := newFakeWallet(t, srv, decimal.Zero)
+
+	_, err := donorWallet.Transfer(ctx, altcurrency.BAT, altcurrency.BAT.ToProbi(decimal.NewFromFloat(5)), destWallet.ProviderID)
+	assert.True(t, wallet.IsInsufficientBalance(err), "transfers larger than the balance should fail")
+
+	// sign with a key other than the one registered for the card
+	_, otherKey, err := httpsignature.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	donorWallet.PrivKey = otherKey
+	_, err = donorWallet.Transfer(ctx, altcurrency.BAT, altcurrency.BAT.ToProbi(decimal.NewFromFloat(1)), destWallet.ProviderID)
+	assert.True(t, wallet.IsInvalidSignature(err), "transfers signed by the wrong key should fail")
+
+	_, err = destWallet.GetTransaction(ctx, "00000000-0000-4000-0000-000000000000")
+	assert.True(t, wallet.IsNotFound(err))
+
+	address, err := destWallet.CreateCardAddress(ctx, "anonymous")
+	require.NoError(t, err)
+	assert.NotEmpty(t, address)
+}
+
+func TestFakeCanceledContext(t *testing.T) {
+	srv := fake.New()
+	defer srv.Close()
+	defer UseAPI(srv.URL, srv.Client())()
+
+	w := newFakeWallet(t, srv, decimal.NewFromFloat(1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := w.GetBalance(ctx, true)
+	assert.True(t, errors.Is(err, context.Canceled), "requests should be bound by the passed context")
+}
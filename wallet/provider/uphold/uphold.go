@@ -2,6 +2,7 @@ package uphold
 
 import (
 	"bytes"
+	"context"
 	"crypto"
 	"encoding/base64"
 	"encoding/hex"
@@ -92,7 +93,16 @@ func init() {
 	}
 }
 
-// TODO add context?
+// UseAPI points the package at an alternate Uphold compatible API, such as the one provided by
+// the uphold/fake package, using httpClient for requests. It returns a function restoring the previous API.
+func UseAPI(baseURL string, httpClient *http.Client) func() {
+	prevAPIBase, prevClient := upholdAPIBase, client
+	upholdAPIBase = baseURL
+	client = httpClient
+	return func() {
+		upholdAPIBase, client = prevAPIBase, prevClient
+	}
+}
 
 // New returns an uphold wallet constructed using the provided parameters
 // NOTE that it does not register a wallet with Uphold if it does not already exist
@@ -126,8 +136,8 @@ func FromWalletInfo(info wallet.Info) (*Wallet, error) {
 	return New(info, ed25519.PrivateKey{}, publicKey)
 }
 
-func newRequest(method, path string, body io.Reader) (*http.Request, error) {
-	req, err := http.NewRequest(method, upholdAPIBase+path, body)
+func newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, upholdAPIBase+path, body)
 	if err == nil {
 		req.Header.Add("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(accessToken+":X-OAuth-Basic")))
 	}
@@ -139,7 +149,7 @@ func submit(req *http.Request) ([]byte, *http.Response, error) {
 
 	dump, err := httputil.DumpRequestOut(req, true)
 	if err != nil {
-		panic(err)
+		return nil, nil, err
 	}
 	log.WithFields(log.Fields{
 		"path": "github.com/brave-intl/bat-go/wallet/provider/uphold",
@@ -193,14 +203,14 @@ type createCardRequest struct {
 }
 
 // sign registration for this wallet with Uphold with label
-func (w *Wallet) signRegistration(label string) (*http.Request, error) {
+func (w *Wallet) signRegistration(ctx context.Context, label string) (*http.Request, error) {
 	reqPayload := createCardRequest{Label: label, AltCurrency: w.Info.AltCurrency, PublicKey: w.PubKey.String()}
 	payload, err := json.Marshal(reqPayload)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := newRequest("POST", "/v0/me/cards", bytes.NewBuffer(payload))
+	req, err := newRequest(ctx, "POST", "/v0/me/cards", bytes.NewBuffer(payload))
 	if err != nil {
 		return nil, err
 	}
@@ -215,8 +225,8 @@ func (w *Wallet) signRegistration(label string) (*http.Request, error) {
 }
 
 // Register a wallet with Uphold with label
-func (w *Wallet) Register(label string) error {
-	req, err := w.signRegistration(label)
+func (w *Wallet) Register(ctx context.Context, label string) error {
+	req, err := w.signRegistration(ctx, label)
 	if err != nil {
 		return err
 	}
@@ -236,7 +246,7 @@ func (w *Wallet) Register(label string) error {
 }
 
 // SubmitRegistration from a b64 encoded signed string
-func (w *Wallet) SubmitRegistration(registrationB64 string) error {
+func (w *Wallet) SubmitRegistration(ctx context.Context, registrationB64 string) error {
 	b, err := base64.StdEncoding.DecodeString(registrationB64)
 	if err != nil {
 		return err
@@ -248,7 +258,7 @@ func (w *Wallet) SubmitRegistration(registrationB64 string) error {
 		return err
 	}
 
-	req, err := newRequest("POST", "/v0/me/cards", nil)
+	req, err := newRequest(ctx, "POST", "/v0/me/cards", nil)
 	if err != nil {
 		return err
 	}
@@ -274,7 +284,7 @@ func (w *Wallet) SubmitRegistration(registrationB64 string) error {
 
 // PrepareRegistration returns a b64 encoded serialized signed registration suitable for SubmitRegistration
 func (w *Wallet) PrepareRegistration(label string) (string, error) {
-	req, err := w.signRegistration(label)
+	req, err := w.signRegistration(context.Background(), label)
 	if err != nil {
 		return "", err
 	}
@@ -307,8 +317,8 @@ type CardDetails struct {
 }
 
 // GetCardDetails returns the details associated with the wallet's backing Uphold card
-func (w *Wallet) GetCardDetails() (*CardDetails, error) {
-	req, err := newRequest("GET", "/v0/me/cards/"+w.ProviderID, nil)
+func (w *Wallet) GetCardDetails(ctx context.Context) (*CardDetails, error) {
+	req, err := newRequest(ctx, "GET", "/v0/me/cards/"+w.ProviderID, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -343,14 +353,14 @@ type transactionRequest struct {
 	Message      string       `json:"message,omitempty"`
 }
 
-func (w *Wallet) signTransfer(altc altcurrency.AltCurrency, probi decimal.Decimal, destination string, message string) (*http.Request, error) {
+func (w *Wallet) signTransfer(ctx context.Context, altc altcurrency.AltCurrency, probi decimal.Decimal, destination string, message string) (*http.Request, error) {
 	transferReq := transactionRequest{Denomination: denomination{Amount: altc.FromProbi(probi), Currency: &altc}, Destination: destination, Message: message}
 	unsignedTransaction, err := json.Marshal(&transferReq)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := newRequest("POST", "/v0/me/cards/"+w.ProviderID+"/transactions?commit=true", bytes.NewBuffer(unsignedTransaction))
+	req, err := newRequest(ctx, "POST", "/v0/me/cards/"+w.ProviderID+"/transactions?commit=true", bytes.NewBuffer(unsignedTransaction))
 	if err != nil {
 		return nil, err
 	}
@@ -366,7 +376,7 @@ func (w *Wallet) signTransfer(altc altcurrency.AltCurrency, probi decimal.Decima
 
 // PrepareTransaction returns a b64 encoded serialized signed transaction suitable for SubmitTransaction
 func (w *Wallet) PrepareTransaction(altcurrency altcurrency.AltCurrency, probi decimal.Decimal, destination string, message string) (string, error) {
-	req, err := w.signTransfer(altcurrency, probi, destination, message)
+	req, err := w.signTransfer(context.Background(), altcurrency, probi, destination, message)
 	if err != nil {
 		return "", err
 	}
@@ -385,8 +395,8 @@ func (w *Wallet) PrepareTransaction(altcurrency altcurrency.AltCurrency, probi d
 }
 
 // Transfer moves funds out of the associated wallet and to the specific destination
-func (w *Wallet) Transfer(altcurrency altcurrency.AltCurrency, probi decimal.Decimal, destination string) (*wallet.TransactionInfo, error) {
-	req, err := w.signTransfer(altcurrency, probi, destination, "")
+func (w *Wallet) Transfer(ctx context.Context, altcurrency altcurrency.AltCurrency, probi decimal.Decimal, destination string) (*wallet.TransactionInfo, error) {
+	req, err := w.signTransfer(ctx, altcurrency, probi, destination, "")
 	if err != nil {
 		return nil, err
 	}
@@ -606,7 +616,7 @@ func (resp upholdTransactionResponse) ToTransactionInfo() *wallet.TransactionInf
 
 // SubmitTransaction submits the base64 encoded transaction for verification but does not move funds
 //   unless confirm is set to true.
-func (w *Wallet) SubmitTransaction(transactionB64 string, confirm bool) (*wallet.TransactionInfo, error) {
+func (w *Wallet) SubmitTransaction(ctx context.Context, transactionB64 string, confirm bool) (*wallet.TransactionInfo, error) {
 	_, err := w.VerifyTransaction(transactionB64)
 	if err != nil {
 		return nil, err
@@ -627,7 +637,7 @@ func (w *Wallet) SubmitTransaction(transactionB64 string, confirm bool) (*wallet
 		url = url + "?commit=true"
 	}
 
-	req, err := newRequest("POST", url, nil)
+	req, err := newRequest(ctx, "POST", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -652,8 +662,8 @@ func (w *Wallet) SubmitTransaction(transactionB64 string, confirm bool) (*wallet
 }
 
 // ConfirmTransaction confirms a previously submitted transaction, moving funds
-func (w *Wallet) ConfirmTransaction(id string) (*wallet.TransactionInfo, error) {
-	req, err := newRequest("POST", "/v0/me/cards/"+w.ProviderID+"/transactions/"+id+"/commit", nil)
+func (w *Wallet) ConfirmTransaction(ctx context.Context, id string) (*wallet.TransactionInfo, error) {
+	req, err := newRequest(ctx, "POST", "/v0/me/cards/"+w.ProviderID+"/transactions/"+id+"/commit", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -676,8 +686,8 @@ func (w *Wallet) ConfirmTransaction(id string) (*wallet.TransactionInfo, error)
 }
 
 // GetTransaction returns info about a previously confirmed transaction
-func (w *Wallet) GetTransaction(id string) (*wallet.TransactionInfo, error) {
-	req, err := newRequest("GET", "/v0/me/transactions/"+id, nil)
+func (w *Wallet) GetTransaction(ctx context.Context, id string) (*wallet.TransactionInfo, error) {
+	req, err := newRequest(ctx, "GET", "/v0/me/transactions/"+id, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -696,7 +706,7 @@ func (w *Wallet) GetTransaction(id string) (*wallet.TransactionInfo, error) {
 }
 
 // ListTransactions for this wallet, pagination not yet supported
-func (w *Wallet) ListTransactions(limit int, startDate time.Time) ([]wallet.TransactionInfo, error) {
+func (w *Wallet) ListTransactions(ctx context.Context, limit int, startDate time.Time) ([]wallet.TransactionInfo, error) {
 	var out []wallet.TransactionInfo
 	if limit > 0 {
 		out = make([]wallet.TransactionInfo, 0, limit)
@@ -704,7 +714,7 @@ func (w *Wallet) ListTransactions(limit int, startDate time.Time) ([]wallet.Tran
 	var totalTransactions int
 	toExit := false
 	for {
-		req, err := newRequest("GET", "/v0/me/cards/"+w.ProviderID+"/transactions", nil)
+		req, err := newRequest(ctx, "GET", "/v0/me/cards/"+w.ProviderID+"/transactions", nil)
 		if err != nil {
 			return nil, err
 		}
@@ -773,14 +783,14 @@ func (w *Wallet) ListTransactions(limit int, startDate time.Time) ([]wallet.Tran
 }
 
 // GetBalance returns the last known balance, if refresh is true then the current balance is fetched
-func (w *Wallet) GetBalance(refresh bool) (*wallet.Balance, error) {
+func (w *Wallet) GetBalance(ctx context.Context, refresh bool) (*wallet.Balance, error) {
 	if !refresh {
 		return w.LastBalance, nil
 	}
 
 	var balance wallet.Balance
 
-	details, err := w.GetCardDetails()
+	details, err := w.GetCardDetails(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -807,14 +817,14 @@ type createCardAddressResponse struct {
 }
 
 // CreateCardAddress on network, returning the address
-func (w *Wallet) CreateCardAddress(network string) (string, error) {
+func (w *Wallet) CreateCardAddress(ctx context.Context, network string) (string, error) {
 	reqPayload := createCardAddressRequest{Network: network}
 	payload, err := json.Marshal(reqPayload)
 	if err != nil {
 		return "", err
 	}
 
-	req, err := newRequest("POST", fmt.Sprintf("/v0/me/cards/%s/addresses", w.ProviderID), bytes.NewBuffer(payload))
+	req, err := newRequest(ctx, "POST", fmt.Sprintf("/v0/me/cards/%s/addresses", w.ProviderID), bytes.NewBuffer(payload))
 	if err != nil {
 		return "", err
 	}
@@ -1,6 +1,7 @@
 package uphold
 
 import (
+	"context"
 	"encoding/hex"
 	"errors"
 	"net/http"
@@ -37,7 +38,7 @@ func TestGetCardDetails(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	_, err = wallet.GetBalance(true)
+	_, err = wallet.GetBalance(context.Background(), true)
 	if err != nil {
 		t.Error(err)
 	}
@@ -62,7 +63,7 @@ func TestRegister(t *testing.T) {
 	}
 
 	destWallet := &Wallet{Info: info, PrivKey: privateKey, PubKey: publicKey}
-	err = destWallet.Register("bat-go test card")
+	err = destWallet.Register(context.Background(), "bat-go test card")
 	if err != nil {
 		t.Error(err)
 	}
@@ -171,7 +172,7 @@ func TestTransactions(t *testing.T) {
 	}
 
 	destWallet := &Wallet{Info: info, PrivKey: privateKey, PubKey: publicKey}
-	err = destWallet.Register("bat-go test transaction card")
+	err = destWallet.Register(context.Background(), "bat-go test transaction card")
 	if err != nil {
 		t.Error(err)
 	}
@@ -191,12 +192,12 @@ func TestTransactions(t *testing.T) {
 		t.Error(err)
 	}
 
-	submitInfo, err := donorWallet.SubmitTransaction(tx, false)
+	submitInfo, err := donorWallet.SubmitTransaction(context.Background(), tx, false)
 	if err != nil {
 		t.Error(err)
 	}
 
-	balance, err := destWallet.GetBalance(true)
+	balance, err := destWallet.GetBalance(context.Background(), true)
 	if err != nil {
 		t.Error(err)
 	}
@@ -206,7 +207,7 @@ func TestTransactions(t *testing.T) {
 	}
 
 	// Submitted but unconfirmed transactions cannot be retrieved via GetTransaction
-	_, err = donorWallet.GetTransaction(submitInfo.ID)
+	_, err = donorWallet.GetTransaction(context.Background(), submitInfo.ID)
 	if err == nil {
 		t.Error("Expected error retrieving unconfirmed transaction")
 	}
@@ -214,7 +215,7 @@ func TestTransactions(t *testing.T) {
 		t.Error("Expected \"missing\" transaction as error cause")
 	}
 
-	commitInfo, err := donorWallet.ConfirmTransaction(submitInfo.ID)
+	commitInfo, err := donorWallet.ConfirmTransaction(context.Background(), submitInfo.ID)
 	if err != nil {
 		t.Error(err)
 	}
@@ -231,7 +232,7 @@ func TestTransactions(t *testing.T) {
 		t.Error("Transaction probi mismatch!")
 	}
 
-	getInfo, err := donorWallet.GetTransaction(submitInfo.ID)
+	getInfo, err := donorWallet.GetTransaction(context.Background(), submitInfo.ID)
 	if err != nil {
 		t.Error(err)
 	}
@@ -248,7 +249,7 @@ func TestTransactions(t *testing.T) {
 		t.Error("Transaction probi mismatch!")
 	}
 
-	balance, err = destWallet.GetBalance(true)
+	balance, err = destWallet.GetBalance(context.Background(), true)
 	if err != nil {
 		t.Error(err)
 	}
@@ -257,12 +258,12 @@ func TestTransactions(t *testing.T) {
 		t.Error("Submit with confirm should result in a balance.")
 	}
 
-	txInfo, err := destWallet.Transfer(altcurrency.BAT, submitInfo.Probi, donorWallet.ProviderID)
+	txInfo, err := destWallet.Transfer(context.Background(), altcurrency.BAT, submitInfo.Probi, donorWallet.ProviderID)
 	if err != nil {
 		t.Error(err)
 	}
 
-	balance, err = destWallet.GetBalance(true)
+	balance, err = destWallet.GetBalance(context.Background(), true)
 	if err != nil {
 		t.Error(err)
 	}
@@ -295,7 +296,7 @@ func TestFingerprintCheck(t *testing.T) {
 
 	w := requireDonorWallet(t)
 
-	req, err := w.signRegistration("randomlabel")
+	req, err := w.signRegistration(context.Background(), "randomlabel")
 	if err != nil {
 		t.Error(err)
 	}
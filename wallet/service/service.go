@@ -2,13 +2,12 @@ package service
 
 import (
 	"context"
-	"errors"
+	"fmt"
 
 	"github.com/brave-intl/bat-go/utils/clients/ledger"
 	errorutils "github.com/brave-intl/bat-go/utils/errors"
 	"github.com/brave-intl/bat-go/wallet"
 	"github.com/brave-intl/bat-go/wallet/provider"
-	"github.com/brave-intl/bat-go/wallet/provider/uphold"
 	uuid "github.com/satori/go.uuid"
 )
 
@@ -85,14 +84,14 @@ func (service *Service) SubmitAnonCardTransaction(ctx context.Context, walletID
 	if err != nil {
 		return nil, errorutils.Wrap(err, "error getting wallet")
 	}
+	if !provider.Supports(walletInfo.Provider, provider.AnonCardVerify) {
+		return nil, fmt.Errorf("wallet provider %s does not support anonymous card transactions", walletInfo.Provider)
+	}
 	providerWallet, err := provider.GetWallet(*walletInfo)
 	if err != nil {
 		return nil, err
 	}
-	anonCard, ok := providerWallet.(*uphold.Wallet)
-	if !ok {
-		return nil, errors.New("Only uphold wallets are supported")
-	}
+	anonCard := providerWallet.(wallet.AnonCardVerifier)
 
 	// FIXME needs to require the idempotency key
 	_, err = anonCard.VerifyAnonCardTransaction(transaction)
@@ -101,5 +100,5 @@ func (service *Service) SubmitAnonCardTransaction(ctx context.Context, walletID
 	}
 
 	// Submit and confirm since we are requiring the idempotency key
-	return anonCard.SubmitTransaction(transaction, true)
+	return anonCard.SubmitTransaction(ctx, transaction, true)
 }
@@ -2,6 +2,7 @@
 package wallet
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -13,7 +14,7 @@ import (
 // the last known balance and provider
 type Info struct {
 	ID            string                   `json:"paymentId" valid:"uuidv4,optional" db:"id"`
-	Provider      string                   `json:"provider" valid:"in(uphold)" db:"provider"`
+	Provider      string                   `json:"provider" valid:"walletprovider" db:"provider"`
 	ProviderID    string                   `json:"providerId" valid:"uuidv4" db:"provider_id"`
 	AltCurrency   *altcurrency.AltCurrency `json:"altcurrency" valid:"-"`
 	PublicKey     string                   `json:"publicKey,omitempty" valid:"hexadecimal,optional" db:"public_key"`
@@ -52,6 +53,21 @@ func (a ByTime) Len() int           { return len(a) }
 func (a ByTime) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a ByTime) Less(i, j int) bool { return a[i].Time.Before(a[j].Time) }
 
+// Transaction statuses after which a transaction will no longer change
+const (
+	// TransactionCompleted indicates funds were moved
+	TransactionCompleted = "completed"
+	// TransactionFailed indicates the transaction failed and funds were not moved
+	TransactionFailed = "failed"
+	// TransactionCancelled indicates the transaction was cancelled and funds were not moved
+	TransactionCancelled = "cancelled"
+)
+
+// IsTerminalStatus returns true if the transaction status will no longer change
+func IsTerminalStatus(status string) bool {
+	return status == TransactionCompleted || status == TransactionFailed || status == TransactionCancelled
+}
+
 // Balance holds balance information for a wallet
 type Balance struct {
 	TotalProbi       decimal.Decimal
@@ -61,23 +77,48 @@ type Balance struct {
 }
 
 // Wallet is an interface for a cryptocurrency wallet
+// Methods which communicate with the wallet provider accept a context which bounds the request
 type Wallet interface {
 	GetWalletInfo() Info
 	// Transfer moves funds out of the associated wallet and to the specific destination
-	Transfer(altcurrency altcurrency.AltCurrency, probi decimal.Decimal, destination string) (*TransactionInfo, error)
+	Transfer(ctx context.Context, altcurrency altcurrency.AltCurrency, probi decimal.Decimal, destination string) (*TransactionInfo, error)
 	// VerifyTransaction verifies that the base64 encoded transaction is valid
 	// NOTE VerifyTransaction must guard against transactions that seek to exploit parser differences
 	// such as including additional fields that are not understood by local implementation but may
 	// be understood by the upstream wallet provider.
 	VerifyTransaction(transactionB64 string) (*TransactionInfo, error)
 	// SubmitTransaction submits the base64 encoded transaction for verification but does not move funds
-	SubmitTransaction(transactionB64 string, confirm bool) (*TransactionInfo, error)
+	SubmitTransaction(ctx context.Context, transactionB64 string, confirm bool) (*TransactionInfo, error)
 	// ConfirmTransaction confirms a previously submitted transaction, moving funds
-	ConfirmTransaction(id string) (*TransactionInfo, error)
+	ConfirmTransaction(ctx context.Context, id string) (*TransactionInfo, error)
 	// GetBalance returns the last known balance, if refresh is true then the current balance is fetched
-	GetBalance(refresh bool) (*Balance, error)
+	GetBalance(ctx context.Context, refresh bool) (*Balance, error)
 	// ListTransactions for this wallet, limit number of transactions returned
-	ListTransactions(limit int, startDate time.Time) ([]TransactionInfo, error)
+	ListTransactions(ctx context.Context, limit int, startDate time.Time) ([]TransactionInfo, error)
+}
+
+// AnonCardVerifier is implemented by wallets which can verify transactions signed by an anonymous card
+type AnonCardVerifier interface {
+	Wallet
+	// VerifyAnonCardTransaction verifies that the base64 encoded transaction is valid and signed by
+	// the card associated with this wallet
+	VerifyAnonCardTransaction(transactionB64 string) (*TransactionInfo, error)
+}
+
+// TransactionPreparer is implemented by wallets which support the prepare / submit / confirm flow
+type TransactionPreparer interface {
+	Wallet
+	// PrepareTransaction returns a base64 encoded signed transaction which can later be submitted
+	PrepareTransaction(altcurrency altcurrency.AltCurrency, probi decimal.Decimal, destination string, message string) (string, error)
+	// GetTransaction returns a previously confirmed transaction by id
+	GetTransaction(ctx context.Context, id string) (*TransactionInfo, error)
+}
+
+// CardAddressCreator is implemented by wallets which can create deposit addresses on other networks
+type CardAddressCreator interface {
+	Wallet
+	// CreateCardAddress creates a new address on the passed network for the wallet
+	CreateCardAddress(ctx context.Context, network string) (string, error)
 }
 
 // IsNotFound is a helper method for determining if an error indicates a missing resource
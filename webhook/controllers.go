@@ -0,0 +1,55 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/brave-intl/bat-go/middleware"
+	"github.com/brave-intl/bat-go/utils/handlers"
+	"github.com/brave-intl/bat-go/utils/requestutils"
+	"github.com/go-chi/chi"
+)
+
+// Router for webhook endpoints
+func Router(service *Service) chi.Router {
+	r := chi.NewRouter()
+	r.Method("POST", "/uphold", middleware.HTTPSignedOnly(service)(middleware.InstrumentHandler("UpholdWebhook", UpholdWebhook(service))))
+	return r
+}
+
+// UpholdWebhook is the handler for transaction notifications sent by uphold
+func UpholdWebhook(service *Service) handlers.AppHandler {
+	return handlers.AppHandler(func(w http.ResponseWriter, r *http.Request) *handlers.AppError {
+		payload, err := requestutils.Read(r.Body)
+		if err != nil {
+			return handlers.WrapError(err, "Error reading body", http.StatusBadRequest)
+		}
+
+		var event Event
+		err = json.Unmarshal(payload, &event)
+		if err != nil {
+			return handlers.WrapError(err, "Error in request body", http.StatusBadRequest)
+		}
+
+		_, err = govalidator.ValidateStruct(event)
+		if err != nil {
+			return handlers.WrapValidationError(err)
+		}
+
+		if event.Data.Transaction != nil {
+			_, err = govalidator.ValidateStruct(event.Data.Transaction)
+			if err != nil {
+				return handlers.WrapValidationError(err)
+			}
+		}
+
+		err = service.HandleEvent(r.Context(), &event, payload)
+		if err != nil {
+			return handlers.WrapError(err, "Error handling webhook event", http.StatusInternalServerError)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		return nil
+	})
+}
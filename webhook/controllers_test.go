@@ -0,0 +1,129 @@
+package webhook
+
+import (
+	"context"
+	"crypto"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/brave-intl/bat-go/utils/httpsignature"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryDatastore struct {
+	sync.Mutex
+	processed map[string]bool
+	matched   map[string]bool
+}
+
+func (m *memoryDatastore) InsertEvent(event *Event, payload []byte) (bool, error) {
+	m.Lock()
+	defer m.Unlock()
+	return !m.processed[event.ID], nil
+}
+
+func (m *memoryDatastore) MarkEventProcessed(id string, matched bool) error {
+	m.Lock()
+	defer m.Unlock()
+	m.processed[id] = true
+	m.matched[id] = matched
+	return nil
+}
+
+type recordingUpdater struct {
+	known   map[string]bool
+	updates []string
+}
+
+func (u *recordingUpdater) UpdateTransactionStatus(ctx context.Context, transactionID string, status string) (bool, error) {
+	if !u.known[transactionID] {
+		return false, nil
+	}
+	u.updates = append(u.updates, transactionID+":"+status)
+	return true, nil
+}
+
+func TestParseKeys(t *testing.T) {
+	keys, err := parseKeys("primary:424073b208e97af51cab7a389bcfe6942a3b7c7520fe9dab84f311f7846f5fcf,")
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+
+	_, err = parseKeys("primary")
+	assert.Error(t, err)
+
+	_, err = parseKeys("primary:4240")
+	assert.Error(t, err, "keys must be ed25519 public keys")
+}
+
+func TestReplayCallbacks(t *testing.T) {
+	publicKey, privateKey, err := httpsignature.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	_, wrongKey, err := httpsignature.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+
+	datastore := &memoryDatastore{processed: map[string]bool{}, matched: map[string]bool{}}
+	updater := &recordingUpdater{known: map[string]bool{"4b9f1c8e-2f5a-4a5d-8a61-0c7b3d4e5f60": true}}
+	service := &Service{
+		datastore: datastore,
+		keys:      map[string]httpsignature.Verifier{"uphold": publicKey},
+		updaters:  []TransactionUpdater{updater},
+	}
+
+	r := chi.NewRouter()
+	r.Mount("/v1/webhooks", Router(service))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	f, err := os.Open("testdata/callbacks.json")
+	require.NoError(t, err)
+	defer func() {
+		_ = f.Close()
+	}()
+	callbacks, err := ReadCallbacks(f)
+	require.NoError(t, err)
+	require.Len(t, callbacks, 5)
+
+	statuses, err := Replay(context.Background(), srv.Client(), srv.URL+"/v1/webhooks", callbacks[:1], "uphold", wrongKey)
+	require.NoError(t, err)
+	assert.Equal(t, []int{http.StatusForbidden}, statuses, "callbacks signed by an unknown key should be rejected")
+
+	statuses, err = Replay(context.Background(), srv.Client(), srv.URL+"/v1/webhooks", callbacks, "unknown", privateKey)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, statuses[0], "callbacks signed with an unknown keyID should be rejected")
+	assert.Empty(t, updater.updates)
+
+	statuses, err = Replay(context.Background(), srv.Client(), srv.URL+"/v1/webhooks", callbacks, "uphold", privateKey)
+	require.NoError(t, err)
+	assert.Equal(t, []int{200, 200, 200, 200, 200}, statuses)
+
+	// the duplicate delivery is only applied once
+	assert.Equal(t, []string{
+		"4b9f1c8e-2f5a-4a5d-8a61-0c7b3d4e5f60:processing",
+		"4b9f1c8e-2f5a-4a5d-8a61-0c7b3d4e5f60:completed",
+	}, updater.updates)
+
+	assert.True(t, datastore.matched["2d4e6f80-1a3b-4c5d-8e7f-9a0b1c2d3e4f"])
+	assert.False(t, datastore.matched["5f6a7b8c-9d0e-4f1a-8b2c-3d4e5f6a7b8c"], "unknown transactions should be recorded as unmatched")
+	assert.True(t, datastore.processed["9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d"], "unknown event types should be acknowledged")
+
+	// a body which does not match the signed digest is rejected
+	req, err := http.NewRequest("POST", srv.URL+"/v1/webhooks/uphold", strings.NewReader(string(callbacks[0].Body)))
+	require.NoError(t, err)
+	var s httpsignature.Signature
+	s.Algorithm = httpsignature.ED25519
+	s.KeyID = "uphold"
+	s.Headers = []string{"digest", "(request-target)"}
+	require.NoError(t, s.Sign(privateKey, crypto.Hash(0), req))
+	req.Body = http.NoBody
+	req.ContentLength = 0
+	resp, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
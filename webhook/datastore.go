@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"github.com/brave-intl/bat-go/datastore/grantserver"
+
+	// needed for magic migration
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+)
+
+// Datastore abstracts over the underlying datastore
+type Datastore interface {
+	// InsertEvent records a received event, returning false if it has already been processed
+	InsertEvent(event *Event, payload []byte) (bool, error)
+	// MarkEventProcessed once all transaction updates have been applied
+	MarkEventProcessed(id string, matched bool) error
+}
+
+// Postgres is a Datastore wrapper around a postgres database
+type Postgres struct {
+	grantserver.Postgres
+}
+
+// NewPostgres creates a new Postgres Datastore
+func NewPostgres(databaseURL string, performMigration bool, dbStatsPrefix ...string) (*Postgres, error) {
+	pg, err := grantserver.NewPostgres(databaseURL, performMigration, dbStatsPrefix...)
+	if pg != nil {
+		return &Postgres{*pg}, err
+	}
+	return nil, err
+}
+
+// InsertEvent records a received event, returning false if it has already been processed
+func (pg *Postgres) InsertEvent(event *Event, payload []byte) (bool, error) {
+	statement := `
+	insert into webhook_events (id, type, transaction_id, payload)
+	values ($1, $2, $3, $4)
+	on conflict (id) do nothing`
+	_, err := pg.DB.Exec(statement, event.ID, event.Type, event.TransactionID(), payload)
+	if err != nil {
+		return false, err
+	}
+
+	var pending bool
+	err = pg.DB.Get(&pending, `select processed_at is null from webhook_events where id = $1`, event.ID)
+	if err != nil {
+		return false, err
+	}
+
+	return pending, nil
+}
+
+// MarkEventProcessed once all transaction updates have been applied
+func (pg *Postgres) MarkEventProcessed(id string, matched bool) error {
+	statement := `
+	update webhook_events
+	set processed_at = current_timestamp, matched = $2
+	where id = $1`
+	_, err := pg.DB.Exec(statement, id, matched)
+	return err
+}
@@ -0,0 +1,43 @@
+// Package webhook receives signed transaction status notifications from the wallet provider
+package webhook
+
+import (
+	"context"
+)
+
+const (
+	// TransactionStatusChanged is sent by the wallet provider when a transaction changes status
+	TransactionStatusChanged = "transaction:status:changed"
+)
+
+// Transaction included in an event
+type Transaction struct {
+	ID     string `json:"id" valid:"required"`
+	Status string `json:"status" valid:"required"`
+}
+
+// EventData holds the resources an event refers to
+type EventData struct {
+	Transaction *Transaction `json:"transaction,omitempty" valid:"optional"`
+}
+
+// Event is a notification sent by the wallet provider
+type Event struct {
+	ID   string    `json:"id" valid:"required"`
+	Type string    `json:"type" valid:"required"`
+	Data EventData `json:"data" valid:"-"`
+}
+
+// TransactionID returns the id of the transaction the event refers to, if any
+func (event *Event) TransactionID() *string {
+	if event.Data.Transaction == nil {
+		return nil
+	}
+	return &event.Data.Transaction.ID
+}
+
+// TransactionUpdater is implemented by services which track the status of provider transactions
+type TransactionUpdater interface {
+	// UpdateTransactionStatus of the transaction with the passed id, returning true if it was known
+	UpdateTransactionStatus(ctx context.Context, transactionID string, status string) (bool, error)
+}
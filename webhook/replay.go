@@ -0,0 +1,84 @@
+package webhook
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/brave-intl/bat-go/utils/httpsignature"
+)
+
+// Callback is a recorded webhook request which can be replayed
+type Callback struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body"`
+}
+
+// ReadCallbacks from newline delimited json
+func ReadCallbacks(r io.Reader) ([]Callback, error) {
+	callbacks := []Callback{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+		var callback Callback
+		err := json.Unmarshal([]byte(line), &callback)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing callback %d: %w", len(callbacks)+1, err)
+		}
+		callbacks = append(callbacks, callback)
+	}
+	return callbacks, scanner.Err()
+}
+
+// Replay the recorded callbacks against baseURL in order, returning the response status codes
+// If signer is not nil each callback is signed again using keyID, otherwise recorded signatures are sent as is
+func Replay(ctx context.Context, client *http.Client, baseURL string, callbacks []Callback, keyID string, signer crypto.Signer) ([]int, error) {
+	statuses := make([]int, 0, len(callbacks))
+	for _, callback := range callbacks {
+		method := callback.Method
+		if len(method) == 0 {
+			method = "POST"
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(baseURL, "/")+callback.Path, bytes.NewReader(callback.Body))
+		if err != nil {
+			return statuses, err
+		}
+		for k, v := range callback.Headers {
+			req.Header.Set(k, v)
+		}
+		req.Header.Set("content-type", "application/json")
+
+		if signer != nil {
+			req.Header.Del("Digest")
+			var s httpsignature.Signature
+			s.Algorithm = httpsignature.ED25519
+			s.KeyID = keyID
+			s.Headers = []string{"digest", "(request-target)"}
+			err = s.Sign(signer, crypto.Hash(0), req)
+			if err != nil {
+				return statuses, err
+			}
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return statuses, err
+		}
+		_ = resp.Body.Close()
+		statuses = append(statuses, resp.StatusCode)
+	}
+	return statuses, nil
+}
@@ -0,0 +1,125 @@
+package webhook
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/brave-intl/bat-go/utils/httpsignature"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ed25519"
+)
+
+var (
+	// countWebhookEvents counts the received webhook events broken down by how they were handled
+	countWebhookEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_events_total",
+			Help: "count of webhook events received ( since last start ) broken down by result",
+		},
+		[]string{"result"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(countWebhookEvents)
+}
+
+// Service contains datastore and the services notified of transaction updates
+type Service struct {
+	datastore Datastore
+	keys      map[string]httpsignature.Verifier
+	updaters  []TransactionUpdater
+}
+
+// parseKeys from a comma separated list of keyID:hexPublicKey pairs
+func parseKeys(keys string) (map[string]httpsignature.Verifier, error) {
+	out := map[string]httpsignature.Verifier{}
+	for _, pair := range strings.Split(keys, ",") {
+		if len(pair) == 0 {
+			continue
+		}
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("webhook key %q must be of the form keyID:publicKey", pair)
+		}
+		publicKey, err := hex.DecodeString(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("webhook key %q is not valid hex: %w", parts[0], err)
+		}
+		if len(publicKey) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("webhook key %q is not an ed25519 public key", parts[0])
+		}
+		out[parts[0]] = httpsignature.Ed25519PubKey(publicKey)
+	}
+	return out, nil
+}
+
+// InitService creates a service using the passed datastore, the signing keys of the wallet provider
+// are read from WEBHOOK_PUBLIC_KEYS
+func InitService(datastore Datastore, updaters ...TransactionUpdater) (*Service, error) {
+	keys, err := parseKeys(os.Getenv("WEBHOOK_PUBLIC_KEYS"))
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("WEBHOOK_PUBLIC_KEYS must be passed")
+	}
+
+	return &Service{
+		datastore: datastore,
+		keys:      keys,
+		updaters:  updaters,
+	}, nil
+}
+
+// LookupPublicKey based on the HTTP signing keyID, which is one of the configured provider keys
+func (s *Service) LookupPublicKey(ctx context.Context, keyID string) (*httpsignature.Verifier, error) {
+	verifier, ok := s.keys[keyID]
+	if !ok {
+		return nil, nil
+	}
+	return &verifier, nil
+}
+
+// HandleEvent applies the transaction update in the event, events which have already been processed are ignored
+func (s *Service) HandleEvent(ctx context.Context, event *Event, payload []byte) error {
+	pending, err := s.datastore.InsertEvent(event, payload)
+	if err != nil {
+		return fmt.Errorf("error recording webhook event: %w", err)
+	}
+	if !pending {
+		countWebhookEvents.With(prometheus.Labels{"result": "duplicate"}).Inc()
+		return nil
+	}
+
+	matched := false
+	if event.Type == TransactionStatusChanged && event.Data.Transaction != nil {
+		for _, updater := range s.updaters {
+			found, err := updater.UpdateTransactionStatus(ctx, event.Data.Transaction.ID, event.Data.Transaction.Status)
+			if err != nil {
+				// the event is left unprocessed so a redelivery is applied
+				return fmt.Errorf("error updating transaction status: %w", err)
+			}
+			matched = matched || found
+		}
+	}
+
+	err = s.datastore.MarkEventProcessed(event.ID, matched)
+	if err != nil {
+		return fmt.Errorf("error marking webhook event processed: %w", err)
+	}
+
+	result := "processed"
+	if event.Type != TransactionStatusChanged {
+		result = "ignored"
+	} else if !matched {
+		result = "unmatched"
+	}
+	countWebhookEvents.With(prometheus.Labels{"result": result}).Inc()
+
+	return nil
+}